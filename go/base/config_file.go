@@ -0,0 +1,469 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-ini/ini"
+	"gopkg.in/yaml.v3"
+)
+
+const configFileProfilesKey = "profiles"
+
+var (
+	configFileEnvVariableRegexp = regexp.MustCompile(`[$][{]([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?[}]`)
+
+	// configFileForbiddenKeys are flags that make no sense in a config file
+	configFileForbiddenKeys = map[string]bool{
+		"conf":         true,
+		"conf-profile": true,
+		"check-flag":   true,
+		"help":         true,
+		"version":      true,
+	}
+)
+
+// configFileSetting is a single flag value read from a config file
+type configFileSetting struct {
+	key   string
+	value string
+	items []string
+	line  int
+}
+
+// position describes where the setting is found in given config file, for the sake of error messages.
+// INI files have no line information.
+func (this configFileSetting) position(fileName string) string {
+	if this.line > 0 {
+		return fmt.Sprintf("%s:%d", fileName, this.line)
+	}
+	return fmt.Sprintf("%s: [osc]", fileName)
+}
+
+// StringListFlag is a flag which may be given multiple times, collecting all of its values.
+// In a YAML config file, such a flag is given a list, each item of which is a value.
+type StringListFlag []string
+
+func (this *StringListFlag) String() string {
+	if this == nil {
+		return ""
+	}
+	return strings.Join(*this, "; ")
+}
+
+func (this *StringListFlag) Set(value string) error {
+	*this = append(*this, value)
+	return nil
+}
+
+// IsYAMLConfigFile returns true when given config file is expected to be in YAML format,
+// as opposed to the classic INI (my.cnf-like) format
+func IsYAMLConfigFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// interpolateConfigFileValue expands ${VAR} and ${VAR:-default} references to environment variables
+func interpolateConfigFileValue(value string) (string, error) {
+	var err error
+	result := configFileEnvVariableRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		submatch := configFileEnvVariableRegexp.FindStringSubmatch(match)
+		if envValue, ok := os.LookupEnv(submatch[1]); ok {
+			return envValue
+		}
+		if strings.Contains(match, ":-") {
+			return submatch[2]
+		}
+		if err == nil {
+			err = fmt.Errorf("environment variable %s is not set", submatch[1])
+		}
+		return match
+	})
+	return result, err
+}
+
+// readConfigFileSettings reads flag settings off a YAML mapping node. Lists are
+// converted into comma separated values, which is what list-like flags expect, and
+// kept as items for flags which are given multiple times instead.
+func readConfigFileSettings(fileName string, mapping *yaml.Node) (settings []configFileSetting, err error) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+		setting := configFileSetting{key: keyNode.Value, line: keyNode.Line}
+		switch valueNode.Kind {
+		case yaml.ScalarNode:
+			setting.value = valueNode.Value
+		case yaml.SequenceNode:
+			values := []string{}
+			for _, itemNode := range valueNode.Content {
+				if itemNode.Kind != yaml.ScalarNode {
+					return settings, fmt.Errorf("%s:%d: %s: expecting a list of scalar values", fileName, itemNode.Line, setting.key)
+				}
+				item, err := interpolateConfigFileValue(itemNode.Value)
+				if err != nil {
+					return settings, fmt.Errorf("%s:%d: %s: %+v", fileName, itemNode.Line, setting.key, err)
+				}
+				values = append(values, itemNode.Value)
+				setting.items = append(setting.items, item)
+			}
+			setting.value = strings.Join(values, ",")
+		default:
+			return settings, fmt.Errorf("%s:%d: %s: expecting a scalar value or a list of scalar values", fileName, valueNode.Line, setting.key)
+		}
+		if setting.value, err = interpolateConfigFileValue(setting.value); err != nil {
+			return settings, fmt.Errorf("%s:%d: %s: %+v", fileName, setting.line, setting.key, err)
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// parseConfigFile parses YAML config file content into flag settings. Top level keys are
+// flag names. Settings of the given profile, found under the "profiles" key, are applied
+// on top of the top level settings.
+func parseConfigFile(fileName string, content []byte, profile string) (settings []configFileSetting, err error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return settings, fmt.Errorf("%s: %+v", fileName, err)
+	}
+	if len(document.Content) == 0 {
+		if profile != "" {
+			return settings, fmt.Errorf("%s: profile %q not found", fileName, profile)
+		}
+		return settings, nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return settings, fmt.Errorf("%s:%d: expecting a mapping of flag names to values", fileName, root.Line)
+	}
+
+	var profiles *yaml.Node
+	topLevel := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == configFileProfilesKey {
+			profiles = root.Content[i+1]
+			if profiles.Kind != yaml.MappingNode {
+				return settings, fmt.Errorf("%s:%d: %s: expecting a mapping of profile names to settings", fileName, profiles.Line, configFileProfilesKey)
+			}
+			continue
+		}
+		topLevel.Content = append(topLevel.Content, root.Content[i], root.Content[i+1])
+	}
+	if settings, err = readConfigFileSettings(fileName, topLevel); err != nil {
+		return settings, err
+	}
+	if profile == "" {
+		return settings, nil
+	}
+	if profiles != nil {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			if profiles.Content[i].Value != profile {
+				continue
+			}
+			profileNode := profiles.Content[i+1]
+			if profileNode.Kind != yaml.MappingNode {
+				return settings, fmt.Errorf("%s:%d: %s: expecting a mapping of flag names to values", fileName, profileNode.Line, profile)
+			}
+			profileSettings, err := readConfigFileSettings(fileName, profileNode)
+			if err != nil {
+				return settings, err
+			}
+			return append(settings, profileSettings...), nil
+		}
+	}
+	return settings, fmt.Errorf("%s: profile %q not found", fileName, profile)
+}
+
+// ApplyConfigFile reads a config file and sets the flags in given flag set accordingly.
+// In a YAML file any flag may be set by its name; in an INI file, by its name with
+// underscores in the [osc] section. Precedence, highest first: flags given on the
+// command line, the selected profile, top level settings, flag defaults. Unknown keys
+// fail a YAML file, but are skipped in an INI file's [osc] section, which pt-online-schema-change
+// and other tools may share: their keys are returned.
+func ApplyConfigFile(flagSet *flag.FlagSet, fileName string, profile string) (skippedKeys []string, err error) {
+	settings, err := readConfigFile(fileName, profile)
+	if err != nil {
+		return skippedKeys, err
+	}
+	return applyConfigFileSettings(flagSet, fileName, settings)
+}
+
+// ApplyConfigFile applies the context's config file to the flags in given flag set, as
+// ApplyConfigFile does, warning of skipped keys, and remembers the applied settings for later reloads.
+func (this *MigrationContext) ApplyConfigFile(flagSet *flag.FlagSet) error {
+	settings, err := readConfigFile(this.ConfigFile, this.ConfigFileProfile)
+	if err != nil {
+		return err
+	}
+	skippedKeys, err := applyConfigFileSettings(flagSet, this.ConfigFile, settings)
+	if err != nil {
+		return err
+	}
+	for _, key := range skippedKeys {
+		this.Log.Warningf("%s: [osc] %s: unknown flag; skipping", this.ConfigFile, key)
+	}
+	this.setConfigFileValues(settings)
+	return nil
+}
+
+func applyConfigFileSettings(flagSet *flag.FlagSet, fileName string, settings []configFileSetting) (skippedKeys []string, err error) {
+	explicitFlags := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		explicitFlags[f.Name] = true
+	})
+	// profile settings come last and therefore override top level settings
+	for _, setting := range settings {
+		if configFileForbiddenKeys[setting.key] {
+			return skippedKeys, fmt.Errorf("%s: %s: cannot be set in a config file", setting.position(fileName), setting.key)
+		}
+		if flagSet.Lookup(setting.key) == nil {
+			if setting.line == 0 {
+				// An INI file (which has no line information) shares its [osc] section with other tools
+				skippedKeys = append(skippedKeys, setting.key)
+				continue
+			}
+			return skippedKeys, fmt.Errorf("%s: %s: unknown flag", setting.position(fileName), setting.key)
+		}
+		if explicitFlags[setting.key] {
+			continue
+		}
+		if _, isStringList := flagSet.Lookup(setting.key).Value.(*StringListFlag); isStringList && setting.items != nil {
+			for _, item := range setting.items {
+				if err := flagSet.Set(setting.key, item); err != nil {
+					return skippedKeys, fmt.Errorf("%s: %s: invalid value %q: %+v", setting.position(fileName), setting.key, item, err)
+				}
+			}
+			continue
+		}
+		if err := flagSet.Set(setting.key, setting.value); err != nil {
+			return skippedKeys, fmt.Errorf("%s: %s: invalid value %q: %+v", setting.position(fileName), setting.key, setting.value, err)
+		}
+	}
+	return skippedKeys, nil
+}
+
+// setConfigFileValues remembers given settings as the config file's last applied values
+func (this *MigrationContext) setConfigFileValues(settings []configFileSetting) {
+	values := make(map[string]string)
+	for _, setting := range settings {
+		values[setting.key] = setting.value
+	}
+	this.configMutex.Lock()
+	defer this.configMutex.Unlock()
+	this.configFileValues = values
+}
+
+// configFileValueChanged returns true when given config file value differs from the one last
+// applied, or when it was not set in the file before
+func (this *MigrationContext) configFileValueChanged(key string, value string) bool {
+	this.configMutex.Lock()
+	defer this.configMutex.Unlock()
+	lastValue, ok := this.configFileValues[key]
+	return !ok || lastValue != value
+}
+
+// ConfigChange is a setting changed by reloading the config file
+type ConfigChange struct {
+	Key      string
+	OldValue string
+	NewValue string
+}
+
+func (this ConfigChange) String() string {
+	return fmt.Sprintf("%s: %q => %q", this.Key, this.OldValue, this.NewValue)
+}
+
+// dynamicConfigSetting is a setting which may change while the migration runs
+type dynamicConfigSetting struct {
+	key   string
+	get   func(*MigrationContext) string
+	apply func(*MigrationContext, string) error
+}
+
+// dynamicConfigSettings are the settings applied by ReloadConfigFile, in order
+var dynamicConfigSettings = []dynamicConfigSetting{
+	{
+		key: "chunk-size",
+		get: func(this *MigrationContext) string { return strconv.FormatInt(atomic.LoadInt64(&this.ChunkSize), 10) },
+		apply: func(this *MigrationContext, value string) error {
+			chunkSize, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				this.SetChunkSize(chunkSize)
+			}
+			return err
+		},
+	},
+	{
+		key: "dml-batch-size",
+		get: func(this *MigrationContext) string {
+			return strconv.FormatInt(atomic.LoadInt64(&this.DMLBatchSize), 10)
+		},
+		apply: func(this *MigrationContext, value string) error {
+			dmlBatchSize, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				this.SetDMLBatchSize(dmlBatchSize)
+			}
+			return err
+		},
+	},
+	{
+		key: "nice-ratio",
+		get: func(this *MigrationContext) string { return strconv.FormatFloat(this.GetNiceRatio(), 'f', -1, 64) },
+		apply: func(this *MigrationContext, value string) error {
+			niceRatio, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				this.SetNiceRatio(niceRatio)
+			}
+			return err
+		},
+	},
+	{
+		key: "max-load",
+		get: func(this *MigrationContext) string {
+			maxLoad := this.GetMaxLoad()
+			return maxLoad.String()
+		},
+		apply: func(this *MigrationContext, value string) error { return this.ReadMaxLoad(value) },
+	},
+	{
+		key: "critical-load",
+		get: func(this *MigrationContext) string {
+			criticalLoad := this.GetCriticalLoad()
+			return criticalLoad.String()
+		},
+		apply: func(this *MigrationContext, value string) error { return this.ReadCriticalLoad(value) },
+	},
+	{
+		key: "max-lag-millis",
+		get: func(this *MigrationContext) string {
+			return strconv.FormatInt(atomic.LoadInt64(&this.MaxLagMillisecondsThrottleThreshold), 10)
+		},
+		apply: func(this *MigrationContext, value string) error {
+			maxLagMillis, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				this.SetMaxLagMillisecondsThrottleThreshold(maxLagMillis)
+			}
+			return err
+		},
+	},
+	{
+		key:   "throttle-query",
+		get:   func(this *MigrationContext) string { return this.GetThrottleQuery() },
+		apply: func(this *MigrationContext, value string) error { this.SetThrottleQuery(value); return nil },
+	},
+	{
+		key:   "throttle-http",
+		get:   func(this *MigrationContext) string { return this.GetThrottleHTTP() },
+		apply: func(this *MigrationContext, value string) error { this.SetThrottleHTTP(value); return nil },
+	},
+	{
+		key: "throttle-control-replicas",
+		get: func(this *MigrationContext) string {
+			// InstanceKeyMap is unordered; sort for the sake of comparison
+			replicas := strings.Split(this.GetThrottleControlReplicaKeys().ToCommaDelimitedList(), ",")
+			sort.Strings(replicas)
+			return strings.Join(replicas, ",")
+		},
+		apply: func(this *MigrationContext, value string) error { return this.ReadThrottleControlReplicaKeys(value) },
+	},
+}
+
+// readConfigFile reads the settings of the config file. With an INI file, these are the
+// keys of the [osc] section, e.g. chunk_size, which are named like flags but with
+// underscores instead of dashes.
+func readConfigFile(fileName string, profile string) (settings []configFileSetting, err error) {
+	if IsYAMLConfigFile(fileName) {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return settings, err
+		}
+		return parseConfigFile(fileName, content, profile)
+	}
+	cfg, err := ini.Load(fileName)
+	if err != nil {
+		return settings, err
+	}
+	for _, key := range cfg.Section("osc").Keys() {
+		setting := configFileSetting{key: strings.ReplaceAll(key.Name(), "_", "-")}
+		if setting.value, err = interpolateConfigFileValue(key.String()); err != nil {
+			return settings, fmt.Errorf("%s: [osc] %s: %+v", fileName, key.Name(), err)
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// ReloadConfigFile re-reads the config file and applies the settings which may change while the
+// migration runs: chunk size, DML batch size, nice ratio, load thresholds, max lag and throttle
+// query/HTTP/control replicas. Other settings are not reloaded. Only settings whose value in the
+// file changed since it was last applied are applied, so that values changed in the meantime,
+// e.g. via interactive commands, are kept. Flags explicitly given on the command line keep their
+// values. An invalid value fails the reload as a whole, without applying any setting. It returns
+// the settings which changed.
+func (this *MigrationContext) ReloadConfigFile() (changes []ConfigChange, err error) {
+	if err := this.ReadConfigFile(); err != nil {
+		return changes, err
+	}
+	if this.ConfigFile == "" {
+		return changes, nil
+	}
+	settings, err := readConfigFile(this.ConfigFile, this.ConfigFileProfile)
+	if err != nil {
+		return changes, err
+	}
+	// later settings (i.e. profile settings) override earlier ones
+	values := make(map[string]string)
+	for _, setting := range settings {
+		values[setting.key] = setting.value
+	}
+
+	// validate all values before applying any
+	validationContext := NewMigrationContext()
+	for _, setting := range settings {
+		for _, dynamicSetting := range dynamicConfigSettings {
+			if dynamicSetting.key != setting.key {
+				continue
+			}
+			if err := dynamicSetting.apply(validationContext, setting.value); err != nil {
+				return changes, fmt.Errorf("%s: %s: invalid value %q: %+v", setting.position(this.ConfigFile), setting.key, setting.value, err)
+			}
+		}
+	}
+
+	for _, dynamicSetting := range dynamicConfigSettings {
+		value, ok := values[dynamicSetting.key]
+		if !ok {
+			continue
+		}
+		if this.CommandLineFlags[dynamicSetting.key] {
+			this.Log.Debugf("Config reload: not changing %s, given on the command line", dynamicSetting.key)
+			continue
+		}
+		if !this.configFileValueChanged(dynamicSetting.key, value) {
+			continue
+		}
+		oldValue := dynamicSetting.get(this)
+		if err := dynamicSetting.apply(this, value); err != nil {
+			return changes, err
+		}
+		if newValue := dynamicSetting.get(this); newValue != oldValue {
+			changes = append(changes, ConfigChange{Key: dynamicSetting.key, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	this.setConfigFileValues(settings)
+	return changes, nil
+}
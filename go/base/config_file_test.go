@@ -0,0 +1,287 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfigFileContent = `
+host: replica.example.com
+chunk-size: 1000
+max-load: Threads_running=30
+throttle-control-replicas:
+  - replica1.example.com
+  - replica2.example.com
+password: ${GH_OST_TEST_PASSWORD}
+exact-rowcount: true
+profiles:
+  busy-primary:
+    chunk-size: 200
+    nice-ratio: 2.5
+  night-batch:
+    chunk-size: 5000
+`
+
+func newTestConfigFlagSet() *flag.FlagSet {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.String("host", "127.0.0.1", "")
+	flagSet.String("password", "", "")
+	flagSet.Int64("chunk-size", 1000, "")
+	flagSet.String("max-load", "", "")
+	flagSet.String("throttle-control-replicas", "", "")
+	flagSet.Bool("exact-rowcount", false, "")
+	flagSet.Float64("nice-ratio", 0, "")
+	flagSet.String("conf", "", "")
+	return flagSet
+}
+
+func writeTestConfigFile(t *testing.T, content string) string {
+	fileName := filepath.Join(t.TempDir(), "gh-ost.yaml")
+	require.NoError(t, os.WriteFile(fileName, []byte(content), 0600))
+	return fileName
+}
+
+func TestIsYAMLConfigFile(t *testing.T) {
+	require.True(t, IsYAMLConfigFile("/etc/gh-ost.yaml"))
+	require.True(t, IsYAMLConfigFile("gh-ost.YML"))
+	require.False(t, IsYAMLConfigFile("/etc/my.cnf"))
+	require.False(t, IsYAMLConfigFile(""))
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	t.Setenv("GH_OST_TEST_PASSWORD", "s3cr3t")
+	fileName := writeTestConfigFile(t, testConfigFileContent)
+
+	t.Run("top level", func(t *testing.T) {
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{}))
+		_, err := ApplyConfigFile(flagSet, fileName, "")
+		require.NoError(t, err)
+		require.Equal(t, "replica.example.com", flagSet.Lookup("host").Value.String())
+		require.Equal(t, "1000", flagSet.Lookup("chunk-size").Value.String())
+		require.Equal(t, "Threads_running=30", flagSet.Lookup("max-load").Value.String())
+		require.Equal(t, "replica1.example.com,replica2.example.com", flagSet.Lookup("throttle-control-replicas").Value.String())
+		require.Equal(t, "s3cr3t", flagSet.Lookup("password").Value.String())
+		require.Equal(t, "true", flagSet.Lookup("exact-rowcount").Value.String())
+		require.Equal(t, "0", flagSet.Lookup("nice-ratio").Value.String())
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{}))
+		_, err := ApplyConfigFile(flagSet, fileName, "busy-primary")
+		require.NoError(t, err)
+		require.Equal(t, "replica.example.com", flagSet.Lookup("host").Value.String())
+		require.Equal(t, "200", flagSet.Lookup("chunk-size").Value.String())
+		require.Equal(t, "2.5", flagSet.Lookup("nice-ratio").Value.String())
+	})
+
+	t.Run("command line overrides", func(t *testing.T) {
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{"--chunk-size=300", "--host=other.example.com"}))
+		_, err := ApplyConfigFile(flagSet, fileName, "night-batch")
+		require.NoError(t, err)
+		require.Equal(t, "other.example.com", flagSet.Lookup("host").Value.String())
+		require.Equal(t, "300", flagSet.Lookup("chunk-size").Value.String())
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{}))
+		_, err := ApplyConfigFile(flagSet, fileName, "no-such-profile")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `profile "no-such-profile" not found`)
+	})
+}
+
+func TestApplyConfigFileStringList(t *testing.T) {
+	fileName := writeTestConfigFile(t, "transform-column:\n  - email=lower(email)\n  - name=concat(first, ' ', last)\nprofiles:\n  p:\n    transform-column: dollars=cents / 100\n")
+	{
+		var transformations StringListFlag
+		flagSet := newTestConfigFlagSet()
+		flagSet.Var(&transformations, "transform-column", "")
+		require.NoError(t, flagSet.Parse([]string{}))
+		_, err := ApplyConfigFile(flagSet, fileName, "p")
+		require.NoError(t, err)
+		require.Equal(t, StringListFlag{"email=lower(email)", "name=concat(first, ' ', last)", "dollars=cents / 100"}, transformations)
+	}
+	{
+		var transformations StringListFlag
+		flagSet := newTestConfigFlagSet()
+		flagSet.Var(&transformations, "transform-column", "")
+		require.NoError(t, flagSet.Parse([]string{"--transform-column", "email=upper(email)"}))
+		_, err := ApplyConfigFile(flagSet, fileName, "")
+		require.NoError(t, err)
+		require.Equal(t, StringListFlag{"email=upper(email)"}, transformations)
+	}
+}
+
+func TestApplyConfigFileINI(t *testing.T) {
+	t.Setenv("GH_OST_TEST_MAX_LOAD", "Threads_running=40")
+	fileName := filepath.Join(t.TempDir(), "my.cnf")
+	require.NoError(t, os.WriteFile(fileName, []byte("[client]\nuser=gromit\n[osc]\nchunk_size=300\nmax_load=${GH_OST_TEST_MAX_LOAD}\nexact_rowcount=true\n"), 0600))
+	{
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{"--chunk-size=100"}))
+		_, err := ApplyConfigFile(flagSet, fileName, "")
+		require.NoError(t, err)
+		require.Equal(t, "100", flagSet.Lookup("chunk-size").Value.String())
+		require.Equal(t, "Threads_running=40", flagSet.Lookup("max-load").Value.String())
+		require.Equal(t, "true", flagSet.Lookup("exact-rowcount").Value.String())
+	}
+	{
+		// Keys of other tools sharing the [osc] section are skipped
+		require.NoError(t, os.WriteFile(fileName, []byte("[osc]\nno_such_flag=1\nchunk_size=300\n"), 0600))
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{}))
+		skippedKeys, err := ApplyConfigFile(flagSet, fileName, "")
+		require.NoError(t, err)
+		require.Equal(t, []string{"no-such-flag"}, skippedKeys)
+		require.Equal(t, "300", flagSet.Lookup("chunk-size").Value.String())
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		profile  string
+		expected string
+	}{
+		{"unknown flag", "host: localhost\nno-such-flag: 1\n", "", ":2: no-such-flag: unknown flag"},
+		{"invalid value", "chunk-size: lots\n", "", `:1: chunk-size: invalid value "lots"`},
+		{"invalid profile value", "profiles:\n  p:\n    exact-rowcount: maybe\n", "p", ":3: exact-rowcount: invalid value"},
+		{"forbidden flag", "conf: other.yaml\n", "", ":1: conf: cannot be set in a config file"},
+		{"unset env variable", "password: ${GH_OST_TEST_NO_SUCH_VARIABLE}\n", "", ":1: password: environment variable GH_OST_TEST_NO_SUCH_VARIABLE is not set"},
+		{"nested value", "host:\n  name: localhost\n", "", ":2: host: expecting a scalar value"},
+		{"not a mapping", "- host\n", "", ":1: expecting a mapping"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fileName := writeTestConfigFile(t, tc.content)
+			flagSet := newTestConfigFlagSet()
+			require.NoError(t, flagSet.Parse([]string{}))
+			_, err := ApplyConfigFile(flagSet, fileName, tc.profile)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func TestInterpolateConfigFileValue(t *testing.T) {
+	t.Setenv("GH_OST_TEST_USER", "gromit")
+	{
+		value, err := interpolateConfigFileValue("${GH_OST_TEST_USER}@${GH_OST_TEST_NO_SUCH_VARIABLE:-localhost}")
+		require.NoError(t, err)
+		require.Equal(t, "gromit@localhost", value)
+	}
+	{
+		value, err := interpolateConfigFileValue("no variables $HOME here")
+		require.NoError(t, err)
+		require.Equal(t, "no variables $HOME here", value)
+	}
+	{
+		_, err := interpolateConfigFileValue("${GH_OST_TEST_NO_SUCH_VARIABLE}")
+		require.Error(t, err)
+	}
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		fileName := writeTestConfigFile(t, "chunk-size: 1000\nmax-load: Threads_running=30\nhost: ignored.example.com\nprofiles:\n  busy-primary:\n    chunk-size: 200\n    nice-ratio: 0.5\n")
+		migrationContext := NewMigrationContext()
+		migrationContext.ConfigFile = fileName
+		migrationContext.ConfigFileProfile = "busy-primary"
+		migrationContext.SetChunkSize(1000)
+
+		changes, err := migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Equal(t, []ConfigChange{
+			{Key: "chunk-size", OldValue: "1000", NewValue: "200"},
+			{Key: "nice-ratio", OldValue: "0", NewValue: "0.5"},
+			{Key: "max-load", OldValue: "", NewValue: "Threads_running=30"},
+		}, changes)
+		require.Equal(t, int64(200), migrationContext.ChunkSize)
+
+		changes, err = migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Empty(t, changes)
+	})
+
+	t.Run("ini", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "my.cnf")
+		require.NoError(t, os.WriteFile(fileName, []byte("[client]\nuser=gromit\n[osc]\nchunk_size=300\nmax_lag_millis=2500\nthrottle_control_replicas=replica2:3306,replica1:3306\n"), 0600))
+		migrationContext := NewMigrationContext()
+		migrationContext.ConfigFile = fileName
+
+		changes, err := migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+		require.Equal(t, int64(300), migrationContext.ChunkSize)
+		require.Equal(t, int64(2500), migrationContext.MaxLagMillisecondsThrottleThreshold)
+		require.Equal(t, ConfigChange{Key: "throttle-control-replicas", OldValue: "", NewValue: "replica1:3306,replica2:3306"}, changes[2])
+	})
+
+	t.Run("command line flags", func(t *testing.T) {
+		fileName := writeTestConfigFile(t, "chunk-size: 500\ndml-batch-size: 50\n")
+		migrationContext := NewMigrationContext()
+		migrationContext.ConfigFile = fileName
+		migrationContext.CommandLineFlags["chunk-size"] = true
+		migrationContext.SetChunkSize(1000)
+
+		changes, err := migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Equal(t, []ConfigChange{{Key: "dml-batch-size", OldValue: "10", NewValue: "50"}}, changes)
+		require.Equal(t, int64(1000), migrationContext.ChunkSize)
+	})
+
+	t.Run("unchanged settings", func(t *testing.T) {
+		flagSet := newTestConfigFlagSet()
+		require.NoError(t, flagSet.Parse([]string{}))
+		fileName := writeTestConfigFile(t, "chunk-size: 500\nnice-ratio: 0.5\n")
+		migrationContext := NewMigrationContext()
+		migrationContext.ConfigFile = fileName
+		require.NoError(t, migrationContext.ApplyConfigFile(flagSet))
+		migrationContext.SetChunkSize(500)
+		migrationContext.SetNiceRatio(0.5)
+
+		// e.g. changed by an interactive command
+		migrationContext.SetChunkSize(750)
+		changes, err := migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Empty(t, changes)
+		require.Equal(t, int64(750), migrationContext.ChunkSize)
+
+		require.NoError(t, os.WriteFile(fileName, []byte("chunk-size: 500\nnice-ratio: 1.5\n"), 0600))
+		changes, err = migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Equal(t, []ConfigChange{{Key: "nice-ratio", OldValue: "0.5", NewValue: "1.5"}}, changes)
+		require.Equal(t, int64(750), migrationContext.ChunkSize)
+
+		require.NoError(t, os.WriteFile(fileName, []byte("chunk-size: 600\nnice-ratio: 1.5\n"), 0600))
+		changes, err = migrationContext.ReloadConfigFile()
+		require.NoError(t, err)
+		require.Equal(t, []ConfigChange{{Key: "chunk-size", OldValue: "750", NewValue: "600"}}, changes)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		fileName := writeTestConfigFile(t, "chunk-size: 500\nmax-load: Threads_running\n")
+		migrationContext := NewMigrationContext()
+		migrationContext.ConfigFile = fileName
+		migrationContext.SetChunkSize(1000)
+
+		_, err := migrationContext.ReloadConfigFile()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ":2: max-load: invalid value")
+		require.Equal(t, int64(1000), migrationContext.ChunkSize)
+	})
+}
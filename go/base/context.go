@@ -41,6 +41,13 @@ const (
 	CutOverTwoStep
 )
 
+type ConcurrentDDLAction int
+
+const (
+	ConcurrentDDLAbort ConcurrentDDLAction = iota
+	ConcurrentDDLThrottle
+)
+
 type ThrottleReasonHint string
 
 const (
@@ -82,6 +89,8 @@ type MigrationContext struct {
 	OriginalTableName     string
 	AlterStatement        string
 	AlterStatementOptions string // anything following the 'ALTER TABLE [schema.]table' from AlterStatement
+	RenamedTableName      string // new table name, when the ALTER statement includes RENAME TO|AS
+	RenameTableCompatView bool
 
 	countMutex               sync.Mutex
 	countTableRowsCancelFunc func()
@@ -95,14 +104,19 @@ type MigrationContext struct {
 	SkipStrictMode           bool
 	AllowZeroInDate          bool
 	NullableUniqueKeyAllowed bool
+	NoUniqueKeyAllowed       bool
+	DuplicateRowsAllowed     bool
 	ApproveRenamedColumns    bool
 	SkipRenamedColumns       bool
 	IsTungsten               bool
 	DiscardForeignKeys       bool
+	RebindParentForeignKeys  bool
+	PreserveForeignKeys      bool
 	AliyunRDS                bool
 	GoogleCloudPlatform      bool
 	AzureMySQL               bool
 	AttemptInstantDDL        bool
+	CopyPerPartition         bool
 
 	// SkipPortValidation allows skipping the port validation in `ValidateConnection`
 	// This is useful when connecting to a MySQL instance where the external port
@@ -110,17 +124,51 @@ type MigrationContext struct {
 	SkipPortValidation bool
 
 	config            ContextConfig
+	masterCredentials credentials
 	configMutex       *sync.Mutex
 	ConfigFile        string
-	CliUser           string
-	CliPassword       string
-	UseTLS            bool
-	TLSAllowInsecure  bool
-	TLSCACertificate  string
-	TLSCertificate    string
-	TLSKey            string
-	CliMasterUser     string
-	CliMasterPassword string
+	ConfigFileProfile string
+	// configFileValues are the config file settings as last applied, by key, so that reloading
+	// only applies settings changed in the file since
+	configFileValues map[string]string
+	// CommandLineFlags are the flags explicitly given on the command line, which config files do not override
+	CommandLineFlags   map[string]bool
+	CliUser            string
+	CliPassword        string
+	UseTLS             bool
+	TLSAllowInsecure   bool
+	TLSCACertificate   string
+	TLSCertificate     string
+	TLSKey             string
+	CliMasterUser      string
+	CliMasterPassword  string
+	PasswordFile       string
+	MasterPasswordFile string
+	LoginPath          string
+	ReadMyCnf          bool
+	CredentialHelper   string
+
+	BinlogHost             string
+	BinlogPort             int
+	BinlogUser             string
+	BinlogPassword         string
+	BinlogPasswordFile     string
+	BinlogUseTLS           bool
+	BinlogTLSAllowInsecure bool
+	BinlogTLSCACertificate string
+	BinlogTLSCertificate   string
+	BinlogTLSKey           string
+
+	TargetHost         string
+	TargetPort         int
+	TargetUser         string
+	TargetPassword     string
+	TargetDatabaseName string
+	TargetTableName    string
+
+	CredentialsRefreshIntervalSeconds int64
+	credentialsRefreshMutex           *sync.Mutex
+	lastCredentialsRefresh            time.Time
 
 	HeartbeatIntervalMilliseconds       int64
 	defaultNumRetries                   int64
@@ -128,12 +176,14 @@ type MigrationContext struct {
 	niceRatio                           float64
 	MaxLagMillisecondsThrottleThreshold int64
 	throttleControlReplicaKeys          *mysql.InstanceKeyMap
+	targetThrottleControlReplicaKeys    *mysql.InstanceKeyMap
 	ThrottleFlagFile                    string
 	ThrottleAdditionalFlagFile          string
 	throttleQuery                       string
 	throttleHTTP                        string
 	IgnoreHTTPErrors                    bool
 	ThrottleCommandedByUser             int64
+	ThrottleCommandedByHook             int64
 	HibernateUntil                      int64
 	maxLoad                             LoadMap
 	criticalLoad                        LoadMap
@@ -151,6 +201,20 @@ type MigrationContext struct {
 	HooksHintOwner                      string
 	HooksHintToken                      string
 	HooksStatusIntervalSec              int64
+	HooksTimeoutSeconds                 int64
+	HooksTimeouts                       string
+	HooksAsync                          string
+	HooksIgnoreExitCode                 string
+	HooksWebhookURLs                    string
+	HooksWebhookEvents                  string
+	HooksWebhookFailOnError             string
+	HooksWebhookSecret                  string
+	HooksWebhookTimeoutMillis           int64
+	HooksWebhookRetries                 int64
+
+	CDCTapDestination   string
+	CDCTapBufferSize    int64
+	CDCTapTimeoutMillis int64
 
 	DropServeSocket bool
 	ServeSocketFile string
@@ -165,6 +229,7 @@ type MigrationContext struct {
 	InitiallyDropGhostTable      bool
 	TimestampOldTable            bool // Should old table name include a timestamp
 	CutOverType                  CutOver
+	ConcurrentDDLAction          ConcurrentDDLAction
 	ReplicaServerId              uint
 
 	Hostname                               string
@@ -182,6 +247,10 @@ type MigrationContext struct {
 	InspectorMySQLVersion                  string
 	ApplierConnectionConfig                *mysql.ConnectionConfig
 	ApplierMySQLVersion                    string
+	BinlogConnectionConfig                 *mysql.ConnectionConfig
+	BinlogMySQLVersion                     string
+	TargetConnectionConfig                 *mysql.ConnectionConfig
+	TargetMySQLVersion                     string
 	StartTime                              time.Time
 	RowCopyStartTime                       time.Time
 	RowCopyEndTime                         time.Time
@@ -200,13 +269,18 @@ type MigrationContext struct {
 	ThrottleHTTPStatusCode                 int64
 	ThrottleHTTPTimeoutMillis              int64
 	controlReplicasLagResult               mysql.ReplicationLagResult
+	targetReplicasLagResult                mysql.ReplicationLagResult
 	TotalRowsCopied                        int64
+	TotalRowsDiscarded                     int64
+	TotalRowsArchived                      int64
 	TotalDMLEventsApplied                  int64
 	DMLBatchSize                           int64
 	isThrottled                            bool
 	throttleReason                         string
 	throttleReasonHint                     ThrottleReasonHint
 	throttleGeneralCheckResult             ThrottleCheckResult
+	hooksStatusMessage                     string
+	concurrentDDL                          string
 	throttleMutex                          *sync.Mutex
 	throttleHTTPMutex                      *sync.Mutex
 	IsPostponingCutOver                    int64
@@ -214,8 +288,10 @@ type MigrationContext struct {
 	AllEventsUpToLockProcessedInjectedFlag int64
 	CleanupImminentFlag                    int64
 	UserCommandedUnpostponeFlag            int64
+	PostponeCutOverCommandedByHook         int64
 	CutOverCompleteFlag                    int64
 	InCutOverCriticalSectionFlag           int64
+	CutOverAttempts                        int64
 	PanicAbort                             chan error
 
 	OriginalTableColumnsOnApplier    *sql.ColumnList
@@ -227,22 +303,47 @@ type MigrationContext struct {
 	GhostTableVirtualColumns         *sql.ColumnList
 	GhostTableUniqueKeys             [](*sql.UniqueKey)
 	UniqueKey                        *sql.UniqueKey
+	FullRowMatchColumns              *sql.ColumnList
 	SharedColumns                    *sql.ColumnList
 	ColumnRenameMap                  map[string]string
 	DroppedColumnsMap                map[string]bool
 	MappedSharedColumns              *sql.ColumnList
+	ColumnTransformations            sql.ColumnTransformations
+	RowFilter                        *sql.RowFilter
 	MigrationRangeMinValues          *sql.ColumnValues
 	MigrationRangeMaxValues          *sql.ColumnValues
 	Iteration                        int64
 	MigrationIterationRangeMinValues *sql.ColumnValues
 	MigrationIterationRangeMaxValues *sql.ColumnValues
 	ForceTmpTableName                string
+	ArchiveTableName                 string
+	ArchiveFileName                  string
+	ArchiveColumns                   *sql.ColumnList
+	ArchiveRowFilter                 *sql.RowFilter
+	SideTableName                    string
+	SideColumnNames                  []string
+	SideTableColumns                 *sql.ColumnList
+	ShardColumnName                  string
+	ShardTableNames                  []string
+	ShardRouter                      *sql.ShardRouter
+
+	OriginalTablePartitions     []string
+	GhostTablePartitions        []string
+	copyPartitionIndex          int64
+	copyPartitionIterationStart int64
 
 	IncludeTriggers     bool
 	RemoveTriggerSuffix bool
 	TriggerSuffix       string
 	Triggers            []mysql.Trigger
 
+	ParentSideForeignKeys               []mysql.ForeignKey
+	ParentSideForeignKeysRebindDuration time.Duration
+
+	RemoveForeignKeySuffix bool
+	ForeignKeySuffix       string
+	ChildSideForeignKeys   []mysql.ForeignKey
+
 	recentBinlogCoordinates mysql.BinlogCoordinates
 
 	BinlogSyncerMaxReconnectAttempts int
@@ -296,10 +397,13 @@ func NewMigrationContext() *MigrationContext {
 		throttleMutex:                       &sync.Mutex{},
 		throttleHTTPMutex:                   &sync.Mutex{},
 		throttleControlReplicaKeys:          mysql.NewInstanceKeyMap(),
+		targetThrottleControlReplicaKeys:    mysql.NewInstanceKeyMap(),
 		configMutex:                         &sync.Mutex{},
 		pointOfInterestTimeMutex:            &sync.Mutex{},
 		lastHeartbeatOnChangelogMutex:       &sync.Mutex{},
 		ColumnRenameMap:                     make(map[string]string),
+		CommandLineFlags:                    make(map[string]bool),
+		credentialsRefreshMutex:             &sync.Mutex{},
 		PanicAbort:                          make(chan error),
 		Log:                                 NewDefaultLogger(),
 	}
@@ -336,6 +440,15 @@ func getSafeTableName(baseName string, suffix string) string {
 	return fmt.Sprintf("_%s_%s", baseName[0:len(baseName)-extraCharacters], suffix)
 }
 
+// GetMigratedTableName returns the name the migrated table assumes at cut-over: the
+// original table name, or the new name when the ALTER statement renames the table
+func (this *MigrationContext) GetMigratedTableName() string {
+	if this.RenamedTableName != "" {
+		return this.RenamedTableName
+	}
+	return this.OriginalTableName
+}
+
 // GetGhostTableName generates the name of ghost table, based on original table name
 // or a given table name
 func (this *MigrationContext) GetGhostTableName() string {
@@ -375,6 +488,30 @@ func (this *MigrationContext) GetChangelogTableName() string {
 	}
 }
 
+// IsCrossServerMigration is `true` when the table is migrated onto another server (--target-host),
+// rather than onto a ghost table next to the original table
+func (this *MigrationContext) IsCrossServerMigration() bool {
+	return this.TargetHost != ""
+}
+
+// GetGhostDatabaseName returns the name of the database holding the ghost table: that of the original
+// table, or the target database on a cross-server migration
+func (this *MigrationContext) GetGhostDatabaseName() string {
+	if this.IsCrossServerMigration() && this.TargetDatabaseName != "" {
+		return this.TargetDatabaseName
+	}
+	return this.DatabaseName
+}
+
+// GetTargetTableName returns the name under which the ghost table is handed off on the target server
+// of a cross-server migration
+func (this *MigrationContext) GetTargetTableName() string {
+	if this.TargetTableName != "" {
+		return this.TargetTableName
+	}
+	return this.GetMigratedTableName()
+}
+
 // GetVoluntaryLockName returns a name of a voluntary lock to be used throughout
 // the swap-tables process.
 func (this *MigrationContext) GetVoluntaryLockName() string {
@@ -397,6 +534,32 @@ func (this *MigrationContext) GetApplierHostname() string {
 	return this.ApplierConnectionConfig.ImpliedKey.Hostname
 }
 
+// UsesSeparateBinlogConnection is true when any of the --binlog-* connection flags is given,
+// in which case the binlog reader does not simply use the inspector's connection config
+func (this *MigrationContext) UsesSeparateBinlogConnection() bool {
+	return this.BinlogHost != "" || this.BinlogPort != 0 || this.BinlogUser != "" ||
+		this.BinlogPassword != "" || this.BinlogPasswordFile != "" || this.BinlogUseTLS
+}
+
+// GetBinlogConnectionConfig returns the connection config of the binlog reader
+func (this *MigrationContext) GetBinlogConnectionConfig() *mysql.ConnectionConfig {
+	if this.BinlogConnectionConfig == nil {
+		return this.InspectorConnectionConfig
+	}
+	return this.BinlogConnectionConfig
+}
+
+// GetTargetHostname is a safe access method to the target hostname of a cross-server migration
+func (this *MigrationContext) GetTargetHostname() string {
+	if this.TargetConnectionConfig == nil {
+		return ""
+	}
+	if this.TargetConnectionConfig.ImpliedKey == nil {
+		return ""
+	}
+	return this.TargetConnectionConfig.ImpliedKey.Hostname
+}
+
 // GetInspectorHostname is a safe access method to the inspector hostname
 func (this *MigrationContext) GetInspectorHostname() string {
 	if this.InspectorConnectionConfig == nil {
@@ -578,10 +741,124 @@ func (this *MigrationContext) GetTotalRowsCopied() int64 {
 	return atomic.LoadInt64(&this.TotalRowsCopied)
 }
 
+// GetTotalRowsDiscarded returns the number of rows row copy iterated over yet did not copy,
+// as they do not match the row filter (--where)
+func (this *MigrationContext) GetTotalRowsDiscarded() int64 {
+	return atomic.LoadInt64(&this.TotalRowsDiscarded)
+}
+
+// GetTotalRowsArchived returns the number of rows row copy archived (--archive-table, --archive-file)
+func (this *MigrationContext) GetTotalRowsArchived() int64 {
+	return atomic.LoadInt64(&this.TotalRowsArchived)
+}
+
+// IsVerticalSplit returns true when the migration splits the table's columns onto a side table (--side-table)
+func (this *MigrationContext) IsVerticalSplit() bool {
+	return this.SideTableName != ""
+}
+
+// GetSideGhostTableName generates the name of the side table's ghost table, which is
+// renamed to the side table (--side-table) at cut-over
+func (this *MigrationContext) GetSideGhostTableName() string {
+	return getSafeTableName(this.SideTableName, "gho")
+}
+
+// IsHorizontalSplit returns true when the migration splits the table's rows onto shard tables (--shard-tables)
+func (this *MigrationContext) IsHorizontalSplit() bool {
+	return len(this.ShardTableNames) > 0
+}
+
+// GetShardGhostTableName generates the name of given shard's ghost table, which is renamed to
+// the shard table at cut-over
+func (this *MigrationContext) GetShardGhostTableName(shard int) string {
+	return getSafeTableName(this.ShardTableNames[shard], "gho")
+}
+
+// IsArchiving returns true when the data a migration discards is archived onto a table or file
+func (this *MigrationContext) IsArchiving() bool {
+	return this.ArchiveTableName != "" || this.ArchiveFileName != ""
+}
+
+// GetFullRowImageFlags returns the flags in use which rely on binlog events holding all of a row's
+// columns (binlog_row_image=FULL)
+func (this *MigrationContext) GetFullRowImageFlags() (flags []string) {
+	if this.RowFilter != nil {
+		flags = append(flags, "--where")
+	}
+	if this.ArchiveTableName != "" {
+		flags = append(flags, "--archive-table")
+	}
+	if this.ArchiveFileName != "" {
+		flags = append(flags, "--archive-file")
+	}
+	if this.IsVerticalSplit() {
+		flags = append(flags, "--side-table")
+	}
+	if this.IsHorizontalSplit() {
+		flags = append(flags, "--shard-tables")
+	}
+	return flags
+}
+
+// GetTotalRowsIterated returns the number of rows row copy iterated over: copied or discarded
+func (this *MigrationContext) GetTotalRowsIterated() int64 {
+	return this.GetTotalRowsCopied() + this.GetTotalRowsDiscarded()
+}
+
 func (this *MigrationContext) GetIteration() int64 {
 	return atomic.LoadInt64(&this.Iteration)
 }
 
+// IsRangeStartIteration returns true when the current iteration is the first one
+// of the copied range: the entire table, or the current partition when copying per partition.
+// The first iteration includes the range start values.
+func (this *MigrationContext) IsRangeStartIteration() bool {
+	return this.GetIteration() == atomic.LoadInt64(&this.copyPartitionIterationStart)
+}
+
+// GetCopyPartitionName returns the name of the partition being copied, or an empty
+// string when not copying per partition.
+func (this *MigrationContext) GetCopyPartitionName() string {
+	if !this.CopyPerPartition {
+		return ""
+	}
+	index := atomic.LoadInt64(&this.copyPartitionIndex)
+	if index >= int64(len(this.OriginalTablePartitions)) {
+		return ""
+	}
+	return this.OriginalTablePartitions[index]
+}
+
+// GetCopyPartitionProgress returns the 1-based ordinal of the partition being copied,
+// and the total number of partitions.
+func (this *MigrationContext) GetCopyPartitionProgress() (ordinal int64, total int64) {
+	return atomic.LoadInt64(&this.copyPartitionIndex) + 1, int64(len(this.OriginalTablePartitions))
+}
+
+// HasNextCopyPartition returns true when copying per partition and there are
+// partitions following the one being copied.
+func (this *MigrationContext) HasNextCopyPartition() bool {
+	if !this.CopyPerPartition {
+		return false
+	}
+	return atomic.LoadInt64(&this.copyPartitionIndex)+1 < int64(len(this.OriginalTablePartitions))
+}
+
+// GetNextCopyPartitionName returns the name of the partition following the one being copied
+func (this *MigrationContext) GetNextCopyPartitionName() string {
+	if !this.HasNextCopyPartition() {
+		return ""
+	}
+	return this.OriginalTablePartitions[atomic.LoadInt64(&this.copyPartitionIndex)+1]
+}
+
+// AdvanceCopyPartition moves row copy onto the next partition. The next iteration
+// is the range start iteration of that partition.
+func (this *MigrationContext) AdvanceCopyPartition() {
+	atomic.AddInt64(&this.copyPartitionIndex, 1)
+	atomic.StoreInt64(&this.copyPartitionIterationStart, this.GetIteration())
+}
+
 func (this *MigrationContext) MarkPointOfInterest() int64 {
 	this.pointOfInterestTimeMutex.Lock()
 	defer this.pointOfInterestTimeMutex.Unlock()
@@ -686,6 +963,41 @@ func (this *MigrationContext) IsThrottled() (bool, string, ThrottleReasonHint) {
 	return this.isThrottled, this.throttleReason, this.throttleReasonHint
 }
 
+// GetHooksStatusMessage returns the message most recently attached to the status by a hook
+func (this *MigrationContext) GetHooksStatusMessage() string {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	return this.hooksStatusMessage
+}
+
+func (this *MigrationContext) SetHooksStatusMessage(message string) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	this.hooksStatusMessage = message
+}
+
+// GetConcurrentDDL returns the concurrent DDL awaiting operator acknowledgement, if any
+func (this *MigrationContext) GetConcurrentDDL() string {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	return this.concurrentDDL
+}
+
+func (this *MigrationContext) SetConcurrentDDL(concurrentDDL string) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	this.concurrentDDL = concurrentDDL
+}
+
+// AcknowledgeConcurrentDDL clears and returns the concurrent DDL awaiting operator acknowledgement
+func (this *MigrationContext) AcknowledgeConcurrentDDL() string {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	concurrentDDL := this.concurrentDDL
+	this.concurrentDDL = ""
+	return concurrentDDL
+}
+
 func (this *MigrationContext) GetThrottleQuery() string {
 	this.throttleMutex.Lock()
 	defer this.throttleMutex.Unlock()
@@ -818,6 +1130,35 @@ func (this *MigrationContext) SetControlReplicasLagResult(lagResult *mysql.Repli
 	}
 }
 
+// GetTargetReplicasLagResult returns the maximum lag of the target server's replicas (--target-throttle-control-replicas)
+func (this *MigrationContext) GetTargetReplicasLagResult() mysql.ReplicationLagResult {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	lagResult := this.targetReplicasLagResult
+	return lagResult
+}
+
+func (this *MigrationContext) SetTargetReplicasLagResult(lagResult *mysql.ReplicationLagResult) {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+	if lagResult == nil {
+		this.targetReplicasLagResult = *mysql.NewNoReplicationLagResult()
+	} else {
+		this.targetReplicasLagResult = *lagResult
+	}
+}
+
+// GetTargetThrottleControlReplicaKeys returns the replicas of the target server checked for lag
+func (this *MigrationContext) GetTargetThrottleControlReplicaKeys() *mysql.InstanceKeyMap {
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	keys := mysql.NewInstanceKeyMap()
+	keys.AddKeys(this.targetThrottleControlReplicaKeys.GetInstanceKeys())
+	return keys
+}
+
 func (this *MigrationContext) GetThrottleControlReplicaKeys() *mysql.InstanceKeyMap {
 	this.throttleMutex.Lock()
 	defer this.throttleMutex.Unlock()
@@ -827,6 +1168,79 @@ func (this *MigrationContext) GetThrottleControlReplicaKeys() *mysql.InstanceKey
 	return keys
 }
 
+// ReadColumnTransformations parses "column=expression" column transformation definitions
+func (this *MigrationContext) ReadColumnTransformations(definitions []string) error {
+	transformations := sql.ColumnTransformations{}
+	for _, definition := range definitions {
+		transformation, err := sql.ParseColumnTransformation(definition)
+		if err != nil {
+			return err
+		}
+		if transformations.Get(transformation.Column) != nil {
+			return fmt.Errorf("Column %s is transformed more than once", transformation.Column)
+		}
+		transformations = append(transformations, transformation)
+	}
+	this.ColumnTransformations = transformations
+	return nil
+}
+
+// ReadRowFilter parses the row filter (--where), an SQL predicate over original table columns
+func (this *MigrationContext) ReadRowFilter(expression string) error {
+	if expression == "" {
+		return nil
+	}
+	rowFilter, err := sql.NewRowFilter(expression)
+	if err != nil {
+		return err
+	}
+	this.RowFilter = rowFilter
+	return nil
+}
+
+// ReadSideColumnNames parses the comma delimited list of columns moved onto the side table (--side-columns)
+func (this *MigrationContext) ReadSideColumnNames(sideColumns string) error {
+	if sideColumns == "" {
+		return nil
+	}
+	this.SideColumnNames = []string{}
+	for _, columnName := range strings.Split(sideColumns, ",") {
+		columnName = strings.TrimSpace(columnName)
+		if columnName == "" {
+			return fmt.Errorf("Empty column name in --side-columns %q", sideColumns)
+		}
+		this.SideColumnNames = append(this.SideColumnNames, columnName)
+	}
+	return nil
+}
+
+// ReadShardTables parses the comma delimited list of shard tables (--shard-tables), and sets up
+// the routing of rows onto them by given method (--shard-by) and range bounds (--shard-ranges)
+func (this *MigrationContext) ReadShardTables(shardTables string, shardMethod string, shardRanges string) error {
+	if shardTables == "" {
+		return nil
+	}
+	this.ShardTableNames = []string{}
+	for _, tableName := range strings.Split(shardTables, ",") {
+		tableName = strings.TrimSpace(tableName)
+		if tableName == "" {
+			return fmt.Errorf("Empty table name in --shard-tables %q", shardTables)
+		}
+		for _, shardTableName := range this.ShardTableNames {
+			if strings.EqualFold(tableName, shardTableName) {
+				return fmt.Errorf("Table %s is listed more than once in --shard-tables", tableName)
+			}
+		}
+		this.ShardTableNames = append(this.ShardTableNames, tableName)
+	}
+	rangeBounds, err := sql.ParseShardRangeBounds(shardRanges)
+	if err != nil {
+		return err
+	}
+	this.ShardRouter, err = sql.NewShardRouter(sql.ShardMethod(shardMethod), len(this.ShardTableNames), rangeBounds)
+	return err
+}
+
 func (this *MigrationContext) ReadThrottleControlReplicaKeys(throttleControlReplicas string) error {
 	keys := mysql.NewInstanceKeyMap()
 	if err := keys.ReadCommaDelimitedList(throttleControlReplicas); err != nil {
@@ -840,6 +1254,21 @@ func (this *MigrationContext) ReadThrottleControlReplicaKeys(throttleControlRepl
 	return nil
 }
 
+// ReadTargetThrottleControlReplicaKeys reads the comma delimited list of the target server's replicas
+// to check for lag (--target-throttle-control-replicas)
+func (this *MigrationContext) ReadTargetThrottleControlReplicaKeys(targetThrottleControlReplicas string) error {
+	keys := mysql.NewInstanceKeyMap()
+	if err := keys.ReadCommaDelimitedList(targetThrottleControlReplicas); err != nil {
+		return err
+	}
+
+	this.throttleMutex.Lock()
+	defer this.throttleMutex.Unlock()
+
+	this.targetThrottleControlReplicaKeys = keys
+	return nil
+}
+
 func (this *MigrationContext) AddThrottleControlReplicaKey(key mysql.InstanceKey) error {
 	this.throttleMutex.Lock()
 	defer this.throttleMutex.Unlock()
@@ -848,25 +1277,95 @@ func (this *MigrationContext) AddThrottleControlReplicaKey(key mysql.InstanceKey
 	return nil
 }
 
-// ApplyCredentials sorts out the credentials between the config file and the CLI flags
-func (this *MigrationContext) ApplyCredentials() {
+// ApplyCredentials sorts out the credentials between the credentials sources and the CLI flags.
+// Sources are applied in increasing order of precedence: my.cnf files, the login path, the config
+// file, the credential helper, password files and finally the CLI flags. The resulting inspector
+// credentials are shared by the applier, the throttle control replicas and the binlog reader;
+// master credentials apply to the applier when --assume-master-host is given. The config file's
+// credentials are read, and the master credentials written, under configMutex; other sources are
+// read without holding it, as the credential helper may take a while.
+func (this *MigrationContext) ApplyCredentials() error {
 	this.configMutex.Lock()
-	defer this.configMutex.Unlock()
+	configCredentials := credentials{user: this.config.Client.User, password: this.config.Client.Password}
+	this.configMutex.Unlock()
 
-	if this.config.Client.User != "" {
-		this.InspectorConnectionConfig.User = this.config.Client.User
+	var inspectorCredentials credentials
+	if this.ReadMyCnf {
+		myCnfCredentials, err := readMyCnfCredentials()
+		if err != nil {
+			return err
+		}
+		inspectorCredentials.apply(myCnfCredentials)
 	}
-	if this.CliUser != "" {
-		// Override
-		this.InspectorConnectionConfig.User = this.CliUser
+	if this.LoginPath != "" {
+		loginPathCredentials, err := readLoginPathCredentials(this.LoginPath)
+		if err != nil {
+			return err
+		}
+		inspectorCredentials.apply(loginPathCredentials)
 	}
-	if this.config.Client.Password != "" {
-		this.InspectorConnectionConfig.Password = this.config.Client.Password
+	inspectorCredentials.apply(configCredentials)
+	if this.CredentialHelper != "" {
+		helperCredentials, err := runCredentialHelper(this.CredentialHelper, "inspector", this.InspectorConnectionConfig.Key)
+		if err != nil {
+			return err
+		}
+		inspectorCredentials.apply(helperCredentials)
+	}
+	if this.PasswordFile != "" {
+		password, err := readPasswordFile(this.PasswordFile)
+		if err != nil {
+			return err
+		}
+		inspectorCredentials.apply(credentials{password: password})
+	}
+	// Override
+	inspectorCredentials.apply(credentials{user: this.CliUser, password: this.CliPassword})
+
+	var currentCredentials credentials
+	currentCredentials.user, currentCredentials.password = this.InspectorConnectionConfig.GetCredentials()
+	currentCredentials.apply(inspectorCredentials)
+	this.InspectorConnectionConfig.SetCredentials(currentCredentials.user, currentCredentials.password)
+
+	if this.AssumeMasterHostname == "" {
+		return nil
+	}
+	var masterCredentials credentials
+	if this.CredentialHelper != "" {
+		masterKey, err := mysql.ParseInstanceKey(this.AssumeMasterHostname)
+		if err != nil {
+			return err
+		}
+		helperCredentials, err := runCredentialHelper(this.CredentialHelper, "master", *masterKey)
+		if err != nil {
+			return err
+		}
+		masterCredentials.apply(helperCredentials)
 	}
-	if this.CliPassword != "" {
-		// Override
-		this.InspectorConnectionConfig.Password = this.CliPassword
+	if this.MasterPasswordFile != "" {
+		password, err := readPasswordFile(this.MasterPasswordFile)
+		if err != nil {
+			return err
+		}
+		masterCredentials.apply(credentials{password: password})
 	}
+	// Override
+	masterCredentials.apply(credentials{user: this.CliMasterUser, password: this.CliMasterPassword})
+
+	this.configMutex.Lock()
+	defer this.configMutex.Unlock()
+
+	this.masterCredentials = masterCredentials
+	return nil
+}
+
+// GetMasterCredentials returns the user & password to use on the master, when --assume-master-host
+// is given. Empty values mean the inspector's credentials apply.
+func (this *MigrationContext) GetMasterCredentials() (user string, password string) {
+	this.configMutex.Lock()
+	defer this.configMutex.Unlock()
+
+	return this.masterCredentials.user, this.masterCredentials.password
 }
 
 func (this *MigrationContext) SetupTLS() error {
@@ -876,12 +1375,58 @@ func (this *MigrationContext) SetupTLS() error {
 	return nil
 }
 
+// SetupBinlogConnectionConfig sets up the connection config of the binlog reader. Without any
+// --binlog-* connection flag, the binlog reader uses the inspector's connection config. Otherwise,
+// binlog settings not given default to the inspector's: host, port, TLS config and credentials,
+// where the credential helper, if any, is asked for "binlog" role credentials first. It must be
+// called after the inspector's credentials & TLS are set up, and may be called again to refresh.
+func (this *MigrationContext) SetupBinlogConnectionConfig() error {
+	if !this.UsesSeparateBinlogConnection() {
+		this.BinlogConnectionConfig = this.InspectorConnectionConfig
+		return nil
+	}
+	key := this.InspectorConnectionConfig.Key
+	if this.BinlogHost != "" {
+		key.Hostname = this.BinlogHost
+	}
+	if this.BinlogPort != 0 {
+		key.Port = this.BinlogPort
+	}
+	if this.BinlogConnectionConfig == nil || this.BinlogConnectionConfig == this.InspectorConnectionConfig {
+		this.BinlogConnectionConfig = this.InspectorConnectionConfig.DuplicateCredentials(key)
+	}
+
+	var binlogCredentials credentials
+	binlogCredentials.user, binlogCredentials.password = this.InspectorConnectionConfig.GetCredentials()
+	if this.CredentialHelper != "" && this.BinlogUser == "" && this.BinlogPassword == "" && this.BinlogPasswordFile == "" {
+		helperCredentials, err := runCredentialHelper(this.CredentialHelper, "binlog", key)
+		if err != nil {
+			return err
+		}
+		binlogCredentials.apply(helperCredentials)
+	}
+	if this.BinlogPasswordFile != "" {
+		password, err := readPasswordFile(this.BinlogPasswordFile)
+		if err != nil {
+			return err
+		}
+		binlogCredentials.apply(credentials{password: password})
+	}
+	binlogCredentials.apply(credentials{user: this.BinlogUser, password: this.BinlogPassword})
+	this.BinlogConnectionConfig.SetCredentials(binlogCredentials.user, binlogCredentials.password)
+
+	if this.BinlogUseTLS {
+		return this.BinlogConnectionConfig.UseTLS(this.BinlogTLSCACertificate, this.BinlogTLSCertificate, this.BinlogTLSKey, this.BinlogTLSAllowInsecure)
+	}
+	return this.BinlogConnectionConfig.CopyTLSConfig(this.InspectorConnectionConfig)
+}
+
 // ReadConfigFile attempts to read the config file, if it exists
 func (this *MigrationContext) ReadConfigFile() error {
 	this.configMutex.Lock()
 	defer this.configMutex.Unlock()
 
-	if this.ConfigFile == "" {
+	if this.ConfigFile == "" || IsYAMLConfigFile(this.ConfigFile) {
 		return nil
 	}
 	cfg, err := ini.Load(this.ConfigFile)
@@ -941,6 +1486,26 @@ func (this *MigrationContext) GetGhostTriggerName(triggerName string) string {
 	return triggerName + this.TriggerSuffix
 }
 
+// GetGhostForeignKeyName generates the name of a foreign key on the ghost table, based on the
+// original foreign key name. Foreign key names are unique per schema, hence the suffix.
+func (this *MigrationContext) GetGhostForeignKeyName(foreignKeyName string) string {
+	if this.RemoveForeignKeySuffix && strings.HasSuffix(foreignKeyName, this.ForeignKeySuffix) {
+		return strings.TrimSuffix(foreignKeyName, this.ForeignKeySuffix)
+	}
+	// else
+	return foreignKeyName + this.ForeignKeySuffix
+}
+
+// GetReboundForeignKeyName generates the name of a parent-side foreign key once rebound onto
+// the migrated table. The original constraint is dropped and the new one added in a single ALTER,
+// hence the name must differ: a leading underscore is toggled, similarly to pt-online-schema-change
+func (this *MigrationContext) GetReboundForeignKeyName(foreignKeyName string) string {
+	if strings.HasPrefix(foreignKeyName, "_") {
+		return strings.TrimPrefix(foreignKeyName, "_")
+	}
+	return "_" + foreignKeyName
+}
+
 // validateGhostTriggerLength check if the ghost trigger name length is not more than 64 characters
 func (this *MigrationContext) ValidateGhostTriggerLengthBelowMaxLength(triggerName string) bool {
 	ghostTriggerName := this.GetGhostTriggerName(triggerName)
@@ -13,6 +13,8 @@ import (
 
 	"github.com/openark/golib/log"
 	"github.com/stretchr/testify/require"
+
+	"github.com/github/gh-ost/go/sql"
 )
 
 func init() {
@@ -59,6 +61,35 @@ func TestGetTableNames(t *testing.T) {
 	}
 }
 
+func TestGetMigratedTableName(t *testing.T) {
+	context := NewMigrationContext()
+	context.OriginalTableName = "some_table"
+	require.Equal(t, "some_table", context.GetMigratedTableName())
+	context.RenamedTableName = "other_table"
+	require.Equal(t, "other_table", context.GetMigratedTableName())
+	require.Equal(t, "_some_table_gho", context.GetGhostTableName())
+	require.Equal(t, "_some_table_del", context.GetOldTableName())
+}
+
+func TestGetTargetNames(t *testing.T) {
+	context := NewMigrationContext()
+	context.DatabaseName = "test"
+	context.OriginalTableName = "some_table"
+	context.TargetDatabaseName = "other_db"
+	require.False(t, context.IsCrossServerMigration())
+	require.Equal(t, "test", context.GetGhostDatabaseName())
+
+	context.TargetHost = "target.example.com"
+	require.True(t, context.IsCrossServerMigration())
+	require.Equal(t, "other_db", context.GetGhostDatabaseName())
+	require.Equal(t, "some_table", context.GetTargetTableName())
+	context.RenamedTableName = "other_table"
+	require.Equal(t, "other_table", context.GetTargetTableName())
+	context.TargetTableName = "target_table"
+	require.Equal(t, "target_table", context.GetTargetTableName())
+	require.Equal(t, "_some_table_gho", context.GetGhostTableName())
+}
+
 func TestGetTriggerNames(t *testing.T) {
 	{
 		context := NewMigrationContext()
@@ -85,6 +116,33 @@ func TestGetTriggerNames(t *testing.T) {
 	}
 }
 
+func TestGetGhostForeignKeyName(t *testing.T) {
+	{
+		context := NewMigrationContext()
+		context.ForeignKeySuffix = "_gho"
+		require.Equal(t, "my_fk_gho", context.GetGhostForeignKeyName("my_fk"))
+	}
+	{
+		context := NewMigrationContext()
+		context.ForeignKeySuffix = "_gho"
+		context.RemoveForeignKeySuffix = true
+		require.Equal(t, "my_fk", context.GetGhostForeignKeyName("my_fk_gho"))
+		require.Equal(t, "my_fk_gho", context.GetGhostForeignKeyName("my_fk"))
+	}
+	{
+		context := NewMigrationContext()
+		context.ForeignKeySuffix = "_gho"
+		require.Equal(t, "my_fk_gho_gho", context.GetGhostForeignKeyName("my_fk_gho"))
+	}
+}
+
+func TestGetReboundForeignKeyName(t *testing.T) {
+	context := NewMigrationContext()
+	require.Equal(t, "_child_fk", context.GetReboundForeignKeyName("child_fk"))
+	require.Equal(t, "child_fk", context.GetReboundForeignKeyName("_child_fk"))
+	require.Equal(t, "_child_fk", context.GetReboundForeignKeyName("__child_fk"))
+}
+
 func TestValidateGhostTriggerLengthBelowMaxLength(t *testing.T) {
 	{
 		context := NewMigrationContext()
@@ -121,6 +179,39 @@ func TestValidateGhostTriggerLengthBelowMaxLength(t *testing.T) {
 	}
 }
 
+func TestCopyPartitions(t *testing.T) {
+	{
+		context := NewMigrationContext()
+		context.OriginalTablePartitions = []string{"p0", "p1"}
+		require.Equal(t, "", context.GetCopyPartitionName())
+		require.False(t, context.HasNextCopyPartition())
+		require.True(t, context.IsRangeStartIteration())
+	}
+	{
+		context := NewMigrationContext()
+		context.CopyPerPartition = true
+		context.OriginalTablePartitions = []string{"p0", "p1", "p2"}
+		require.Equal(t, "p0", context.GetCopyPartitionName())
+		require.True(t, context.HasNextCopyPartition())
+		require.Equal(t, "p1", context.GetNextCopyPartitionName())
+		require.True(t, context.IsRangeStartIteration())
+
+		context.Iteration = 5
+		require.False(t, context.IsRangeStartIteration())
+		context.AdvanceCopyPartition()
+		require.True(t, context.IsRangeStartIteration())
+		require.Equal(t, "p1", context.GetCopyPartitionName())
+		ordinal, total := context.GetCopyPartitionProgress()
+		require.Equal(t, int64(2), ordinal)
+		require.Equal(t, int64(3), total)
+
+		context.AdvanceCopyPartition()
+		require.Equal(t, "p2", context.GetCopyPartitionName())
+		require.False(t, context.HasNextCopyPartition())
+		require.Equal(t, "", context.GetNextCopyPartitionName())
+	}
+}
+
 func TestReadConfigFile(t *testing.T) {
 	{
 		context := NewMigrationContext()
@@ -182,3 +273,80 @@ func TestReadConfigFile(t *testing.T) {
 		}
 	}
 }
+
+func TestReadColumnTransformations(t *testing.T) {
+	context := NewMigrationContext()
+	require.NoError(t, context.ReadColumnTransformations([]string{"email=lower(email)", "dollars = cents / 100"}))
+	require.Len(t, context.ColumnTransformations, 2)
+	require.Equal(t, "dollars", context.ColumnTransformations[1].Column)
+	require.Equal(t, "cents / 100", context.ColumnTransformations[1].Expression)
+
+	require.Error(t, context.ReadColumnTransformations([]string{"email=lower(email)", "EMAIL=upper(email)"}))
+	require.Error(t, context.ReadColumnTransformations([]string{"lower(email)"}))
+}
+
+func TestReadRowFilter(t *testing.T) {
+	context := NewMigrationContext()
+	require.NoError(t, context.ReadRowFilter(""))
+	require.Nil(t, context.RowFilter)
+
+	require.NoError(t, context.ReadRowFilter("status = 'active'"))
+	require.NotNil(t, context.RowFilter)
+	require.Equal(t, "status = 'active'", context.RowFilter.Expression)
+
+	require.Error(t, context.ReadRowFilter("   "))
+}
+
+func TestReadSideColumnNames(t *testing.T) {
+	context := NewMigrationContext()
+	require.NoError(t, context.ReadSideColumnNames(""))
+	require.Nil(t, context.SideColumnNames)
+
+	require.NoError(t, context.ReadSideColumnNames("description, payload"))
+	require.Equal(t, []string{"description", "payload"}, context.SideColumnNames)
+
+	require.Error(t, context.ReadSideColumnNames("description,,payload"))
+}
+
+func TestGetSideGhostTableName(t *testing.T) {
+	context := NewMigrationContext()
+	context.OriginalTableName = "some_table"
+	require.False(t, context.IsVerticalSplit())
+
+	context.SideTableName = "some_table_details"
+	require.True(t, context.IsVerticalSplit())
+	require.Equal(t, "_some_table_details_gho", context.GetSideGhostTableName())
+	require.Equal(t, "_some_table_gho", context.GetGhostTableName())
+}
+
+func TestReadShardTables(t *testing.T) {
+	context := NewMigrationContext()
+	require.NoError(t, context.ReadShardTables("", "hash", ""))
+	require.False(t, context.IsHorizontalSplit())
+
+	require.NoError(t, context.ReadShardTables("events_0, events_1,events_2", "range", "1000,2000"))
+	require.True(t, context.IsHorizontalSplit())
+	require.Equal(t, []string{"events_0", "events_1", "events_2"}, context.ShardTableNames)
+	require.Equal(t, sql.RangeShardMethod, context.ShardRouter.Method)
+	require.Equal(t, []int64{1000, 2000}, context.ShardRouter.RangeBounds)
+	require.Equal(t, "_events_1_gho", context.GetShardGhostTableName(1))
+
+	require.Error(t, NewMigrationContext().ReadShardTables("events_0,,events_1", "hash", ""))
+	require.Error(t, NewMigrationContext().ReadShardTables("events_0,EVENTS_0", "hash", ""))
+	require.Error(t, NewMigrationContext().ReadShardTables("events_0", "hash", ""))
+	require.Error(t, NewMigrationContext().ReadShardTables("events_0,events_1", "range", ""))
+	require.Error(t, NewMigrationContext().ReadShardTables("events_0,events_1", "modulo", ""))
+}
+
+func TestReadTargetThrottleControlReplicaKeys(t *testing.T) {
+	context := NewMigrationContext()
+	require.Equal(t, 0, context.GetTargetThrottleControlReplicaKeys().Len())
+
+	require.NoError(t, context.ReadTargetThrottleControlReplicaKeys("target-replica1:3306,target-replica2:3307"))
+	require.Equal(t, 2, context.GetTargetThrottleControlReplicaKeys().Len())
+	require.Equal(t, 0, context.GetThrottleControlReplicaKeys().Len())
+
+	context.SetTargetReplicasLagResult(nil)
+	require.NoError(t, context.GetTargetReplicasLagResult().Err)
+	require.Equal(t, time.Duration(0), context.GetTargetReplicasLagResult().Lag)
+}
@@ -0,0 +1,333 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-ini/ini"
+
+	"github.com/github/gh-ost/go/mysql"
+)
+
+const (
+	credentialHelperTimeout       = time.Minute
+	minCredentialsRefreshInterval = 10 * time.Second
+	myLoginCnfKeyLength           = 20
+)
+
+// credentials is a user/password pair read from some credentials source.
+// Empty fields are not set by that source.
+type credentials struct {
+	user     string
+	password string
+}
+
+// apply sets the non-empty fields of given credentials onto these credentials
+func (this *credentials) apply(other credentials) {
+	if other.user != "" {
+		this.user = other.user
+	}
+	if other.password != "" {
+		this.password = other.password
+	}
+}
+
+// myCnfFiles are the option files read with --read-my-cnf, in the order the mysql client reads them
+func myCnfFiles() []string {
+	files := []string{"/etc/my.cnf", "/etc/mysql/my.cnf"}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".my.cnf"))
+	}
+	return files
+}
+
+// myLoginCnfFile returns the path of the login path file, as the mysql client resolves it
+func myLoginCnfFile() (string, error) {
+	if fileName := os.Getenv("MYSQL_TEST_LOGIN_FILE"); fileName != "" {
+		return fileName, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".mylogin.cnf"), nil
+}
+
+// loadOptionFile loads a my.cnf-like option file, tolerating the mysql client's own syntax
+func loadOptionFile(source interface{}) (*ini.File, error) {
+	return ini.LoadSources(ini.LoadOptions{
+		AllowBooleanKeys:        true,
+		SkipUnrecognizableLines: true,
+	}, source)
+}
+
+// readOptionFileCredentials reads user & password off given sections of an option file.
+// Later sections override earlier ones.
+func readOptionFileCredentials(cfg *ini.File, sections ...string) (result credentials) {
+	for _, section := range sections {
+		if !cfg.HasSection(section) {
+			continue
+		}
+		result.apply(credentials{
+			user:     cfg.Section(section).Key("user").String(),
+			password: cfg.Section(section).Key("password").String(),
+		})
+	}
+	return result
+}
+
+// readMyCnfCredentials reads user & password off the [client] section of the standard
+// option files that exist
+func readMyCnfCredentials() (result credentials, err error) {
+	for _, fileName := range myCnfFiles() {
+		if _, err := os.Stat(fileName); os.IsNotExist(err) {
+			continue
+		}
+		cfg, err := loadOptionFile(fileName)
+		if err != nil {
+			return result, fmt.Errorf("Error reading %s: %+v", fileName, err)
+		}
+		result.apply(readOptionFileCredentials(cfg, "client"))
+	}
+	return result, nil
+}
+
+// decryptMyLoginCnf decrypts the content of a .mylogin.cnf file, as written by
+// mysql_config_editor: 4 unused bytes, a 20 bytes key, then length-prefixed
+// AES-128-ECB encrypted lines.
+func decryptMyLoginCnf(content []byte) ([]byte, error) {
+	if len(content) < 4+myLoginCnfKeyLength {
+		return nil, fmt.Errorf("login path file is too short")
+	}
+	key := content[4 : 4+myLoginCnfKeyLength]
+	aesKey := make([]byte, aes.BlockSize)
+	for i, b := range key {
+		aesKey[i%aes.BlockSize] ^= b
+	}
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext bytes.Buffer
+	reader := bytes.NewReader(content[4+myLoginCnfKeyLength:])
+	for {
+		var cipherLength int32
+		if err := binary.Read(reader, binary.LittleEndian, &cipherLength); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if cipherLength <= 0 || cipherLength%aes.BlockSize != 0 || int(cipherLength) > reader.Len() {
+			return nil, fmt.Errorf("login path file is corrupt")
+		}
+		line := make([]byte, cipherLength)
+		if _, err := io.ReadFull(reader, line); err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(line); i += aes.BlockSize {
+			block.Decrypt(line[i:i+aes.BlockSize], line[i:i+aes.BlockSize])
+		}
+		padding := int(line[len(line)-1])
+		if padding == 0 || padding > aes.BlockSize {
+			return nil, fmt.Errorf("login path file is corrupt")
+		}
+		plaintext.Write(line[:len(line)-padding])
+	}
+	return plaintext.Bytes(), nil
+}
+
+// readLoginPathCredentials reads user & password of given login path off .mylogin.cnf.
+// Like the mysql client, the [client] login path applies first.
+func readLoginPathCredentials(loginPath string) (result credentials, err error) {
+	fileName, err := myLoginCnfFile()
+	if err != nil {
+		return result, err
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return result, err
+	}
+	plaintext, err := decryptMyLoginCnf(content)
+	if err != nil {
+		return result, fmt.Errorf("Error reading %s: %+v", fileName, err)
+	}
+	cfg, err := loadOptionFile(plaintext)
+	if err != nil {
+		return result, fmt.Errorf("Error reading %s: %+v", fileName, err)
+	}
+	if !cfg.HasSection(loginPath) {
+		return result, fmt.Errorf("Login path %s not found in %s", loginPath, fileName)
+	}
+	return readOptionFileCredentials(cfg, "client", loginPath), nil
+}
+
+// readPasswordFile reads a password off given file. A single trailing newline is
+// not considered part of the password.
+func readPasswordFile(fileName string) (string, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", err
+	}
+	password := strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r")
+	if password == "" {
+		return "", fmt.Errorf("Password file %s is empty", fileName)
+	}
+	return password, nil
+}
+
+// runCredentialHelper executes the credential helper command for given server. Much like git's
+// credential helpers, the helper writes key=value lines to its standard output; user (or username)
+// and password are recognized. The helper's standard error goes to gh-ost's standard error.
+func runCredentialHelper(command string, role string, key mysql.InstanceKey) (result credentials, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("GH_OST_CREDENTIAL_ROLE=%s", role),
+		fmt.Sprintf("GH_OST_CREDENTIAL_HOST=%s", key.Hostname),
+		fmt.Sprintf("GH_OST_CREDENTIAL_PORT=%d", key.Port),
+	)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("Credential helper timed out after %s", credentialHelperTimeout)
+	}
+	if err != nil {
+		return result, fmt.Errorf("Credential helper failed: %+v", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		tokens := strings.SplitN(scanner.Text(), "=", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(tokens[0]) {
+		case "user", "username":
+			result.user = tokens[1]
+		case "password":
+			result.password = tokens[1]
+		}
+	}
+	if result.user == "" && result.password == "" {
+		return result, fmt.Errorf("Credential helper provided neither user nor password")
+	}
+	return result, nil
+}
+
+// RefreshCredentials re-reads credentials and TLS certificates from their sources, and applies them
+// to the inspector, applier and target connection configs. Connections opened from now on, including binlog
+// reader reconnects, use them; established connections are unaffected.
+func (this *MigrationContext) RefreshCredentials() error {
+	this.credentialsRefreshMutex.Lock()
+	defer this.credentialsRefreshMutex.Unlock()
+
+	return this.refreshCredentials()
+}
+
+// RefreshCredentialsOnAccessDenied refreshes credentials upon an access denied error. Many connections
+// may be denied at once, hence credentials are refreshed at most once per minCredentialsRefreshInterval;
+// connections denied within that interval are retried with the already refreshed credentials.
+func (this *MigrationContext) RefreshCredentialsOnAccessDenied() error {
+	this.credentialsRefreshMutex.Lock()
+	defer this.credentialsRefreshMutex.Unlock()
+
+	if time.Since(this.lastCredentialsRefresh) < minCredentialsRefreshInterval {
+		return nil
+	}
+	this.Log.Infof("Access denied; refreshing credentials")
+	return this.refreshCredentials()
+}
+
+func (this *MigrationContext) refreshCredentials() error {
+	if err := this.ReadConfigFile(); err != nil {
+		return err
+	}
+	if err := this.ApplyCredentials(); err != nil {
+		return err
+	}
+	if err := this.SetupTLS(); err != nil {
+		return err
+	}
+	if err := this.SetupBinlogConnectionConfig(); err != nil {
+		return err
+	}
+	this.lastCredentialsRefresh = time.Now()
+
+	if err := this.refreshApplierCredentials(); err != nil {
+		return err
+	}
+	return this.SetupTargetConnectionConfig()
+}
+
+// refreshApplierCredentials applies refreshed credentials and TLS certificates to the applier's
+// connection config, when it is not the inspector's
+func (this *MigrationContext) refreshApplierCredentials() error {
+	applierConnectionConfig := this.ApplierConnectionConfig
+	if applierConnectionConfig == nil || applierConnectionConfig == this.InspectorConnectionConfig || applierConnectionConfig.Key.Hostname == "" {
+		return nil
+	}
+	user, password := this.InspectorConnectionConfig.GetCredentials()
+	if this.AssumeMasterHostname != "" && !applierConnectionConfig.Key.Equals(&this.InspectorConnectionConfig.Key) {
+		masterUser, masterPassword := this.GetMasterCredentials()
+		if masterUser != "" {
+			user = masterUser
+		}
+		if masterPassword != "" {
+			password = masterPassword
+		}
+	}
+	applierConnectionConfig.SetCredentials(user, password)
+	if this.UseTLS {
+		return applierConnectionConfig.UseTLS(this.TLSCACertificate, this.TLSCertificate, this.TLSKey, this.TLSAllowInsecure)
+	}
+	return nil
+}
+
+// SetupTargetConnectionConfig sets up the connection config of the target server of a cross-server
+// migration (--target-host). Target settings not given default to the applier's: port, TLS config and
+// credentials, where the credential helper, if any, is asked for "target" role credentials first. It
+// must be called after the applier's connection config is set up, and may be called again to refresh.
+func (this *MigrationContext) SetupTargetConnectionConfig() error {
+	if !this.IsCrossServerMigration() || this.ApplierConnectionConfig == nil {
+		return nil
+	}
+	key := mysql.InstanceKey{Hostname: this.TargetHost, Port: this.ApplierConnectionConfig.Key.Port}
+	if this.TargetPort != 0 {
+		key.Port = this.TargetPort
+	}
+	if this.TargetConnectionConfig == nil {
+		this.TargetConnectionConfig = this.ApplierConnectionConfig.DuplicateCredentials(key)
+	}
+
+	var targetCredentials credentials
+	targetCredentials.user, targetCredentials.password = this.ApplierConnectionConfig.GetCredentials()
+	if this.CredentialHelper != "" && this.TargetUser == "" && this.TargetPassword == "" {
+		helperCredentials, err := runCredentialHelper(this.CredentialHelper, "target", key)
+		if err != nil {
+			return err
+		}
+		targetCredentials.apply(helperCredentials)
+	}
+	targetCredentials.apply(credentials{user: this.TargetUser, password: this.TargetPassword})
+	this.TargetConnectionConfig.SetCredentials(targetCredentials.user, targetCredentials.password)
+
+	return this.TargetConnectionConfig.CopyTLSConfig(this.ApplierConnectionConfig)
+}
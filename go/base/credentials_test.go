@@ -0,0 +1,339 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package base
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/gh-ost/go/mysql"
+)
+
+// encryptMyLoginCnf does what mysql_config_editor does, for the sake of testing
+func encryptMyLoginCnf(t *testing.T, plaintext string) []byte {
+	key := []byte("0123456789abcdefghij")
+	aesKey := make([]byte, aes.BlockSize)
+	for i, b := range key {
+		aesKey[i%aes.BlockSize] ^= b
+	}
+	block, err := aes.NewCipher(aesKey)
+	require.NoError(t, err)
+
+	var content bytes.Buffer
+	content.Write([]byte{0, 0, 0, 0})
+	content.Write(key)
+	for _, line := range strings.SplitAfter(plaintext, "\n") {
+		if line == "" {
+			continue
+		}
+		padding := aes.BlockSize - len(line)%aes.BlockSize
+		cipherLine := append([]byte(line), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		for i := 0; i < len(cipherLine); i += aes.BlockSize {
+			block.Encrypt(cipherLine[i:i+aes.BlockSize], cipherLine[i:i+aes.BlockSize])
+		}
+		require.NoError(t, binary.Write(&content, binary.LittleEndian, int32(len(cipherLine))))
+		content.Write(cipherLine)
+	}
+	return content.Bytes()
+}
+
+func writeTestFile(t *testing.T, name string, content []byte, perm os.FileMode) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(fileName, content, perm))
+	return fileName
+}
+
+func TestDecryptMyLoginCnf(t *testing.T) {
+	plaintext := "[client]\nuser = \"gromit\"\n[migrations]\npassword = \"a much longer password than a single block\"\n"
+	decrypted, err := decryptMyLoginCnf(encryptMyLoginCnf(t, plaintext))
+	require.NoError(t, err)
+	require.Equal(t, plaintext, string(decrypted))
+
+	_, err = decryptMyLoginCnf([]byte("short"))
+	require.Error(t, err)
+}
+
+func TestReadLoginPathCredentials(t *testing.T) {
+	plaintext := "[client]\nuser = \"gromit\"\npassword = \"cheese\"\n[migrations]\npassword = \"wallace\"\nhost = \"localhost\"\n"
+	t.Setenv("MYSQL_TEST_LOGIN_FILE", writeTestFile(t, ".mylogin.cnf", encryptMyLoginCnf(t, plaintext), 0600))
+
+	result, err := readLoginPathCredentials("migrations")
+	require.NoError(t, err)
+	require.Equal(t, credentials{user: "gromit", password: "wallace"}, result)
+
+	_, err = readLoginPathCredentials("no-such-login-path")
+	require.Error(t, err)
+}
+
+func TestReadMyCnfCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".my.cnf"), []byte("[client]\nuser=gromit\npassword=\"cheese\"\nskip-ssl\n[mysqld]\nuser=mysql\n"), 0600))
+
+	result, err := readMyCnfCredentials()
+	require.NoError(t, err)
+	require.Equal(t, credentials{user: "gromit", password: "cheese"}, result)
+}
+
+func TestReadPasswordFile(t *testing.T) {
+	{
+		password, err := readPasswordFile(writeTestFile(t, "password", []byte("s3cr3t\n"), 0600))
+		require.NoError(t, err)
+		require.Equal(t, "s3cr3t", password)
+	}
+	{
+		password, err := readPasswordFile(writeTestFile(t, "password", []byte(" s3cr3t \r\n"), 0600))
+		require.NoError(t, err)
+		require.Equal(t, " s3cr3t ", password)
+	}
+	{
+		_, err := readPasswordFile(writeTestFile(t, "password", []byte("\n"), 0600))
+		require.Error(t, err)
+	}
+	{
+		_, err := readPasswordFile("/does/not/exist")
+		require.Error(t, err)
+	}
+}
+
+func TestApplyCredentials(t *testing.T) {
+	helper := writeTestFile(t, "helper", []byte("#!/bin/sh\necho \"username=helper-$GH_OST_CREDENTIAL_ROLE\"\necho \"password=$GH_OST_CREDENTIAL_HOST:$GH_OST_CREDENTIAL_PORT\"\n"), 0700)
+
+	t.Run("credential helper", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		migrationContext.InspectorConnectionConfig.Key.Hostname = "replica.example.com"
+		migrationContext.InspectorConnectionConfig.Key.Port = 3306
+		migrationContext.AssumeMasterHostname = "primary.example.com:3307"
+		migrationContext.CredentialHelper = helper
+		require.NoError(t, migrationContext.ApplyCredentials())
+
+		require.Equal(t, "helper-inspector", migrationContext.InspectorConnectionConfig.User)
+		require.Equal(t, "replica.example.com:3306", migrationContext.InspectorConnectionConfig.Password)
+		user, password := migrationContext.GetMasterCredentials()
+		require.Equal(t, "helper-master", user)
+		require.Equal(t, "primary.example.com:3307", password)
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		migrationContext.AssumeMasterHostname = "primary.example.com"
+		migrationContext.CredentialHelper = helper
+		migrationContext.PasswordFile = writeTestFile(t, "password", []byte("from-file\n"), 0600)
+		migrationContext.MasterPasswordFile = writeTestFile(t, "master-password", []byte("master-from-file\n"), 0600)
+		migrationContext.CliUser = "cli-user"
+		require.NoError(t, migrationContext.ApplyCredentials())
+
+		require.Equal(t, "cli-user", migrationContext.InspectorConnectionConfig.User)
+		require.Equal(t, "from-file", migrationContext.InspectorConnectionConfig.Password)
+		user, password := migrationContext.GetMasterCredentials()
+		require.Equal(t, "helper-master", user)
+		require.Equal(t, "master-from-file", password)
+
+		migrationContext.CliPassword = "cli-password"
+		require.NoError(t, migrationContext.ApplyCredentials())
+		require.Equal(t, "cli-password", migrationContext.InspectorConnectionConfig.Password)
+	})
+
+	t.Run("failing credential helper", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		migrationContext.CredentialHelper = "exit 1"
+		require.Error(t, migrationContext.ApplyCredentials())
+
+		migrationContext.CredentialHelper = "echo nothing useful"
+		require.Error(t, migrationContext.ApplyCredentials())
+	})
+
+	t.Run("no master credentials", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		migrationContext.CliUser = "gromit"
+		require.NoError(t, migrationContext.ApplyCredentials())
+		require.Equal(t, "gromit", migrationContext.InspectorConnectionConfig.User)
+		user, password := migrationContext.GetMasterCredentials()
+		require.Empty(t, user)
+		require.Empty(t, password)
+	})
+
+	t.Run("concurrent master credentials", func(t *testing.T) {
+		// Applied master credentials are read while a refresh applies them anew, e.g. on reconnecting
+		migrationContext := NewMigrationContext()
+		migrationContext.AssumeMasterHostname = "primary.example.com"
+		migrationContext.CliMasterUser = "wallace"
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				migrationContext.GetMasterCredentials()
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, migrationContext.ApplyCredentials())
+		}
+		<-done
+		user, _ := migrationContext.GetMasterCredentials()
+		require.Equal(t, "wallace", user)
+	})
+}
+
+func TestRefreshCredentials(t *testing.T) {
+	passwordFile := writeTestFile(t, "password", []byte("first\n"), 0600)
+	masterPasswordFile := writeTestFile(t, "master-password", []byte("master-first\n"), 0600)
+
+	migrationContext := NewMigrationContext()
+	migrationContext.InspectorConnectionConfig.Key = mysql.InstanceKey{Hostname: "replica.example.com", Port: 3306}
+	migrationContext.AssumeMasterHostname = "primary.example.com"
+	migrationContext.CliUser = "gromit"
+	migrationContext.PasswordFile = passwordFile
+	migrationContext.MasterPasswordFile = masterPasswordFile
+	require.NoError(t, migrationContext.ApplyCredentials())
+	migrationContext.ApplierConnectionConfig = migrationContext.InspectorConnectionConfig.DuplicateCredentials(mysql.InstanceKey{Hostname: "primary.example.com", Port: 3306})
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0600))
+	require.NoError(t, os.WriteFile(masterPasswordFile, []byte("master-second\n"), 0600))
+	require.NoError(t, migrationContext.RefreshCredentials())
+
+	user, password := migrationContext.InspectorConnectionConfig.GetCredentials()
+	require.Equal(t, "gromit", user)
+	require.Equal(t, "second", password)
+	user, password = migrationContext.ApplierConnectionConfig.GetCredentials()
+	require.Equal(t, "gromit", user)
+	require.Equal(t, "master-second", password)
+
+	// refreshed just now; an access denied error does not refresh again
+	require.NoError(t, os.WriteFile(passwordFile, []byte("third\n"), 0600))
+	require.NoError(t, migrationContext.RefreshCredentialsOnAccessDenied())
+	_, password = migrationContext.InspectorConnectionConfig.GetCredentials()
+	require.Equal(t, "second", password)
+
+	migrationContext.lastCredentialsRefresh = time.Now().Add(-minCredentialsRefreshInterval)
+	require.NoError(t, migrationContext.RefreshCredentialsOnAccessDenied())
+	_, password = migrationContext.InspectorConnectionConfig.GetCredentials()
+	require.Equal(t, "third", password)
+
+	require.NoError(t, os.Remove(passwordFile))
+	require.Error(t, migrationContext.RefreshCredentials())
+	_, password = migrationContext.InspectorConnectionConfig.GetCredentials()
+	require.Equal(t, "third", password)
+}
+
+func TestSetupBinlogConnectionConfig(t *testing.T) {
+	t.Run("same as inspector", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		require.False(t, migrationContext.UsesSeparateBinlogConnection())
+		require.NoError(t, migrationContext.SetupBinlogConnectionConfig())
+		require.Same(t, migrationContext.InspectorConnectionConfig, migrationContext.GetBinlogConnectionConfig())
+	})
+
+	t.Run("separate binlog server", func(t *testing.T) {
+		helper := writeTestFile(t, "helper", []byte("#!/bin/sh\necho \"username=helper-$GH_OST_CREDENTIAL_ROLE\"\necho \"password=$GH_OST_CREDENTIAL_HOST\"\n"), 0700)
+		migrationContext := NewMigrationContext()
+		migrationContext.InspectorConnectionConfig.Key = mysql.InstanceKey{Hostname: "replica.example.com", Port: 3306}
+		migrationContext.CredentialHelper = helper
+		migrationContext.BinlogHost = "binlog.example.com"
+		migrationContext.BinlogPort = 3307
+		require.NoError(t, migrationContext.ApplyCredentials())
+		require.NoError(t, migrationContext.SetupBinlogConnectionConfig())
+
+		binlogConnectionConfig := migrationContext.GetBinlogConnectionConfig()
+		require.NotSame(t, migrationContext.InspectorConnectionConfig, binlogConnectionConfig)
+		require.Equal(t, mysql.InstanceKey{Hostname: "binlog.example.com", Port: 3307}, binlogConnectionConfig.Key)
+		user, password := binlogConnectionConfig.GetCredentials()
+		require.Equal(t, "helper-binlog", user)
+		require.Equal(t, "binlog.example.com", password)
+		require.Nil(t, binlogConnectionConfig.TLSConfig())
+	})
+
+	t.Run("replication user", func(t *testing.T) {
+		passwordFile := writeTestFile(t, "binlog-password", []byte("repl-password\n"), 0600)
+		migrationContext := NewMigrationContext()
+		migrationContext.InspectorConnectionConfig.Key = mysql.InstanceKey{Hostname: "replica.example.com", Port: 3306}
+		migrationContext.CliUser = "gromit"
+		migrationContext.CliPassword = "cheese"
+		migrationContext.BinlogUser = "repl"
+		migrationContext.BinlogPasswordFile = passwordFile
+		require.NoError(t, migrationContext.ApplyCredentials())
+		require.NoError(t, migrationContext.SetupBinlogConnectionConfig())
+
+		binlogConnectionConfig := migrationContext.GetBinlogConnectionConfig()
+		require.Equal(t, migrationContext.InspectorConnectionConfig.Key, binlogConnectionConfig.Key)
+		user, password := binlogConnectionConfig.GetCredentials()
+		require.Equal(t, "repl", user)
+		require.Equal(t, "repl-password", password)
+
+		// refreshing updates the same connection config
+		require.NoError(t, os.WriteFile(passwordFile, []byte("rotated\n"), 0600))
+		require.NoError(t, migrationContext.RefreshCredentials())
+		require.Same(t, binlogConnectionConfig, migrationContext.GetBinlogConnectionConfig())
+		_, password = binlogConnectionConfig.GetCredentials()
+		require.Equal(t, "rotated", password)
+		user, _ = migrationContext.InspectorConnectionConfig.GetCredentials()
+		require.Equal(t, "gromit", user)
+	})
+}
+
+func TestSetupTargetConnectionConfig(t *testing.T) {
+	t.Run("no target", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		migrationContext.ApplierConnectionConfig = migrationContext.InspectorConnectionConfig
+		require.False(t, migrationContext.IsCrossServerMigration())
+		require.NoError(t, migrationContext.SetupTargetConnectionConfig())
+		require.Nil(t, migrationContext.TargetConnectionConfig)
+	})
+
+	t.Run("same credentials as master", func(t *testing.T) {
+		migrationContext := NewMigrationContext()
+		migrationContext.InspectorConnectionConfig.Key = mysql.InstanceKey{Hostname: "replica.example.com", Port: 3306}
+		migrationContext.CliUser = "gromit"
+		migrationContext.CliPassword = "cheese"
+		migrationContext.TargetHost = "target.example.com"
+		require.NoError(t, migrationContext.ApplyCredentials())
+		migrationContext.ApplierConnectionConfig = migrationContext.InspectorConnectionConfig.DuplicateCredentials(mysql.InstanceKey{Hostname: "primary.example.com", Port: 3307})
+		require.NoError(t, migrationContext.SetupTargetConnectionConfig())
+
+		targetConnectionConfig := migrationContext.TargetConnectionConfig
+		require.Equal(t, mysql.InstanceKey{Hostname: "target.example.com", Port: 3307}, targetConnectionConfig.Key)
+		user, password := targetConnectionConfig.GetCredentials()
+		require.Equal(t, "gromit", user)
+		require.Equal(t, "cheese", password)
+		require.Equal(t, "target.example.com", migrationContext.GetTargetHostname())
+	})
+
+	t.Run("target credentials", func(t *testing.T) {
+		helper := writeTestFile(t, "helper", []byte("#!/bin/sh\necho \"username=helper-$GH_OST_CREDENTIAL_ROLE\"\necho \"password=$GH_OST_CREDENTIAL_HOST\"\n"), 0700)
+		migrationContext := NewMigrationContext()
+		migrationContext.InspectorConnectionConfig.Key = mysql.InstanceKey{Hostname: "replica.example.com", Port: 3306}
+		migrationContext.CliUser = "gromit"
+		migrationContext.CliPassword = "cheese"
+		migrationContext.CredentialHelper = helper
+		migrationContext.TargetHost = "target.example.com"
+		migrationContext.TargetPort = 3308
+		require.NoError(t, migrationContext.ApplyCredentials())
+		migrationContext.ApplierConnectionConfig = migrationContext.InspectorConnectionConfig.DuplicateCredentials(mysql.InstanceKey{Hostname: "primary.example.com", Port: 3306})
+		require.NoError(t, migrationContext.SetupTargetConnectionConfig())
+
+		targetConnectionConfig := migrationContext.TargetConnectionConfig
+		require.Equal(t, mysql.InstanceKey{Hostname: "target.example.com", Port: 3308}, targetConnectionConfig.Key)
+		user, password := targetConnectionConfig.GetCredentials()
+		require.Equal(t, "helper-target", user)
+		require.Equal(t, "target.example.com", password)
+
+		// given target credentials override the helper's, and refreshing updates the same connection config
+		migrationContext.TargetUser = "wallace"
+		migrationContext.TargetPassword = "wensleydale"
+		require.NoError(t, migrationContext.RefreshCredentials())
+		require.Same(t, targetConnectionConfig, migrationContext.TargetConnectionConfig)
+		user, password = targetConnectionConfig.GetCredentials()
+		require.Equal(t, "wallace", user)
+		require.Equal(t, "wensleydale", password)
+	})
+}
@@ -0,0 +1,222 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// BinlogDDLEvent is a binary log entry indicating a change in a table's structure: either a DDL
+// statement affecting the table, or the table's number of columns as seen on a table map event
+type BinlogDDLEvent struct {
+	DatabaseName string
+	TableName    string
+	Statement    string
+	ColumnCount  int
+}
+
+func NewBinlogDDLEvent(databaseName, tableName, statement string) *BinlogDDLEvent {
+	event := &BinlogDDLEvent{
+		DatabaseName: databaseName,
+		TableName:    tableName,
+		Statement:    statement,
+	}
+	return event
+}
+
+func NewBinlogColumnCountEvent(databaseName, tableName string, columnCount int) *BinlogDDLEvent {
+	event := &BinlogDDLEvent{
+		DatabaseName: databaseName,
+		TableName:    tableName,
+		ColumnCount:  columnCount,
+	}
+	return event
+}
+
+// IsColumnCount is true when this event reports a table's number of columns rather than a DDL statement
+func (this *BinlogDDLEvent) IsColumnCount() bool {
+	return this.Statement == ""
+}
+
+func (this *BinlogDDLEvent) String() string {
+	if this.IsColumnCount() {
+		return fmt.Sprintf("[%d columns on %s:%s]", this.ColumnCount, this.DatabaseName, this.TableName)
+	}
+	return fmt.Sprintf("[%s on %s:%s]", this.Statement, this.DatabaseName, this.TableName)
+}
+
+// ddlToken is a token of a DDL statement: a keyword or an identifier, or punctuation
+type ddlToken struct {
+	text   string
+	quoted bool
+}
+
+func (this ddlToken) is(keywords ...string) bool {
+	if this.quoted {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(this.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// isName is true when this token is an identifier or a keyword, rather than punctuation
+func (this ddlToken) isName() bool {
+	if this.quoted {
+		return true
+	}
+	for _, r := range this.text {
+		return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return false
+}
+
+// tokenizeDDLStatement splits given statement into tokens, skipping comments and string literals
+func tokenizeDDLStatement(statement string) (tokens []ddlToken) {
+	runes := []rune(statement)
+	isIdentifierRune := func(r rune) bool {
+		return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' {
+					i++
+				} else if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+					} else {
+						break
+					}
+				}
+			}
+			i++
+		case r == '`':
+			var identifier strings.Builder
+			for i++; i < len(runes); i++ {
+				if runes[i] == '`' {
+					if i+1 < len(runes) && runes[i+1] == '`' {
+						i++
+					} else {
+						break
+					}
+				}
+				identifier.WriteRune(runes[i])
+			}
+			i++
+			tokens = append(tokens, ddlToken{text: identifier.String(), quoted: true})
+		case r == '#' || (r == '-' && i+2 < len(runes) && runes[i+1] == '-' && unicode.IsSpace(runes[i+2])):
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/'); i++ {
+			}
+			i++
+		case isIdentifierRune(r):
+			start := i
+			for ; i < len(runes) && isIdentifierRune(runes[i]); i++ {
+			}
+			tokens = append(tokens, ddlToken{text: string(runes[start:i])})
+		default:
+			tokens = append(tokens, ddlToken{text: string(r)})
+			i++
+		}
+	}
+	return tokens
+}
+
+// ParseDDLStatement returns the DDL events of given statement, one per table the statement alters,
+// truncates, drops, renames or creates or drops an index on. Tables not qualified by a database name
+// are in given default database. Statements issued by gh-ost itself, as well as any other statement,
+// result in no events.
+func ParseDDLStatement(defaultDatabaseName, statement string) (events []*BinlogDDLEvent) {
+	if strings.Contains(statement, "/* gh-ost */") {
+		return nil
+	}
+	tokens := tokenizeDDLStatement(statement)
+	pos := 0
+	peek := func() ddlToken {
+		if pos < len(tokens) {
+			return tokens[pos]
+		}
+		return ddlToken{}
+	}
+	skip := func(keywords ...string) bool {
+		if peek().is(keywords...) {
+			pos++
+			return true
+		}
+		return false
+	}
+	readTable := func() bool {
+		if !peek().isName() {
+			return false
+		}
+		databaseName, tableName := defaultDatabaseName, tokens[pos].text
+		pos++
+		if peek().is(".") && pos+1 < len(tokens) && tokens[pos+1].isName() {
+			databaseName, tableName = tableName, tokens[pos+1].text
+			pos += 2
+		}
+		events = append(events, NewBinlogDDLEvent(databaseName, tableName, statement))
+		return true
+	}
+	readTables := func(separator string) {
+		for readTable() && (skip(",") || (separator != "" && skip(separator))) {
+		}
+	}
+	switch {
+	case skip("alter"):
+		skip("online", "offline")
+		skip("ignore")
+		if skip("table") {
+			readTable()
+		}
+	case skip("truncate"):
+		skip("table")
+		readTable()
+	case skip("drop"):
+		if skip("table", "tables") {
+			if skip("if") {
+				skip("exists")
+			}
+			readTables("")
+		} else if skip("index") {
+			for pos < len(tokens) && !skip("on") {
+				pos++
+			}
+			readTable()
+		}
+	case skip("rename"):
+		if skip("table", "tables") {
+			readTables("to")
+		}
+	case skip("create"):
+		skip("unique", "fulltext", "spatial")
+		if skip("table") {
+			if skip("if") {
+				skip("not")
+				skip("exists")
+			}
+			readTable()
+		} else if skip("index") {
+			for pos < len(tokens) && !skip("on") {
+				pos++
+			}
+			readTable()
+		}
+	}
+	return events
+}
@@ -0,0 +1,49 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parsedDDLTables(t *testing.T, defaultDatabaseName, statement string) (tables []string) {
+	for _, event := range ParseDDLStatement(defaultDatabaseName, statement) {
+		require.Equal(t, statement, event.Statement)
+		tables = append(tables, event.DatabaseName+"."+event.TableName)
+	}
+	return tables
+}
+
+func TestParseDDLStatement(t *testing.T) {
+	require.Equal(t, []string{"test.tbl"}, parsedDDLTables(t, "test", "ALTER TABLE tbl ADD COLUMN c INT"))
+	require.Equal(t, []string{"other.tbl"}, parsedDDLTables(t, "test", "alter online ignore table `other`.`tbl` drop column c"))
+	require.Equal(t, []string{"test.weird`tbl"}, parsedDDLTables(t, "test", "/* maintenance */ alter table `weird``tbl` engine=innodb"))
+	require.Equal(t, []string{"test.tbl"}, parsedDDLTables(t, "test", "truncate tbl"))
+	require.Equal(t, []string{"test.tbl"}, parsedDDLTables(t, "test", "TRUNCATE TABLE tbl"))
+	require.Equal(t, []string{"test.a", "other.b"}, parsedDDLTables(t, "test", "DROP TABLE IF EXISTS `a`,`other`.`b` /* generated by server */"))
+	require.Equal(t, []string{"test.a", "test.b", "test.c", "other.d"}, parsedDDLTables(t, "test", "rename table a to b, c to other.d"))
+	require.Equal(t, []string{"test.tbl"}, parsedDDLTables(t, "test", "create unique index name_idx on tbl (name)"))
+	require.Equal(t, []string{"test.tbl"}, parsedDDLTables(t, "test", "drop index `on` on tbl"))
+	require.Equal(t, []string{"test.tbl"}, parsedDDLTables(t, "test", "create table if not exists tbl (id int primary key)"))
+}
+
+func TestParseDDLStatementIgnored(t *testing.T) {
+	require.Empty(t, ParseDDLStatement("test", "BEGIN"))
+	require.Empty(t, ParseDDLStatement("test", "insert into tbl values (1)"))
+	require.Empty(t, ParseDDLStatement("test", "alter /* gh-ost */ table `test`.`_tbl_gho` add column c int"))
+	require.Empty(t, ParseDDLStatement("test", "drop temporary table if exists tbl"))
+	require.Empty(t, ParseDDLStatement("test", "create temporary table tbl (id int)"))
+	require.Empty(t, ParseDDLStatement("test", "alter database test character set utf8mb4"))
+	require.Empty(t, ParseDDLStatement("test", "create database tbl"))
+}
+
+func TestBinlogDDLEventString(t *testing.T) {
+	require.Equal(t, "[truncate tbl on test:tbl]", NewBinlogDDLEvent("test", "tbl", "truncate tbl").String())
+	require.Equal(t, "[3 columns on test:tbl]", NewBinlogColumnCountEvent("test", "tbl", 3).String())
+	require.True(t, NewBinlogColumnCountEvent("test", "tbl", 3).IsColumnCount())
+}
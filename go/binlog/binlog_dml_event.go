@@ -8,7 +8,9 @@ package binlog
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/github/gh-ost/go/mysql"
 	"github.com/github/gh-ost/go/sql"
 )
 
@@ -44,13 +46,20 @@ func ToEventDML(description string) EventDML {
 	return NotDML
 }
 
-// BinlogDMLEvent is a binary log rows (DML) event entry, with data
+// BinlogDMLEvent is a binary log rows (DML) event entry, with data.
+// With binlog_row_image=MINIMAL or NOBLOB, row images may lack columns: WhereColumnsPresent and
+// NewColumnsPresent then tell which columns the values hold. They are nil for complete row images.
+// Coordinates and Timestamp are those of the rows event holding the row change.
 type BinlogDMLEvent struct {
-	DatabaseName      string
-	TableName         string
-	DML               EventDML
-	WhereColumnValues *sql.ColumnValues
-	NewColumnValues   *sql.ColumnValues
+	DatabaseName        string
+	TableName           string
+	DML                 EventDML
+	Coordinates         mysql.BinlogCoordinates
+	Timestamp           time.Time
+	WhereColumnValues   *sql.ColumnValues
+	NewColumnValues     *sql.ColumnValues
+	WhereColumnsPresent []bool
+	NewColumnsPresent   []bool
 }
 
 func NewBinlogDMLEvent(databaseName, tableName string, dml EventDML) *BinlogDMLEvent {
@@ -65,3 +74,44 @@ func NewBinlogDMLEvent(databaseName, tableName string, dml EventDML) *BinlogDMLE
 func (this *BinlogDMLEvent) String() string {
 	return fmt.Sprintf("[%+v on %s:%s]", this.DML, this.DatabaseName, this.TableName)
 }
+
+// ColumnsPresence returns which columns of a row image given column bitmap (as found on rows events)
+// marks as present, or nil when all columns are present
+func ColumnsPresence(bitmap []byte, columnCount int) []bool {
+	presence := make([]bool, columnCount)
+	isComplete := true
+	for i := range presence {
+		presence[i] = i/8 < len(bitmap) && bitmap[i/8]&(1<<uint(i%8)) != 0
+		isComplete = isComplete && presence[i]
+	}
+	if isComplete {
+		return nil
+	}
+	return presence
+}
+
+// IsColumnPresent is true when given presence, as returned by ColumnsPresence, includes given column ordinal
+func IsColumnPresent(presence []bool, ordinal int) bool {
+	return presence == nil || (ordinal < len(presence) && presence[ordinal])
+}
+
+// completeNewColumnValues fills the columns an UPDATE's partial after image lacks with their values
+// in the before image: a column missing from the after image is unchanged
+func (this *BinlogDMLEvent) completeNewColumnValues() {
+	if this.NewColumnsPresent == nil {
+		return
+	}
+	whereValues := this.WhereColumnValues.AbstractValues()
+	newValues := this.NewColumnValues.AbstractValues()
+	isComplete := true
+	for i := range newValues {
+		if !this.NewColumnsPresent[i] && IsColumnPresent(this.WhereColumnsPresent, i) {
+			newValues[i] = whereValues[i]
+			this.NewColumnsPresent[i] = true
+		}
+		isComplete = isComplete && this.NewColumnsPresent[i]
+	}
+	if isComplete {
+		this.NewColumnsPresent = nil
+	}
+}
@@ -0,0 +1,47 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"testing"
+
+	"github.com/github/gh-ost/go/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnsPresence(t *testing.T) {
+	require.Nil(t, ColumnsPresence([]byte{0x07}, 3))
+	require.Nil(t, ColumnsPresence([]byte{0xff, 0x01}, 9))
+	require.Equal(t, []bool{true, false, true}, ColumnsPresence([]byte{0x05}, 3))
+	require.Equal(t, []bool{true, true, true, true, true, true, true, true, false}, ColumnsPresence([]byte{0xff, 0x00}, 9))
+
+	require.True(t, IsColumnPresent(nil, 2))
+	require.True(t, IsColumnPresent([]bool{true, false, true}, 2))
+	require.False(t, IsColumnPresent([]bool{true, false, true}, 1))
+}
+
+func TestCompleteNewColumnValues(t *testing.T) {
+	t.Run("minimal", func(t *testing.T) {
+		event := NewBinlogDMLEvent("test", "tbl", UpdateDML)
+		event.WhereColumnValues = sql.ToColumnValues([]interface{}{3, nil, nil})
+		event.WhereColumnsPresent = []bool{true, false, false}
+		event.NewColumnValues = sql.ToColumnValues([]interface{}{nil, nil, "changed"})
+		event.NewColumnsPresent = []bool{false, false, true}
+		event.completeNewColumnValues()
+		require.Equal(t, []interface{}{3, nil, "changed"}, event.NewColumnValues.AbstractValues())
+		require.Equal(t, []bool{true, false, true}, event.NewColumnsPresent)
+	})
+
+	t.Run("complete before image", func(t *testing.T) {
+		event := NewBinlogDMLEvent("test", "tbl", UpdateDML)
+		event.WhereColumnValues = sql.ToColumnValues([]interface{}{3, "unchanged", "original"})
+		event.NewColumnValues = sql.ToColumnValues([]interface{}{nil, nil, "changed"})
+		event.NewColumnsPresent = []bool{false, false, true}
+		event.completeNewColumnValues()
+		require.Equal(t, []interface{}{3, "unchanged", "changed"}, event.NewColumnValues.AbstractValues())
+		require.Nil(t, event.NewColumnsPresent)
+	})
+}
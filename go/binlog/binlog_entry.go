@@ -16,6 +16,7 @@ type BinlogEntry struct {
 	EndLogPos   uint64
 
 	DmlEvent *BinlogDMLEvent
+	DdlEvent *BinlogDDLEvent
 }
 
 // NewBinlogEntry creates an empty, ready to go BinlogEntry object
@@ -43,5 +44,8 @@ func (this *BinlogEntry) Duplicate() *BinlogEntry {
 
 // String() returns a string representation of this binlog entry
 func (this *BinlogEntry) String() string {
+	if this.DdlEvent != nil {
+		return fmt.Sprintf("[BinlogEntry at %+v; ddl:%+v]", this.Coordinates, this.DdlEvent)
+	}
 	return fmt.Sprintf("[BinlogEntry at %+v; dml:%+v]", this.Coordinates, this.DmlEvent)
 }
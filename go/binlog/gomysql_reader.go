@@ -28,22 +28,26 @@ type GoMySQLReader struct {
 	currentCoordinates       mysql.BinlogCoordinates
 	currentCoordinatesMutex  *sync.Mutex
 	LastAppliedRowsEventHint mysql.BinlogCoordinates
+	tableColumnCounts        map[string]int
+	payloadDecoder           *TransactionPayloadDecoder
 }
 
 func NewGoMySQLReader(migrationContext *base.MigrationContext) *GoMySQLReader {
-	connectionConfig := migrationContext.InspectorConnectionConfig
+	connectionConfig := migrationContext.GetBinlogConnectionConfig()
+	user, password := connectionConfig.GetCredentials()
 	return &GoMySQLReader{
 		migrationContext:        migrationContext,
 		connectionConfig:        connectionConfig,
 		currentCoordinates:      mysql.BinlogCoordinates{},
 		currentCoordinatesMutex: &sync.Mutex{},
+		tableColumnCounts:       make(map[string]int),
 		binlogSyncer: replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
 			ServerID:                uint32(migrationContext.ReplicaServerId),
-			Flavor:                  gomysql.MySQLFlavor,
+			Flavor:                  mysql.BinlogFlavorFor(migrationContext.BinlogMySQLVersion),
 			Host:                    connectionConfig.Key.Hostname,
 			Port:                    uint16(connectionConfig.Key.Port),
-			User:                    connectionConfig.User,
-			Password:                connectionConfig.Password,
+			User:                    user,
+			Password:                password,
 			TLSConfig:               connectionConfig.TLSConfig(),
 			UseDecimal:              true,
 			MaxReconnectAttempts:    migrationContext.BinlogSyncerMaxReconnectAttempts,
@@ -87,10 +91,20 @@ func (this *GoMySQLReader) handleRowsEvent(ev *replication.BinlogEvent, rowsEven
 		return nil
 	}
 
+	if err := this.emitRowsEvent(ev, rowsEvent, entriesChannel); err != nil {
+		return err
+	}
+	this.LastAppliedRowsEventHint = this.currentCoordinates
+	return nil
+}
+
+// emitRowsEvent emits a DML event for each row change of given rows event, at current coordinates
+func (this *GoMySQLReader) emitRowsEvent(ev *replication.BinlogEvent, rowsEvent *replication.RowsEvent, entriesChannel chan<- *BinlogEntry) error {
 	dml := ToEventDML(ev.Header.EventType.String())
 	if dml == NotDML {
 		return fmt.Errorf("Unknown DML type: %s", ev.Header.EventType.String())
 	}
+	columnCount := int(rowsEvent.ColumnCount)
 	for i, row := range rowsEvent.Rows {
 		if dml == UpdateDML && i%2 == 1 {
 			// An update has two rows (WHERE+SET)
@@ -103,19 +117,26 @@ func (this *GoMySQLReader) handleRowsEvent(ev *replication.BinlogEvent, rowsEven
 			string(rowsEvent.Table.Table),
 			dml,
 		)
+		binlogEntry.DmlEvent.Coordinates = this.currentCoordinates
+		binlogEntry.DmlEvent.Timestamp = time.Unix(int64(ev.Header.Timestamp), 0)
 		switch dml {
 		case InsertDML:
 			{
 				binlogEntry.DmlEvent.NewColumnValues = sql.ToColumnValues(row)
+				binlogEntry.DmlEvent.NewColumnsPresent = ColumnsPresence(rowsEvent.ColumnBitmap1, columnCount)
 			}
 		case UpdateDML:
 			{
 				binlogEntry.DmlEvent.WhereColumnValues = sql.ToColumnValues(row)
 				binlogEntry.DmlEvent.NewColumnValues = sql.ToColumnValues(rowsEvent.Rows[i+1])
+				binlogEntry.DmlEvent.WhereColumnsPresent = ColumnsPresence(rowsEvent.ColumnBitmap1, columnCount)
+				binlogEntry.DmlEvent.NewColumnsPresent = ColumnsPresence(rowsEvent.ColumnBitmap2, columnCount)
+				binlogEntry.DmlEvent.completeNewColumnValues()
 			}
 		case DeleteDML:
 			{
 				binlogEntry.DmlEvent.WhereColumnValues = sql.ToColumnValues(row)
+				binlogEntry.DmlEvent.WhereColumnsPresent = ColumnsPresence(rowsEvent.ColumnBitmap1, columnCount)
 			}
 		}
 		// The channel will do the throttling. Whoever is reading from the channel
@@ -124,6 +145,93 @@ func (this *GoMySQLReader) handleRowsEvent(ev *replication.BinlogEvent, rowsEven
 		// In reality, reads will be synchronous
 		entriesChannel <- binlogEntry
 	}
+	return nil
+}
+
+// handleQueryEvent emits a DDL event for each table affected by a DDL statement
+func (this *GoMySQLReader) handleQueryEvent(queryEvent *replication.QueryEvent, entriesChannel chan<- *BinlogEntry) {
+	if this.currentCoordinates.SmallerThanOrEquals(&this.LastAppliedRowsEventHint) {
+		this.migrationContext.Log.Debugf("Skipping handled query at %+v", this.currentCoordinates)
+		return
+	}
+	ddlEvents := ParseDDLStatement(string(queryEvent.Schema), string(queryEvent.Query))
+	if len(ddlEvents) == 0 {
+		return
+	}
+	for _, ddlEvent := range ddlEvents {
+		binlogEntry := NewBinlogEntryAt(this.currentCoordinates)
+		binlogEntry.DdlEvent = ddlEvent
+		entriesChannel <- binlogEntry
+	}
+	this.LastAppliedRowsEventHint = this.currentCoordinates
+}
+
+// handleTableMapEvent emits a column count event for a table first seen, or seen with a different
+// number of columns than before, which indicates its structure has changed
+func (this *GoMySQLReader) handleTableMapEvent(tableMapEvent *replication.TableMapEvent, entriesChannel chan<- *BinlogEntry) {
+	if this.currentCoordinates.SmallerThanOrEquals(&this.LastAppliedRowsEventHint) {
+		return
+	}
+	this.emitTableColumnCount(tableMapEvent, entriesChannel)
+}
+
+// emitTableColumnCount emits a column count event, at current coordinates, for the table of given
+// table map event when first seen or seen with a different number of columns than before
+func (this *GoMySQLReader) emitTableColumnCount(tableMapEvent *replication.TableMapEvent, entriesChannel chan<- *BinlogEntry) {
+	databaseName, tableName := string(tableMapEvent.Schema), string(tableMapEvent.Table)
+	columnCount := int(tableMapEvent.ColumnCount)
+	key := fmt.Sprintf("%s.%s", databaseName, tableName)
+	if previousColumnCount, found := this.tableColumnCounts[key]; found && previousColumnCount == columnCount {
+		return
+	}
+	this.tableColumnCounts[key] = columnCount
+
+	binlogEntry := NewBinlogEntryAt(this.currentCoordinates)
+	binlogEntry.DdlEvent = NewBinlogColumnCountEvent(databaseName, tableName, columnCount)
+	entriesChannel <- binlogEntry
+}
+
+// handleFormatDescriptionEvent sets up decoding of transaction payload events, which needs the
+// binlog's format description to parse the events held within
+func (this *GoMySQLReader) handleFormatDescriptionEvent(ev *replication.BinlogEvent) error {
+	payloadDecoder, err := NewTransactionPayloadDecoder(ev, mysql.BinlogFlavorFor(this.migrationContext.BinlogMySQLVersion), true, time.UTC)
+	if err != nil {
+		return err
+	}
+	if this.payloadDecoder != nil {
+		this.payloadDecoder.Close()
+	}
+	this.payloadDecoder = payloadDecoder
+	return nil
+}
+
+// handleTransactionPayloadEvent handles the events of a compressed transaction (binlog_transaction_compression).
+// All of these events are at the coordinates of the payload event, hence they are handled altogether.
+func (this *GoMySQLReader) handleTransactionPayloadEvent(ev *replication.BinlogEvent, payloadEvent *replication.GenericEvent, entriesChannel chan<- *BinlogEntry) error {
+	if this.currentCoordinates.IsLogPosOverflowBeyond4Bytes(&this.LastAppliedRowsEventHint) {
+		return fmt.Errorf("Unexpected transaction payload event at %+v, the binlog end_log_pos is overflow 4 bytes", this.currentCoordinates)
+	}
+	if this.currentCoordinates.SmallerThanOrEquals(&this.LastAppliedRowsEventHint) {
+		this.migrationContext.Log.Debugf("Skipping handled transaction payload at %+v", this.currentCoordinates)
+		return nil
+	}
+	if this.payloadDecoder == nil {
+		return fmt.Errorf("Unexpected transaction payload event at %+v, before any format description event", this.currentCoordinates)
+	}
+	events, err := this.payloadDecoder.Decode(payloadEvent.Data)
+	if err != nil {
+		return fmt.Errorf("Cannot decode transaction payload event at %+v: %+v", this.currentCoordinates, err)
+	}
+	for _, payloadEv := range events {
+		switch binlogEvent := payloadEv.Event.(type) {
+		case *replication.TableMapEvent:
+			this.emitTableColumnCount(binlogEvent, entriesChannel)
+		case *replication.RowsEvent:
+			if err := this.emitRowsEvent(payloadEv, binlogEvent, entriesChannel); err != nil {
+				return err
+			}
+		}
+	}
 	this.LastAppliedRowsEventHint = this.currentCoordinates
 	return nil
 }
@@ -156,10 +264,24 @@ func (this *GoMySQLReader) StreamEvents(canStopStreaming func() bool, entriesCha
 				this.currentCoordinates.LogFile = string(binlogEvent.NextLogName)
 			}()
 			this.migrationContext.Log.Infof("rotate to next log from %s:%d to %s", this.currentCoordinates.LogFile, int64(ev.Header.LogPos), binlogEvent.NextLogName)
+		case *replication.FormatDescriptionEvent:
+			if err := this.handleFormatDescriptionEvent(ev); err != nil {
+				return err
+			}
+		case *replication.QueryEvent:
+			this.handleQueryEvent(binlogEvent, entriesChannel)
+		case *replication.TableMapEvent:
+			this.handleTableMapEvent(binlogEvent, entriesChannel)
 		case *replication.RowsEvent:
 			if err := this.handleRowsEvent(ev, binlogEvent, entriesChannel); err != nil {
 				return err
 			}
+		case *replication.GenericEvent:
+			if ev.Header.EventType == TransactionPayloadEventType {
+				if err := this.handleTransactionPayloadEvent(ev, binlogEvent, entriesChannel); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	this.migrationContext.Log.Debugf("done streaming events")
@@ -169,5 +291,8 @@ func (this *GoMySQLReader) StreamEvents(canStopStreaming func() bool, entriesCha
 
 func (this *GoMySQLReader) Close() error {
 	this.binlogSyncer.Close()
+	if this.payloadDecoder != nil {
+		this.payloadDecoder.Close()
+	}
 	return nil
 }
@@ -0,0 +1,149 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TransactionPayloadEventType is the type of the events MySQL 8.0.20+ writes with
+// binlog_transaction_compression enabled, each holding a transaction's (compressed) events.
+// go-mysql does not know this event, and hands it over as a replication.GenericEvent
+const TransactionPayloadEventType replication.EventType = 40
+
+// Transaction payload header field types and compression types, see MySQL's
+// libbinlogevents/include/compression/base.h and binlog_event.h
+const (
+	transactionPayloadHeaderEndMark             = 0
+	transactionPayloadSizeField                 = 1
+	transactionPayloadCompressionTypeField      = 2
+	transactionPayloadUncompressedSizeField     = 3
+	transactionPayloadCompressionTypeZstd       = 0
+	transactionPayloadCompressionTypeNone       = 255
+	transactionPayloadMaxUncompressedEventsSize = 1024 * 1024 * 1024
+)
+
+// TransactionPayloadDecoder decodes transaction payload events into the events they hold
+type TransactionPayloadDecoder struct {
+	parser      *replication.BinlogParser
+	zstdDecoder *zstd.Decoder
+}
+
+// NewTransactionPayloadDecoder creates a decoder parsing the events held in transaction payload
+// events the way given format description event describes, with given parser settings
+func NewTransactionPayloadDecoder(formatDescriptionEvent *replication.BinlogEvent, flavor string, useDecimal bool, timestampStringLocation *time.Location) (*TransactionPayloadDecoder, error) {
+	zstdDecoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(transactionPayloadMaxUncompressedEventsSize))
+	if err != nil {
+		return nil, err
+	}
+	parser := replication.NewBinlogParser()
+	parser.SetFlavor(flavor)
+	parser.SetUseDecimal(useDecimal)
+	parser.SetTimestampStringLocation(timestampStringLocation)
+
+	// Events within a payload never carry a checksum, whatever the binlog's checksum algorithm
+	rawData := make([]byte, len(formatDescriptionEvent.RawData))
+	copy(rawData, formatDescriptionEvent.RawData)
+	if event, ok := formatDescriptionEvent.Event.(*replication.FormatDescriptionEvent); ok && event.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32 {
+		rawData[len(rawData)-replication.BinlogChecksumLength-1] = replication.BINLOG_CHECKSUM_ALG_OFF
+	}
+	if _, err := parser.Parse(rawData); err != nil {
+		zstdDecoder.Close()
+		return nil, fmt.Errorf("Cannot parse format description event: %+v", err)
+	}
+	return &TransactionPayloadDecoder{
+		parser:      parser,
+		zstdDecoder: zstdDecoder,
+	}, nil
+}
+
+// readTransactionPayloadHeader reads the header fields of a transaction payload event's data,
+// returning the compression type and the (compressed) payload that follows the header
+func readTransactionPayloadHeader(data []byte) (compressionType uint64, payload []byte, err error) {
+	readPackedInt := func(pos int) (uint64, int, error) {
+		value, _, n := gomysql.LengthEncodedInt(data[pos:])
+		if n == 0 || pos+n > len(data) {
+			return 0, 0, fmt.Errorf("Truncated transaction payload header at %d", pos)
+		}
+		return value, pos + n, nil
+	}
+	compressionType = transactionPayloadCompressionTypeNone
+	payloadSize := uint64(len(data))
+	pos := 0
+	for pos < len(data) {
+		fieldType, next, err := readPackedInt(pos)
+		if err != nil {
+			return compressionType, nil, err
+		}
+		pos = next
+		if fieldType == transactionPayloadHeaderEndMark {
+			break
+		}
+		fieldLength, next, err := readPackedInt(pos)
+		if err != nil {
+			return compressionType, nil, err
+		}
+		pos = next
+		switch fieldType {
+		case transactionPayloadSizeField:
+			if payloadSize, _, err = readPackedInt(pos); err != nil {
+				return compressionType, nil, err
+			}
+		case transactionPayloadCompressionTypeField:
+			if compressionType, _, err = readPackedInt(pos); err != nil {
+				return compressionType, nil, err
+			}
+		}
+		pos += int(fieldLength)
+	}
+	if pos > len(data) || uint64(len(data)-pos) < payloadSize {
+		return compressionType, nil, fmt.Errorf("Truncated transaction payload: expected %d bytes, got %d", payloadSize, len(data)-pos)
+	}
+	return compressionType, data[pos : pos+int(payloadSize)], nil
+}
+
+// Decode returns the events held in given transaction payload event data
+func (this *TransactionPayloadDecoder) Decode(data []byte) (events []*replication.BinlogEvent, err error) {
+	compressionType, payload, err := readTransactionPayloadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	switch compressionType {
+	case transactionPayloadCompressionTypeZstd:
+		if payload, err = this.zstdDecoder.DecodeAll(payload, nil); err != nil {
+			return nil, fmt.Errorf("Cannot decompress transaction payload: %+v", err)
+		}
+	case transactionPayloadCompressionTypeNone:
+	default:
+		return nil, fmt.Errorf("Unsupported transaction payload compression type: %d", compressionType)
+	}
+	for len(payload) > 0 {
+		if len(payload) < replication.EventHeaderSize {
+			return events, fmt.Errorf("Truncated event in transaction payload: %d bytes", len(payload))
+		}
+		eventSize := int(binary.LittleEndian.Uint32(payload[9:13]))
+		if eventSize < replication.EventHeaderSize || eventSize > len(payload) {
+			return events, fmt.Errorf("Invalid event size in transaction payload: %d, %d bytes remaining", eventSize, len(payload))
+		}
+		event, err := this.parser.Parse(payload[:eventSize])
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+		payload = payload[eventSize:]
+	}
+	return events, nil
+}
+
+func (this *TransactionPayloadDecoder) Close() {
+	this.zstdDecoder.Close()
+}
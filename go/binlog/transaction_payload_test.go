@@ -0,0 +1,124 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package binlog
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func testRawEvent(eventType replication.EventType, body []byte) []byte {
+	header := make([]byte, replication.EventHeaderSize)
+	header[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(header[9:], uint32(replication.EventHeaderSize+len(body)))
+	return append(header, body...)
+}
+
+// testFormatDescriptionEvent returns a MySQL 8.0 format description event, with CRC32 checksums
+func testFormatDescriptionEvent(t *testing.T) *replication.BinlogEvent {
+	body := []byte{4, 0}
+	serverVersion := make([]byte, 50)
+	copy(serverVersion, "8.0.36")
+	body = append(body, serverVersion...)
+	body = append(body, 0, 0, 0, 0, replication.EventHeaderSize)
+	postHeaderLengths := make([]byte, 40)
+	postHeaderLengths[replication.TABLE_MAP_EVENT-1] = 8
+	postHeaderLengths[replication.WRITE_ROWS_EVENTv2-1] = 10
+	body = append(body, postHeaderLengths...)
+	body = append(body, replication.BINLOG_CHECKSUM_ALG_CRC32, 0, 0, 0, 0)
+
+	event, err := replication.NewBinlogParser().Parse(testRawEvent(replication.FORMAT_DESCRIPTION_EVENT, body))
+	require.NoError(t, err)
+	return event
+}
+
+// testTransactionEvents returns the raw events of a transaction inserting 42 into `test`.`gh_ost_test`
+func testTransactionEvents() []byte {
+	tableMap := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+	tableMap = append(tableMap, 4)
+	tableMap = append(tableMap, "test"...)
+	tableMap = append(tableMap, 0, 11)
+	tableMap = append(tableMap, "gh_ost_test"...)
+	tableMap = append(tableMap, 0, 1, gomysql.MYSQL_TYPE_LONG, 0, 0)
+
+	writeRows := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 1, 1, 0, 42, 0, 0, 0}
+
+	events := testRawEvent(replication.TABLE_MAP_EVENT, tableMap)
+	return append(events, testRawEvent(replication.WRITE_ROWS_EVENTv2, writeRows)...)
+}
+
+func testTransactionPayload(compressionType byte, payload []byte, uncompressedSize int) []byte {
+	data := []byte{
+		transactionPayloadCompressionTypeField, 1, compressionType,
+		transactionPayloadUncompressedSizeField, 1, byte(uncompressedSize),
+		transactionPayloadSizeField, 1, byte(len(payload)),
+		transactionPayloadHeaderEndMark,
+	}
+	return append(data, payload...)
+}
+
+func requireTransactionEvents(t *testing.T, events []*replication.BinlogEvent) {
+	require.Len(t, events, 2)
+	tableMapEvent, ok := events[0].Event.(*replication.TableMapEvent)
+	require.True(t, ok)
+	require.Equal(t, "test", string(tableMapEvent.Schema))
+	require.Equal(t, "gh_ost_test", string(tableMapEvent.Table))
+
+	rowsEvent, ok := events[1].Event.(*replication.RowsEvent)
+	require.True(t, ok)
+	require.Equal(t, replication.WRITE_ROWS_EVENTv2, events[1].Header.EventType)
+	require.Equal(t, [][]interface{}{{int32(42)}}, rowsEvent.Rows)
+}
+
+func TestTransactionPayloadDecoderZstd(t *testing.T) {
+	decoder, err := NewTransactionPayloadDecoder(testFormatDescriptionEvent(t), gomysql.MySQLFlavor, true, time.UTC)
+	require.NoError(t, err)
+	defer decoder.Close()
+
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer encoder.Close()
+	transactionEvents := testTransactionEvents()
+	compressed := encoder.EncodeAll(transactionEvents, nil)
+
+	events, err := decoder.Decode(testTransactionPayload(transactionPayloadCompressionTypeZstd, compressed, len(transactionEvents)))
+	require.NoError(t, err)
+	requireTransactionEvents(t, events)
+}
+
+func TestTransactionPayloadDecoderUncompressed(t *testing.T) {
+	decoder, err := NewTransactionPayloadDecoder(testFormatDescriptionEvent(t), gomysql.MySQLFlavor, true, time.UTC)
+	require.NoError(t, err)
+	defer decoder.Close()
+
+	transactionEvents := testTransactionEvents()
+	events, err := decoder.Decode(testTransactionPayload(transactionPayloadCompressionTypeNone, transactionEvents, len(transactionEvents)))
+	require.NoError(t, err)
+	requireTransactionEvents(t, events)
+}
+
+func TestTransactionPayloadDecoderErrors(t *testing.T) {
+	decoder, err := NewTransactionPayloadDecoder(testFormatDescriptionEvent(t), gomysql.MySQLFlavor, true, time.UTC)
+	require.NoError(t, err)
+	defer decoder.Close()
+
+	transactionEvents := testTransactionEvents()
+	_, err = decoder.Decode(testTransactionPayload(1, transactionEvents, len(transactionEvents)))
+	require.Error(t, err)
+
+	payload := testTransactionPayload(transactionPayloadCompressionTypeNone, transactionEvents, len(transactionEvents))
+	_, err = decoder.Decode(payload[:len(payload)-1])
+	require.Error(t, err)
+
+	_, err = decoder.Decode(testTransactionPayload(transactionPayloadCompressionTypeZstd, []byte("not zstd"), 8))
+	require.Error(t, err)
+}
@@ -26,7 +26,7 @@ import (
 var AppVersion, GitCommit string
 
 // acceptSignals registers for OS signals
-func acceptSignals(migrationContext *base.MigrationContext) {
+func acceptSignals(migrationContext *base.MigrationContext, migrator *logic.Migrator) {
 	c := make(chan os.Signal, 1)
 
 	signal.Notify(c, syscall.SIGHUP)
@@ -35,11 +35,7 @@ func acceptSignals(migrationContext *base.MigrationContext) {
 			switch sig {
 			case syscall.SIGHUP:
 				migrationContext.Log.Infof("Received SIGHUP. Reloading configuration")
-				if err := migrationContext.ReadConfigFile(); err != nil {
-					log.Errore(err)
-				} else {
-					migrationContext.MarkPointOfInterest()
-				}
+				migrator.ReloadConfig()
 			}
 		}
 	}()
@@ -56,7 +52,14 @@ func main() {
 	flag.StringVar(&migrationContext.CliPassword, "password", "", "MySQL password")
 	flag.StringVar(&migrationContext.CliMasterUser, "master-user", "", "MySQL user on master, if different from that on replica. Requires --assume-master-host")
 	flag.StringVar(&migrationContext.CliMasterPassword, "master-password", "", "MySQL password on master, if different from that on replica. Requires --assume-master-host")
-	flag.StringVar(&migrationContext.ConfigFile, "conf", "", "Config file")
+	flag.StringVar(&migrationContext.PasswordFile, "password-file", "", "File containing the MySQL password, keeping the password off the command line")
+	flag.StringVar(&migrationContext.MasterPasswordFile, "master-password-file", "", "File containing the MySQL password on master, if different from that on replica. Requires --assume-master-host")
+	flag.StringVar(&migrationContext.LoginPath, "login-path", "", "Read MySQL user & password from this login path in ~/.mylogin.cnf, as created by mysql_config_editor")
+	flag.BoolVar(&migrationContext.ReadMyCnf, "read-my-cnf", false, "Read MySQL user & password from the [client] section of /etc/my.cnf, /etc/mysql/my.cnf and ~/.my.cnf")
+	flag.Int64Var(&migrationContext.CredentialsRefreshIntervalSeconds, "credentials-refresh-interval-seconds", 0, "When greater than zero, re-read credentials and TLS certificates from their sources at this interval, and upon access denied errors. New connections use the refreshed credentials")
+	flag.StringVar(&migrationContext.CredentialHelper, "credential-helper", "", "Shell command printing 'user=...' and 'password=...' lines, run for the inspected server and for --assume-master-host")
+	flag.StringVar(&migrationContext.ConfigFile, "conf", "", "Config file. Either a my.cnf-like INI file with [client] credentials and [osc] settings, or a .yaml/.yml file where any flag may be set")
+	flag.StringVar(&migrationContext.ConfigFileProfile, "conf-profile", "", "Name of a profile in a YAML --conf file, whose settings override the file's top level settings. Flags given on the command line override both")
 	askPass := flag.Bool("ask-pass", false, "prompt for MySQL password")
 	charset := flag.String("charset", "utf8mb4,utf8,latin1", "The default charset for the database connection is utf8mb4, utf8, latin1.")
 
@@ -66,10 +69,30 @@ func main() {
 	flag.StringVar(&migrationContext.TLSKey, "ssl-key", "", "Key in PEM format for TLS connections to MySQL hosts. Requires --ssl")
 	flag.BoolVar(&migrationContext.TLSAllowInsecure, "ssl-allow-insecure", false, "Skips verification of MySQL hosts' certificate chain and host name. Requires --ssl")
 
+	flag.StringVar(&migrationContext.BinlogHost, "binlog-host", "", "Read binary logs from this server rather than from the inspected server (--host), e.g. a dedicated binlog server. It must replicate the same changes as the inspected server")
+	flag.IntVar(&migrationContext.BinlogPort, "binlog-port", 0, "Port of the binlog server (default: same as --port)")
+	flag.StringVar(&migrationContext.BinlogUser, "binlog-user", "", "MySQL user for reading binary logs, e.g. a replication-only user (default: same as --user). Requires REPLICATION SLAVE and REPLICATION CLIENT privileges")
+	flag.StringVar(&migrationContext.BinlogPassword, "binlog-password", "", "MySQL password for --binlog-user")
+	flag.StringVar(&migrationContext.BinlogPasswordFile, "binlog-password-file", "", "File containing the MySQL password for --binlog-user")
+	flag.BoolVar(&migrationContext.BinlogUseTLS, "binlog-ssl", false, "Enable SSL encrypted connections to the binlog server, with --binlog-ssl-* settings rather than --ssl-* settings (default: same as --ssl)")
+	flag.StringVar(&migrationContext.BinlogTLSCACertificate, "binlog-ssl-ca", "", "CA certificate in PEM format for TLS connections to the binlog server. Requires --binlog-ssl")
+	flag.StringVar(&migrationContext.BinlogTLSCertificate, "binlog-ssl-cert", "", "Certificate in PEM format for TLS connections to the binlog server. Requires --binlog-ssl")
+	flag.StringVar(&migrationContext.BinlogTLSKey, "binlog-ssl-key", "", "Key in PEM format for TLS connections to the binlog server. Requires --binlog-ssl")
+	flag.BoolVar(&migrationContext.BinlogTLSAllowInsecure, "binlog-ssl-allow-insecure", false, "Skips verification of the binlog server's certificate chain and host name. Requires --binlog-ssl")
+
+	flag.StringVar(&migrationContext.TargetHost, "target-host", "", "Cross-server migration: create and populate the ghost table on this server rather than on the master, then hand off the migrated table to it in place of a cut-over")
+	flag.IntVar(&migrationContext.TargetPort, "target-port", 0, "Port of the target server (default: same as the master's port). Requires --target-host")
+	flag.StringVar(&migrationContext.TargetUser, "target-user", "", "MySQL user on the target server (default: same as the master's user). Requires --target-host")
+	flag.StringVar(&migrationContext.TargetPassword, "target-password", "", "MySQL password for --target-user. Requires --target-host")
+	flag.StringVar(&migrationContext.TargetDatabaseName, "target-database", "", "Database on the target server (default: same as --database). Requires --target-host")
+	flag.StringVar(&migrationContext.TargetTableName, "target-table", "", "Name of the migrated table on the target server (default: the migrated table's name). Requires --target-host")
+
 	flag.StringVar(&migrationContext.DatabaseName, "database", "", "database name (mandatory)")
 	flag.StringVar(&migrationContext.OriginalTableName, "table", "", "table name (mandatory)")
 	flag.StringVar(&migrationContext.AlterStatement, "alter", "", "alter statement (mandatory)")
 	flag.BoolVar(&migrationContext.AttemptInstantDDL, "attempt-instant-ddl", false, "Attempt to use instant DDL for this migration first")
+	flag.BoolVar(&migrationContext.RenameTableCompatView, "rename-table-compat-view", false, "When the alter statement renames the table (RENAME TO|AS), create a view under the original table name after cut-over, reading from the renamed table")
+	flag.BoolVar(&migrationContext.CopyPerPartition, "copy-per-partition", false, "Copy rows of a partitioned table one partition at a time, using PARTITION (p) selection. Progress is reported per partition")
 	storageEngine := flag.String("storage-engine", "innodb", "Specify table storage engine (default: 'innodb'). When 'rocksdb': the session transaction isolation level is changed from REPEATABLE_READ to READ_COMMITTED.")
 
 	flag.BoolVar(&migrationContext.CountTableRows, "exact-rowcount", false, "actually count table rows as opposed to estimate them (results in more accurate progress estimation)")
@@ -77,10 +100,27 @@ func main() {
 	flag.BoolVar(&migrationContext.AllowedRunningOnMaster, "allow-on-master", false, "allow this migration to run directly on master. Preferably it would run on a replica")
 	flag.BoolVar(&migrationContext.AllowedMasterMaster, "allow-master-master", false, "explicitly allow running in a master-master setup")
 	flag.BoolVar(&migrationContext.NullableUniqueKeyAllowed, "allow-nullable-unique-key", false, "allow gh-ost to migrate based on a unique key with nullable columns. As long as no NULL values exist, this should be OK. If NULL values exist in chosen key, data may be corrupted. Use at your own risk!")
+	flag.BoolVar(&migrationContext.NoUniqueKeyAllowed, "allow-no-unique-key", false, "allow gh-ost to migrate a table without a shared unique key. gh-ost then uses a unique key of the ghost table whose columns exist on the original table, or else matches rows by all of their columns. This is considerably more expensive, and duplicate rows may not be migrated accurately. Use at your own risk!")
+	flag.BoolVar(&migrationContext.DuplicateRowsAllowed, "allow-duplicate-rows", false, "with --allow-no-unique-key, when rows are matched by all of their columns, allow migrating a table holding identical duplicate rows. Row copy copies them as they are, but a row inserted while migrating which is identical to a row the ghost table already has is not applied: writes to duplicate rows may leave fewer copies on the migrated table")
 	flag.BoolVar(&migrationContext.ApproveRenamedColumns, "approve-renamed-columns", false, "in case your `ALTER` statement renames columns, gh-ost will note that and offer its interpretation of the rename. By default gh-ost does not proceed to execute. This flag approves that gh-ost's interpretation is correct")
 	flag.BoolVar(&migrationContext.SkipRenamedColumns, "skip-renamed-columns", false, "in case your `ALTER` statement renames columns, gh-ost will note that and offer its interpretation of the rename. By default gh-ost does not proceed to execute. This flag tells gh-ost to skip the renamed columns, i.e. to treat what gh-ost thinks are renamed columns as unrelated columns. NOTE: you may lose column data")
+	var columnTransformations base.StringListFlag
+	flag.Var(&columnTransformations, "transform-column", "Populate a ghost table column with an SQL expression over original table columns, as 'column=expression', e.g. \"email=lower(email)\", on row copy and when applying binlog events. May be given multiple times")
+	flag.StringVar(&migrationContext.ArchiveTableName, "archive-table", "", "Archive the data the migration discards, i.e. the values of dropped columns (keyed by the unique key) or rows not matching --where, into this table, which gh-ost creates in the migrated database. On row copy and when applying binlog events")
+	flag.StringVar(&migrationContext.ArchiveFileName, "archive-file", "", "Archive the data the migration discards, i.e. the values of dropped columns (keyed by the unique key) or rows not matching --where, into this local file. Format is by file extension: .csv or .jsonl, gzip compressed when followed by .gz")
+	rowFilter := flag.String("where", "", "Only migrate rows matching this SQL predicate over original table columns, e.g. \"created_at >= '2024-01-01'\". Other rows are not copied, and are removed from the ghost table when updated into not matching. The migrated table then only holds matching rows")
 	flag.BoolVar(&migrationContext.IsTungsten, "tungsten", false, "explicitly let gh-ost know that you are running on a tungsten-replication based topology (you are likely to also provide --assume-master-host)")
 	flag.BoolVar(&migrationContext.DiscardForeignKeys, "discard-foreign-keys", false, "DANGER! This flag will migrate a table that has foreign keys and will NOT create foreign keys on the ghost table, thus your altered table will have NO foreign keys. This is useful for intentional dropping of foreign keys")
+	flag.StringVar(&migrationContext.SideTableName, "side-table", "", "Vertical split: move the --side-columns onto this table, which gh-ost creates in the migrated database, keyed by the migration's unique key. It is populated along the migrated table, and comes to exist at cut-over. The --alter must drop these columns")
+	sideColumns := flag.String("side-columns", "", "Comma delimited list of columns to move onto --side-table")
+	flag.StringVar(&migrationContext.ShardColumnName, "shard-column", "", "Horizontal split: route rows onto the --shard-tables by the value of this column of the original table")
+	shardTables := flag.String("shard-tables", "", "Horizontal split: comma delimited list of tables, which gh-ost creates in the migrated database with the migrated structure, and onto which rows are split by --shard-column. They come to exist at cut-over, replacing the original table")
+	shardBy := flag.String("shard-by", string(sql.HashShardMethod), "Routing of rows onto --shard-tables: 'hash' (CRC32 of --shard-column, modulo the number of shard tables) or 'range' (by --shard-ranges)")
+	shardRanges := flag.String("shard-ranges", "", "Comma delimited, ascending integer bounds of --shard-by=range, one fewer than --shard-tables. A row goes onto the first shard table whose bound exceeds its --shard-column value, else onto the last one")
+	flag.BoolVar(&migrationContext.PreserveForeignKeys, "preserve-foreign-keys", false, "Migrate a table that has (child-side) foreign keys, and re-create these foreign keys on the ghost table within the cut-over, after validating all rows satisfy them. Requires '--foreign-key-suffix'")
+	flag.StringVar(&migrationContext.ForeignKeySuffix, "foreign-key-suffix", "", "Add a suffix to the names of preserved foreign keys (i.e '_v2'), as foreign key names are unique per schema. Requires '--preserve-foreign-keys'")
+	flag.BoolVar(&migrationContext.RemoveForeignKeySuffix, "remove-foreign-key-suffix-if-exists", false, "Remove given suffix from name of foreign key. Requires '--preserve-foreign-keys' and '--foreign-key-suffix'")
+	flag.BoolVar(&migrationContext.RebindParentForeignKeys, "rebind-parent-foreign-keys", false, "Allow migrating a table referenced by foreign keys of other (child) tables. Within the cut-over, such foreign keys are re-created on the child tables with foreign_key_checks=0, so that they reference the migrated table. Child tables are locked along with the original table. Requires '--cut-over=two-step'")
 	flag.BoolVar(&migrationContext.SkipForeignKeyChecks, "skip-foreign-key-checks", false, "set to 'true' when you know for certain there are no foreign keys on your table, and wish to skip the time it takes for gh-ost to verify that")
 	flag.BoolVar(&migrationContext.SkipStrictMode, "skip-strict-mode", false, "explicitly tell gh-ost binlog applier not to enforce strict sql mode")
 	flag.BoolVar(&migrationContext.AllowZeroInDate, "allow-zero-in-date", false, "explicitly tell gh-ost binlog applier to ignore NO_ZERO_IN_DATE,NO_ZERO_DATE in sql_mode")
@@ -98,6 +138,7 @@ func main() {
 	flag.BoolVar(&migrationContext.InitiallyDropGhostTable, "initially-drop-ghost-table", false, "Drop a possibly existing Ghost table (remains from a previous run?) before beginning operation. Default is to panic and abort if such table exists")
 	flag.BoolVar(&migrationContext.TimestampOldTable, "timestamp-old-table", false, "Use a timestamp in old table name. This makes old table names unique and non conflicting cross migrations")
 	cutOver := flag.String("cut-over", "atomic", "choose cut-over type (default|atomic, two-step)")
+	concurrentDDLAction := flag.String("concurrent-ddl-action", "abort", "action on DDL by anyone other than gh-ost on the migrated tables, seen on the binary logs (abort, throttle). 'throttle' throttles until acknowledged via the 'acknowledge-ddl' interactive command")
 	flag.BoolVar(&migrationContext.ForceNamedCutOverCommand, "force-named-cut-over", false, "When true, the 'unpostpone|cut-over' interactive command must name the migrated table")
 	flag.BoolVar(&migrationContext.ForceNamedPanicCommand, "force-named-panic", false, "When true, the 'panic' interactive command must name the migrated table")
 
@@ -114,6 +155,7 @@ func main() {
 	maxLagMillis := flag.Int64("max-lag-millis", 1500, "replication lag at which to throttle operation")
 	replicationLagQuery := flag.String("replication-lag-query", "", "Deprecated. gh-ost uses an internal, subsecond resolution query")
 	throttleControlReplicas := flag.String("throttle-control-replicas", "", "List of replicas on which to check for lag; comma delimited. Example: myhost1.com:3306,myhost2.com,myhost3.com:3307")
+	targetThrottleControlReplicas := flag.String("target-throttle-control-replicas", "", "Cross-server migration: list of the target server's replicas on which to check for lag; comma delimited. Requires --target-host")
 	throttleQuery := flag.String("throttle-query", "", "when given, issued (every second) to check if operation should throttle. Expecting to return zero for no-throttle, >0 for throttle. Query is issued on the migrated server. Make sure this query is lightweight")
 	throttleHTTP := flag.String("throttle-http", "", "when given, gh-ost checks given URL via HEAD request; any response code other than 200 (OK) causes throttling; make sure it has low latency response")
 	flag.Int64Var(&migrationContext.ThrottleHTTPIntervalMillis, "throttle-http-interval-millis", 100, "Number of milliseconds to wait before triggering another HTTP throttle check")
@@ -134,6 +176,20 @@ func main() {
 	flag.StringVar(&migrationContext.HooksHintOwner, "hooks-hint-owner", "", "arbitrary name of owner to be injected to hooks via GH_OST_HOOKS_HINT_OWNER, for your convenience")
 	flag.StringVar(&migrationContext.HooksHintToken, "hooks-hint-token", "", "arbitrary token to be injected to hooks via GH_OST_HOOKS_HINT_TOKEN, for your convenience")
 	flag.Int64Var(&migrationContext.HooksStatusIntervalSec, "hooks-status-interval", 60, "how many seconds to wait between calling onStatus hook")
+	flag.Int64Var(&migrationContext.HooksTimeoutSeconds, "hooks-timeout-seconds", 0, "timeout in seconds for hook executables; a hook which times out is killed along with its process group, and fails. 0 means no timeout")
+	flag.StringVar(&migrationContext.HooksTimeouts, "hooks-timeouts", "", "comma delimited list of hook=seconds, overriding --hooks-timeout-seconds per hook, e.g. 'gh-ost-on-status=10,gh-ost-on-before-cut-over=3600'")
+	flag.StringVar(&migrationContext.HooksAsync, "hooks-async", "", "comma delimited list of informational hooks (gh-ost-on-status, gh-ost-on-interactive-command) to run asynchronously, without waiting on them")
+	flag.StringVar(&migrationContext.HooksIgnoreExitCode, "hooks-ignore-exit-code", "", "comma delimited list of hooks (or 'all') whose failure is logged and ignored rather than failing the hook")
+	flag.StringVar(&migrationContext.HooksWebhookURLs, "hooks-webhook-url", "", "comma delimited list of URLs to which a JSON payload is POSTed on each hook, in addition to hook executables found in --hooks-path")
+	flag.StringVar(&migrationContext.HooksWebhookEvents, "hooks-webhook-events", "", "comma delimited list of hooks (e.g. gh-ost-on-success) for which webhooks are called (default: empty, ie. all hooks)")
+	flag.StringVar(&migrationContext.HooksWebhookFailOnError, "hooks-webhook-fail-on-error", "", "comma delimited list of hooks (or 'all') for which a failing webhook fails the hook, as a failing hook executable does. Other webhook failures are logged and ignored")
+	flag.StringVar(&migrationContext.HooksWebhookSecret, "hooks-webhook-secret", "", "when given, webhook payloads are signed with HMAC-SHA256 using this secret, in the X-Gh-Ost-Signature header")
+	flag.Int64Var(&migrationContext.HooksWebhookTimeoutMillis, "hooks-webhook-timeout-millis", 5000, "timeout in milliseconds for a single webhook request")
+	flag.Int64Var(&migrationContext.HooksWebhookRetries, "hooks-webhook-retries", 2, "number of times to retry a failing webhook request, one second apart")
+
+	flag.StringVar(&migrationContext.CDCTapDestination, "cdc-tap", "", "Emit the binlog row changes of the migrated table as JSON lines to this destination: a file path, unix:///path/to/socket, or an http(s):// URL to which batches are POSTed. Never blocks the migration: events overflowing --cdc-tap-buffer-size are dropped")
+	flag.Int64Var(&migrationContext.CDCTapBufferSize, "cdc-tap-buffer-size", 10000, "number of events --cdc-tap buffers while its destination is slow or unavailable. Events beyond are dropped, and a gap record marks the loss")
+	flag.Int64Var(&migrationContext.CDCTapTimeoutMillis, "cdc-tap-timeout-millis", 1000, "timeout in milliseconds for a single --cdc-tap write, connection or request")
 
 	flag.UintVar(&migrationContext.ReplicaServerId, "replica-server-id", 99999, "server id used by gh-ost process. Default: 99999")
 	flag.IntVar(&migrationContext.BinlogSyncerMaxReconnectAttempts, "binlogsyncer-max-reconnect-attempts", 0, "when master node fails, the maximum number of binlog synchronization attempts to reconnect. 0 is unlimited")
@@ -161,6 +217,17 @@ func main() {
 	if *checkFlag {
 		return
 	}
+	flag.Visit(func(f *flag.Flag) {
+		migrationContext.CommandLineFlags[f.Name] = true
+	})
+	if migrationContext.ConfigFileProfile != "" && !base.IsYAMLConfigFile(migrationContext.ConfigFile) {
+		migrationContext.Log.Fatalf("--conf-profile requires a YAML --conf file")
+	}
+	if migrationContext.ConfigFile != "" {
+		if err := migrationContext.ApplyConfigFile(flag.CommandLine); err != nil {
+			migrationContext.Log.Fatale(err)
+		}
+	}
 	if *help {
 		fmt.Fprintf(os.Stdout, "Usage of gh-ost:\n")
 		flag.PrintDefaults()
@@ -237,6 +304,24 @@ func main() {
 	if migrationContext.SwitchToRowBinlogFormat && migrationContext.AssumeRBR {
 		migrationContext.Log.Fatal("--switch-to-rbr and --assume-rbr are mutually exclusive")
 	}
+	if migrationContext.PreserveForeignKeys && migrationContext.DiscardForeignKeys {
+		migrationContext.Log.Fatal("--preserve-foreign-keys and --discard-foreign-keys are mutually exclusive")
+	}
+	if migrationContext.PreserveForeignKeys && migrationContext.SkipForeignKeyChecks {
+		migrationContext.Log.Fatal("--preserve-foreign-keys and --skip-foreign-key-checks are mutually exclusive")
+	}
+	if migrationContext.PreserveForeignKeys && migrationContext.ForeignKeySuffix == "" {
+		migrationContext.Log.Fatal("--foreign-key-suffix must be used with --preserve-foreign-keys")
+	}
+	if !migrationContext.PreserveForeignKeys && migrationContext.ForeignKeySuffix != "" {
+		migrationContext.Log.Fatal("--foreign-key-suffix cannot be be used without --preserve-foreign-keys")
+	}
+	if migrationContext.RemoveForeignKeySuffix && migrationContext.ForeignKeySuffix == "" {
+		migrationContext.Log.Fatal("--remove-foreign-key-suffix-if-exists requires --foreign-key-suffix")
+	}
+	if migrationContext.RebindParentForeignKeys && migrationContext.SkipForeignKeyChecks {
+		migrationContext.Log.Fatal("--rebind-parent-foreign-keys and --skip-foreign-key-checks are mutually exclusive")
+	}
 	if migrationContext.TestOnReplicaSkipReplicaStop {
 		if !migrationContext.TestOnReplica {
 			migrationContext.Log.Fatal("--test-on-replica-skip-replica-stop requires --test-on-replica to be enabled")
@@ -249,6 +334,15 @@ func main() {
 	if migrationContext.CliMasterPassword != "" && migrationContext.AssumeMasterHostname == "" {
 		migrationContext.Log.Fatal("--master-password requires --assume-master-host")
 	}
+	if migrationContext.MasterPasswordFile != "" && migrationContext.AssumeMasterHostname == "" {
+		migrationContext.Log.Fatal("--master-password-file requires --assume-master-host")
+	}
+	if migrationContext.MasterPasswordFile != "" && migrationContext.CliMasterPassword != "" {
+		migrationContext.Log.Fatal("--master-password-file and --master-password are mutually exclusive")
+	}
+	if migrationContext.PasswordFile != "" && (migrationContext.CliPassword != "" || *askPass) {
+		migrationContext.Log.Fatal("--password-file is mutually exclusive with --password and --ask-pass")
+	}
 	if migrationContext.TLSCACertificate != "" && !migrationContext.UseTLS {
 		migrationContext.Log.Fatal("--ssl-ca requires --ssl")
 	}
@@ -261,6 +355,83 @@ func main() {
 	if migrationContext.TLSAllowInsecure && !migrationContext.UseTLS {
 		migrationContext.Log.Fatal("--ssl-allow-insecure requires --ssl")
 	}
+	if migrationContext.BinlogTLSCACertificate != "" && !migrationContext.BinlogUseTLS {
+		migrationContext.Log.Fatal("--binlog-ssl-ca requires --binlog-ssl")
+	}
+	if migrationContext.BinlogTLSCertificate != "" && !migrationContext.BinlogUseTLS {
+		migrationContext.Log.Fatal("--binlog-ssl-cert requires --binlog-ssl")
+	}
+	if migrationContext.BinlogTLSKey != "" && !migrationContext.BinlogUseTLS {
+		migrationContext.Log.Fatal("--binlog-ssl-key requires --binlog-ssl")
+	}
+	if migrationContext.BinlogTLSAllowInsecure && !migrationContext.BinlogUseTLS {
+		migrationContext.Log.Fatal("--binlog-ssl-allow-insecure requires --binlog-ssl")
+	}
+	if migrationContext.BinlogPasswordFile != "" && migrationContext.BinlogPassword != "" {
+		migrationContext.Log.Fatal("--binlog-password-file and --binlog-password are mutually exclusive")
+	}
+	if migrationContext.CDCTapBufferSize < 1 {
+		migrationContext.Log.Fatal("--cdc-tap-buffer-size must be greater than 0")
+	}
+	if migrationContext.CDCTapTimeoutMillis < 1 {
+		migrationContext.Log.Fatal("--cdc-tap-timeout-millis must be greater than 0")
+	}
+	if migrationContext.DuplicateRowsAllowed && !migrationContext.NoUniqueKeyAllowed {
+		migrationContext.Log.Fatal("--allow-duplicate-rows requires --allow-no-unique-key")
+	}
+	if migrationContext.ArchiveTableName != "" && migrationContext.ArchiveFileName != "" {
+		migrationContext.Log.Fatal("--archive-table and --archive-file are mutually exclusive")
+	}
+	if migrationContext.IsVerticalSplit() {
+		if *sideColumns == "" {
+			migrationContext.Log.Fatal("--side-table requires --side-columns")
+		}
+		if migrationContext.IsCrossServerMigration() {
+			migrationContext.Log.Fatal("--side-table is incompatible with --target-host")
+		}
+		if migrationContext.AttemptInstantDDL {
+			migrationContext.Log.Fatal("--side-table is incompatible with --attempt-instant-ddl")
+		}
+	} else if *sideColumns != "" {
+		migrationContext.Log.Fatal("--side-columns requires --side-table")
+	}
+	if *shardTables != "" {
+		if migrationContext.ShardColumnName == "" {
+			migrationContext.Log.Fatal("--shard-tables requires --shard-column")
+		}
+		if migrationContext.IsCrossServerMigration() || migrationContext.IsVerticalSplit() {
+			migrationContext.Log.Fatal("--shard-tables is incompatible with --target-host and --side-table")
+		}
+		if migrationContext.TestOnReplica {
+			migrationContext.Log.Fatal("--shard-tables is incompatible with --test-on-replica")
+		}
+		if migrationContext.IncludeTriggers || migrationContext.PreserveForeignKeys || migrationContext.RebindParentForeignKeys {
+			migrationContext.Log.Fatal("--shard-tables is incompatible with --include-triggers, --preserve-foreign-keys and --rebind-parent-foreign-keys")
+		}
+		if migrationContext.AttemptInstantDDL || migrationContext.RenameTableCompatView {
+			migrationContext.Log.Fatal("--shard-tables is incompatible with --attempt-instant-ddl and --rename-table-compat-view")
+		}
+	} else if migrationContext.ShardColumnName != "" || *shardRanges != "" || *shardBy != string(sql.HashShardMethod) {
+		migrationContext.Log.Fatal("--shard-column, --shard-by and --shard-ranges require --shard-tables")
+	}
+	if !migrationContext.IsCrossServerMigration() {
+		if migrationContext.TargetPort != 0 || migrationContext.TargetUser != "" || migrationContext.TargetPassword != "" || migrationContext.TargetDatabaseName != "" || migrationContext.TargetTableName != "" || *targetThrottleControlReplicas != "" {
+			migrationContext.Log.Fatal("--target-port, --target-user, --target-password, --target-database, --target-table and --target-throttle-control-replicas require --target-host")
+		}
+	} else {
+		if migrationContext.TestOnReplica || migrationContext.MigrateOnReplica {
+			migrationContext.Log.Fatal("--target-host is incompatible with --test-on-replica and --migrate-on-replica")
+		}
+		if migrationContext.ArchiveTableName != "" || migrationContext.ArchiveFileName != "" {
+			migrationContext.Log.Fatal("--target-host is incompatible with --archive-table and --archive-file")
+		}
+		if migrationContext.IncludeTriggers || migrationContext.PreserveForeignKeys || migrationContext.RebindParentForeignKeys {
+			migrationContext.Log.Fatal("--target-host is incompatible with --include-triggers, --preserve-foreign-keys and --rebind-parent-foreign-keys")
+		}
+		if migrationContext.AttemptInstantDDL || migrationContext.RenameTableCompatView {
+			migrationContext.Log.Fatal("--target-host is incompatible with --attempt-instant-ddl and --rename-table-compat-view")
+		}
+	}
 	if *replicationLagQuery != "" {
 		migrationContext.Log.Warningf("--replication-lag-query is deprecated")
 	}
@@ -289,9 +460,35 @@ func main() {
 	default:
 		migrationContext.Log.Fatalf("Unknown cut-over: %s", *cutOver)
 	}
+	if migrationContext.RebindParentForeignKeys && migrationContext.CutOverType != base.CutOverTwoStep {
+		migrationContext.Log.Fatal("--rebind-parent-foreign-keys requires --cut-over=two-step")
+	}
+	switch *concurrentDDLAction {
+	case "abort", "":
+		migrationContext.ConcurrentDDLAction = base.ConcurrentDDLAbort
+	case "throttle":
+		migrationContext.ConcurrentDDLAction = base.ConcurrentDDLThrottle
+	default:
+		migrationContext.Log.Fatalf("Unknown concurrent-ddl-action: %s", *concurrentDDLAction)
+	}
 	if err := migrationContext.ReadConfigFile(); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
+	if err := migrationContext.ReadColumnTransformations(columnTransformations); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if err := migrationContext.ReadRowFilter(*rowFilter); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if err := migrationContext.ReadSideColumnNames(*sideColumns); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if err := migrationContext.ReadShardTables(*shardTables, *shardBy, *shardRanges); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
+	if err := migrationContext.ReadTargetThrottleControlReplicaKeys(*targetThrottleControlReplicas); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
 	if err := migrationContext.ReadThrottleControlReplicaKeys(*throttleControlReplicas); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
@@ -321,10 +518,15 @@ func main() {
 	migrationContext.SetThrottleHTTP(*throttleHTTP)
 	migrationContext.SetIgnoreHTTPErrors(*ignoreHTTPErrors)
 	migrationContext.SetDefaultNumRetries(*defaultRetries)
-	migrationContext.ApplyCredentials()
+	if err := migrationContext.ApplyCredentials(); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
 	if err := migrationContext.SetupTLS(); err != nil {
 		migrationContext.Log.Fatale(err)
 	}
+	if err := migrationContext.SetupBinlogConnectionConfig(); err != nil {
+		migrationContext.Log.Fatale(err)
+	}
 	if err := migrationContext.SetCutOverLockTimeoutSeconds(*cutOverLockTimeoutSeconds); err != nil {
 		migrationContext.Log.Errore(err)
 	}
@@ -333,9 +535,9 @@ func main() {
 	}
 
 	log.Infof("starting gh-ost %+v (git commit: %s)", AppVersion, GitCommit)
-	acceptSignals(migrationContext)
-
 	migrator := logic.NewMigrator(migrationContext, AppVersion)
+	acceptSignals(migrationContext, migrator)
+
 	if err := migrator.Migrate(); err != nil {
 		migrator.ExecOnFailureHook()
 		migrationContext.Log.Fatale(err)
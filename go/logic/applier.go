@@ -27,6 +27,10 @@ import (
 const (
 	GhostChangelogTableComment = "gh-ost changelog"
 	atomicCutOverMagicHint     = "ghost-cut-over-sentry"
+	maxChangelogValueLength    = 4096
+	// maxTargetCopyInsertBytes bounds the size of the values of a single INSERT, by which rows copied
+	// onto the target server of a cross-server migration are inserted
+	maxTargetCopyInsertBytes = 4 * 1024 * 1024
 )
 
 type dmlBuildResult struct {
@@ -56,10 +60,16 @@ func newDmlBuildResultError(err error) *dmlBuildResult {
 // `--execute-on-replica` are given.
 // Applier is the one to actually write row data and apply binlog events onto the ghost table.
 // It is where the ghost & changelog tables get created. It is where the cut-over phase happens.
+// On a vertical split (--side-table), it maintains the side table's ghost table alongside the ghost table.
+// On a horizontal split (--shard-tables), rows are rather written onto shard ghost tables, the ghost table
+// merely serving as their template.
+// On a cross-server migration (--target-host), the ghost table is rather created and written
+// on the target server, and the cut-over is replaced by a hand-off.
 type Applier struct {
 	connectionConfig  *mysql.ConnectionConfig
 	db                *gosql.DB
 	singletonDB       *gosql.DB
+	ghostDB           *gosql.DB
 	migrationContext  *base.MigrationContext
 	finishedMigrating int64
 	name              string
@@ -67,6 +77,23 @@ type Applier struct {
 	dmlDeleteQueryBuilder *sql.DMLDeleteQueryBuilder
 	dmlInsertQueryBuilder *sql.DMLInsertQueryBuilder
 	dmlUpdateQueryBuilder *sql.DMLUpdateQueryBuilder
+
+	archiveDeleteQueryBuilder *sql.DMLDeleteQueryBuilder
+	archiveInsertQueryBuilder *sql.DMLInsertQueryBuilder
+	archiveUpdateQueryBuilder *sql.DMLUpdateQueryBuilder
+	archiveFileWriter         *ArchiveFileWriter
+
+	sideDeleteQueryBuilder *sql.DMLDeleteQueryBuilder
+	sideInsertQueryBuilder *sql.DMLInsertQueryBuilder
+	sideUpdateQueryBuilder *sql.DMLUpdateQueryBuilder
+
+	shardDeleteQueryBuilders []*sql.DMLDeleteQueryBuilder
+	shardInsertQueryBuilders []*sql.DMLInsertQueryBuilder
+	shardUpdateQueryBuilders []*sql.DMLUpdateQueryBuilder
+	shardRowFilters          []*sql.RowFilter
+
+	reboundChildTables          []string
+	childSideForeignKeysOnGhost bool
 }
 
 func NewApplier(migrationContext *base.MigrationContext) *Applier {
@@ -79,13 +106,10 @@ func NewApplier(migrationContext *base.MigrationContext) *Applier {
 }
 
 func (this *Applier) InitDBConnections() (err error) {
-	applierUri := this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
-	uriWithMulti := fmt.Sprintf("%s&multiStatements=true", applierUri)
-	if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, uriWithMulti); err != nil {
+	if this.db, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, this.name, this.connectionConfig, this.migrationContext.DatabaseName, "&multiStatements=true"); err != nil {
 		return err
 	}
-	singletonApplierUri := fmt.Sprintf("%s&timeout=0", applierUri)
-	if this.singletonDB, _, err = mysql.GetDB(this.migrationContext.Uuid, singletonApplierUri); err != nil {
+	if this.singletonDB, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, this.name, this.connectionConfig, this.migrationContext.DatabaseName, "&timeout=0"); err != nil {
 		return err
 	}
 	this.singletonDB.SetMaxOpenConns(1)
@@ -111,12 +135,54 @@ func (this *Applier) InitDBConnections() (err error) {
 		return err
 	}
 	this.migrationContext.Log.Infof("Applier initiated on %+v, version %+v", this.connectionConfig.ImpliedKey, this.migrationContext.ApplierMySQLVersion)
+	return this.initTargetDBConnection()
+}
+
+// initTargetDBConnection connects to the target server of a cross-server migration (--target-host), where
+// the ghost table is created and written. Otherwise, the ghost table is written over the applier's connection.
+func (this *Applier) initTargetDBConnection() (err error) {
+	if !this.migrationContext.IsCrossServerMigration() {
+		this.ghostDB = this.db
+		return nil
+	}
+	targetConnectionConfig := this.migrationContext.TargetConnectionConfig
+	if this.ghostDB, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, "target", targetConnectionConfig, this.migrationContext.GetGhostDatabaseName(), "&multiStatements=true"); err != nil {
+		return err
+	}
+	version, err := base.ValidateConnection(this.ghostDB, targetConnectionConfig, this.migrationContext, "target")
+	if err != nil {
+		return err
+	}
+	this.migrationContext.TargetMySQLVersion = version
+	if !this.migrationContext.AliyunRDS && !this.migrationContext.GoogleCloudPlatform && !this.migrationContext.AzureMySQL {
+		if impliedKey, err := mysql.GetInstanceKey(this.ghostDB); err != nil {
+			return err
+		} else {
+			targetConnectionConfig.ImpliedKey = impliedKey
+		}
+	}
+	if targetConnectionConfig.ImpliedKey.Equals(this.connectionConfig.ImpliedKey) && this.migrationContext.GetGhostDatabaseName() == this.migrationContext.DatabaseName {
+		return fmt.Errorf("Target %+v is the migrated server itself, and the target database is the migrated database. Bailing out", *targetConnectionConfig.ImpliedKey)
+	}
+	this.migrationContext.Log.Infof("Target initiated on %+v, version %+v", targetConnectionConfig.ImpliedKey, this.migrationContext.TargetMySQLVersion)
 	return nil
 }
 
 func (this *Applier) prepareQueries() (err error) {
+	if err := this.prepareArchiveQueries(); err != nil {
+		return err
+	}
+	if err := this.prepareSideQueries(); err != nil {
+		return err
+	}
+	if err := this.prepareShardQueries(); err != nil {
+		return err
+	}
+	if this.migrationContext.UniqueKey.IsFullRow {
+		return this.prepareFullRowQueries()
+	}
 	if this.dmlDeleteQueryBuilder, err = sql.NewDMLDeleteQueryBuilder(
-		this.migrationContext.DatabaseName,
+		this.migrationContext.GetGhostDatabaseName(),
 		this.migrationContext.GetGhostTableName(),
 		this.migrationContext.OriginalTableColumns,
 		&this.migrationContext.UniqueKey.Columns,
@@ -124,27 +190,195 @@ func (this *Applier) prepareQueries() (err error) {
 		return err
 	}
 	if this.dmlInsertQueryBuilder, err = sql.NewDMLInsertQueryBuilder(
-		this.migrationContext.DatabaseName,
+		this.migrationContext.GetGhostDatabaseName(),
 		this.migrationContext.GetGhostTableName(),
 		this.migrationContext.OriginalTableColumns,
 		this.migrationContext.SharedColumns,
 		this.migrationContext.MappedSharedColumns,
+		this.migrationContext.ColumnTransformations,
+		this.migrationContext.RowFilter,
 	); err != nil {
 		return err
 	}
 	if this.dmlUpdateQueryBuilder, err = sql.NewDMLUpdateQueryBuilder(
-		this.migrationContext.DatabaseName,
+		this.migrationContext.GetGhostDatabaseName(),
+		this.migrationContext.GetGhostTableName(),
+		this.migrationContext.OriginalTableColumns,
+		this.migrationContext.SharedColumns,
+		this.migrationContext.MappedSharedColumns,
+		&this.migrationContext.UniqueKey.Columns,
+		this.migrationContext.ColumnTransformations,
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// prepareFullRowQueries prepares the DML query builders of a table which has no usable unique key:
+// rows are deleted and inserted by matching all of their columns, and updates are applied as
+// a delete followed by an insert.
+func (this *Applier) prepareFullRowQueries() (err error) {
+	if this.dmlDeleteQueryBuilder, err = sql.NewDMLFullRowDeleteQueryBuilder(
+		this.migrationContext.GetGhostDatabaseName(),
+		this.migrationContext.GetGhostTableName(),
+		this.migrationContext.OriginalTableColumns,
+		this.migrationContext.FullRowMatchColumns,
+	); err != nil {
+		return err
+	}
+	if this.dmlInsertQueryBuilder, err = sql.NewDMLFullRowInsertQueryBuilder(
+		this.migrationContext.GetGhostDatabaseName(),
 		this.migrationContext.GetGhostTableName(),
 		this.migrationContext.OriginalTableColumns,
 		this.migrationContext.SharedColumns,
 		this.migrationContext.MappedSharedColumns,
+		this.migrationContext.FullRowMatchColumns,
+		this.migrationContext.ColumnTransformations,
+		this.migrationContext.RowFilter,
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// prepareArchiveQueries prepares the DML query builders of the archive table (--archive-table), onto which
+// binlog events are applied as onto the ghost table, restricted to the archived columns and rows.
+// On --noop, nothing is archived.
+func (this *Applier) prepareArchiveQueries() (err error) {
+	if this.migrationContext.ArchiveTableName == "" || this.migrationContext.Noop {
+		return nil
+	}
+	if this.archiveDeleteQueryBuilder, err = sql.NewDMLDeleteQueryBuilder(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.ArchiveTableName,
+		this.migrationContext.OriginalTableColumns,
+		&this.migrationContext.UniqueKey.Columns,
+	); err != nil {
+		return err
+	}
+	if this.archiveInsertQueryBuilder, err = sql.NewDMLInsertQueryBuilder(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.ArchiveTableName,
+		this.migrationContext.OriginalTableColumns,
+		this.migrationContext.ArchiveColumns,
+		this.migrationContext.ArchiveColumns,
+		nil,
+		this.migrationContext.ArchiveRowFilter,
+	); err != nil {
+		return err
+	}
+	if this.archiveUpdateQueryBuilder, err = sql.NewDMLUpdateQueryBuilder(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.ArchiveTableName,
+		this.migrationContext.OriginalTableColumns,
+		this.migrationContext.ArchiveColumns,
+		this.migrationContext.ArchiveColumns,
+		&this.migrationContext.UniqueKey.Columns,
+		nil,
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// prepareSideQueries prepares the DML query builders of the side table's ghost table (--side-table), onto which
+// binlog events are applied as onto the ghost table, restricted to the side table's columns.
+// On --noop, there is no side table.
+func (this *Applier) prepareSideQueries() (err error) {
+	if !this.migrationContext.IsVerticalSplit() || this.migrationContext.Noop {
+		return nil
+	}
+	if this.sideDeleteQueryBuilder, err = sql.NewDMLDeleteQueryBuilder(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.GetSideGhostTableName(),
+		this.migrationContext.OriginalTableColumns,
+		&this.migrationContext.UniqueKey.Columns,
+	); err != nil {
+		return err
+	}
+	if this.sideInsertQueryBuilder, err = sql.NewDMLInsertQueryBuilder(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.GetSideGhostTableName(),
+		this.migrationContext.OriginalTableColumns,
+		this.migrationContext.SideTableColumns,
+		this.migrationContext.SideTableColumns,
+		nil,
+		this.migrationContext.RowFilter,
+	); err != nil {
+		return err
+	}
+	if this.sideUpdateQueryBuilder, err = sql.NewDMLUpdateQueryBuilder(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.GetSideGhostTableName(),
+		this.migrationContext.OriginalTableColumns,
+		this.migrationContext.SideTableColumns,
+		this.migrationContext.SideTableColumns,
 		&this.migrationContext.UniqueKey.Columns,
+		nil,
 	); err != nil {
 		return err
 	}
 	return nil
 }
 
+// prepareShardQueries prepares the DML query builders and row copy filters of the shard ghost tables
+// (--shard-tables), onto which rows are routed rather than onto the ghost table. On --noop, there are
+// no shard ghost tables.
+func (this *Applier) prepareShardQueries() (err error) {
+	if !this.migrationContext.IsHorizontalSplit() || this.migrationContext.Noop {
+		return nil
+	}
+	for i := range this.migrationContext.ShardTableNames {
+		shardGhostTableName := this.migrationContext.GetShardGhostTableName(i)
+		deleteQueryBuilder, err := sql.NewDMLDeleteQueryBuilder(
+			this.migrationContext.DatabaseName,
+			shardGhostTableName,
+			this.migrationContext.OriginalTableColumns,
+			&this.migrationContext.UniqueKey.Columns,
+		)
+		if err != nil {
+			return err
+		}
+		insertQueryBuilder, err := sql.NewDMLInsertQueryBuilder(
+			this.migrationContext.DatabaseName,
+			shardGhostTableName,
+			this.migrationContext.OriginalTableColumns,
+			this.migrationContext.SharedColumns,
+			this.migrationContext.MappedSharedColumns,
+			this.migrationContext.ColumnTransformations,
+			this.migrationContext.RowFilter,
+		)
+		if err != nil {
+			return err
+		}
+		updateQueryBuilder, err := sql.NewDMLUpdateQueryBuilder(
+			this.migrationContext.DatabaseName,
+			shardGhostTableName,
+			this.migrationContext.OriginalTableColumns,
+			this.migrationContext.SharedColumns,
+			this.migrationContext.MappedSharedColumns,
+			&this.migrationContext.UniqueKey.Columns,
+			this.migrationContext.ColumnTransformations,
+		)
+		if err != nil {
+			return err
+		}
+		shardCondition := this.migrationContext.ShardRouter.BuildShardCondition(i)
+		if this.migrationContext.RowFilter != nil {
+			shardCondition = fmt.Sprintf("%s and (%s)", shardCondition, this.migrationContext.RowFilter.Expression)
+		}
+		rowFilter, err := sql.NewRowFilter(shardCondition)
+		if err != nil {
+			return err
+		}
+		this.shardDeleteQueryBuilders = append(this.shardDeleteQueryBuilders, deleteQueryBuilder)
+		this.shardInsertQueryBuilders = append(this.shardInsertQueryBuilders, insertQueryBuilder)
+		this.shardUpdateQueryBuilders = append(this.shardUpdateQueryBuilders, updateQueryBuilder)
+		this.shardRowFilters = append(this.shardRowFilters, rowFilter)
+	}
+	return nil
+}
+
 // validateAndReadGlobalVariables potentially reads server global variables, such as the time_zone and wait_timeout.
 func (this *Applier) validateAndReadGlobalVariables() error {
 	query := `select /* gh-ost */ @@global.time_zone, @@global.wait_timeout`
@@ -198,8 +432,13 @@ func (this *Applier) readTableColumns() (err error) {
 
 // showTableStatus returns the output of `show table status like '...'` command
 func (this *Applier) showTableStatus(tableName string) (rowMap sqlutils.RowMap) {
-	query := fmt.Sprintf(`show /* gh-ost */ table status from %s like '%s'`, sql.EscapeName(this.migrationContext.DatabaseName), tableName)
-	sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+	return this.showTableStatusOn(this.db, this.migrationContext.DatabaseName, tableName)
+}
+
+// showTableStatusOn returns the output of `show table status like '...'` command on given server & database
+func (this *Applier) showTableStatusOn(db *gosql.DB, databaseName, tableName string) (rowMap sqlutils.RowMap) {
+	query := fmt.Sprintf(`show /* gh-ost */ table status from %s like '%s'`, sql.EscapeName(databaseName), tableName)
+	sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
 		rowMap = m
 		return nil
 	})
@@ -212,6 +451,13 @@ func (this *Applier) tableExists(tableName string) (tableFound bool) {
 	return (m != nil)
 }
 
+// ghostServerTableExists checks if a given table exists in the ghost table's database: the migrated
+// database, or the target database on a cross-server migration
+func (this *Applier) ghostServerTableExists(tableName string) (tableFound bool) {
+	m := this.showTableStatusOn(this.ghostDB, this.migrationContext.GetGhostDatabaseName(), tableName)
+	return (m != nil)
+}
+
 // ValidateOrDropExistingTables verifies ghost and changelog tables do not exist,
 // or attempts to drop them if instructed to.
 func (this *Applier) ValidateOrDropExistingTables() error {
@@ -220,7 +466,7 @@ func (this *Applier) ValidateOrDropExistingTables() error {
 			return err
 		}
 	}
-	if this.tableExists(this.migrationContext.GetGhostTableName()) {
+	if this.ghostServerTableExists(this.migrationContext.GetGhostTableName()) {
 		return fmt.Errorf("Table %s already exists. Panicking. Use --initially-drop-ghost-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetGhostTableName()))
 	}
 	if this.migrationContext.InitiallyDropOldTable {
@@ -235,6 +481,44 @@ func (this *Applier) ValidateOrDropExistingTables() error {
 	if this.tableExists(this.migrationContext.GetOldTableName()) {
 		return fmt.Errorf("Table %s already exists. Panicking. Use --initially-drop-old-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetOldTableName()))
 	}
+	if this.migrationContext.IsCrossServerMigration() {
+		if this.ghostServerTableExists(this.migrationContext.GetTargetTableName()) {
+			return fmt.Errorf("Table %s.%s already exists on target %+v, and the migrated table is to be handed off as it. Bailing out", sql.EscapeName(this.migrationContext.GetGhostDatabaseName()), sql.EscapeName(this.migrationContext.GetTargetTableName()), *this.migrationContext.TargetConnectionConfig.ImpliedKey)
+		}
+	} else if this.migrationContext.RenamedTableName != "" && this.tableExists(this.migrationContext.RenamedTableName) {
+		return fmt.Errorf("Table %s already exists, and the ALTER statement renames the table onto it. Bailing out", sql.EscapeName(this.migrationContext.RenamedTableName))
+	}
+	if this.migrationContext.ArchiveTableName != "" && this.tableExists(this.migrationContext.ArchiveTableName) {
+		return fmt.Errorf("Archive table %s already exists. Bailing out; drop or rename it, or choose another --archive-table", sql.EscapeName(this.migrationContext.ArchiveTableName))
+	}
+	if this.migrationContext.IsVerticalSplit() {
+		if this.migrationContext.InitiallyDropGhostTable {
+			if err := this.DropSideGhostTable(); err != nil {
+				return err
+			}
+		}
+		if this.tableExists(this.migrationContext.GetSideGhostTableName()) {
+			return fmt.Errorf("Table %s already exists. Panicking. Use --initially-drop-ghost-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetSideGhostTableName()))
+		}
+		if this.tableExists(this.migrationContext.SideTableName) {
+			return fmt.Errorf("Side table %s already exists. Bailing out; drop or rename it, or choose another --side-table", sql.EscapeName(this.migrationContext.SideTableName))
+		}
+	}
+	if this.migrationContext.IsHorizontalSplit() {
+		if this.migrationContext.InitiallyDropGhostTable {
+			if err := this.DropShardGhostTables(); err != nil {
+				return err
+			}
+		}
+		for i, shardTableName := range this.migrationContext.ShardTableNames {
+			if this.tableExists(this.migrationContext.GetShardGhostTableName(i)) {
+				return fmt.Errorf("Table %s already exists. Panicking. Use --initially-drop-ghost-table to force dropping it, though I really prefer that you drop it or rename it away", sql.EscapeName(this.migrationContext.GetShardGhostTableName(i)))
+			}
+			if this.tableExists(shardTableName) {
+				return fmt.Errorf("Shard table %s already exists. Bailing out; drop or rename it, or choose other --shard-tables", sql.EscapeName(shardTableName))
+			}
+		}
+	}
 
 	return nil
 }
@@ -269,7 +553,8 @@ func (this *Applier) AttemptInstantDDL() error {
 	return err
 }
 
-// CreateGhostTable creates the ghost table on the applier host
+// CreateGhostTable creates the ghost table on the applier host, or on the target server of
+// a cross-server migration
 func (this *Applier) CreateGhostTable() error {
 	query := fmt.Sprintf(`create /* gh-ost */ table %s.%s like %s.%s`,
 		sql.EscapeName(this.migrationContext.DatabaseName),
@@ -277,13 +562,19 @@ func (this *Applier) CreateGhostTable() error {
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
 	)
+	if this.migrationContext.IsCrossServerMigration() {
+		var err error
+		if query, err = this.buildCreateGhostTableOnTargetQuery(); err != nil {
+			return err
+		}
+	}
 	this.migrationContext.Log.Infof("Creating ghost table %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 
 	err := func() error {
-		tx, err := this.db.Begin()
+		tx, err := this.ghostDB.Begin()
 		if err != nil {
 			return err
 		}
@@ -310,21 +601,210 @@ func (this *Applier) CreateGhostTable() error {
 	return err
 }
 
+// buildCreateGhostTableOnTargetQuery builds the statement creating the ghost table on the target server
+// of a cross-server migration, where `create table ... like` cannot apply, off the original table's
+// `show create table` statement
+func (this *Applier) buildCreateGhostTableOnTargetQuery() (string, error) {
+	var tableName, createTableStatement string
+	query := fmt.Sprintf(`show /* gh-ost */ create table %s.%s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+	)
+	if err := this.db.QueryRow(query).Scan(&tableName, &createTableStatement); err != nil {
+		return "", err
+	}
+	prefix := fmt.Sprintf("CREATE TABLE %s", sql.EscapeName(this.migrationContext.OriginalTableName))
+	if !strings.HasPrefix(createTableStatement, prefix) {
+		return "", fmt.Errorf("Unexpected CREATE statement of %s.%s: %s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), createTableStatement)
+	}
+	return fmt.Sprintf(`create /* gh-ost */ table %s.%s%s`,
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		strings.TrimPrefix(createTableStatement, prefix),
+	), nil
+}
+
+// CreateArchive creates the archive table (--archive-table) or file (--archive-file), which keep the data
+// the migration discards. The archive table has the archived columns, typed as on the original table,
+// and a key on the migration's unique key columns; it has no defaults, secondary keys or generated columns.
+func (this *Applier) CreateArchive() error {
+	if this.migrationContext.ArchiveFileName != "" {
+		writer, err := NewArchiveFileWriter(this.migrationContext.ArchiveFileName, this.migrationContext.ArchiveColumns.Names())
+		if err != nil {
+			return err
+		}
+		this.archiveFileWriter = writer
+		this.migrationContext.Log.Infof("Created archive file %s", this.migrationContext.ArchiveFileName)
+		return nil
+	}
+	if this.migrationContext.ArchiveTableName == "" {
+		return nil
+	}
+	query := `select /* gh-ost */
+			COLUMN_NAME, COLUMN_TYPE, ifnull(CHARACTER_SET_NAME, '') as CHARACTER_SET_NAME, ifnull(COLLATION_NAME, '') as COLLATION_NAME
+		from
+			information_schema.columns
+		where
+			table_schema=? and table_name=?`
+	columnDefinitions := make(map[string]string)
+	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		definition := fmt.Sprintf("%s %s", sql.EscapeName(m.GetString("COLUMN_NAME")), m.GetString("COLUMN_TYPE"))
+		if charset := m.GetString("CHARACTER_SET_NAME"); charset != "" {
+			definition = fmt.Sprintf("%s character set %s collate %s", definition, charset, m.GetString("COLLATION_NAME"))
+		}
+		columnDefinitions[strings.ToLower(m.GetString("COLUMN_NAME"))] = definition
+		return nil
+	}, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	definitions := []string{}
+	for _, column := range this.migrationContext.ArchiveColumns.Columns() {
+		definition, ok := columnDefinitions[strings.ToLower(column.Name)]
+		if !ok {
+			return fmt.Errorf("Cannot find archived column %s on %s.%s", sql.EscapeName(column.Name), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		if this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil && !this.migrationContext.UniqueKey.HasNullable {
+			definition = fmt.Sprintf("%s not null", definition)
+		} else {
+			definition = fmt.Sprintf("%s null", definition)
+		}
+		definitions = append(definitions, definition)
+	}
+	uniqueKeyColumnNames := []string{}
+	for _, columnName := range this.migrationContext.UniqueKey.Columns.Names() {
+		uniqueKeyColumnNames = append(uniqueKeyColumnNames, sql.EscapeName(columnName))
+	}
+	keyDefinition := "primary key"
+	if this.migrationContext.UniqueKey.HasNullable {
+		keyDefinition = "unique key"
+	}
+	definitions = append(definitions, fmt.Sprintf("%s (%s)", keyDefinition, strings.Join(uniqueKeyColumnNames, ", ")))
+
+	query = fmt.Sprintf(`create /* gh-ost */ table %s.%s (%s)`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.ArchiveTableName),
+		strings.Join(definitions, ", "),
+	)
+	this.migrationContext.Log.Infof("Creating archive table %s.%s",
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.ArchiveTableName),
+	)
+	this.migrationContext.Log.Debugf("CREATE statement: %s", query)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Archive table created")
+	return nil
+}
+
+// CreateSideGhostTable creates the side table's ghost table (--side-table), like the original table, and
+// drops all but its unique key and the side table's columns. Other indexes are dropped along.
+func (this *Applier) CreateSideGhostTable() error {
+	if !this.migrationContext.IsVerticalSplit() {
+		return nil
+	}
+	query := fmt.Sprintf(`create /* gh-ost */ table %s.%s like %s.%s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+	)
+	this.migrationContext.Log.Infof("Creating side ghost table %s.%s",
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+	)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+
+	alterOptions := []string{}
+	query = `select /* gh-ost */ distinct INDEX_NAME from information_schema.statistics where table_schema=? and table_name=?`
+	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+		indexName := m.GetString("INDEX_NAME")
+		switch {
+		case indexName == this.migrationContext.UniqueKey.Name:
+		case indexName == "PRIMARY":
+			alterOptions = append(alterOptions, "drop primary key")
+		default:
+			alterOptions = append(alterOptions, fmt.Sprintf("drop key %s", sql.EscapeName(indexName)))
+		}
+		return nil
+	}, this.migrationContext.DatabaseName, this.migrationContext.GetSideGhostTableName())
+	if err != nil {
+		return err
+	}
+	sideTableColumns := make(map[string]bool)
+	for _, columnName := range this.migrationContext.SideTableColumns.Names() {
+		sideTableColumns[columnName] = true
+	}
+	for _, column := range this.migrationContext.OriginalTableColumns.Columns() {
+		if !sideTableColumns[column.Name] {
+			alterOptions = append(alterOptions, fmt.Sprintf("drop column %s", sql.EscapeName(column.Name)))
+		}
+	}
+	query = fmt.Sprintf(`alter /* gh-ost */ table %s.%s %s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+		strings.Join(alterOptions, ", "),
+	)
+	this.migrationContext.Log.Debugf("ALTER statement: %s", query)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Side ghost table created")
+	return nil
+}
+
+// CreateShardGhostTables creates the shard ghost tables (--shard-tables) like the ghost table, which
+// must already be altered. The ghost table itself is left empty.
+func (this *Applier) CreateShardGhostTables() error {
+	for i := range this.migrationContext.ShardTableNames {
+		query := fmt.Sprintf(`create /* gh-ost */ table %s.%s like %s.%s`,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetShardGhostTableName(i)),
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		)
+		this.migrationContext.Log.Infof("Creating shard ghost table %s.%s",
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetShardGhostTableName(i)),
+		)
+		if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+			return err
+		}
+	}
+	this.migrationContext.Log.Infof("Shard ghost tables created")
+	return nil
+}
+
+// CloseArchive closes the archive file (--archive-file), if any. A compressed file is only complete once closed.
+func (this *Applier) CloseArchive() error {
+	if this.archiveFileWriter == nil {
+		return nil
+	}
+	if err := this.archiveFileWriter.Close(); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Closed archive file %s", this.migrationContext.ArchiveFileName)
+	return nil
+}
+
 // AlterGhost applies `alter` statement on ghost table
 func (this *Applier) AlterGhost() error {
 	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s %s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		this.migrationContext.AlterStatementOptions,
 	)
 	this.migrationContext.Log.Infof("Altering ghost table %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	this.migrationContext.Log.Debugf("ALTER statement: %s", query)
 
 	err := func() error {
-		tx, err := this.db.Begin()
+		tx, err := this.ghostDB.Begin()
 		if err != nil {
 			return err
 		}
@@ -354,16 +834,16 @@ func (this *Applier) AlterGhost() error {
 // AlterGhost applies `alter` statement on ghost table
 func (this *Applier) AlterGhostAutoIncrement() error {
 	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s AUTO_INCREMENT=%d`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		this.migrationContext.OriginalTableAutoIncrement,
 	)
 	this.migrationContext.Log.Infof("Altering ghost table AUTO_INCREMENT value %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	this.migrationContext.Log.Debugf("AUTO_INCREMENT ALTER statement: %s", query)
-	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+	if _, err := sqlutils.ExecNoPrepare(this.ghostDB, query); err != nil {
 		return err
 	}
 	this.migrationContext.Log.Infof("Ghost table AUTO_INCREMENT altered")
@@ -379,12 +859,13 @@ func (this *Applier) CreateChangelogTable() error {
 			id bigint unsigned auto_increment,
 			last_update timestamp not null DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			hint varchar(64) charset ascii not null,
-			value varchar(4096) charset ascii not null,
+			value varchar(%d) charset ascii not null,
 			primary key(id),
 			unique key hint_uidx(hint)
 		) auto_increment=256 comment='%s'`,
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetChangelogTableName()),
+		maxChangelogValueLength,
 		GhostChangelogTableComment,
 	)
 	this.migrationContext.Log.Infof("Creating changelog table %s.%s",
@@ -400,15 +881,20 @@ func (this *Applier) CreateChangelogTable() error {
 
 // dropTable drops a given table on the applied host
 func (this *Applier) dropTable(tableName string) error {
+	return this.dropTableOn(this.db, this.migrationContext.DatabaseName, tableName)
+}
+
+// dropTableOn drops a given table on given server & database
+func (this *Applier) dropTableOn(db *gosql.DB, databaseName, tableName string) error {
 	query := fmt.Sprintf(`drop /* gh-ost */ table if exists %s.%s`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(databaseName),
 		sql.EscapeName(tableName),
 	)
 	this.migrationContext.Log.Infof("Dropping table %s.%s",
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(databaseName),
 		sql.EscapeName(tableName),
 	)
-	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+	if _, err := sqlutils.ExecNoPrepare(db, query); err != nil {
 		return err
 	}
 	this.migrationContext.Log.Infof("Table dropped")
@@ -465,24 +951,314 @@ func (this *Applier) CreateTriggersOnGhost() error {
 	return err
 }
 
-// DropChangelogTable drops the changelog table on the applier host
-func (this *Applier) DropChangelogTable() error {
-	return this.dropTable(this.migrationContext.GetChangelogTableName())
+// getGhostForeignKeyColumns returns the columns of a child-side foreign key, as named on the ghost table
+func (this *Applier) getGhostForeignKeyColumns(foreignKey mysql.ForeignKey) []string {
+	columns := make([]string, len(foreignKey.Columns))
+	for i, column := range foreignKey.Columns {
+		columns[i] = column
+		if mappedColumn, ok := this.migrationContext.ColumnRenameMap[column]; ok {
+			columns[i] = mappedColumn
+		}
+	}
+	return columns
 }
 
-// DropOldTable drops the _Old table on the applier host
-func (this *Applier) DropOldTable() error {
-	return this.dropTable(this.migrationContext.GetOldTableName())
+// ValidateChildSideForeignKeysOnGhost verifies all rows of the ghost table satisfy the original
+// table's child-side foreign keys, prior to creating these on the ghost table. It runs within the
+// cut-over's locked section, once all binlog events are applied: the ghost table is then in sync
+// with the original table, and no longer written to.
+func (this *Applier) ValidateChildSideForeignKeysOnGhost() error {
+	for _, foreignKey := range this.migrationContext.ChildSideForeignKeys {
+		query, err := sql.BuildForeignKeyViolationsQuery(
+			this.migrationContext.DatabaseName,
+			this.migrationContext.GetGhostTableName(),
+			this.getGhostForeignKeyColumns(foreignKey),
+			foreignKey.ReferencedTableSchema,
+			foreignKey.ReferencedTableName,
+			foreignKey.ReferencedColumns,
+		)
+		if err != nil {
+			return err
+		}
+		var numViolations int64
+		if err := this.db.QueryRow(query).Scan(&numViolations); err != nil {
+			return err
+		}
+		if numViolations > 0 {
+			return fmt.Errorf("Found %d rows on %s.%s which do not satisfy foreign key %s. Bailing out", numViolations, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetGhostTableName()), sql.EscapeName(foreignKey.Name))
+		}
+		this.migrationContext.Log.Infof("Validated all rows satisfy foreign key %s", sql.EscapeName(foreignKey.Name))
+	}
+	return nil
 }
 
-// DropGhostTable drops the ghost table on the applier host
+// buildChildSideForeignKeysQuery builds the ALTER statement adding the original table's child-side
+// foreign keys to the ghost table, or dropping them from it
+func (this *Applier) buildChildSideForeignKeysQuery(drop bool) (string, error) {
+	clauses := []string{}
+	for _, foreignKey := range this.migrationContext.ChildSideForeignKeys {
+		ghostForeignKeyName := this.migrationContext.GetGhostForeignKeyName(foreignKey.Name)
+		if drop {
+			clauses = append(clauses, fmt.Sprintf("drop foreign key %s", sql.EscapeName(ghostForeignKeyName)))
+			continue
+		}
+		definition, err := sql.BuildForeignKeyDefinition(
+			ghostForeignKeyName,
+			this.getGhostForeignKeyColumns(foreignKey),
+			foreignKey.ReferencedTableSchema,
+			foreignKey.ReferencedTableName,
+			foreignKey.ReferencedColumns,
+			foreignKey.UpdateRule,
+			foreignKey.DeleteRule,
+		)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, fmt.Sprintf("add %s", definition))
+	}
+	return fmt.Sprintf(`alter /* gh-ost */ table %s.%s %s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		strings.Join(clauses, ", "),
+	), nil
+}
+
+// execChildSideForeignKeysQuery runs given ALTER on the ghost table with foreign_key_checks=0, which makes
+// for an in-place, non-copying ALTER, and with the cut-over's lock timeout, as it runs within the cut-over
+func (this *Applier) execChildSideForeignKeysQuery(query string) error {
+	this.migrationContext.Log.Debugf("ALTER statement: %s", query)
+
+	tx, err := this.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`set /* gh-ost */ session lock_wait_timeout:=%d`, this.migrationContext.CutOverLockTimeoutSeconds)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`SET SESSION foreign_key_checks = 0`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddChildSideForeignKeysToGhost creates the original table's child-side foreign keys on the ghost
+// table. It runs within the cut-over's locked section, right after ValidateChildSideForeignKeysOnGhost:
+// foreign keys are created with foreign_key_checks=0, rows having just been validated.
+func (this *Applier) AddChildSideForeignKeysToGhost() error {
+	query, err := this.buildChildSideForeignKeysQuery(false)
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Adding %d foreign keys to ghost table %s.%s",
+		len(this.migrationContext.ChildSideForeignKeys),
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+	)
+	if err := this.execChildSideForeignKeysQuery(query); err != nil {
+		return err
+	}
+	this.childSideForeignKeysOnGhost = true
+	this.migrationContext.Log.Infof("Foreign keys added to ghost table")
+	return nil
+}
+
+// DropChildSideForeignKeysFromGhost undoes AddChildSideForeignKeysToGhost when the cut-over fails past it,
+// so that the ghost table goes on catching up with the original table without foreign keys until the next
+// cut-over attempt.
+func (this *Applier) DropChildSideForeignKeysFromGhost() error {
+	if !this.childSideForeignKeysOnGhost {
+		return nil
+	}
+	query, err := this.buildChildSideForeignKeysQuery(true)
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Dropping %d foreign keys from ghost table %s.%s",
+		len(this.migrationContext.ChildSideForeignKeys),
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+	)
+	if err := this.execChildSideForeignKeysQuery(query); err != nil {
+		return err
+	}
+	this.childSideForeignKeysOnGhost = false
+	return nil
+}
+
+// getParentSideForeignKeysChildTables groups the parent-side foreign keys by their (escaped) child table,
+// in order of appearance
+func (this *Applier) getParentSideForeignKeysChildTables() (childTables []string, childTablesForeignKeys map[string][]mysql.ForeignKey) {
+	childTablesForeignKeys = make(map[string][]mysql.ForeignKey)
+	for _, foreignKey := range this.migrationContext.ParentSideForeignKeys {
+		childTable := fmt.Sprintf("%s.%s", sql.EscapeName(foreignKey.TableSchema), sql.EscapeName(foreignKey.TableName))
+		if _, ok := childTablesForeignKeys[childTable]; !ok {
+			childTables = append(childTables, childTable)
+		}
+		childTablesForeignKeys[childTable] = append(childTablesForeignKeys[childTable], foreignKey)
+	}
+	return childTables, childTablesForeignKeys
+}
+
+// buildRebindForeignKeysQuery builds the ALTER statement re-creating given foreign keys of a child table
+// onto the ghost table, which the cut-over then renames onto the migrated table. When restoring, it rather
+// builds the statement re-creating them as they were, onto the original table.
+func (this *Applier) buildRebindForeignKeysQuery(childTable string, foreignKeys []mysql.ForeignKey, restore bool) (string, error) {
+	clauses := []string{}
+	for _, foreignKey := range foreignKeys {
+		dropName := foreignKey.Name
+		addName := this.migrationContext.GetReboundForeignKeyName(foreignKey.Name)
+		referencedTableName := this.migrationContext.GetGhostTableName()
+		referencedColumns := make([]string, len(foreignKey.ReferencedColumns))
+		for i, column := range foreignKey.ReferencedColumns {
+			referencedColumns[i] = column
+			if mappedColumn, ok := this.migrationContext.ColumnRenameMap[column]; ok && !restore {
+				referencedColumns[i] = mappedColumn
+			}
+		}
+		if restore {
+			dropName, addName = addName, dropName
+			referencedTableName = this.migrationContext.OriginalTableName
+		}
+		definition, err := sql.BuildForeignKeyDefinition(
+			addName,
+			foreignKey.Columns,
+			this.migrationContext.DatabaseName,
+			referencedTableName,
+			referencedColumns,
+			foreignKey.UpdateRule,
+			foreignKey.DeleteRule,
+		)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, fmt.Sprintf("drop foreign key %s", sql.EscapeName(dropName)), fmt.Sprintf("add %s", definition))
+	}
+	return fmt.Sprintf(`alter /* gh-ost */ table %s %s`, childTable, strings.Join(clauses, ", ")), nil
+}
+
+// RebindParentSideForeignKeys re-creates the foreign keys of child tables which reference the
+// original table, so that they reference the ghost table. It runs within the cut-over's locked section,
+// on the session locking the original and child tables, right before the tables are swapped: the
+// re-created foreign keys follow the ghost table as it is renamed onto the migrated table, while the
+// original table is renamed away with no foreign keys referencing it.
+// Foreign keys are re-created with foreign_key_checks=0; each child table is altered once. Should any
+// ALTER fail, the child tables already altered are restored, and the cut-over is to be aborted.
+func (this *Applier) RebindParentSideForeignKeys() error {
+	childTables, childTablesForeignKeys := this.getParentSideForeignKeysChildTables()
+	if _, err := sqlutils.ExecNoPrepare(this.singletonDB, `SET /* gh-ost */ SESSION foreign_key_checks = 0`); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := sqlutils.ExecNoPrepare(this.singletonDB, `SET /* gh-ost */ SESSION foreign_key_checks = 1`); err != nil {
+			this.migrationContext.Log.Errore(err)
+		}
+	}()
+
+	var totalDuration time.Duration
+	for _, childTable := range childTables {
+		query, err := this.buildRebindForeignKeysQuery(childTable, childTablesForeignKeys[childTable], false)
+		if err != nil {
+			return err
+		}
+		this.migrationContext.Log.Infof("Rebinding %d foreign keys on %s onto %s.%s",
+			len(childTablesForeignKeys[childTable]),
+			childTable,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetMigratedTableName()),
+		)
+		this.migrationContext.Log.Debugf("ALTER statement: %s", query)
+
+		startTime := time.Now()
+		if _, err := sqlutils.ExecNoPrepare(this.singletonDB, query); err != nil {
+			this.migrationContext.Log.Errorf("Failed rebinding foreign keys on %s: %+v; restoring child tables", childTable, err)
+			this.restoreParentSideForeignKeys(this.reboundChildTables)
+			return err
+		}
+		this.reboundChildTables = append(this.reboundChildTables, childTable)
+		duration := time.Since(startTime)
+		totalDuration += duration
+		for _, foreignKey := range childTablesForeignKeys[childTable] {
+			this.migrationContext.Log.Infof("Rebuilt foreign key %s on %s as %s", sql.EscapeName(foreignKey.Name), childTable, sql.EscapeName(this.migrationContext.GetReboundForeignKeyName(foreignKey.Name)))
+		}
+		this.migrationContext.Log.Infof("Rebinding foreign keys of %s took %+v, while tables were locked", childTable, duration)
+	}
+	this.migrationContext.ParentSideForeignKeysRebindDuration = totalDuration
+	this.migrationContext.Log.Infof("Rebound %d parent-side foreign keys on %d child tables; added lock time: %+v", len(this.migrationContext.ParentSideForeignKeys), len(childTables), totalDuration)
+	return nil
+}
+
+// RestoreParentSideForeignKeys undoes RebindParentSideForeignKeys, when the cut-over fails past it.
+// It runs on the session locking the original and child tables.
+func (this *Applier) RestoreParentSideForeignKeys() error {
+	if len(this.reboundChildTables) == 0 {
+		return nil
+	}
+	if _, err := sqlutils.ExecNoPrepare(this.singletonDB, `SET /* gh-ost */ SESSION foreign_key_checks = 0`); err != nil {
+		return err
+	}
+	defer sqlutils.ExecNoPrepare(this.singletonDB, `SET /* gh-ost */ SESSION foreign_key_checks = 1`)
+	return this.restoreParentSideForeignKeys(this.reboundChildTables)
+}
+
+// restoreParentSideForeignKeys re-creates the foreign keys of given, rebound child tables onto the original table
+func (this *Applier) restoreParentSideForeignKeys(childTables []string) (err error) {
+	_, childTablesForeignKeys := this.getParentSideForeignKeysChildTables()
+	for i := len(childTables) - 1; i >= 0; i-- {
+		childTable := childTables[i]
+		query, buildErr := this.buildRebindForeignKeysQuery(childTable, childTablesForeignKeys[childTable], true)
+		if buildErr != nil {
+			return buildErr
+		}
+		this.migrationContext.Log.Infof("Restoring foreign keys on %s", childTable)
+		if _, execErr := sqlutils.ExecNoPrepare(this.singletonDB, query); execErr != nil {
+			err = this.migrationContext.Log.Errore(execErr)
+			continue
+		}
+		this.reboundChildTables = this.reboundChildTables[:i]
+	}
+	return err
+}
+
+// DropChangelogTable drops the changelog table on the applier host
+func (this *Applier) DropChangelogTable() error {
+	return this.dropTable(this.migrationContext.GetChangelogTableName())
+}
+
+// DropOldTable drops the _Old table on the applier host
+func (this *Applier) DropOldTable() error {
+	return this.dropTable(this.migrationContext.GetOldTableName())
+}
+
+// DropSideGhostTable drops the side table's ghost table (--side-table) on the applier host
+func (this *Applier) DropSideGhostTable() error {
+	return this.dropTable(this.migrationContext.GetSideGhostTableName())
+}
+
+// DropShardGhostTables drops the shard ghost tables (--shard-tables) on the applier host
+func (this *Applier) DropShardGhostTables() error {
+	for i := range this.migrationContext.ShardTableNames {
+		if err := this.dropTable(this.migrationContext.GetShardGhostTableName(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropGhostTable drops the ghost table on the applier host, or on the target server of a
+// cross-server migration
 func (this *Applier) DropGhostTable() error {
-	return this.dropTable(this.migrationContext.GetGhostTableName())
+	return this.dropTableOn(this.ghostDB, this.migrationContext.GetGhostDatabaseName(), this.migrationContext.GetGhostTableName())
 }
 
 // WriteChangelog writes a value to the changelog table.
 // It returns the hint as given, for convenience
-func (this *Applier) WriteChangelog(hint, value string) (string, error) {
+// buildWriteChangelogQuery returns the query and args writing given hint and value onto the changelog table
+func (this *Applier) buildWriteChangelogQuery(hint, value string) (string, []interface{}) {
 	explicitId := 0
 	switch hint {
 	case "heartbeat":
@@ -491,6 +1267,8 @@ func (this *Applier) WriteChangelog(hint, value string) (string, error) {
 		explicitId = 2
 	case "throttle":
 		explicitId = 3
+	case "archive-file":
+		explicitId = 4
 	}
 	query := fmt.Sprintf(`
 		insert /* gh-ost */
@@ -501,11 +1279,18 @@ func (this *Applier) WriteChangelog(hint, value string) (string, error) {
 			(NULLIF(?, 0), ?, ?)
 		on duplicate key update
 			last_update=NOW(),
+			hint=VALUES(hint),
 			value=VALUES(value)`,
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetChangelogTableName()),
 	)
-	_, err := sqlutils.ExecNoPrepare(this.db, query, explicitId, hint, value)
+	// hint is (re)set so that partial binlog row images (binlog_row_image=MINIMAL) include it
+	return query, []interface{}{explicitId, hint, value}
+}
+
+func (this *Applier) WriteChangelog(hint, value string) (string, error) {
+	query, args := this.buildWriteChangelogQuery(hint, value)
+	_, err := sqlutils.ExecNoPrepare(this.db, query, args...)
 	return hint, err
 }
 
@@ -571,9 +1356,9 @@ func (this *Applier) ExecuteThrottleQuery() (int64, error) {
 }
 
 // readMigrationMinValues returns the minimum values to be iterated on rowcopy
-func (this *Applier) readMigrationMinValues(tx *gosql.Tx, uniqueKey *sql.UniqueKey) error {
+func (this *Applier) readMigrationMinValues(tx *gosql.Tx, uniqueKey *sql.UniqueKey, partitionName string) error {
 	this.migrationContext.Log.Debugf("Reading migration range according to key: %s", uniqueKey.Name)
-	query, err := sql.BuildUniqueKeyMinValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, uniqueKey)
+	query, err := sql.BuildUniqueKeyMinValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, partitionName, uniqueKey)
 	if err != nil {
 		return err
 	}
@@ -596,9 +1381,9 @@ func (this *Applier) readMigrationMinValues(tx *gosql.Tx, uniqueKey *sql.UniqueK
 }
 
 // readMigrationMaxValues returns the maximum values to be iterated on rowcopy
-func (this *Applier) readMigrationMaxValues(tx *gosql.Tx, uniqueKey *sql.UniqueKey) error {
+func (this *Applier) readMigrationMaxValues(tx *gosql.Tx, uniqueKey *sql.UniqueKey, partitionName string) error {
 	this.migrationContext.Log.Debugf("Reading migration range according to key: %s", uniqueKey.Name)
-	query, err := sql.BuildUniqueKeyMaxValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, uniqueKey)
+	query, err := sql.BuildUniqueKeyMaxValuesPreparedQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, partitionName, uniqueKey)
 	if err != nil {
 		return err
 	}
@@ -641,23 +1426,59 @@ func (this *Applier) ReadMigrationRangeValues() error {
 	if _, err := this.WriteChangelogState(string(ReadMigrationRangeValues)); err != nil {
 		return err
 	}
+	if err := this.readMigrationRangeValues(this.migrationContext.GetCopyPartitionName()); err != nil {
+		return err
+	}
+	if this.migrationContext.CopyPerPartition && this.migrationContext.MigrationRangeMinValues == nil {
+		// First partition is empty
+		if _, err := this.ReadNextPartitionRangeValues(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// readMigrationRangeValues reads min/max values of the table, or of given partition
+func (this *Applier) readMigrationRangeValues(partitionName string) error {
 	tx, err := this.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if err := this.readMigrationMinValues(tx, this.migrationContext.UniqueKey); err != nil {
+	this.migrationContext.MigrationRangeMinValues = nil
+	this.migrationContext.MigrationRangeMaxValues = nil
+	if err := this.readMigrationMinValues(tx, this.migrationContext.UniqueKey, partitionName); err != nil {
 		return err
 	}
-	if err := this.readMigrationMaxValues(tx, this.migrationContext.UniqueKey); err != nil {
+	if err := this.readMigrationMaxValues(tx, this.migrationContext.UniqueKey, partitionName); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
 
+// ReadNextPartitionRangeValues moves row copy onto the next non-empty partition, when
+// copying per partition, and reads its min/max values. It returns "false" if there is
+// no such partition.
+func (this *Applier) ReadNextPartitionRangeValues() (hasFurtherPartition bool, err error) {
+	for this.migrationContext.HasNextCopyPartition() {
+		partitionName := this.migrationContext.GetNextCopyPartitionName()
+		if err := this.readMigrationRangeValues(partitionName); err != nil {
+			return false, err
+		}
+		this.migrationContext.AdvanceCopyPartition()
+		this.migrationContext.MigrationIterationRangeMaxValues = nil
+		if this.migrationContext.MigrationRangeMinValues != nil {
+			ordinal, total := this.migrationContext.GetCopyPartitionProgress()
+			this.migrationContext.Log.Infof("Copying partition %s (%d/%d)", partitionName, ordinal, total)
+			return true, nil
+		}
+		this.migrationContext.Log.Debugf("Partition %s is empty", partitionName)
+	}
+	return false, nil
+}
+
 // CalculateNextIterationRangeEndValues reads the next-iteration-range-end unique key values,
 // which will be used for copying the next chunk of rows. Ir returns "false" if there is
 // no further chunk to work through, i.e. we're past the last chunk and are done with
@@ -675,11 +1496,12 @@ func (this *Applier) CalculateNextIterationRangeEndValues() (hasFurtherRange boo
 		query, explodedArgs, err := buildFunc(
 			this.migrationContext.DatabaseName,
 			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetCopyPartitionName(),
 			&this.migrationContext.UniqueKey.Columns,
 			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
 			this.migrationContext.MigrationRangeMaxValues.AbstractValues(),
 			atomic.LoadInt64(&this.migrationContext.ChunkSize),
-			this.migrationContext.GetIteration() == 0,
+			this.migrationContext.IsRangeStartIteration(),
 			fmt.Sprintf("iteration:%d", this.migrationContext.GetIteration()),
 		)
 		if err != nil {
@@ -711,78 +1533,441 @@ func (this *Applier) CalculateNextIterationRangeEndValues() (hasFurtherRange boo
 	return hasFurtherRange, nil
 }
 
+// archiveIterationRange archives the chunk's rows, or its rows the row filter discards, within
+// the chunk-INSERT's transaction. Rows are inserted onto the archive table, or read and returned
+// as records, which the caller writes onto the archive file before the transaction commits.
+func (this *Applier) archiveIterationRange(tx *gosql.Tx, uniqueKeyName string) (rowsArchived int64, records []*archiveRecord, err error) {
+	if this.migrationContext.ArchiveTableName != "" {
+		query, explodedArgs, err := sql.BuildRangeInsertPreparedQuery(
+			this.migrationContext.DatabaseName,
+			this.migrationContext.OriginalTableName,
+			this.migrationContext.ArchiveTableName,
+			this.migrationContext.GetCopyPartitionName(),
+			this.migrationContext.ArchiveColumns.Names(),
+			this.migrationContext.ArchiveColumns.Names(),
+			nil,
+			this.migrationContext.ArchiveRowFilter,
+			uniqueKeyName,
+			&this.migrationContext.UniqueKey.Columns,
+			nil,
+			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+			this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+			this.migrationContext.IsRangeStartIteration(),
+			this.migrationContext.IsTransactionalTable(),
+			strings.HasPrefix(this.migrationContext.ApplierMySQLVersion, "8."),
+		)
+		if err != nil {
+			return rowsArchived, records, err
+		}
+		result, err := tx.Exec(query, explodedArgs...)
+		if err != nil {
+			return rowsArchived, records, err
+		}
+		rowsArchived, _ = result.RowsAffected()
+		return rowsArchived, records, nil
+	}
+	if this.archiveFileWriter == nil {
+		return rowsArchived, records, nil
+	}
+	query, explodedArgs, err := sql.BuildRangeSelectPreparedQuery(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.OriginalTableName,
+		this.migrationContext.GetCopyPartitionName(),
+		this.migrationContext.ArchiveColumns.Names(),
+		this.migrationContext.ArchiveRowFilter,
+		uniqueKeyName,
+		&this.migrationContext.UniqueKey.Columns,
+		this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+		this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+		this.migrationContext.IsRangeStartIteration(),
+	)
+	if err != nil {
+		return rowsArchived, records, err
+	}
+	// Archived TIMESTAMP values are written in UTC, as binlog events hold them
+	if _, err := tx.Exec(`SET SESSION time_zone = '+00:00'`); err != nil {
+		return rowsArchived, records, err
+	}
+	rows, err := tx.Query(query, explodedArgs...)
+	if err != nil {
+		return rowsArchived, records, err
+	}
+	defer rows.Close()
+
+	archiveColumns := this.migrationContext.ArchiveColumns.Columns()
+	rawValues := make([]gosql.RawBytes, len(archiveColumns))
+	valuePointers := make([]interface{}, len(rawValues))
+	for i := range rawValues {
+		valuePointers[i] = &rawValues[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(valuePointers...); err != nil {
+			return rowsArchived, records, err
+		}
+		record := &archiveRecord{operation: archiveOperationCopy, values: make([]interface{}, len(rawValues))}
+		for i, rawValue := range rawValues {
+			switch {
+			case rawValue == nil:
+			case archiveColumns[i].IsBinary():
+				record.values[i] = []byte(string(rawValue))
+			default:
+				record.values[i] = string(rawValue)
+			}
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return rowsArchived, records, err
+	}
+	return int64(len(records)), records, nil
+}
+
+// writeArchiveFileRecords writes given records onto the archive file, ahead of committing the transaction
+// applying them, and records the file's size on the changelog table within that transaction. Should
+// gh-ost crash, the file holds all committed records, and whatever follows the recorded size is uncommitted.
+func (this *Applier) writeArchiveFileRecords(tx *gosql.Tx, records []*archiveRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	size, err := this.archiveFileWriter.WriteRecords(records)
+	if err != nil {
+		return err
+	}
+	query, args := this.buildWriteChangelogQuery("archive-file", fmt.Sprintf("%d", size))
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+// buildIterationInsertQueries builds the queries copying the current iteration range: onto the ghost table,
+// or on a horizontal split (--shard-tables), onto each of the shard ghost tables, the rows routed onto it
+func (this *Applier) buildIterationInsertQueries(uniqueKeyName string, fullRowMatchColumnNames []string) (insertQueries []*dmlBuildResult, err error) {
+	ghostTableNames := []string{this.migrationContext.GetGhostTableName()}
+	rowFilters := []*sql.RowFilter{this.migrationContext.RowFilter}
+	if len(this.shardRowFilters) > 0 {
+		ghostTableNames = []string{}
+		for i := range this.migrationContext.ShardTableNames {
+			ghostTableNames = append(ghostTableNames, this.migrationContext.GetShardGhostTableName(i))
+		}
+		rowFilters = this.shardRowFilters
+	}
+	for i, ghostTableName := range ghostTableNames {
+		query, explodedArgs, err := sql.BuildRangeInsertPreparedQuery(
+			this.migrationContext.DatabaseName,
+			this.migrationContext.OriginalTableName,
+			ghostTableName,
+			this.migrationContext.GetCopyPartitionName(),
+			this.migrationContext.SharedColumns.Names(),
+			this.migrationContext.MappedSharedColumns.Names(),
+			this.migrationContext.ColumnTransformations,
+			rowFilters[i],
+			uniqueKeyName,
+			&this.migrationContext.UniqueKey.Columns,
+			fullRowMatchColumnNames,
+			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+			this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+			this.migrationContext.IsRangeStartIteration(),
+			this.migrationContext.IsTransactionalTable(),
+			// TODO: Don't hardcode this
+			strings.HasPrefix(this.migrationContext.ApplierMySQLVersion, "8."),
+		)
+		if err != nil {
+			return insertQueries, err
+		}
+		insertQueries = append(insertQueries, newDmlBuildResult(query, explodedArgs, 0, nil))
+	}
+	return insertQueries, nil
+}
+
+// copySideIterationRange copies the side table's columns (--side-table) of the current iteration range
+// onto the side table's ghost table, within given transaction, which copies the range onto the ghost table
+func (this *Applier) copySideIterationRange(tx *gosql.Tx, uniqueKeyName string) error {
+	if this.sideInsertQueryBuilder == nil {
+		return nil
+	}
+	query, explodedArgs, err := sql.BuildRangeInsertPreparedQuery(
+		this.migrationContext.DatabaseName,
+		this.migrationContext.OriginalTableName,
+		this.migrationContext.GetSideGhostTableName(),
+		this.migrationContext.GetCopyPartitionName(),
+		this.migrationContext.SideTableColumns.Names(),
+		this.migrationContext.SideTableColumns.Names(),
+		nil,
+		this.migrationContext.RowFilter,
+		uniqueKeyName,
+		&this.migrationContext.UniqueKey.Columns,
+		nil,
+		this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+		this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+		this.migrationContext.IsRangeStartIteration(),
+		this.migrationContext.IsTransactionalTable(),
+		strings.HasPrefix(this.migrationContext.ApplierMySQLVersion, "8."),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(query, explodedArgs...)
+	return err
+}
+
 // ApplyIterationInsertQuery issues a chunk-INSERT query on the ghost table. It is where
-// data actually gets copied from original table.
-func (this *Applier) ApplyIterationInsertQuery() (chunkSize int64, rowsAffected int64, duration time.Duration, err error) {
+// data actually gets copied from original table. With a row filter (--where), it also counts
+// the chunk's rows which the filter discards. Archived rows (--archive-table, --archive-file)
+// are archived in the same transaction, as are the side table's columns copied (--side-table). On a cross-server migration, the chunk is rather read
+// off the original table and written onto the target server.
+func (this *Applier) ApplyIterationInsertQuery() (chunkSize int64, rowsAffected int64, rowsDiscarded int64, duration time.Duration, err error) {
 	startTime := time.Now()
 	chunkSize = atomic.LoadInt64(&this.migrationContext.ChunkSize)
 
-	query, explodedArgs, err := sql.BuildRangeInsertPreparedQuery(
+	uniqueKeyName := ""
+	if this.migrationContext.UniqueKey.HasOriginalIndex() {
+		uniqueKeyName = this.migrationContext.UniqueKey.Name
+	}
+	var fullRowMatchColumnNames []string
+	if this.migrationContext.UniqueKey.IsFullRow {
+		fullRowMatchColumnNames = this.migrationContext.FullRowMatchColumns.Names()
+	}
+	var discardedCountQuery string
+	var discardedCountArgs []interface{}
+	if this.migrationContext.RowFilter != nil {
+		discardedCountQuery, discardedCountArgs, err = sql.BuildRangeDiscardedCountPreparedQuery(
+			this.migrationContext.DatabaseName,
+			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetCopyPartitionName(),
+			this.migrationContext.RowFilter,
+			uniqueKeyName,
+			&this.migrationContext.UniqueKey.Columns,
+			this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
+			this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
+			this.migrationContext.IsRangeStartIteration(),
+		)
+		if err != nil {
+			return chunkSize, rowsAffected, rowsDiscarded, duration, err
+		}
+	}
+	if this.migrationContext.IsCrossServerMigration() {
+		if rowsAffected, rowsDiscarded, err = this.copyIterationRangeToTarget(uniqueKeyName, discardedCountQuery, discardedCountArgs); err != nil {
+			return chunkSize, rowsAffected, rowsDiscarded, duration, err
+		}
+		duration = time.Since(startTime)
+		this.migrationContext.Log.Debugf(
+			"Copied range onto target: [%s]..[%s]; iteration: %d; chunk-size: %d",
+			this.migrationContext.MigrationIterationRangeMinValues,
+			this.migrationContext.MigrationIterationRangeMaxValues,
+			this.migrationContext.GetIteration(),
+			chunkSize)
+		return chunkSize, rowsAffected, rowsDiscarded, duration, nil
+	}
+	insertQueries, err := this.buildIterationInsertQueries(uniqueKeyName, fullRowMatchColumnNames)
+	if err != nil {
+		return chunkSize, rowsAffected, rowsDiscarded, duration, err
+	}
+
+	var rowsArchived int64
+	var archiveRecords []*archiveRecord
+	err = func() error {
+		tx, err := this.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		sessionQuery := fmt.Sprintf(`SET SESSION time_zone = '%s'`, this.migrationContext.ApplierTimeZone)
+		sessionQuery = fmt.Sprintf("%s, %s", sessionQuery, this.generateSqlModeQuery())
+
+		if _, err := tx.Exec(sessionQuery); err != nil {
+			return err
+		}
+		for _, insertQuery := range insertQueries {
+			result, err := tx.Exec(insertQuery.query, insertQuery.args...)
+			if err != nil {
+				return err
+			}
+			insertRowsAffected, _ := result.RowsAffected()
+			rowsAffected += insertRowsAffected
+		}
+		if discardedCountQuery != "" {
+			if err := tx.QueryRow(discardedCountQuery, discardedCountArgs...).Scan(&rowsDiscarded); err != nil {
+				return err
+			}
+		}
+		if err := this.copySideIterationRange(tx, uniqueKeyName); err != nil {
+			return err
+		}
+		if rowsArchived, archiveRecords, err = this.archiveIterationRange(tx, uniqueKeyName); err != nil {
+			return err
+		}
+		if err := this.writeArchiveFileRecords(tx, archiveRecords); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}()
+
+	if err != nil {
+		return chunkSize, rowsAffected, rowsDiscarded, duration, err
+	}
+	atomic.AddInt64(&this.migrationContext.TotalRowsArchived, rowsArchived)
+	duration = time.Since(startTime)
+	this.migrationContext.Log.Debugf(
+		"Issued INSERT on range: [%s]..[%s]; iteration: %d; chunk-size: %d",
+		this.migrationContext.MigrationIterationRangeMinValues,
+		this.migrationContext.MigrationIterationRangeMaxValues,
+		this.migrationContext.GetIteration(),
+		chunkSize)
+	return chunkSize, rowsAffected, rowsDiscarded, duration, nil
+}
+
+// copyIterationRangeToTarget copies the current iteration range of a cross-server migration: rows are
+// read off the original table, then inserted onto the ghost table on the target server. The source
+// transaction, holding shared locks on the range, only commits once the rows are written on the target.
+func (this *Applier) copyIterationRangeToTarget(uniqueKeyName string, discardedCountQuery string, discardedCountArgs []interface{}) (rowsAffected int64, rowsDiscarded int64, err error) {
+	query, explodedArgs, ghostColumns, err := sql.BuildRangeCopySelectPreparedQuery(
 		this.migrationContext.DatabaseName,
 		this.migrationContext.OriginalTableName,
-		this.migrationContext.GetGhostTableName(),
+		this.migrationContext.GetCopyPartitionName(),
 		this.migrationContext.SharedColumns.Names(),
 		this.migrationContext.MappedSharedColumns.Names(),
-		this.migrationContext.UniqueKey.Name,
+		this.migrationContext.ColumnTransformations,
+		this.migrationContext.RowFilter,
+		uniqueKeyName,
 		&this.migrationContext.UniqueKey.Columns,
 		this.migrationContext.MigrationIterationRangeMinValues.AbstractValues(),
 		this.migrationContext.MigrationIterationRangeMaxValues.AbstractValues(),
-		this.migrationContext.GetIteration() == 0,
+		this.migrationContext.IsRangeStartIteration(),
 		this.migrationContext.IsTransactionalTable(),
-		// TODO: Don't hardcode this
 		strings.HasPrefix(this.migrationContext.ApplierMySQLVersion, "8."),
 	)
 	if err != nil {
-		return chunkSize, rowsAffected, duration, err
+		return rowsAffected, rowsDiscarded, err
+	}
+	sessionQuery := fmt.Sprintf(`SET SESSION time_zone = '%s'`, this.migrationContext.ApplierTimeZone)
+
+	sourceTx, err := this.db.Begin()
+	if err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	defer sourceTx.Rollback()
+	if _, err := sourceTx.Exec(sessionQuery); err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	rowsValues, err := this.readRangeCopyRows(sourceTx, query, explodedArgs, ghostColumns)
+	if err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	if discardedCountQuery != "" {
+		if err := sourceTx.QueryRow(discardedCountQuery, discardedCountArgs...).Scan(&rowsDiscarded); err != nil {
+			return rowsAffected, rowsDiscarded, err
+		}
+	}
+
+	targetTx, err := this.ghostDB.Begin()
+	if err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	defer targetTx.Rollback()
+	if _, err := targetTx.Exec(fmt.Sprintf("%s, %s", sessionQuery, this.generateSqlModeQuery())); err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	insertRows := func(batch [][]interface{}) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		insertQuery, err := sql.BuildMultiRowInsertIgnorePreparedQuery(this.migrationContext.GetGhostDatabaseName(), this.migrationContext.GetGhostTableName(), ghostColumns, len(batch))
+		if err != nil {
+			return err
+		}
+		args := make([]interface{}, 0, len(batch)*len(ghostColumns))
+		for _, rowValues := range batch {
+			args = append(args, rowValues...)
+		}
+		result, err := targetTx.Exec(insertQuery, args...)
+		if err != nil {
+			return err
+		}
+		batchRowsAffected, _ := result.RowsAffected()
+		rowsAffected += batchRowsAffected
+		return nil
 	}
-
-	sqlResult, err := func() (gosql.Result, error) {
-		tx, err := this.db.Begin()
-		if err != nil {
-			return nil, err
+	var batch [][]interface{}
+	batchBytes := 0
+	for _, rowValues := range rowsValues {
+		batch = append(batch, rowValues)
+		for _, value := range rowValues {
+			switch value := value.(type) {
+			case string:
+				batchBytes += len(value)
+			case []byte:
+				batchBytes += len(value)
+			}
 		}
-		defer tx.Rollback()
+		if batchBytes >= maxTargetCopyInsertBytes {
+			if err := insertRows(batch); err != nil {
+				return rowsAffected, rowsDiscarded, err
+			}
+			batch, batchBytes = nil, 0
+		}
+	}
+	if err := insertRows(batch); err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	if err := targetTx.Commit(); err != nil {
+		return rowsAffected, rowsDiscarded, err
+	}
+	return rowsAffected, rowsDiscarded, sourceTx.Commit()
+}
 
-		sessionQuery := fmt.Sprintf(`SET SESSION time_zone = '%s'`, this.migrationContext.ApplierTimeZone)
-		sessionQuery = fmt.Sprintf("%s, %s", sessionQuery, this.generateSqlModeQuery())
+// readRangeCopyRows reads the rows of a range copy query. Textual values are read as strings, such that
+// they are written in the connection's charset; other values are read as raw bytes.
+func (this *Applier) readRangeCopyRows(tx *gosql.Tx, query string, args []interface{}, ghostColumns []string) (rowsValues [][]interface{}, err error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		if _, err := tx.Exec(sessionQuery); err != nil {
-			return nil, err
+	textual := make([]bool, len(ghostColumns))
+	for i, columnName := range ghostColumns {
+		if column := this.migrationContext.GhostTableColumns.GetColumn(columnName); column != nil {
+			textual[i] = column.Charset != "" || column.Type == sql.JSONColumnType
 		}
-		result, err := tx.Exec(query, explodedArgs...)
-		if err != nil {
+	}
+	scanArgs := make([]interface{}, len(ghostColumns))
+	rawValues := make([]gosql.RawBytes, len(ghostColumns))
+	for i := range rawValues {
+		scanArgs[i] = &rawValues[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
 			return nil, err
 		}
-		if err := tx.Commit(); err != nil {
-			return nil, err
+		rowValues := make([]interface{}, len(ghostColumns))
+		for i, rawValue := range rawValues {
+			switch {
+			case rawValue == nil:
+				rowValues[i] = nil
+			case textual[i]:
+				rowValues[i] = string(rawValue)
+			default:
+				rowValues[i] = append([]byte{}, rawValue...)
+			}
 		}
-		return result, nil
-	}()
-
-	if err != nil {
-		return chunkSize, rowsAffected, duration, err
+		rowsValues = append(rowsValues, rowValues)
 	}
-	rowsAffected, _ = sqlResult.RowsAffected()
-	duration = time.Since(startTime)
-	this.migrationContext.Log.Debugf(
-		"Issued INSERT on range: [%s]..[%s]; iteration: %d; chunk-size: %d",
-		this.migrationContext.MigrationIterationRangeMinValues,
-		this.migrationContext.MigrationIterationRangeMaxValues,
-		this.migrationContext.GetIteration(),
-		chunkSize)
-	return chunkSize, rowsAffected, duration, nil
+	return rowsValues, rows.Err()
 }
 
-// LockOriginalTable places a write lock on the original table
+// LockOriginalTable places a write lock on the original table, as well as on the child tables of
+// parent-side foreign keys to be rebound (--rebind-parent-foreign-keys)
 func (this *Applier) LockOriginalTable() error {
-	query := fmt.Sprintf(`lock /* gh-ost */ tables %s.%s write`,
-		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
-	)
-	this.migrationContext.Log.Infof("Locking %s.%s",
+	lockedTables := []string{fmt.Sprintf("%s.%s",
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
-	)
+	)}
+	// Child tables of rebound foreign keys are locked along, so that none is written while its
+	// foreign keys are rebound
+	childTables, _ := this.getParentSideForeignKeysChildTables()
+	lockedTables = append(lockedTables, childTables...)
+	query := fmt.Sprintf(`lock /* gh-ost */ tables %s write`, strings.Join(lockedTables, " write, "))
+	this.migrationContext.Log.Infof("Locking %s", strings.Join(lockedTables, ", "))
 	this.migrationContext.LockTablesStartTime = time.Now()
 	if _, err := sqlutils.ExecNoPrepare(this.singletonDB, query); err != nil {
 		return err
@@ -804,7 +1989,7 @@ func (this *Applier) UnlockTables() error {
 
 // SwapTablesQuickAndBumpy issues a two-step swap table operation:
 // - rename original table to _old
-// - rename ghost table to original
+// - rename ghost table to original, or on a horizontal split (--shard-tables), each shard ghost table to its shard table
 // There is a point in time in between where the table does not exist.
 func (this *Applier) SwapTablesQuickAndBumpy() error {
 	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
@@ -817,29 +2002,141 @@ func (this *Applier) SwapTablesQuickAndBumpy() error {
 	if _, err := sqlutils.ExecNoPrepare(this.singletonDB, query); err != nil {
 		return err
 	}
+	if this.migrationContext.IsHorizontalSplit() {
+		for i, shardTableName := range this.migrationContext.ShardTableNames {
+			query = fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
+				sql.EscapeName(this.migrationContext.DatabaseName),
+				sql.EscapeName(this.migrationContext.GetShardGhostTableName(i)),
+				sql.EscapeName(shardTableName),
+			)
+			this.migrationContext.Log.Infof("Renaming shard ghost table %s", sql.EscapeName(this.migrationContext.GetShardGhostTableName(i)))
+			if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+				return err
+			}
+		}
+		this.migrationContext.RenameTablesEndTime = time.Now()
+
+		this.migrationContext.Log.Infof("Tables renamed")
+		return nil
+	}
 	query = fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
 	)
 	this.migrationContext.Log.Infof("Renaming ghost table")
 	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
 		return err
 	}
+	if this.migrationContext.IsVerticalSplit() {
+		query = fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+			sql.EscapeName(this.migrationContext.SideTableName),
+		)
+		this.migrationContext.Log.Infof("Renaming side ghost table")
+		if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+			return err
+		}
+	}
 	this.migrationContext.RenameTablesEndTime = time.Now()
 
 	this.migrationContext.Log.Infof("Tables renamed")
 	return nil
 }
 
+// RenameGhostTableOnTarget renames the ghost table on the target server of a cross-server migration
+// onto its final name. This is the hand-off: from this point on, the target table is the migrated table.
+func (this *Applier) RenameGhostTableOnTarget() error {
+	query := fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetTargetTableName()),
+	)
+	this.migrationContext.Log.Infof("Renaming ghost table on target")
+	this.migrationContext.RenameTablesStartTime = time.Now()
+	if _, err := sqlutils.ExecNoPrepare(this.ghostDB, query); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Ghost table renamed on target")
+	return nil
+}
+
+// RenameGhostTableOnTargetRollback renames the target table back to the ghost table, undoing
+// RenameGhostTableOnTarget when the hand-off fails
+func (this *Applier) RenameGhostTableOnTargetRollback() error {
+	query := fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetTargetTableName()),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
+		sql.EscapeName(this.migrationContext.GetGhostTableName()),
+	)
+	this.migrationContext.Log.Infof("Renaming back ghost table on target")
+	if _, err := sqlutils.ExecNoPrepare(this.ghostDB, query); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenameOriginalTableAway renames the original table to _del once a cross-server migration is
+// handed off. It is issued on the connection holding the write lock on the original table.
+func (this *Applier) RenameOriginalTableAway() error {
+	query := fmt.Sprintf(`alter /* gh-ost */ table %s.%s rename %s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetOldTableName()),
+	)
+	this.migrationContext.Log.Infof("Renaming original table")
+	if _, err := sqlutils.ExecNoPrepare(this.singletonDB, query); err != nil {
+		return err
+	}
+	this.migrationContext.RenameTablesEndTime = time.Now()
+	this.migrationContext.Log.Infof("Original table renamed")
+	return nil
+}
+
+// CreateRenamedTableCompatView creates a view under the original table name, over the
+// renamed, migrated table. This lets apps keep on using the old name after the cut-over.
+func (this *Applier) CreateRenamedTableCompatView() error {
+	query := fmt.Sprintf(`create /* gh-ost */ view %s.%s as select * from %s.%s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.RenamedTableName),
+	)
+	this.migrationContext.Log.Infof("Creating compatibility view %s.%s",
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+	)
+	if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Compatibility view created")
+	return nil
+}
+
 // RenameTablesRollback renames back both table: original back to ghost,
-// _old back to original. This is used by `--test-on-replica`
+// _old back to original, as well as the side table back to its ghost table (--side-table).
+// This is used by `--test-on-replica`
 func (this *Applier) RenameTablesRollback() (renameError error) {
+	if this.migrationContext.IsVerticalSplit() {
+		query := fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.SideTableName),
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+		)
+		this.migrationContext.Log.Infof("Renaming back side table")
+		if _, err := sqlutils.ExecNoPrepare(this.db, query); err != nil {
+			this.migrationContext.Log.Errore(err)
+		}
+	}
 	// Restoring tables to original names.
 	// We prefer the single, atomic operation:
 	query := fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s, %s.%s to %s.%s`,
 		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
@@ -854,7 +2151,7 @@ func (this *Applier) RenameTablesRollback() (renameError error) {
 	// But, if for some reason the above was impossible to do, we rename one by one.
 	query = fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
 		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
@@ -1202,8 +2499,34 @@ func (this *Applier) AtomicCutoverRename(sessionIdChan chan int64, tablesRenamed
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 		sql.EscapeName(this.migrationContext.DatabaseName),
-		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(this.migrationContext.GetMigratedTableName()),
 	)
+	if this.migrationContext.IsHorizontalSplit() {
+		// The original table is replaced by the shard tables, which come to exist in the same, atomic operation
+		query = fmt.Sprintf(`rename /* gh-ost */ table %s.%s to %s.%s`,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.OriginalTableName),
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetOldTableName()),
+		)
+		for i, shardTableName := range this.migrationContext.ShardTableNames {
+			query = fmt.Sprintf(`%s, %s.%s to %s.%s`, query,
+				sql.EscapeName(this.migrationContext.DatabaseName),
+				sql.EscapeName(this.migrationContext.GetShardGhostTableName(i)),
+				sql.EscapeName(this.migrationContext.DatabaseName),
+				sql.EscapeName(shardTableName),
+			)
+		}
+	}
+	if this.migrationContext.IsVerticalSplit() {
+		// The side table comes to exist in the same, atomic operation
+		query = fmt.Sprintf(`%s, %s.%s to %s.%s`, query,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.SideTableName),
+		)
+	}
 	this.migrationContext.Log.Infof("Issuing and expecting this to block: %s", query)
 	if _, err := tx.Exec(query); err != nil {
 		tablesRenamed <- err
@@ -1237,23 +2560,83 @@ func (this *Applier) updateModifiesUniqueKeyColumns(dmlEvent *binlog.BinlogDMLEv
 	return "", false
 }
 
+// validateWhereColumnsPresent checks that the before image of a DML event holds the columns the
+// event's row is matched by on the ghost table, which a partial binlog row image may lack
+func (this *Applier) validateWhereColumnsPresent(dmlEvent *binlog.BinlogDMLEvent) error {
+	if dmlEvent.WhereColumnsPresent == nil {
+		return nil
+	}
+	matchColumns := &this.migrationContext.UniqueKey.Columns
+	if this.migrationContext.UniqueKey.IsFullRow {
+		matchColumns = this.migrationContext.FullRowMatchColumns
+	}
+	for _, column := range matchColumns.Columns() {
+		if !binlog.IsColumnPresent(dmlEvent.WhereColumnsPresent, this.migrationContext.OriginalTableColumns.Ordinals[column.Name]) {
+			return fmt.Errorf("Binlog row image of %s lacks column %s, which is required to match rows. binlog_row_image=%s is not supported with migration key %s", dmlEvent, sql.EscapeName(column.Name), this.migrationContext.OriginalBinlogRowImage, this.migrationContext.UniqueKey.Name)
+		}
+	}
+	return nil
+}
+
+// validateFullRowImage checks that a binlog event holds complete row images, should the flags in use rely on
+// them. binlog_row_image is validated upon startup, yet a session may log its events with another row image.
+func (this *Applier) validateFullRowImage(dmlEvent *binlog.BinlogDMLEvent) error {
+	if dmlEvent.WhereColumnsPresent == nil && dmlEvent.NewColumnsPresent == nil {
+		return nil
+	}
+	if flags := this.migrationContext.GetFullRowImageFlags(); len(flags) > 0 {
+		return fmt.Errorf("Binlog row image of %s is partial, likely due to a session-level binlog_row_image, but %s require complete row images", dmlEvent, strings.Join(flags, ", "))
+	}
+	return nil
+}
+
+// originalTableRowsDelta returns the number of rows a DML event adds to the original table
+func originalTableRowsDelta(dmlEvent *binlog.BinlogDMLEvent) int64 {
+	switch dmlEvent.DML {
+	case binlog.InsertDML:
+		return 1
+	case binlog.DeleteDML:
+		return -1
+	}
+	return 0
+}
+
 // buildDMLEventQuery creates a query to operate on the ghost table, based on an intercepted binlog
 // event entry on the original table.
 func (this *Applier) buildDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) []*dmlBuildResult {
+	if len(this.shardInsertQueryBuilders) > 0 {
+		return this.buildShardDMLEventQuery(dmlEvent)
+	}
 	switch dmlEvent.DML {
 	case binlog.DeleteDML:
 		{
+			if err := this.validateWhereColumnsPresent(dmlEvent); err != nil {
+				return []*dmlBuildResult{newDmlBuildResultError(err)}
+			}
 			query, uniqueKeyArgs, err := this.dmlDeleteQueryBuilder.BuildQuery(dmlEvent.WhereColumnValues.AbstractValues())
 			return []*dmlBuildResult{newDmlBuildResult(query, uniqueKeyArgs, -1, err)}
 		}
 	case binlog.InsertDML:
 		{
+			if dmlEvent.NewColumnsPresent != nil {
+				query, sharedArgs, err := this.dmlInsertQueryBuilder.BuildPartialQuery(dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.NewColumnsPresent)
+				return []*dmlBuildResult{newDmlBuildResult(query, sharedArgs, 1, err)}
+			}
 			query, sharedArgs, err := this.dmlInsertQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues())
 			return []*dmlBuildResult{newDmlBuildResult(query, sharedArgs, 1, err)}
 		}
 	case binlog.UpdateDML:
 		{
-			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified {
+			if err := this.validateWhereColumnsPresent(dmlEvent); err != nil {
+				return []*dmlBuildResult{newDmlBuildResultError(err)}
+			}
+			// With a row filter, the updated row may come to match or no longer match the filter:
+			// it is deleted, and re-inserted if it matches
+			if modifiedColumn, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified || this.migrationContext.UniqueKey.IsFullRow || this.migrationContext.RowFilter != nil {
+				if dmlEvent.NewColumnsPresent != nil {
+					// The row is deleted and re-inserted, which takes all of its columns
+					return []*dmlBuildResult{newDmlBuildResultError(fmt.Errorf("Cannot apply %s modifying %s: its binlog row image lacks some columns (binlog_row_image=%s)", dmlEvent, sql.EscapeName(modifiedColumn), this.migrationContext.OriginalBinlogRowImage))}
+				}
 				results := make([]*dmlBuildResult, 0, 2)
 				dmlEvent.DML = binlog.DeleteDML
 				results = append(results, this.buildDMLEventQuery(dmlEvent)...)
@@ -1261,6 +2644,17 @@ func (this *Applier) buildDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) []*dmlB
 				results = append(results, this.buildDMLEventQuery(dmlEvent)...)
 				return results
 			}
+			if dmlEvent.NewColumnsPresent != nil {
+				query, sharedArgs, uniqueKeyArgs, err := this.dmlUpdateQueryBuilder.BuildPartialQuery(dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues(), dmlEvent.NewColumnsPresent)
+				if err == nil && query == "" {
+					// None of the changed columns are on the ghost table
+					return []*dmlBuildResult{}
+				}
+				args := sqlutils.Args()
+				args = append(args, sharedArgs...)
+				args = append(args, uniqueKeyArgs...)
+				return []*dmlBuildResult{newDmlBuildResult(query, args, 0, err)}
+			}
 			query, sharedArgs, uniqueKeyArgs, err := this.dmlUpdateQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
 			args := sqlutils.Args()
 			args = append(args, sharedArgs...)
@@ -1271,13 +2665,273 @@ func (this *Applier) buildDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) []*dmlB
 	return []*dmlBuildResult{newDmlBuildResultError(fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML))}
 }
 
-// ApplyDMLEventQueries applies multiple DML queries onto the _ghost_ table
+// buildArchiveDMLEventQuery creates the queries applying an intercepted binlog event onto the archive
+// table (--archive-table). It must be called before buildDMLEventQuery, which may rewrite the event.
+func (this *Applier) buildArchiveDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) []*dmlBuildResult {
+	if err := this.validateFullRowImage(dmlEvent); err != nil {
+		return []*dmlBuildResult{newDmlBuildResultError(err)}
+	}
+	switch dmlEvent.DML {
+	case binlog.DeleteDML:
+		{
+			query, uniqueKeyArgs, err := this.archiveDeleteQueryBuilder.BuildQuery(dmlEvent.WhereColumnValues.AbstractValues())
+			return []*dmlBuildResult{newDmlBuildResult(query, uniqueKeyArgs, 0, err)}
+		}
+	case binlog.InsertDML:
+		{
+			query, sharedArgs, err := this.archiveInsertQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues())
+			return []*dmlBuildResult{newDmlBuildResult(query, sharedArgs, 0, err)}
+		}
+	case binlog.UpdateDML:
+		{
+			// The updated row may come to be archived, or no longer be: it is deleted, and re-inserted if archived
+			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified || this.migrationContext.ArchiveRowFilter != nil {
+				query, uniqueKeyArgs, err := this.archiveDeleteQueryBuilder.BuildQuery(dmlEvent.WhereColumnValues.AbstractValues())
+				if err != nil {
+					return []*dmlBuildResult{newDmlBuildResultError(err)}
+				}
+				results := []*dmlBuildResult{newDmlBuildResult(query, uniqueKeyArgs, 0, nil)}
+				query, sharedArgs, err := this.archiveInsertQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues())
+				return append(results, newDmlBuildResult(query, sharedArgs, 0, err))
+			}
+			query, sharedArgs, uniqueKeyArgs, err := this.archiveUpdateQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
+			args := sqlutils.Args()
+			args = append(args, sharedArgs...)
+			args = append(args, uniqueKeyArgs...)
+			return []*dmlBuildResult{newDmlBuildResult(query, args, 0, err)}
+		}
+	}
+	return []*dmlBuildResult{newDmlBuildResultError(fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML))}
+}
+
+// buildSideDMLEventQuery creates the queries applying an intercepted binlog event onto the side table's ghost
+// table (--side-table). It must be called before buildDMLEventQuery, which may rewrite the event.
+func (this *Applier) buildSideDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) []*dmlBuildResult {
+	if err := this.validateFullRowImage(dmlEvent); err != nil {
+		return []*dmlBuildResult{newDmlBuildResultError(err)}
+	}
+	switch dmlEvent.DML {
+	case binlog.DeleteDML:
+		{
+			query, uniqueKeyArgs, err := this.sideDeleteQueryBuilder.BuildQuery(dmlEvent.WhereColumnValues.AbstractValues())
+			return []*dmlBuildResult{newDmlBuildResult(query, uniqueKeyArgs, 0, err)}
+		}
+	case binlog.InsertDML:
+		{
+			query, sharedArgs, err := this.sideInsertQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues())
+			return []*dmlBuildResult{newDmlBuildResult(query, sharedArgs, 0, err)}
+		}
+	case binlog.UpdateDML:
+		{
+			// As on the ghost table, an updated row whose key changes, or which may come to match or no
+			// longer match the row filter, is deleted, and re-inserted if it matches
+			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified || this.migrationContext.RowFilter != nil {
+				query, uniqueKeyArgs, err := this.sideDeleteQueryBuilder.BuildQuery(dmlEvent.WhereColumnValues.AbstractValues())
+				if err != nil {
+					return []*dmlBuildResult{newDmlBuildResultError(err)}
+				}
+				results := []*dmlBuildResult{newDmlBuildResult(query, uniqueKeyArgs, 0, nil)}
+				query, sharedArgs, err := this.sideInsertQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues())
+				return append(results, newDmlBuildResult(query, sharedArgs, 0, err))
+			}
+			query, sharedArgs, uniqueKeyArgs, err := this.sideUpdateQueryBuilder.BuildQuery(dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
+			args := sqlutils.Args()
+			args = append(args, sharedArgs...)
+			args = append(args, uniqueKeyArgs...)
+			return []*dmlBuildResult{newDmlBuildResult(query, args, 0, err)}
+		}
+	}
+	return []*dmlBuildResult{newDmlBuildResultError(fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML))}
+}
+
+// buildShardDMLEventQuery creates the queries applying an intercepted binlog event onto the shard ghost tables
+// (--shard-tables), routing each row image onto its shard. An updated row which moves across shards is
+// deleted from its former shard and inserted onto its new one.
+func (this *Applier) buildShardDMLEventQuery(dmlEvent *binlog.BinlogDMLEvent) []*dmlBuildResult {
+	if err := this.validateFullRowImage(dmlEvent); err != nil {
+		return []*dmlBuildResult{newDmlBuildResultError(err)}
+	}
+	router := this.migrationContext.ShardRouter
+	tableColumns := this.migrationContext.OriginalTableColumns
+	buildDelete := func() *dmlBuildResult {
+		shard, err := router.Route(dmlEvent.WhereColumnValues.AbstractValues(), tableColumns)
+		if err != nil {
+			return newDmlBuildResultError(err)
+		}
+		query, uniqueKeyArgs, err := this.shardDeleteQueryBuilders[shard].BuildQuery(dmlEvent.WhereColumnValues.AbstractValues())
+		return newDmlBuildResult(query, uniqueKeyArgs, -1, err)
+	}
+	buildInsert := func() *dmlBuildResult {
+		shard, err := router.Route(dmlEvent.NewColumnValues.AbstractValues(), tableColumns)
+		if err != nil {
+			return newDmlBuildResultError(err)
+		}
+		query, sharedArgs, err := this.shardInsertQueryBuilders[shard].BuildQuery(dmlEvent.NewColumnValues.AbstractValues())
+		return newDmlBuildResult(query, sharedArgs, 1, err)
+	}
+	switch dmlEvent.DML {
+	case binlog.DeleteDML:
+		{
+			if err := this.validateWhereColumnsPresent(dmlEvent); err != nil {
+				return []*dmlBuildResult{newDmlBuildResultError(err)}
+			}
+			return []*dmlBuildResult{buildDelete()}
+		}
+	case binlog.InsertDML:
+		{
+			return []*dmlBuildResult{buildInsert()}
+		}
+	case binlog.UpdateDML:
+		{
+			if err := this.validateWhereColumnsPresent(dmlEvent); err != nil {
+				return []*dmlBuildResult{newDmlBuildResultError(err)}
+			}
+			whereShard, err := router.Route(dmlEvent.WhereColumnValues.AbstractValues(), tableColumns)
+			if err != nil {
+				return []*dmlBuildResult{newDmlBuildResultError(err)}
+			}
+			newShard, err := router.Route(dmlEvent.NewColumnValues.AbstractValues(), tableColumns)
+			if err != nil {
+				return []*dmlBuildResult{newDmlBuildResultError(err)}
+			}
+			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified || whereShard != newShard || this.migrationContext.RowFilter != nil {
+				return []*dmlBuildResult{buildDelete(), buildInsert()}
+			}
+			query, sharedArgs, uniqueKeyArgs, err := this.shardUpdateQueryBuilders[newShard].BuildQuery(dmlEvent.NewColumnValues.AbstractValues(), dmlEvent.WhereColumnValues.AbstractValues())
+			args := sqlutils.Args()
+			args = append(args, sharedArgs...)
+			args = append(args, uniqueKeyArgs...)
+			return []*dmlBuildResult{newDmlBuildResult(query, args, 0, err)}
+		}
+	}
+	return []*dmlBuildResult{newDmlBuildResultError(fmt.Errorf("Unknown dml event type: %+v", dmlEvent.DML))}
+}
+
+// archiveRecordValue returns a binlog event's value of given column as written onto the archive file, as row
+// copy reads it: binary values as bytes, BINARY ones padded to the column's length, text decoded from the
+// column's character set, and other values as text
+func archiveRecordValue(column *sql.Column, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if column.IsBinary() {
+		var bytesValue []byte
+		switch value := value.(type) {
+		case string:
+			bytesValue = []byte(value)
+		case []byte:
+			bytesValue = append([]byte{}, value...)
+		default:
+			return column.FormatValue(value)
+		}
+		// Binlog events strip BINARY values of trailing zero bytes
+		if column.Type == sql.BinaryColumnType && uint(len(bytesValue)) < column.BinaryOctetLength {
+			bytesValue = append(bytesValue, make([]byte, column.BinaryOctetLength-uint(len(bytesValue)))...)
+		}
+		return bytesValue
+	}
+	switch value.(type) {
+	case string, []byte:
+		if column.Charset != "" {
+			return column.JSONValue(value)
+		}
+	}
+	return column.FormatValue(value)
+}
+
+// archiveRecordValues returns the archived columns' values of a binlog event row, as written onto the archive file
+func (this *Applier) archiveRecordValues(row []interface{}) []interface{} {
+	values := make([]interface{}, 0, this.migrationContext.ArchiveColumns.Len())
+	for _, column := range this.migrationContext.ArchiveColumns.Columns() {
+		value := row[this.migrationContext.OriginalTableColumns.Ordinals[column.Name]]
+		values = append(values, archiveRecordValue(&column, value))
+	}
+	return values
+}
+
+// buildArchiveDMLEventRecords creates the archive file (--archive-file) records of intercepted binlog events.
+// Which of the events' rows are archived is evaluated by given transaction.
+func (this *Applier) buildArchiveDMLEventRecords(ctx context.Context, tx *gosql.Tx, dmlEvents [](*binlog.BinlogDMLEvent)) (records []*archiveRecord, err error) {
+	rows := [][]interface{}{}
+	for _, dmlEvent := range dmlEvents {
+		if err := this.validateFullRowImage(dmlEvent); err != nil {
+			return records, err
+		}
+		if dmlEvent.DML != binlog.InsertDML {
+			rows = append(rows, dmlEvent.WhereColumnValues.AbstractValues())
+		}
+		if dmlEvent.DML != binlog.DeleteDML {
+			rows = append(rows, dmlEvent.NewColumnValues.AbstractValues())
+		}
+	}
+	isArchived := make([]bool, len(rows))
+	if this.migrationContext.ArchiveRowFilter == nil {
+		for i := range isArchived {
+			isArchived[i] = true
+		}
+	} else if len(rows) > 0 {
+		query, args, err := this.migrationContext.ArchiveRowFilter.BuildMatchQuery(rows, this.migrationContext.OriginalTableColumns)
+		if err != nil {
+			return records, err
+		}
+		isArchivedPointers := make([]interface{}, len(isArchived))
+		for i := range isArchived {
+			isArchivedPointers[i] = &isArchived[i]
+		}
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(isArchivedPointers...); err != nil {
+			return records, err
+		}
+	}
+
+	i := 0
+	for _, dmlEvent := range dmlEvents {
+		switch dmlEvent.DML {
+		case binlog.InsertDML:
+			if isArchived[i] {
+				records = append(records, &archiveRecord{operation: archiveOperationInsert, values: this.archiveRecordValues(rows[i])})
+			}
+			i++
+		case binlog.DeleteDML:
+			if isArchived[i] {
+				records = append(records, &archiveRecord{operation: archiveOperationDelete, values: this.archiveRecordValues(rows[i])})
+			}
+			i++
+		case binlog.UpdateDML:
+			oldRow, newRow := rows[i], rows[i+1]
+			isOldArchived, isNewArchived := isArchived[i], isArchived[i+1]
+			i += 2
+			if _, isModified := this.updateModifiesUniqueKeyColumns(dmlEvent); isModified {
+				// The archived row's key changes: it is another row
+				if isOldArchived {
+					records = append(records, &archiveRecord{operation: archiveOperationDelete, values: this.archiveRecordValues(oldRow)})
+				}
+				if isNewArchived {
+					records = append(records, &archiveRecord{operation: archiveOperationInsert, values: this.archiveRecordValues(newRow)})
+				}
+				continue
+			}
+			switch {
+			case isOldArchived && isNewArchived:
+				records = append(records, &archiveRecord{operation: archiveOperationUpdate, values: this.archiveRecordValues(newRow)})
+			case isNewArchived:
+				records = append(records, &archiveRecord{operation: archiveOperationInsert, values: this.archiveRecordValues(newRow)})
+			case isOldArchived:
+				records = append(records, &archiveRecord{operation: archiveOperationDelete, values: this.archiveRecordValues(oldRow)})
+			}
+		}
+	}
+	return records, nil
+}
+
+// ApplyDMLEventQueries applies multiple DML queries onto the _ghost_ table, and onto the side table's ghost table
+// on a vertical split
 func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent)) error {
 	var totalDelta int64
+	var archiveRecords []*archiveRecord
 	ctx := context.Background()
 
 	err := func() error {
-		conn, err := this.db.Conn(ctx)
+		conn, err := this.ghostDB.Conn(ctx)
 		if err != nil {
 			return err
 		}
@@ -1298,10 +2952,28 @@ func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent))
 			return err
 		}
 
+		if this.archiveFileWriter != nil {
+			if archiveRecords, err = this.buildArchiveDMLEventRecords(ctx, tx, dmlEvents); err != nil {
+				return rollback(err)
+			}
+		}
 		buildResults := make([]*dmlBuildResult, 0, len(dmlEvents))
 		nArgs := 0
 		for _, dmlEvent := range dmlEvents {
-			for _, buildResult := range this.buildDMLEventQuery(dmlEvent) {
+			if this.migrationContext.RowFilter != nil {
+				// The ghost table only holds matching rows, yet the row estimate is that of the
+				// original table, whose rows each insert or delete event adds or removes
+				totalDelta += originalTableRowsDelta(dmlEvent)
+			}
+			eventBuildResults := []*dmlBuildResult{}
+			if this.archiveInsertQueryBuilder != nil {
+				eventBuildResults = append(eventBuildResults, this.buildArchiveDMLEventQuery(dmlEvent)...)
+			}
+			if this.sideInsertQueryBuilder != nil {
+				eventBuildResults = append(eventBuildResults, this.buildSideDMLEventQuery(dmlEvent)...)
+			}
+			eventBuildResults = append(eventBuildResults, this.buildDMLEventQuery(dmlEvent)...)
+			for _, buildResult := range eventBuildResults {
 				if buildResult.err != nil {
 					return rollback(buildResult.err)
 				}
@@ -1314,6 +2986,10 @@ func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent))
 		// We have to use the raw driver connection to access the rows affected
 		// for each statement in the multi-statement.
 		execErr := conn.Raw(func(driverConn any) error {
+			if len(buildResults) == 0 {
+				// e.g. updates of columns the ghost table does not have, with partial binlog row images
+				return nil
+			}
 			ex := driverConn.(driver.ExecerContext)
 			nvc := driverConn.(driver.NamedValueChecker)
 
@@ -1340,6 +3016,9 @@ func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent))
 
 			// each DML is either a single insert (delta +1), update (delta +0) or delete (delta -1).
 			// multiplying by the rows actually affected (either 0 or 1) will give an accurate row delta for this DML event
+			if this.migrationContext.RowFilter != nil {
+				return nil
+			}
 			for i, rowsAffected := range mysqlRes.AllRowsAffected() {
 				totalDelta += buildResults[i].rowsDelta * rowsAffected
 			}
@@ -1349,6 +3028,9 @@ func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent))
 		if execErr != nil {
 			return rollback(execErr)
 		}
+		if err := this.writeArchiveFileRecords(tx, archiveRecords); err != nil {
+			return rollback(err)
+		}
 		if err := tx.Commit(); err != nil {
 			return err
 		}
@@ -1369,6 +3051,12 @@ func (this *Applier) ApplyDMLEventQueries(dmlEvents [](*binlog.BinlogDMLEvent))
 
 func (this *Applier) Teardown() {
 	this.migrationContext.Log.Debugf("Tearing down...")
+	if err := this.CloseArchive(); err != nil {
+		this.migrationContext.Log.Errore(err)
+	}
+	if this.ghostDB != nil && this.ghostDB != this.db {
+		this.ghostDB.Close()
+	}
 	this.db.Close()
 	this.singletonDB.Close()
 	atomic.StoreInt64(&this.finishedMigrating, 1)
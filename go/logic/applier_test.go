@@ -8,9 +8,13 @@ package logic
 import (
 	"context"
 	gosql "database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
@@ -19,6 +23,7 @@ import (
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/mysql"
 	"github.com/github/gh-ost/go/sql"
 )
 
@@ -181,6 +186,664 @@ func TestApplierBuildDMLEventQuery(t *testing.T) {
 	})
 }
 
+func TestApplierBuildDMLEventQueryPartialRowImage(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "item_id", "note"})
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.OriginalBinlogRowImage = "MINIMAL"
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Name:    "PRIMARY",
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+
+	applier := NewApplier(migrationContext)
+	require.NoError(t, applier.prepareQueries())
+
+	t.Run("update", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:        "test",
+			DML:                 binlog.UpdateDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{123456, nil, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+			NewColumnValues:     sql.ToColumnValues([]interface{}{123456, nil, "noted"}),
+			NewColumnsPresent:   []bool{true, false, true},
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t,
+			`update /* gh-ost `+"`test`.`_test_gho`"+` */
+			`+"`test`.`_test_gho`"+`
+		set
+			`+"`id`"+`=?, `+"`note`"+`=?
+		where
+			((`+"`id`"+` = ?))`,
+			strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, "noted", 123456}, res[0].args)
+	})
+
+	t.Run("update modifying the migration key", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:        "test",
+			DML:                 binlog.UpdateDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{123456, nil, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+			NewColumnValues:     sql.ToColumnValues([]interface{}{654321, nil, nil}),
+			NewColumnsPresent:   []bool{true, false, false},
+		})
+		require.Len(t, res, 1)
+		require.Error(t, res[0].err)
+	})
+
+	t.Run("delete lacking the migration key", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:        "test",
+			DML:                 binlog.DeleteDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{nil, 42, nil}),
+			WhereColumnsPresent: []bool{false, true, false},
+		})
+		require.Len(t, res, 1)
+		require.Error(t, res[0].err)
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.InsertDML,
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 42, nil}),
+			NewColumnsPresent: []bool{true, true, false},
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t,
+			`replace /* gh-ost `+"`test`.`_test_gho`"+` */
+		into
+			`+"`test`.`_test_gho`"+`
+			`+"(`id`, `item_id`)"+`
+		values
+			(?, ?)`,
+			strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, 42}, res[0].args)
+	})
+}
+
+func TestApplierBuildDMLEventQueryRowFilter(t *testing.T) {
+	columns := sql.NewColumnList([]string{"id", "item_id"})
+	rowFilter, err := sql.NewRowFilter("item_id > 10")
+	require.NoError(t, err)
+	rowFilter.SetReferencedColumns(columns)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = columns
+	migrationContext.MappedSharedColumns = columns
+	migrationContext.RowFilter = rowFilter
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Name:    "PRIMARY",
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+
+	applier := NewApplier(migrationContext)
+	require.NoError(t, applier.prepareQueries())
+
+	insertQuery := `replace /* gh-ost ` + "`test`.`_test_gho`" + ` */
+		into
+			` + "`test`.`_test_gho`" + `
+			` + "(`id`, `item_id`)" + `
+		select
+			?, ?
+		from
+			dual
+		where
+			exists (select 1 from (select ? as ` + "`item_id`" + `) as ` + "`_gh_ost_row`" + ` where item_id > 10)`
+
+	t.Run("insert", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:    "test",
+			DML:             binlog.InsertDML,
+			NewColumnValues: sql.ToColumnValues([]interface{}{123456, 42}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t, insertQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, 42, 42}, res[0].args)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		dmlEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 7}),
+		}
+		require.Equal(t, int64(0), originalTableRowsDelta(dmlEvent))
+		res := applier.buildDMLEventQuery(dmlEvent)
+		require.Len(t, res, 2)
+		require.NoError(t, res[0].err)
+		require.Equal(t,
+			`delete /* gh-ost `+"`test`.`_test_gho`"+` */
+		from
+			`+"`test`.`_test_gho`"+`
+		where
+			((`+"`id`"+` = ?))`,
+			strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456}, res[0].args)
+		require.NoError(t, res[1].err)
+		require.Equal(t, insertQuery, strings.TrimSpace(res[1].query))
+		require.Equal(t, []interface{}{123456, 7, 7}, res[1].args)
+	})
+
+	t.Run("partial row image", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.InsertDML,
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, nil}),
+			NewColumnsPresent: []bool{true, false},
+		})
+		require.Len(t, res, 1)
+		require.Error(t, res[0].err)
+	})
+}
+
+// newTestColumnsMigrationContext returns a migration context of the `test`.`test` table, with columns
+// (id, item_id, notes) keyed by id, of which the ghost table has given shared columns
+func newTestColumnsMigrationContext(sharedColumnNames ...string) *base.MigrationContext {
+	columns := sql.NewColumnList([]string{"id", "item_id", "notes"})
+	columns.SetCharset("notes", "utf8mb4")
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "test"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.SharedColumns = sql.NewColumnList(sharedColumnNames)
+	migrationContext.MappedSharedColumns = migrationContext.SharedColumns
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Name:    "PRIMARY",
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+	return migrationContext
+}
+
+func newTestArchiveMigrationContext() *base.MigrationContext {
+	migrationContext := newTestColumnsMigrationContext("id", "item_id")
+	migrationContext.ArchiveTableName = "test_archive"
+	migrationContext.ArchiveColumns = migrationContext.OriginalTableColumns.FilterBy(func(column sql.Column) bool {
+		return column.Name != "item_id"
+	})
+	return migrationContext
+}
+
+func TestApplierBuildArchiveDMLEventQuery(t *testing.T) {
+	migrationContext := newTestArchiveMigrationContext()
+	applier := NewApplier(migrationContext)
+	require.NoError(t, applier.prepareQueries())
+
+	deleteQuery := `delete /* gh-ost ` + "`test`.`test_archive`" + ` */
+		from
+			` + "`test`.`test_archive`" + `
+		where
+			((` + "`id`" + ` = ?))`
+	insertQuery := `replace /* gh-ost ` + "`test`.`test_archive`" + ` */
+		into
+			` + "`test`.`test_archive`" + `
+			` + "(`id`, `notes`)" + `
+		values
+			(?, ?)`
+
+	t.Run("insert", func(t *testing.T) {
+		res := applier.buildArchiveDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:    "test",
+			DML:             binlog.InsertDML,
+			NewColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t, insertQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, []byte("fragile")}, res[0].args)
+		require.Equal(t, int64(0), res[0].rowsDelta)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		res := applier.buildArchiveDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.DeleteDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t, deleteQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456}, res[0].args)
+		require.Equal(t, int64(0), res[0].rowsDelta)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		dmlEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 7, "sturdy"}),
+		}
+		res := applier.buildArchiveDMLEventQuery(dmlEvent)
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t,
+			`update /* gh-ost `+"`test`.`test_archive`"+` */
+			`+"`test`.`test_archive`"+`
+		set
+			`+"`id`"+`=?, `+"`notes`"+`=?
+		where
+			((`+"`id`"+` = ?))`,
+			strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, []byte("sturdy"), 123456}, res[0].args)
+		require.Equal(t, binlog.UpdateDML, dmlEvent.DML)
+	})
+
+	t.Run("partial row image", func(t *testing.T) {
+		res := applier.buildArchiveDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:        "test",
+			DML:                 binlog.DeleteDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{123456, nil, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+		})
+		require.Len(t, res, 1)
+		require.Error(t, res[0].err)
+	})
+
+	t.Run("update unique key", func(t *testing.T) {
+		res := applier.buildArchiveDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{654321, 42, "fragile"}),
+		})
+		require.Len(t, res, 2)
+		require.NoError(t, res[0].err)
+		require.Equal(t, deleteQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456}, res[0].args)
+		require.NoError(t, res[1].err)
+		require.Equal(t, insertQuery, strings.TrimSpace(res[1].query))
+		require.Equal(t, []interface{}{654321, []byte("fragile")}, res[1].args)
+	})
+
+	t.Run("noop", func(t *testing.T) {
+		migrationContext := newTestArchiveMigrationContext()
+		migrationContext.Noop = true
+		applier := NewApplier(migrationContext)
+		require.NoError(t, applier.prepareQueries())
+		require.Nil(t, applier.archiveInsertQueryBuilder)
+	})
+}
+
+func newTestShardMigrationContext(t *testing.T) *base.MigrationContext {
+	migrationContext := newTestColumnsMigrationContext("id", "item_id", "notes")
+	migrationContext.ShardColumnName = "item_id"
+	require.NoError(t, migrationContext.ReadShardTables("test_0,test_1", "range", "100"))
+	migrationContext.ShardRouter.Column = *migrationContext.OriginalTableColumns.GetColumn("item_id")
+	return migrationContext
+}
+
+func TestApplierBuildShardDMLEventQuery(t *testing.T) {
+	migrationContext := newTestShardMigrationContext(t)
+	applier := NewApplier(migrationContext)
+	require.NoError(t, applier.prepareQueries())
+	require.Len(t, applier.shardRowFilters, 2)
+	require.Equal(t, "(`item_id` is null or `item_id` < 100)", applier.shardRowFilters[0].Expression)
+	require.Equal(t, "`item_id` >= 100", applier.shardRowFilters[1].Expression)
+
+	t.Run("insert", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:    "test",
+			DML:             binlog.InsertDML,
+			NewColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Contains(t, res[0].query, "`test`.`_test_0_gho`")
+		require.Equal(t, []interface{}{123456, 42, "fragile"}, res[0].args)
+		require.Equal(t, int64(1), res[0].rowsDelta)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.DeleteDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 420, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Contains(t, res[0].query, "`test`.`_test_1_gho`")
+		require.Equal(t, []interface{}{123456}, res[0].args)
+		require.Equal(t, int64(-1), res[0].rowsDelta)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 7, "sturdy"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.True(t, strings.HasPrefix(strings.TrimSpace(res[0].query), "update"))
+		require.Contains(t, res[0].query, "`test`.`_test_0_gho`")
+		require.Equal(t, []interface{}{123456, 7, "sturdy", 123456}, res[0].args)
+		require.Equal(t, int64(0), res[0].rowsDelta)
+	})
+
+	t.Run("update across shards", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 420, "fragile"}),
+		})
+		require.Len(t, res, 2)
+		require.NoError(t, res[0].err)
+		require.True(t, strings.HasPrefix(strings.TrimSpace(res[0].query), "delete"))
+		require.Contains(t, res[0].query, "`test`.`_test_0_gho`")
+		require.Equal(t, int64(-1), res[0].rowsDelta)
+		require.NoError(t, res[1].err)
+		require.True(t, strings.HasPrefix(strings.TrimSpace(res[1].query), "replace"))
+		require.Contains(t, res[1].query, "`test`.`_test_1_gho`")
+		require.Equal(t, []interface{}{123456, 420, "fragile"}, res[1].args)
+		require.Equal(t, int64(1), res[1].rowsDelta)
+	})
+
+	t.Run("null sharding value", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:    "test",
+			DML:             binlog.InsertDML,
+			NewColumnValues: sql.ToColumnValues([]interface{}{123456, nil, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Contains(t, res[0].query, "`test`.`_test_0_gho`")
+	})
+
+	t.Run("partial row image", func(t *testing.T) {
+		res := applier.buildDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:        "test",
+			DML:                 binlog.DeleteDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{123456, nil, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+		})
+		require.Len(t, res, 1)
+		require.Error(t, res[0].err)
+	})
+}
+
+func newTestSideTableMigrationContext() *base.MigrationContext {
+	migrationContext := newTestColumnsMigrationContext("id", "item_id")
+	migrationContext.SideTableName = "test_notes"
+	migrationContext.SideColumnNames = []string{"notes"}
+	migrationContext.SideTableColumns = migrationContext.OriginalTableColumns.FilterBy(func(column sql.Column) bool {
+		return column.Name != "item_id"
+	})
+	return migrationContext
+}
+
+func TestApplierBuildSideDMLEventQuery(t *testing.T) {
+	migrationContext := newTestSideTableMigrationContext()
+	applier := NewApplier(migrationContext)
+	require.NoError(t, applier.prepareQueries())
+
+	deleteQuery := `delete /* gh-ost ` + "`test`.`_test_notes_gho`" + ` */
+		from
+			` + "`test`.`_test_notes_gho`" + `
+		where
+			((` + "`id`" + ` = ?))`
+	insertQuery := `replace /* gh-ost ` + "`test`.`_test_notes_gho`" + ` */
+		into
+			` + "`test`.`_test_notes_gho`" + `
+			` + "(`id`, `notes`)" + `
+		values
+			(?, ?)`
+
+	t.Run("insert", func(t *testing.T) {
+		res := applier.buildSideDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:    "test",
+			DML:             binlog.InsertDML,
+			NewColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t, insertQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, []byte("fragile")}, res[0].args)
+		require.Equal(t, int64(0), res[0].rowsDelta)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		res := applier.buildSideDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.DeleteDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+		})
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t, deleteQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456}, res[0].args)
+		require.Equal(t, int64(0), res[0].rowsDelta)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		dmlEvent := &binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{123456, 7, "sturdy"}),
+		}
+		res := applier.buildSideDMLEventQuery(dmlEvent)
+		require.Len(t, res, 1)
+		require.NoError(t, res[0].err)
+		require.Equal(t,
+			`update /* gh-ost `+"`test`.`_test_notes_gho`"+` */
+			`+"`test`.`_test_notes_gho`"+`
+		set
+			`+"`id`"+`=?, `+"`notes`"+`=?
+		where
+			((`+"`id`"+` = ?))`,
+			strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456, []byte("sturdy"), 123456}, res[0].args)
+		require.Equal(t, binlog.UpdateDML, dmlEvent.DML)
+	})
+
+	t.Run("update unique key", func(t *testing.T) {
+		res := applier.buildSideDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:      "test",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{123456, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{654321, 42, "fragile"}),
+		})
+		require.Len(t, res, 2)
+		require.NoError(t, res[0].err)
+		require.Equal(t, deleteQuery, strings.TrimSpace(res[0].query))
+		require.Equal(t, []interface{}{123456}, res[0].args)
+		require.NoError(t, res[1].err)
+		require.Equal(t, insertQuery, strings.TrimSpace(res[1].query))
+		require.Equal(t, []interface{}{654321, []byte("fragile")}, res[1].args)
+	})
+
+	t.Run("partial row image", func(t *testing.T) {
+		res := applier.buildSideDMLEventQuery(&binlog.BinlogDMLEvent{
+			DatabaseName:        "test",
+			DML:                 binlog.UpdateDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{123456, nil, nil}),
+			NewColumnValues:     sql.ToColumnValues([]interface{}{123456, 7, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+			NewColumnsPresent:   []bool{true, true, false},
+		})
+		require.Len(t, res, 1)
+		require.Error(t, res[0].err)
+	})
+
+	t.Run("noop", func(t *testing.T) {
+		migrationContext := newTestSideTableMigrationContext()
+		migrationContext.Noop = true
+		applier := NewApplier(migrationContext)
+		require.NoError(t, applier.prepareQueries())
+		require.Nil(t, applier.sideInsertQueryBuilder)
+	})
+}
+
+func TestApplierBuildArchiveDMLEventRecords(t *testing.T) {
+	migrationContext := newTestArchiveMigrationContext()
+	migrationContext.ArchiveTableName = ""
+	migrationContext.ArchiveFileName = "archive.csv"
+	applier := NewApplier(migrationContext)
+
+	records, err := applier.buildArchiveDMLEventRecords(context.Background(), nil, []*binlog.BinlogDMLEvent{
+		{
+			DML:             binlog.InsertDML,
+			NewColumnValues: sql.ToColumnValues([]interface{}{1, 42, "fragile"}),
+		},
+		{
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{1, 42, "fragile"}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{1, 7, nil}),
+		},
+		{
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{1, 7, nil}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{2, 7, nil}),
+		},
+		{
+			DML:               binlog.DeleteDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{2, 7, nil}),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*archiveRecord{
+		{operation: archiveOperationInsert, values: []interface{}{"1", "fragile"}},
+		{operation: archiveOperationUpdate, values: []interface{}{"1", nil}},
+		{operation: archiveOperationDelete, values: []interface{}{"1", nil}},
+		{operation: archiveOperationInsert, values: []interface{}{"2", nil}},
+		{operation: archiveOperationDelete, values: []interface{}{"2", nil}},
+	}, records)
+
+	_, err = applier.buildArchiveDMLEventRecords(context.Background(), nil, []*binlog.BinlogDMLEvent{
+		{
+			DML:                 binlog.DeleteDML,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{2, nil, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+		},
+	})
+	require.Error(t, err)
+}
+
+func TestApplierArchiveFileRoundTrip(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "data", "name", "hash"})
+	data := migrationContext.OriginalTableColumns.GetColumn("data")
+	data.DataType = "varbinary"
+	name := migrationContext.OriginalTableColumns.GetColumn("name")
+	name.DataType, name.Charset = "varchar", "latin1"
+	hash := migrationContext.OriginalTableColumns.GetColumn("hash")
+	hash.DataType, hash.Type, hash.BinaryOctetLength = "binary", sql.BinaryColumnType, 4
+	migrationContext.ArchiveColumns = migrationContext.OriginalTableColumns
+	applier := NewApplier(migrationContext)
+
+	// A binlog event row: latin1 text as encoded, and a BINARY value stripped of its trailing zeros
+	records := []*archiveRecord{
+		{operation: archiveOperationInsert, values: applier.archiveRecordValues([]interface{}{int32(1), "\x00\xff\n,\"", "caf\xe9", "\x01\x02"})},
+	}
+	expectedData, expectedName, expectedHash := []byte("\x00\xff\n,\""), "café", []byte{1, 2, 0, 0}
+
+	t.Run("jsonl", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "archive.jsonl")
+		writer, err := NewArchiveFileWriter(fileName, migrationContext.ArchiveColumns.Names())
+		require.NoError(t, err)
+		_, err = writer.WriteRecords(records)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		var record struct {
+			Operation string `json:"_gh_ost_op"`
+			ID        string `json:"id"`
+			Data      []byte `json:"data"`
+			Name      string `json:"name"`
+			Hash      []byte `json:"hash"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(readArchiveFile(t, fileName, false)), &record))
+		require.Equal(t, "insert", record.Operation)
+		require.Equal(t, "1", record.ID)
+		require.Equal(t, expectedData, record.Data)
+		require.Equal(t, expectedName, record.Name)
+		require.Equal(t, expectedHash, record.Hash)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "archive.csv")
+		writer, err := NewArchiveFileWriter(fileName, migrationContext.ArchiveColumns.Names())
+		require.NoError(t, err)
+		_, err = writer.WriteRecords(records)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		fields, err := csv.NewReader(strings.NewReader(readArchiveFile(t, fileName, false))).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, [][]string{
+			{"_gh_ost_op", "id", "data", "name", "hash"},
+			{"insert", "1", string(expectedData), expectedName, string(expectedHash)},
+		}, fields)
+	})
+}
+
+func TestApplierBuildRebindForeignKeysQuery(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "parent"
+	migrationContext.ColumnRenameMap = map[string]string{"id": "parent_id"}
+	migrationContext.ParentSideForeignKeys = []mysql.ForeignKey{
+		{Name: "child_fk", TableSchema: "test", TableName: "child", Columns: []string{"pid"}, ReferencedColumns: []string{"id"}, DeleteRule: "RESTRICT"},
+		{Name: "other_fk", TableSchema: "test", TableName: "other", Columns: []string{"pid"}, ReferencedColumns: []string{"id"}},
+		{Name: "_child_fk2", TableSchema: "test", TableName: "child", Columns: []string{"pid2"}, ReferencedColumns: []string{"id"}},
+	}
+	applier := NewApplier(migrationContext)
+
+	childTables, childTablesForeignKeys := applier.getParentSideForeignKeysChildTables()
+	require.Equal(t, []string{"`test`.`child`", "`test`.`other`"}, childTables)
+	require.Len(t, childTablesForeignKeys["`test`.`child`"], 2)
+
+	query, err := applier.buildRebindForeignKeysQuery("`test`.`child`", childTablesForeignKeys["`test`.`child`"], false)
+	require.NoError(t, err)
+	require.Equal(t, "alter /* gh-ost */ table `test`.`child` "+
+		"drop foreign key `child_fk`, add constraint `_child_fk` foreign key (`pid`) references `test`.`_parent_gho` (`parent_id`) on delete restrict, "+
+		"drop foreign key `_child_fk2`, add constraint `child_fk2` foreign key (`pid2`) references `test`.`_parent_gho` (`parent_id`)", query)
+
+	query, err = applier.buildRebindForeignKeysQuery("`test`.`child`", childTablesForeignKeys["`test`.`child`"], true)
+	require.NoError(t, err)
+	require.Equal(t, "alter /* gh-ost */ table `test`.`child` "+
+		"drop foreign key `_child_fk`, add constraint `child_fk` foreign key (`pid`) references `test`.`parent` (`id`) on delete restrict, "+
+		"drop foreign key `child_fk2`, add constraint `_child_fk2` foreign key (`pid2`) references `test`.`parent` (`id`)", query)
+}
+
+func TestApplierBuildChildSideForeignKeysQuery(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "child"
+	migrationContext.ForeignKeySuffix = "_v2"
+	migrationContext.ColumnRenameMap = map[string]string{"pid": "parent_id"}
+	migrationContext.ChildSideForeignKeys = []mysql.ForeignKey{
+		{Name: "parent_fk", Columns: []string{"pid"}, ReferencedTableSchema: "test", ReferencedTableName: "parent", ReferencedColumns: []string{"id"}, DeleteRule: "RESTRICT"},
+	}
+	applier := NewApplier(migrationContext)
+
+	query, err := applier.buildChildSideForeignKeysQuery(false)
+	require.NoError(t, err)
+	require.Equal(t, "alter /* gh-ost */ table `test`.`_child_gho` add constraint `parent_fk_v2` foreign key (`parent_id`) references `test`.`parent` (`id`) on delete restrict", query)
+
+	query, err = applier.buildChildSideForeignKeysQuery(true)
+	require.NoError(t, err)
+	require.Equal(t, "alter /* gh-ost */ table `test`.`_child_gho` drop foreign key `parent_fk_v2`", query)
+}
+
 func TestApplierInstantDDL(t *testing.T) {
 	migrationContext := base.NewMigrationContext()
 	migrationContext.DatabaseName = "test"
@@ -504,6 +1167,156 @@ func (suite *ApplierTestSuite) TestCreateGhostTable() {
 	suite.Require().Equal("CREATE TABLE `_testing_gho` (\n  `id` int DEFAULT NULL,\n  `item_id` int DEFAULT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci", createDDL)
 }
 
+// TestChildSideForeignKeysAfterParentWriteDuringLag covers a parent row deleted while the ghost table
+// still lags, holding a row which references it: foreign keys are only added once the binlog events
+// catching up on the deletion are applied.
+func (suite *ApplierTestSuite) TestChildSideForeignKeysAfterParentWriteDuringLag() {
+	ctx := context.Background()
+
+	var err error
+
+	_, err = suite.db.ExecContext(ctx, "CREATE TABLE test.parent (id INT PRIMARY KEY);")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "CREATE TABLE test.testing (id INT PRIMARY KEY, parent_id INT, CONSTRAINT parent_fk FOREIGN KEY (parent_id) REFERENCES test.parent (id));")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "CREATE TABLE test._testing_gho (id INT PRIMARY KEY, parent_id INT);")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "INSERT INTO test.parent VALUES (1), (2);")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "INSERT INTO test.testing VALUES (1, 1);")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "INSERT INTO test._testing_gho VALUES (1, 1);")
+	suite.Require().NoError(err)
+
+	connectionConfig, err := GetConnectionConfig(ctx, suite.mysqlContainer)
+	suite.Require().NoError(err)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ApplierConnectionConfig = connectionConfig
+	migrationContext.DatabaseName = "test"
+	migrationContext.SkipPortValidation = true
+	migrationContext.OriginalTableName = "testing"
+	migrationContext.SetConnectionConfig("innodb")
+	migrationContext.ForeignKeySuffix = "_v2"
+	migrationContext.ChildSideForeignKeys = []mysql.ForeignKey{
+		{Name: "parent_fk", Columns: []string{"parent_id"}, ReferencedTableSchema: "test", ReferencedTableName: "parent", ReferencedColumns: []string{"id"}},
+	}
+
+	migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "parent_id"})
+	migrationContext.SharedColumns = sql.NewColumnList([]string{"id", "parent_id"})
+	migrationContext.MappedSharedColumns = sql.NewColumnList([]string{"id", "parent_id"})
+	migrationContext.UniqueKey = &sql.UniqueKey{
+		Name:    "PRIMARY",
+		Columns: *sql.NewColumnList([]string{"id"}),
+	}
+
+	applier := NewApplier(migrationContext)
+	suite.Require().NoError(applier.prepareQueries())
+	defer applier.Teardown()
+
+	err = applier.InitDBConnections()
+	suite.Require().NoError(err)
+
+	// The original table moves onto parent 2, and parent 1 is deleted; the ghost table does not
+	// hold foreign keys yet, hence does not reject the deletion
+	_, err = suite.db.ExecContext(ctx, "UPDATE test.testing SET parent_id = 2 WHERE id = 1;")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "DELETE FROM test.parent WHERE id = 1;")
+	suite.Require().NoError(err)
+
+	// While lagging, the ghost table violates the foreign key
+	suite.Require().Error(applier.ValidateChildSideForeignKeysOnGhost())
+
+	// Catching up, as the cut-over does before adding foreign keys
+	err = applier.ApplyDMLEventQueries([]*binlog.BinlogDMLEvent{
+		{
+			DatabaseName:      "test",
+			TableName:         "testing",
+			DML:               binlog.UpdateDML,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{1, 1}),
+			NewColumnValues:   sql.ToColumnValues([]interface{}{1, 2}),
+		},
+	})
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(applier.ValidateChildSideForeignKeysOnGhost())
+	suite.Require().NoError(applier.AddChildSideForeignKeysToGhost())
+
+	var tableName, createDDL string
+	//nolint:execinquery
+	err = suite.db.QueryRow("SHOW CREATE TABLE test._testing_gho").Scan(&tableName, &createDDL)
+	suite.Require().NoError(err)
+	suite.Require().Contains(createDDL, "CONSTRAINT `parent_fk_v2` FOREIGN KEY (`parent_id`) REFERENCES `parent` (`id`)")
+
+	// A failed cut-over drops them again
+	suite.Require().NoError(applier.DropChildSideForeignKeysFromGhost())
+	//nolint:execinquery
+	err = suite.db.QueryRow("SHOW CREATE TABLE test._testing_gho").Scan(&tableName, &createDDL)
+	suite.Require().NoError(err)
+	suite.Require().NotContains(createDDL, "parent_fk_v2")
+}
+
+// TestShardRouteMatchesShardCondition checks binlog event rows are routed onto the shards row copy copies
+// them onto, for each column type rows may be hashed by
+func (suite *ApplierTestSuite) TestShardRouteMatchesShardCondition() {
+	ctx := context.Background()
+
+	_, err := suite.db.ExecContext(ctx, "CREATE TABLE test.testing (id INT PRIMARY KEY, amount DECIMAL(10,2), name VARCHAR(32) CHARACTER SET utf8mb4, created DATE);")
+	suite.Require().NoError(err)
+	_, err = suite.db.ExecContext(ctx, "INSERT INTO test.testing VALUES (1, 1.50, 'gromit', '2024-03-01'), (2, -0.50, 'wallace', '1999-12-31'), (3, 0, '', '2024-02-29'), (4, 12345678.90, 'shaun', '2000-01-01'), (5, NULL, NULL, NULL);")
+	suite.Require().NoError(err)
+
+	columns := sql.NewColumnList([]string{"id", "amount", "name", "created"})
+	amount := columns.GetColumn("amount")
+	amount.DataType, amount.DeclaredType = "decimal", "decimal(10,2)"
+	name := columns.GetColumn("name")
+	name.DataType, name.DeclaredType, name.Charset = "varchar", "varchar(32)", "utf8mb4"
+	created := columns.GetColumn("created")
+	created.DataType, created.DeclaredType = "date", "date"
+
+	// rows as binlog events hold them: DECIMAL values lose their trailing zeros
+	rows := map[int][]interface{}{}
+	dbRows, err := suite.db.QueryContext(ctx, "SELECT id, amount, name, created FROM test.testing")
+	suite.Require().NoError(err)
+	defer dbRows.Close()
+	for dbRows.Next() {
+		var id int
+		var amount, name, created gosql.NullString
+		suite.Require().NoError(dbRows.Scan(&id, &amount, &name, &created))
+		row := []interface{}{int32(id), nil, nil, nil}
+		if amount.Valid {
+			row[1] = decimal.RequireFromString(amount.String)
+		}
+		if name.Valid {
+			row[2] = name.String
+		}
+		if created.Valid {
+			row[3] = created.String
+		}
+		rows[id] = row
+	}
+	suite.Require().NoError(dbRows.Err())
+
+	for _, columnName := range []string{"amount", "name", "created"} {
+		router, err := sql.NewShardRouter(sql.HashShardMethod, 4, nil)
+		suite.Require().NoError(err)
+		router.Column = *columns.GetColumn(columnName)
+		for shard := 0; shard < router.ShardCount; shard++ {
+			shardRows, err := suite.db.QueryContext(ctx, "SELECT id FROM test.testing WHERE "+router.BuildShardCondition(shard))
+			suite.Require().NoError(err)
+			for shardRows.Next() {
+				var id int
+				suite.Require().NoError(shardRows.Scan(&id))
+				routedShard, err := router.Route(rows[id], columns)
+				suite.Require().NoError(err)
+				suite.Require().Equal(shard, routedShard, "%s of row %d", columnName, id)
+			}
+			suite.Require().NoError(shardRows.Err())
+			shardRows.Close()
+		}
+	}
+}
+
 func TestApplier(t *testing.T) {
 	suite.Run(t, new(ApplierTestSuite))
 }
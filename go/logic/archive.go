@@ -0,0 +1,214 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type archiveFileFormat string
+
+const (
+	archiveFileFormatCSV   archiveFileFormat = "csv"
+	archiveFileFormatJSONL archiveFileFormat = "jsonl"
+)
+
+// archiveOperationColumn is the column of archive file records telling how the record came to be archived
+const archiveOperationColumn = "_gh_ost_op"
+
+type archiveOperation string
+
+const (
+	// archiveOperationCopy: row copy archived a row
+	archiveOperationCopy archiveOperation = "copy"
+	// archiveOperationInsert: a binlog event inserted or updated a row into being archived
+	archiveOperationInsert archiveOperation = "insert"
+	// archiveOperationUpdate: a binlog event updated an archived row
+	archiveOperationUpdate archiveOperation = "update"
+	// archiveOperationDelete: a binlog event deleted an archived row, or updated it into not being archived
+	archiveOperationDelete archiveOperation = "delete"
+)
+
+// archiveNullValue is how CSV archive files hold NULL values, as does LOAD DATA
+const archiveNullValue = `\N`
+
+// archiveRecord is a row written onto an archive file. Values are strings, byte slices of binary
+// columns, or nil for NULL. JSON lines hold byte slices base64 encoded, and CSV files as they are.
+type archiveRecord struct {
+	operation archiveOperation
+	values    []interface{}
+}
+
+// parseArchiveFileName returns the format of an archive file, and whether it is gzip compressed, by its extension
+func parseArchiveFileName(fileName string) (format archiveFileFormat, compressed bool, err error) {
+	extension := strings.ToLower(filepath.Ext(fileName))
+	if extension == ".gz" {
+		compressed = true
+		extension = strings.ToLower(filepath.Ext(strings.TrimSuffix(fileName, filepath.Ext(fileName))))
+	}
+	switch extension {
+	case ".csv":
+		return archiveFileFormatCSV, compressed, nil
+	case ".jsonl":
+		return archiveFileFormatJSONL, compressed, nil
+	}
+	return format, compressed, fmt.Errorf("Unsupported archive file %s: expecting a .csv, .csv.gz, .jsonl or .jsonl.gz file", fileName)
+}
+
+// ArchiveFileWriter writes archived rows onto a local CSV or JSON lines file, optionally gzip compressed.
+// Each record holds the operation archiving the row, followed by the archived columns. Each write is
+// synced onto disk, and when compressed, is a gzip member of its own: the file is valid up to any size
+// returned by WriteRecords, even when a later write is cut short.
+type ArchiveFileWriter struct {
+	file       *os.File
+	compressed bool
+	format     archiveFileFormat
+	columns    []string
+	size       int64
+	mutex      sync.Mutex
+}
+
+// NewArchiveFileWriter creates given archive file, which must not exist, for rows of given columns
+func NewArchiveFileWriter(fileName string, columns []string) (*ArchiveFileWriter, error) {
+	format, compressed, err := parseArchiveFileName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, err
+	}
+	this := &ArchiveFileWriter{
+		file:       file,
+		compressed: compressed,
+		format:     format,
+		columns:    columns,
+	}
+	if format == archiveFileFormatCSV {
+		var header bytes.Buffer
+		csvWriter := csv.NewWriter(&header)
+		csvWriter.Write(append([]string{archiveOperationColumn}, columns...))
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := this.write(header.Bytes()); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return this, nil
+}
+
+func (this *ArchiveFileWriter) writeRecord(buffer *bytes.Buffer, record *archiveRecord) error {
+	if len(record.values) != len(this.columns) {
+		return fmt.Errorf("Got %d values for %d archive columns", len(record.values), len(this.columns))
+	}
+	if this.format == archiveFileFormatCSV {
+		fields := make([]string, 0, len(record.values)+1)
+		fields = append(fields, string(record.operation))
+		for _, value := range record.values {
+			switch value := value.(type) {
+			case nil:
+				fields = append(fields, archiveNullValue)
+			case []byte:
+				fields = append(fields, string(value))
+			default:
+				fields = append(fields, fmt.Sprintf("%v", value))
+			}
+		}
+		csvWriter := csv.NewWriter(buffer)
+		csvWriter.Write(fields)
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	// Keys are written in column order, hence the object is built by hand
+	buffer.WriteString("{")
+	for i, column := range append([]string{archiveOperationColumn}, this.columns...) {
+		var value interface{} = record.operation
+		if i > 0 {
+			value = record.values[i-1]
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.Write(key)
+		buffer.WriteString(":")
+		buffer.Write(encodedValue)
+	}
+	buffer.WriteString("}\n")
+	return nil
+}
+
+// write appends given data onto the file, as a gzip member of its own when compressed, and syncs the file
+func (this *ArchiveFileWriter) write(data []byte) error {
+	if this.compressed {
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		if _, err := gzipWriter.Write(data); err != nil {
+			return err
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return err
+		}
+		data = compressed.Bytes()
+	}
+	written, err := this.file.Write(data)
+	this.size += int64(written)
+	if err != nil {
+		return err
+	}
+	return this.file.Sync()
+}
+
+// WriteRecords writes given records and syncs them onto disk. It returns the size of the file once written.
+func (this *ArchiveFileWriter) WriteRecords(records []*archiveRecord) (size int64, err error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.file == nil {
+		return this.size, fmt.Errorf("Archive file is closed")
+	}
+	var buffer bytes.Buffer
+	for _, record := range records {
+		if err := this.writeRecord(&buffer, record); err != nil {
+			return this.size, err
+		}
+	}
+	if err := this.write(buffer.Bytes()); err != nil {
+		return this.size, err
+	}
+	return this.size, nil
+}
+
+// Close closes the file
+func (this *ArchiveFileWriter) Close() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.file == nil {
+		return nil
+	}
+	defer func() { this.file = nil }()
+	return this.file.Close()
+}
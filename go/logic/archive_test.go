@@ -0,0 +1,122 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArchiveFileName(t *testing.T) {
+	tests := []struct {
+		fileName   string
+		format     archiveFileFormat
+		compressed bool
+	}{
+		{"/tmp/archive.csv", archiveFileFormatCSV, false},
+		{"/tmp/archive.CSV.gz", archiveFileFormatCSV, true},
+		{"archive.jsonl", archiveFileFormatJSONL, false},
+		{"archive.2024.jsonl.gz", archiveFileFormatJSONL, true},
+	}
+	for _, test := range tests {
+		t.Run(test.fileName, func(t *testing.T) {
+			format, compressed, err := parseArchiveFileName(test.fileName)
+			require.NoError(t, err)
+			require.Equal(t, test.format, format)
+			require.Equal(t, test.compressed, compressed)
+		})
+	}
+	for _, fileName := range []string{"archive", "archive.gz", "archive.json", "archive.csv.zip"} {
+		_, _, err := parseArchiveFileName(fileName)
+		require.Error(t, err)
+	}
+}
+
+func readArchiveFile(t *testing.T, fileName string, compressed bool) string {
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	var reader io.Reader = file
+	if compressed {
+		gzipReader, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestArchiveFileWriter(t *testing.T) {
+	records := []*archiveRecord{
+		{operation: archiveOperationCopy, values: []interface{}{"1", "gromit", nil}},
+		{operation: archiveOperationUpdate, values: []interface{}{"2", `say "cheese", wallace`, "7"}},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "archive.csv")
+		writer, err := NewArchiveFileWriter(fileName, []string{"id", "name", "score"})
+		require.NoError(t, err)
+		_, err = writer.WriteRecords(records)
+		require.NoError(t, err)
+		size, err := writer.WriteRecords(records[:1])
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		require.NoError(t, writer.Close())
+		_, err = writer.WriteRecords(records)
+		require.Error(t, err)
+
+		expected := "_gh_ost_op,id,name,score\n" +
+			"copy,1,gromit,\\N\n" +
+			"update,2,\"say \"\"cheese\"\", wallace\",7\n" +
+			"copy,1,gromit,\\N\n"
+		require.Equal(t, expected, readArchiveFile(t, fileName, false))
+		require.Equal(t, int64(len(expected)), size)
+	})
+
+	t.Run("jsonl.gz", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "archive.jsonl.gz")
+		writer, err := NewArchiveFileWriter(fileName, []string{"id", "name", "score"})
+		require.NoError(t, err)
+		size, err := writer.WriteRecords(records)
+		require.NoError(t, err)
+		_, err = writer.WriteRecords(records[:1])
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		expected := `{"_gh_ost_op":"copy","id":"1","name":"gromit","score":null}` + "\n" +
+			`{"_gh_ost_op":"update","id":"2","name":"say \"cheese\", wallace","score":"7"}` + "\n"
+		require.Equal(t, expected+expected[:strings.Index(expected, "\n")+1], readArchiveFile(t, fileName, true))
+
+		// Each write is a gzip member of its own: the file is valid when cut at a returned size
+		require.NoError(t, os.Truncate(fileName, size))
+		require.Equal(t, expected, readArchiveFile(t, fileName, true))
+	})
+
+	t.Run("existing file", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "archive.csv")
+		require.NoError(t, os.WriteFile(fileName, []byte("keep me"), 0640))
+		_, err := NewArchiveFileWriter(fileName, []string{"id"})
+		require.Error(t, err)
+		require.Equal(t, "keep me", readArchiveFile(t, fileName, false))
+	})
+
+	t.Run("mismatched values", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "archive.csv")
+		writer, err := NewArchiveFileWriter(fileName, []string{"id", "name"})
+		require.NoError(t, err)
+		defer writer.Close()
+		_, err = writer.WriteRecords(records[:1])
+		require.Error(t, err)
+	})
+}
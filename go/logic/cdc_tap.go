@@ -0,0 +1,380 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/mysql"
+)
+
+const (
+	// cdcTapMaxBatchSize is the maximum number of events written onto the destination at once
+	cdcTapMaxBatchSize = 1000
+	// cdcTapRetryInterval is the pause following a failed write, while events keep being buffered
+	cdcTapRetryInterval = 1 * time.Second
+	// cdcTapCloseTimeout bounds the time Close waits for buffered events to be written
+	cdcTapCloseTimeout = 10 * time.Second
+	// cdcTapGapRecordType is the type of records marking dropped events
+	cdcTapGapRecordType = "gap"
+)
+
+// cdcTapEvent is a row change as intercepted from the binlog. Values are those of the DML
+// event, which are not modified once read; its DML type is not, hence copied.
+type cdcTapEvent struct {
+	dml          binlog.EventDML
+	databaseName string
+	tableName    string
+	coordinates  mysql.BinlogCoordinates
+	timestamp    time.Time
+	whereValues  []interface{}
+	newValues    []interface{}
+	wherePresent []bool
+	newPresent   []bool
+}
+
+// cdcTapRecord is a JSON line written by the CDC tap. Before and after images are keyed by
+// column name; columns a partial binlog row image lacks are omitted.
+type cdcTapRecord struct {
+	Type      string                 `json:"type"`
+	Database  string                 `json:"database"`
+	Table     string                 `json:"table"`
+	LogFile   string                 `json:"log_file,omitempty"`
+	LogPos    int64                  `json:"log_pos,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Before    map[string]interface{} `json:"before,omitempty"`
+	After     map[string]interface{} `json:"after,omitempty"`
+	Dropped   int64                  `json:"dropped,omitempty"`
+}
+
+// cdcTapSink is a destination of the CDC tap, onto which JSON lines are written in batches
+type cdcTapSink interface {
+	write(lines []byte) error
+	close() error
+}
+
+// cdcTapFileSink appends to a local file
+type cdcTapFileSink struct {
+	file *os.File
+}
+
+func (this *cdcTapFileSink) write(lines []byte) error {
+	_, err := this.file.Write(lines)
+	return err
+}
+
+func (this *cdcTapFileSink) close() error {
+	return this.file.Close()
+}
+
+// cdcTapSocketSink writes to a unix socket, connecting lazily and reconnecting after a failure
+type cdcTapSocketSink struct {
+	path    string
+	timeout time.Duration
+	conn    net.Conn
+}
+
+func (this *cdcTapSocketSink) write(lines []byte) (err error) {
+	if this.conn == nil {
+		if this.conn, err = net.DialTimeout("unix", this.path, this.timeout); err != nil {
+			this.conn = nil
+			return err
+		}
+	}
+	if err := this.conn.SetWriteDeadline(time.Now().Add(this.timeout)); err != nil {
+		this.close()
+		return err
+	}
+	if _, err := this.conn.Write(lines); err != nil {
+		this.close()
+		return err
+	}
+	return nil
+}
+
+func (this *cdcTapSocketSink) close() error {
+	if this.conn == nil {
+		return nil
+	}
+	defer func() { this.conn = nil }()
+	return this.conn.Close()
+}
+
+// cdcTapHTTPSink POSTs each batch of lines to an HTTP endpoint
+type cdcTapHTTPSink struct {
+	url        string
+	timeout    time.Duration
+	httpClient *http.Client
+}
+
+func (this *cdcTapHTTPSink) write(lines []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.url, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned http=%d", this.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (this *cdcTapHTTPSink) close() error {
+	this.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// newCDCTapSink creates the sink of given destination: an http(s):// URL, a unix:// socket, or a file path
+func newCDCTapSink(destination string, timeout time.Duration) (cdcTapSink, error) {
+	switch {
+	case strings.HasPrefix(destination, "http://"), strings.HasPrefix(destination, "https://"):
+		u, err := url.ParseRequestURI(destination)
+		if err != nil {
+			return nil, fmt.Errorf("--cdc-tap: invalid URL %q: %+v", destination, err)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("--cdc-tap: missing host in URL %q", destination)
+		}
+		return &cdcTapHTTPSink{url: destination, timeout: timeout, httpClient: &http.Client{}}, nil
+	case strings.HasPrefix(destination, "unix://"):
+		path := strings.TrimPrefix(destination, "unix://")
+		if path == "" {
+			return nil, fmt.Errorf("--cdc-tap: missing socket path in %q", destination)
+		}
+		return &cdcTapSocketSink{path: path, timeout: timeout}, nil
+	}
+	file, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &cdcTapFileSink{file: file}, nil
+}
+
+// CDCTap emits the row changes gh-ost reads from the binlog on the migrated table as JSON lines,
+// for the benefit of external consumers. It never blocks the migration: events are buffered, and
+// written onto the destination by a goroutine of their own. Events overflowing the buffer, or
+// failing to be written, are dropped; the next record written then is a gap record counting them.
+type CDCTap struct {
+	migrationContext *base.MigrationContext
+	sink             cdcTapSink
+	events           chan *cdcTapEvent
+	done             chan struct{}
+	mutex            sync.RWMutex
+	closed           bool
+	isFailing        bool
+	// abandoned is set when Close gives up waiting; Run then drops the remaining events
+	abandoned int64
+	closeErr  error
+
+	emittedEvents        int64
+	droppedEvents        int64
+	pendingDroppedEvents int64
+}
+
+// NewCDCTap creates a tap onto the destination given by --cdc-tap
+func NewCDCTap(migrationContext *base.MigrationContext) (*CDCTap, error) {
+	timeout := time.Duration(migrationContext.CDCTapTimeoutMillis) * time.Millisecond
+	sink, err := newCDCTapSink(migrationContext.CDCTapDestination, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &CDCTap{
+		migrationContext: migrationContext,
+		sink:             sink,
+		events:           make(chan *cdcTapEvent, migrationContext.CDCTapBufferSize),
+		done:             make(chan struct{}),
+	}, nil
+}
+
+// Emit buffers given DML event to be written. It does not block: when the buffer is full, the event is dropped.
+func (this *CDCTap) Emit(dmlEvent *binlog.BinlogDMLEvent) {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+	if this.closed {
+		return
+	}
+	event := &cdcTapEvent{
+		dml:          dmlEvent.DML,
+		databaseName: dmlEvent.DatabaseName,
+		tableName:    dmlEvent.TableName,
+		coordinates:  dmlEvent.Coordinates,
+		timestamp:    dmlEvent.Timestamp,
+		wherePresent: dmlEvent.WhereColumnsPresent,
+		newPresent:   dmlEvent.NewColumnsPresent,
+	}
+	if dmlEvent.WhereColumnValues != nil {
+		event.whereValues = dmlEvent.WhereColumnValues.AbstractValues()
+	}
+	if dmlEvent.NewColumnValues != nil {
+		event.newValues = dmlEvent.NewColumnValues.AbstractValues()
+	}
+	select {
+	case this.events <- event:
+	default:
+		this.drop(1)
+	}
+}
+
+func (this *CDCTap) drop(count int64) {
+	atomic.AddInt64(&this.droppedEvents, count)
+	atomic.AddInt64(&this.pendingDroppedEvents, count)
+}
+
+// rowImage returns the values of a row image keyed by column name
+func (this *CDCTap) rowImage(values []interface{}, present []bool) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	image := make(map[string]interface{}, len(values))
+	for i, column := range this.migrationContext.OriginalTableColumns.Columns() {
+		if i >= len(values) || !binlog.IsColumnPresent(present, i) {
+			continue
+		}
+		image[column.Name] = column.JSONValue(values[i])
+	}
+	return image
+}
+
+func (this *CDCTap) buildRecord(event *cdcTapEvent) *cdcTapRecord {
+	return &cdcTapRecord{
+		Type:      strings.ToLower(string(event.dml)),
+		Database:  event.databaseName,
+		Table:     event.tableName,
+		LogFile:   event.coordinates.LogFile,
+		LogPos:    event.coordinates.LogPos,
+		Timestamp: event.timestamp,
+		Before:    this.rowImage(event.whereValues, event.wherePresent),
+		After:     this.rowImage(event.newValues, event.newPresent),
+	}
+}
+
+// writeBatch writes given events, preceded by a gap record if events were dropped since the last write
+func (this *CDCTap) writeBatch(events []*cdcTapEvent) {
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	var written int64
+	dropped := atomic.SwapInt64(&this.pendingDroppedEvents, 0)
+	if dropped > 0 {
+		encoder.Encode(&cdcTapRecord{
+			Type:      cdcTapGapRecordType,
+			Database:  this.migrationContext.DatabaseName,
+			Table:     this.migrationContext.OriginalTableName,
+			Timestamp: time.Now(),
+			Dropped:   dropped,
+		})
+	}
+	for _, event := range events {
+		if err := encoder.Encode(this.buildRecord(event)); err != nil {
+			this.migrationContext.Log.Warningf("CDC tap: cannot encode %s event at %s: %+v", event.dml, event.coordinates.DisplayString(), err)
+			this.drop(1)
+			continue
+		}
+		written++
+	}
+	if err := this.sink.write(lines.Bytes()); err != nil {
+		atomic.AddInt64(&this.pendingDroppedEvents, dropped)
+		this.drop(written)
+		if !this.isFailing {
+			this.migrationContext.Log.Warningf("CDC tap: failed writing to %s, dropping events until it recovers: %+v", this.migrationContext.CDCTapDestination, err)
+			this.isFailing = true
+		}
+		this.mutex.RLock()
+		closed := this.closed
+		this.mutex.RUnlock()
+		if !closed {
+			time.Sleep(cdcTapRetryInterval)
+		}
+		return
+	}
+	if this.isFailing {
+		this.migrationContext.Log.Infof("CDC tap: writing to %s recovered", this.migrationContext.CDCTapDestination)
+		this.isFailing = false
+	}
+	atomic.AddInt64(&this.emittedEvents, written)
+}
+
+// Run writes buffered events onto the destination, in batches, until the tap is closed. It then
+// closes the destination: being the only writer, it cannot close it while a write is in flight.
+func (this *CDCTap) Run() {
+	defer close(this.done)
+	for event := range this.events {
+		batch := []*cdcTapEvent{event}
+	batchLoop:
+		for len(batch) < cdcTapMaxBatchSize {
+			select {
+			case event, ok := <-this.events:
+				if !ok {
+					break batchLoop
+				}
+				batch = append(batch, event)
+			default:
+				break batchLoop
+			}
+		}
+		if atomic.LoadInt64(&this.abandoned) > 0 {
+			this.drop(int64(len(batch)))
+			continue
+		}
+		this.writeBatch(batch)
+	}
+	this.closeErr = this.sink.close()
+}
+
+// Close stops accepting events, and waits (for a bounded time) for buffered events to be written and for
+// the destination to be closed. When timing out, remaining events are dropped and the destination is
+// closed in the background, once the ongoing write is done.
+func (this *CDCTap) Close() error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+		return nil
+	}
+	this.closed = true
+	close(this.events)
+	this.mutex.Unlock()
+
+	select {
+	case <-this.done:
+		this.migrationContext.Log.Infof("CDC tap: emitted %d events, dropped %d", this.GetEmittedEvents(), this.GetDroppedEvents())
+		return this.closeErr
+	case <-time.After(cdcTapCloseTimeout):
+		atomic.StoreInt64(&this.abandoned, 1)
+		this.migrationContext.Log.Warningf("CDC tap: timed out writing buffered events to %s, dropping the rest", this.migrationContext.CDCTapDestination)
+		this.migrationContext.Log.Infof("CDC tap: emitted %d events, dropped %d so far", this.GetEmittedEvents(), this.GetDroppedEvents())
+		return nil
+	}
+}
+
+// GetEmittedEvents returns the number of events written onto the destination
+func (this *CDCTap) GetEmittedEvents() int64 {
+	return atomic.LoadInt64(&this.emittedEvents)
+}
+
+// GetDroppedEvents returns the number of events dropped, for overflowing the buffer or failing to be written
+func (this *CDCTap) GetDroppedEvents() int64 {
+	return atomic.LoadInt64(&this.droppedEvents)
+}
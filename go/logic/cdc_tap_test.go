@@ -0,0 +1,224 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/binlog"
+	"github.com/github/gh-ost/go/mysql"
+	"github.com/github/gh-ost/go/sql"
+)
+
+func newCDCTapTestContext(destination string) *base.MigrationContext {
+	columns := sql.NewColumnList([]string{"id", "name", "status"})
+	columns.SetColumnType("status", sql.EnumColumnType)
+	columns.SetEnumValues("status", `'active','archived'`)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tablename"
+	migrationContext.OriginalTableColumns = columns
+	migrationContext.CDCTapDestination = destination
+	migrationContext.CDCTapBufferSize = 100
+	migrationContext.CDCTapTimeoutMillis = 1000
+	return migrationContext
+}
+
+func newCDCTapTestEvents() []*binlog.BinlogDMLEvent {
+	coordinates := mysql.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: 4567}
+	timestamp := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	return []*binlog.BinlogDMLEvent{
+		{
+			DatabaseName:    "test",
+			TableName:       "tablename",
+			DML:             binlog.InsertDML,
+			Coordinates:     coordinates,
+			Timestamp:       timestamp,
+			NewColumnValues: sql.ToColumnValues([]interface{}{int32(1), "gromit", int64(1)}),
+		},
+		{
+			DatabaseName:        "test",
+			TableName:           "tablename",
+			DML:                 binlog.UpdateDML,
+			Coordinates:         coordinates,
+			Timestamp:           timestamp,
+			WhereColumnValues:   sql.ToColumnValues([]interface{}{int32(1), nil, nil}),
+			WhereColumnsPresent: []bool{true, false, false},
+			NewColumnValues:     sql.ToColumnValues([]interface{}{int32(1), "gromit", int64(2)}),
+		},
+		{
+			DatabaseName:      "test",
+			TableName:         "tablename",
+			DML:               binlog.DeleteDML,
+			Coordinates:       coordinates,
+			Timestamp:         timestamp,
+			WhereColumnValues: sql.ToColumnValues([]interface{}{int32(1), "gromit", int64(2)}),
+		},
+	}
+}
+
+func readCDCTapRecords(t *testing.T, reader io.Reader) (records []cdcTapRecord) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var record cdcTapRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func requireCDCTapTestRecords(t *testing.T, records []cdcTapRecord) {
+	require.Len(t, records, 3)
+	for _, record := range records {
+		require.Equal(t, "test", record.Database)
+		require.Equal(t, "tablename", record.Table)
+		require.Equal(t, "mysql-bin.000017", record.LogFile)
+		require.Equal(t, int64(4567), record.LogPos)
+		require.True(t, record.Timestamp.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)))
+	}
+	require.Equal(t, "insert", records[0].Type)
+	require.Nil(t, records[0].Before)
+	require.Equal(t, map[string]interface{}{"id": float64(1), "name": "gromit", "status": "active"}, records[0].After)
+	require.Equal(t, "update", records[1].Type)
+	require.Equal(t, map[string]interface{}{"id": float64(1)}, records[1].Before)
+	require.Equal(t, map[string]interface{}{"id": float64(1), "name": "gromit", "status": "archived"}, records[1].After)
+	require.Equal(t, "delete", records[2].Type)
+	require.Equal(t, map[string]interface{}{"id": float64(1), "name": "gromit", "status": "archived"}, records[2].Before)
+	require.Nil(t, records[2].After)
+}
+
+func TestCDCTap(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "cdc.jsonl")
+		cdcTap, err := NewCDCTap(newCDCTapTestContext(fileName))
+		require.NoError(t, err)
+		go cdcTap.Run()
+		for _, dmlEvent := range newCDCTapTestEvents() {
+			cdcTap.Emit(dmlEvent)
+		}
+		require.NoError(t, cdcTap.Close())
+		require.NoError(t, cdcTap.Close())
+		require.Equal(t, int64(3), cdcTap.GetEmittedEvents())
+		require.Equal(t, int64(0), cdcTap.GetDroppedEvents())
+
+		file, err := os.Open(fileName)
+		require.NoError(t, err)
+		defer file.Close()
+		requireCDCTapTestRecords(t, readCDCTapRecords(t, file))
+	})
+
+	t.Run("unix socket", func(t *testing.T) {
+		socketFile := filepath.Join(t.TempDir(), "cdc.sock")
+		listener, err := net.Listen("unix", socketFile)
+		require.NoError(t, err)
+		defer listener.Close()
+		received := make(chan []cdcTapRecord)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(received)
+				return
+			}
+			defer conn.Close()
+			received <- readCDCTapRecords(t, conn)
+		}()
+
+		cdcTap, err := NewCDCTap(newCDCTapTestContext("unix://" + socketFile))
+		require.NoError(t, err)
+		go cdcTap.Run()
+		for _, dmlEvent := range newCDCTapTestEvents() {
+			cdcTap.Emit(dmlEvent)
+		}
+		require.NoError(t, cdcTap.Close())
+		requireCDCTapTestRecords(t, <-received)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		var records []cdcTapRecord
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			records = append(records, readCDCTapRecords(t, r.Body)...)
+		}))
+		defer server.Close()
+
+		cdcTap, err := NewCDCTap(newCDCTapTestContext(server.URL))
+		require.NoError(t, err)
+		go cdcTap.Run()
+		for _, dmlEvent := range newCDCTapTestEvents() {
+			cdcTap.Emit(dmlEvent)
+		}
+		require.NoError(t, cdcTap.Close())
+		requireCDCTapTestRecords(t, records)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "cdc.jsonl")
+		migrationContext := newCDCTapTestContext(fileName)
+		migrationContext.CDCTapBufferSize = 2
+		cdcTap, err := NewCDCTap(migrationContext)
+		require.NoError(t, err)
+		// Events are emitted ahead of the tap running, overflowing its buffer
+		dmlEvents := newCDCTapTestEvents()
+		for _, dmlEvent := range dmlEvents {
+			cdcTap.Emit(dmlEvent)
+		}
+		require.Equal(t, int64(1), cdcTap.GetDroppedEvents())
+		go cdcTap.Run()
+		require.NoError(t, cdcTap.Close())
+		cdcTap.Emit(dmlEvents[0])
+		require.Equal(t, int64(2), cdcTap.GetEmittedEvents())
+		require.Equal(t, int64(1), cdcTap.GetDroppedEvents())
+
+		file, err := os.Open(fileName)
+		require.NoError(t, err)
+		defer file.Close()
+		records := readCDCTapRecords(t, file)
+		require.Len(t, records, 3)
+		require.Equal(t, cdcTapGapRecordType, records[0].Type)
+		require.Equal(t, int64(1), records[0].Dropped)
+		require.Equal(t, "insert", records[1].Type)
+		require.Equal(t, "update", records[2].Type)
+	})
+
+	t.Run("failing destination", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		cdcTap, err := NewCDCTap(newCDCTapTestContext(server.URL))
+		require.NoError(t, err)
+		for _, dmlEvent := range newCDCTapTestEvents() {
+			cdcTap.Emit(dmlEvent)
+		}
+		go cdcTap.Run()
+		require.NoError(t, cdcTap.Close())
+		require.Equal(t, int64(0), cdcTap.GetEmittedEvents())
+		require.Equal(t, int64(3), cdcTap.GetDroppedEvents())
+	})
+
+	t.Run("invalid destination", func(t *testing.T) {
+		for _, destination := range []string{"unix://", "http://", filepath.Join(t.TempDir(), "no-such-dir", "cdc.jsonl")} {
+			_, err := NewCDCTap(newCDCTapTestContext(destination))
+			require.Error(t, err, destination)
+		}
+	})
+}
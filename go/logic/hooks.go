@@ -6,12 +6,21 @@
 package logic
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/openark/golib/log"
@@ -31,24 +40,217 @@ const (
 	onStatus             = "gh-ost-on-status"
 	onStopReplication    = "gh-ost-on-stop-replication"
 	onStartReplication   = "gh-ost-on-start-replication"
+	onGhostTableCreated  = "gh-ost-on-ghost-table-created"
+	onThrottleChange     = "gh-ost-on-throttle-change"
+	onCutOverAttemptFail = "gh-ost-on-cut-over-attempt-failed"
+	onHibernationBegin   = "gh-ost-on-hibernation-begin"
+	onHibernationEnd     = "gh-ost-on-hibernation-end"
+	onAbort              = "gh-ost-on-abort"
+	onBeforeHandOff      = "gh-ost-on-before-hand-off"
+	onHandOff            = "gh-ost-on-hand-off"
 )
 
+var knownHooks = []string{
+	onStartup,
+	onValidated,
+	onRowCountComplete,
+	onBeforeRowCopy,
+	onRowCopyComplete,
+	onBeginPostponed,
+	onBeforeCutOver,
+	onInteractiveCommand,
+	onSuccess,
+	onFailure,
+	onStatus,
+	onStopReplication,
+	onStartReplication,
+	onGhostTableCreated,
+	onThrottleChange,
+	onCutOverAttemptFail,
+	onHibernationBegin,
+	onHibernationEnd,
+	onAbort,
+	onBeforeHandOff,
+	onHandOff,
+}
+
+// hookResponse is a JSON document which a hook may output (or a webhook may respond with)
+// so as to steer the migration. All fields are optional.
+type hookResponse struct {
+	Throttle        *bool   `json:"throttle"`
+	PostponeCutOver *bool   `json:"postpone-cut-over"`
+	ChunkSize       *int64  `json:"chunk-size"`
+	MaxLagMillis    *int64  `json:"max-lag-millis"`
+	Message         *string `json:"message"`
+}
+
+// parseHookResponse parses a JSON hook response. Unknown fields are rejected, so that
+// arbitrary JSON output is not mistaken for a response.
+func parseHookResponse(data []byte) (*hookResponse, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	response := &hookResponse{}
+	if err := decoder.Decode(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// parseHookOutputResponses returns the hook responses found in a hook's output: any line which
+// holds a JSON hook response. All other output is ignored.
+func parseHookOutputResponses(output []byte) (responses []*hookResponse) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+			continue
+		}
+		if response, err := parseHookResponse([]byte(line)); err == nil {
+			responses = append(responses, response)
+		}
+	}
+	return responses
+}
+
+// asyncAllowedHooks are the informational hooks which may run asynchronously: gh-ost
+// does not wait on them, and their failure does not affect the migration
+var asyncAllowedHooks = []string{
+	onStatus,
+	onInteractiveCommand,
+}
+
+const allHooksHint = "all"
+
+// hookKillWaitDelay is how long to wait for a killed hook's output to be closed,
+// e.g. by background processes it has spawned
+const hookKillWaitDelay = 1 * time.Second
+
+// backgroundHooksQueueSize is how many hooks may await their turn in the background
+const backgroundHooksQueueSize = 100
+
+// maxAbortHookWait is how long gh-ost waits on the gh-ost-on-abort hook before exiting, unless
+// the hook's timeout is shorter
+const maxAbortHookWait = 30 * time.Second
+
 type HooksExecutor struct {
-	migrationContext *base.MigrationContext
-	writer           io.Writer
+	migrationContext    *base.MigrationContext
+	httpClient          *http.Client
+	runningAsync        map[string]bool
+	runningAsyncLock    sync.Mutex
+	backgroundHooks     chan func()
+	backgroundHooksOnce sync.Once
 }
 
 func NewHooksExecutor(migrationContext *base.MigrationContext) *HooksExecutor {
 	return &HooksExecutor{
 		migrationContext: migrationContext,
-		writer:           os.Stderr,
+		httpClient:       &http.Client{},
+		runningAsync:     make(map[string]bool),
+		backgroundHooks:  make(chan func(), backgroundHooksQueueSize),
+	}
+}
+
+// splitHooksList splits a comma delimited list of hooks, as given on the command line,
+// ignoring empty entries
+func splitHooksList(value string) (entries []string) {
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
 	}
+	return entries
+}
+
+// hooksListContains checks whether a list of hooks, as given on the command line,
+// includes the given hook. The special name "all" matches any hook.
+func hooksListContains(value string, hook string) bool {
+	for _, entry := range splitHooksList(value) {
+		if entry == allHooksHint || entry == hook {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownHook(hook string, knownHooks []string) bool {
+	for _, knownHook := range knownHooks {
+		if hook == knownHook {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHooksList validates a list of hooks given to a command line flag
+func validateHooksList(flagName string, value string, allowedHooks []string) error {
+	for _, entry := range splitHooksList(value) {
+		if entry == allHooksHint {
+			continue
+		}
+		if !isKnownHook(entry, allowedHooks) {
+			return fmt.Errorf("--%s: unsupported hook %q", flagName, entry)
+		}
+	}
+	return nil
+}
+
+// parseHooksTimeouts parses a comma delimited list of hook=seconds entries
+func parseHooksTimeouts(value string) (timeouts map[string]int64, err error) {
+	timeouts = make(map[string]int64)
+	for _, entry := range splitHooksList(value) {
+		tokens := strings.SplitN(entry, "=", 2)
+		if len(tokens) != 2 {
+			return timeouts, fmt.Errorf("--hooks-timeouts: expecting hook=seconds, got %q", entry)
+		}
+		hook := strings.TrimSpace(tokens[0])
+		if !isKnownHook(hook, knownHooks) {
+			return timeouts, fmt.Errorf("--hooks-timeouts: unsupported hook %q", hook)
+		}
+		seconds, err := strconv.ParseInt(strings.TrimSpace(tokens[1]), 10, 64)
+		if err != nil || seconds < 0 {
+			return timeouts, fmt.Errorf("--hooks-timeouts: invalid timeout in %q", entry)
+		}
+		timeouts[hook] = seconds
+	}
+	return timeouts, nil
+}
+
+// validateHooks validates the hooks configuration, ahead of any hook being called
+func (this *HooksExecutor) validateHooks() error {
+	if this.migrationContext.HooksTimeoutSeconds < 0 {
+		return fmt.Errorf("--hooks-timeout-seconds must not be negative")
+	}
+	if _, err := parseHooksTimeouts(this.migrationContext.HooksTimeouts); err != nil {
+		return err
+	}
+	if err := validateHooksList("hooks-async", this.migrationContext.HooksAsync, asyncAllowedHooks); err != nil {
+		return err
+	}
+	if err := validateHooksList("hooks-ignore-exit-code", this.migrationContext.HooksIgnoreExitCode, knownHooks); err != nil {
+		return err
+	}
+	return this.validateWebhooks()
+}
+
+// hookTimeout returns the timeout for given hook, or 0 for no timeout
+func (this *HooksExecutor) hookTimeout(baseName string) time.Duration {
+	timeouts, _ := parseHooksTimeouts(this.migrationContext.HooksTimeouts)
+	if seconds, ok := timeouts[baseName]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(this.migrationContext.HooksTimeoutSeconds) * time.Second
 }
 
 func (this *HooksExecutor) applyEnvironmentVariables(extraVariables ...string) []string {
-	env := os.Environ()
+	return append(os.Environ(), this.hookVariables(extraVariables...)...)
+}
+
+// hookVariables returns the GH_OST_* variables describing the migration, in KEY=VALUE form
+func (this *HooksExecutor) hookVariables(extraVariables ...string) []string {
+	var env []string
 	env = append(env, fmt.Sprintf("GH_OST_DATABASE_NAME=%s", this.migrationContext.DatabaseName))
 	env = append(env, fmt.Sprintf("GH_OST_TABLE_NAME=%s", this.migrationContext.OriginalTableName))
+	env = append(env, fmt.Sprintf("GH_OST_NEW_TABLE_NAME=%s", this.migrationContext.GetMigratedTableName()))
 	env = append(env, fmt.Sprintf("GH_OST_GHOST_TABLE_NAME=%s", this.migrationContext.GetGhostTableName()))
 	env = append(env, fmt.Sprintf("GH_OST_OLD_TABLE_NAME=%s", this.migrationContext.GetOldTableName()))
 	env = append(env, fmt.Sprintf("GH_OST_DDL=%s", this.migrationContext.AlterStatement))
@@ -58,6 +260,7 @@ func (this *HooksExecutor) applyEnvironmentVariables(extraVariables ...string) [
 	env = append(env, fmt.Sprintf("GH_OST_ESTIMATED_ROWS=%d", estimatedRows))
 	totalRowsCopied := this.migrationContext.GetTotalRowsCopied()
 	env = append(env, fmt.Sprintf("GH_OST_COPIED_ROWS=%d", totalRowsCopied))
+	env = append(env, fmt.Sprintf("GH_OST_DISCARDED_ROWS=%d", this.migrationContext.GetTotalRowsDiscarded()))
 	env = append(env, fmt.Sprintf("GH_OST_MIGRATED_HOST=%s", this.migrationContext.GetApplierHostname()))
 	env = append(env, fmt.Sprintf("GH_OST_INSPECTED_HOST=%s", this.migrationContext.GetInspectorHostname()))
 	env = append(env, fmt.Sprintf("GH_OST_EXECUTING_HOST=%s", this.migrationContext.Hostname))
@@ -69,20 +272,92 @@ func (this *HooksExecutor) applyEnvironmentVariables(extraVariables ...string) [
 	env = append(env, fmt.Sprintf("GH_OST_HOOKS_HINT_OWNER=%s", this.migrationContext.HooksHintOwner))
 	env = append(env, fmt.Sprintf("GH_OST_HOOKS_HINT_TOKEN=%s", this.migrationContext.HooksHintToken))
 	env = append(env, fmt.Sprintf("GH_OST_DRY_RUN=%t", this.migrationContext.Noop))
+	if this.migrationContext.IsCrossServerMigration() {
+		env = append(env, fmt.Sprintf("GH_OST_TARGET_HOST=%s", this.migrationContext.GetTargetHostname()))
+		env = append(env, fmt.Sprintf("GH_OST_TARGET_DATABASE_NAME=%s", this.migrationContext.GetGhostDatabaseName()))
+		env = append(env, fmt.Sprintf("GH_OST_TARGET_TABLE_NAME=%s", this.migrationContext.GetTargetTableName()))
+	}
 
 	env = append(env, extraVariables...)
 	return env
 }
 
-// executeHook executes a command, and sets relevant environment variables
-// combined output & error are printed to the configured writer.
-func (this *HooksExecutor) executeHook(hook string, extraVariables ...string) error {
-	cmd := exec.Command(hook)
+// logHookOutput logs a hook's output line by line, tagged with the hook's name
+func logHookOutput(hook string, stream string, output []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		log.Infof("%s [%s]: %s", filepath.Base(hook), stream, scanner.Text())
+	}
+}
+
+// executeHook executes a command, and sets relevant environment variables.
+// The hook runs in its own process group, which is killed when the hook times out.
+// stdout & stderr are logged, tagged with the hook name.
+func (this *HooksExecutor) executeHook(baseName string, hook string, extraVariables ...string) error {
+	ctx := context.Background()
+	if timeout := this.hookTimeout(baseName); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, hook)
 	cmd.Env = this.applyEnvironmentVariables(extraVariables...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = hookKillWaitDelay
 
-	combinedOutput, err := cmd.CombinedOutput()
-	fmt.Fprintln(this.writer, string(combinedOutput))
-	return log.Errore(err)
+	err := cmd.Run()
+	logHookOutput(hook, "stdout", stdout.Bytes())
+	logHookOutput(hook, "stderr", stderr.Bytes())
+	if ctx.Err() == context.DeadlineExceeded {
+		return log.Errorf("%s hook %s timed out after %+v and was killed", baseName, hook, this.hookTimeout(baseName))
+	}
+	if err != nil {
+		return log.Errorf("%s hook %s failed: %+v", baseName, hook, err)
+	}
+	for _, response := range parseHookOutputResponses(stdout.Bytes()) {
+		this.applyHookResponse(hook, response)
+	}
+	return nil
+}
+
+// applyHookResponse acts on a hook's response, much like the equivalent interactive commands
+func (this *HooksExecutor) applyHookResponse(hook string, response *hookResponse) {
+	if response.Throttle != nil {
+		log.Infof("hook %s requests throttle=%t", hook, *response.Throttle)
+		if *response.Throttle {
+			atomic.StoreInt64(&this.migrationContext.ThrottleCommandedByHook, 1)
+		} else {
+			atomic.StoreInt64(&this.migrationContext.ThrottleCommandedByHook, 0)
+		}
+	}
+	if response.PostponeCutOver != nil {
+		log.Infof("hook %s requests postpone-cut-over=%t", hook, *response.PostponeCutOver)
+		if *response.PostponeCutOver {
+			atomic.StoreInt64(&this.migrationContext.PostponeCutOverCommandedByHook, 1)
+		} else {
+			atomic.StoreInt64(&this.migrationContext.PostponeCutOverCommandedByHook, 0)
+			if atomic.LoadInt64(&this.migrationContext.IsPostponingCutOver) > 0 {
+				atomic.StoreInt64(&this.migrationContext.UserCommandedUnpostponeFlag, 1)
+			}
+		}
+	}
+	if response.ChunkSize != nil {
+		log.Infof("hook %s requests chunk-size=%d", hook, *response.ChunkSize)
+		this.migrationContext.SetChunkSize(*response.ChunkSize)
+	}
+	if response.MaxLagMillis != nil {
+		log.Infof("hook %s requests max-lag-millis=%d", hook, *response.MaxLagMillis)
+		this.migrationContext.SetMaxLagMillisecondsThrottleThreshold(*response.MaxLagMillis)
+	}
+	if response.Message != nil {
+		this.migrationContext.SetHooksStatusMessage(*response.Message)
+	}
 }
 
 func (this *HooksExecutor) detectHooks(baseName string) (hooks []string, err error) {
@@ -95,19 +370,77 @@ func (this *HooksExecutor) detectHooks(baseName string) (hooks []string, err err
 }
 
 func (this *HooksExecutor) executeHooks(baseName string, extraVariables ...string) error {
+	if hooksListContains(this.migrationContext.HooksAsync, baseName) && isKnownHook(baseName, asyncAllowedHooks) {
+		return this.executeHooksAsync(baseName, extraVariables...)
+	}
+	return this.executeHooksSync(baseName, extraVariables...)
+}
+
+// executeHooksSync executes the hook executables and webhooks for a given hook, sequentially.
+// A failure fails the hook, unless the hook is listed in --hooks-ignore-exit-code.
+func (this *HooksExecutor) executeHooksSync(baseName string, extraVariables ...string) error {
 	hooks, err := this.detectHooks(baseName)
 	if err != nil {
 		return err
 	}
 	for _, hook := range hooks {
 		log.Infof("executing %+v hook: %+v", baseName, hook)
-		if err := this.executeHook(hook, extraVariables...); err != nil {
-			return err
+		if err := this.executeHook(baseName, hook, extraVariables...); err != nil {
+			if !hooksListContains(this.migrationContext.HooksIgnoreExitCode, baseName) {
+				return err
+			}
+			log.Warningf("ignoring %+v hook failure as per --hooks-ignore-exit-code", baseName)
 		}
 	}
+	return this.executeWebhooks(baseName, extraVariables...)
+}
+
+// executeHooksAsync executes a hook in the background, without waiting on it. If the hook's
+// previous execution is still running, this execution is skipped.
+func (this *HooksExecutor) executeHooksAsync(baseName string, extraVariables ...string) error {
+	this.runningAsyncLock.Lock()
+	defer this.runningAsyncLock.Unlock()
+	if this.runningAsync[baseName] {
+		log.Warningf("skipping %+v hook: previous execution still running", baseName)
+		return nil
+	}
+	this.runningAsync[baseName] = true
+	go func() {
+		defer func() {
+			this.runningAsyncLock.Lock()
+			defer this.runningAsyncLock.Unlock()
+			delete(this.runningAsync, baseName)
+		}()
+		if err := this.executeHooksSync(baseName, extraVariables...); err != nil {
+			log.Errorf("asynchronous %+v hook failed: %+v", baseName, err)
+		}
+	}()
 	return nil
 }
 
+// executeInBackground queues a hook onto a single background goroutine, which runs the queued
+// hooks one at a time, in order. Their failure is only logged. Should too many hooks be queued,
+// this one is skipped.
+func (this *HooksExecutor) executeInBackground(baseName string, execute func() error) {
+	this.backgroundHooksOnce.Do(func() {
+		go func() {
+			for run := range this.backgroundHooks {
+				run()
+			}
+		}()
+	})
+	run := func() {
+		if err := execute(); err != nil {
+			log.Errorf("background %+v hook failed: %+v", baseName, err)
+		}
+	}
+	select {
+	case this.backgroundHooks <- run:
+	default:
+		log.Warningf("skipping %+v hook: %d hooks already queued in the background", baseName, backgroundHooksQueueSize)
+	}
+}
+
 func (this *HooksExecutor) onStartup() error {
 	return this.executeHooks(onStartup)
 }
@@ -135,6 +468,14 @@ func (this *HooksExecutor) onBeforeCutOver() error {
 	return this.executeHooks(onBeforeCutOver)
 }
 
+func (this *HooksExecutor) onBeforeHandOff() error {
+	return this.executeHooks(onBeforeHandOff)
+}
+
+func (this *HooksExecutor) onHandOff() error {
+	return this.executeHooks(onHandOff)
+}
+
 func (this *HooksExecutor) onInteractiveCommand(command string) error {
 	v := fmt.Sprintf("GH_OST_COMMAND='%s'", command)
 	return this.executeHooks(onInteractiveCommand, v)
@@ -160,3 +501,55 @@ func (this *HooksExecutor) onStopReplication() error {
 func (this *HooksExecutor) onStartReplication() error {
 	return this.executeHooks(onStartReplication)
 }
+
+func (this *HooksExecutor) onGhostTableCreated() error {
+	return this.executeHooks(onGhostTableCreated)
+}
+
+func (this *HooksExecutor) onThrottleChange(throttled bool, reason string) error {
+	v1 := fmt.Sprintf("GH_OST_THROTTLED=%t", throttled)
+	v2 := fmt.Sprintf("GH_OST_THROTTLE_REASON=%s", reason)
+	return this.executeHooks(onThrottleChange, v1, v2)
+}
+
+func (this *HooksExecutor) onCutOverAttemptFailed(attempt int64, cutOverError error) error {
+	v1 := fmt.Sprintf("GH_OST_CUT_OVER_ATTEMPT=%d", attempt)
+	v2 := fmt.Sprintf("GH_OST_CUT_OVER_ERROR=%s", cutOverError.Error())
+	return this.executeHooks(onCutOverAttemptFail, v1, v2)
+}
+
+func (this *HooksExecutor) onHibernationBegin(variableName string, value, threshold int64, hibernateUntil time.Time) error {
+	v1 := fmt.Sprintf("GH_OST_CRITICAL_LOAD_VARIABLE=%s", variableName)
+	v2 := fmt.Sprintf("GH_OST_CRITICAL_LOAD_VALUE=%d", value)
+	v3 := fmt.Sprintf("GH_OST_CRITICAL_LOAD_THRESHOLD=%d", threshold)
+	v4 := fmt.Sprintf("GH_OST_HIBERNATE_UNTIL=%s", hibernateUntil.Format(time.RFC3339))
+	return this.executeHooks(onHibernationBegin, v1, v2, v3, v4)
+}
+
+func (this *HooksExecutor) onHibernationEnd() error {
+	return this.executeHooks(onHibernationEnd)
+}
+
+func (this *HooksExecutor) onAbort(abortError error) error {
+	v := fmt.Sprintf("GH_OST_ABORT_REASON=%s", abortError.Error())
+	return this.executeHooks(onAbort, v)
+}
+
+// onAbortWithin runs the gh-ost-on-abort hook, waiting on it no longer than maxAbortHookWait (or its
+// timeout, if shorter), so that an abort is not held up by a hanging hook
+func (this *HooksExecutor) onAbortWithin(abortError error) error {
+	wait := maxAbortHookWait
+	if timeout := this.hookTimeout(onAbort); timeout > 0 && timeout+hookKillWaitDelay < wait {
+		wait = timeout + hookKillWaitDelay
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- this.onAbort(abortError)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(wait):
+		return log.Errorf("%s hook still running after %+v; aborting regardless", onAbort, wait)
+	}
+}
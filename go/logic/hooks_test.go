@@ -13,12 +13,14 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/github/gh-ost/go/base"
+	"github.com/github/gh-ost/go/mysql"
 )
 
 func TestHooksExecutorExecuteHooks(t *testing.T) {
@@ -65,17 +67,18 @@ func TestHooksExecutorExecuteHooks(t *testing.T) {
 		if migrationContext.HooksPath, err = writeTmpHookFunc(
 			"TestHooksExecutorExecuteHooks-success",
 			"success-hook",
-			"#!/bin/sh\nenv",
+			"#!/bin/sh\nenv > \"$TEST_ENV_FILE\"",
 		); err != nil {
 			panic(err)
 		}
 		defer os.RemoveAll(migrationContext.HooksPath)
 
-		var buf bytes.Buffer
-		hooksExecutor.writer = &buf
-		require.Nil(t, hooksExecutor.executeHooks("success-hook", "TEST="+t.Name()))
+		envFile := filepath.Join(migrationContext.HooksPath, "env")
+		require.Nil(t, hooksExecutor.executeHooks("success-hook", "TEST="+t.Name(), "TEST_ENV_FILE="+envFile))
+		env, err := os.ReadFile(envFile)
+		require.Nil(t, err)
 
-		scanner := bufio.NewScanner(&buf)
+		scanner := bufio.NewScanner(bytes.NewReader(env))
 		for scanner.Scan() {
 			split := strings.SplitN(scanner.Text(), "=", 2)
 			switch split[0] {
@@ -96,6 +99,8 @@ func TestHooksExecutorExecuteHooks(t *testing.T) {
 				require.Equal(t, int64(60), etaSeconds)
 			case "GH_OST_EXECUTING_HOST":
 				require.Equal(t, migrationContext.Hostname, split[1])
+			case "GH_OST_NEW_TABLE_NAME":
+				require.Equal(t, migrationContext.OriginalTableName, split[1])
 			case "GH_OST_GHOST_TABLE_NAME":
 				require.Equal(t, fmt.Sprintf("_%s_gho", migrationContext.OriginalTableName), split[1])
 			case "GH_OST_OLD_TABLE_NAME":
@@ -110,4 +115,223 @@ func TestHooksExecutorExecuteHooks(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("response", func(t *testing.T) {
+		var err error
+		if migrationContext.HooksPath, err = writeTmpHookFunc(
+			"TestHooksExecutorExecuteHooks-response",
+			"response-hook",
+			"#!/bin/sh\necho 'checking deploys'\necho '{\"throttle\": true, \"message\": \"deploy in progress\"}'",
+		); err != nil {
+			panic(err)
+		}
+		defer os.RemoveAll(migrationContext.HooksPath)
+		defer atomic.StoreInt64(&migrationContext.ThrottleCommandedByHook, 0)
+
+		require.Nil(t, hooksExecutor.executeHooks("response-hook"))
+		require.Equal(t, int64(1), atomic.LoadInt64(&migrationContext.ThrottleCommandedByHook))
+		require.Equal(t, "deploy in progress", migrationContext.GetHooksStatusMessage())
+	})
+}
+
+func TestParseHookOutputResponses(t *testing.T) {
+	output := []byte("some text\n{\"throttle\": true}\n{\"not-a-hook-response\": 1}\n  {\"chunk-size\": 500, \"message\": \"deploying\"}  \n{broken\n")
+	responses := parseHookOutputResponses(output)
+	require.Len(t, responses, 2)
+	require.True(t, *responses[0].Throttle)
+	require.Nil(t, responses[0].ChunkSize)
+	require.Equal(t, int64(500), *responses[1].ChunkSize)
+	require.Equal(t, "deploying", *responses[1].Message)
+}
+
+func TestHooksExecutorApplyHookResponse(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	hooksExecutor := NewHooksExecutor(migrationContext)
+
+	responses := parseHookOutputResponses([]byte(`{"throttle": true, "postpone-cut-over": true, "chunk-size": 2500, "max-lag-millis": 3000, "message": "waiting on deploy"}`))
+	require.Len(t, responses, 1)
+	hooksExecutor.applyHookResponse(onStatus, responses[0])
+	require.Equal(t, int64(1), migrationContext.ThrottleCommandedByHook)
+	require.Equal(t, int64(1), migrationContext.PostponeCutOverCommandedByHook)
+	require.Equal(t, int64(2500), migrationContext.ChunkSize)
+	require.Equal(t, int64(3000), migrationContext.MaxLagMillisecondsThrottleThreshold)
+	require.Equal(t, "waiting on deploy", migrationContext.GetHooksStatusMessage())
+
+	migrationContext.IsPostponingCutOver = 1
+	responses = parseHookOutputResponses([]byte(`{"throttle": false, "postpone-cut-over": false}`))
+	hooksExecutor.applyHookResponse(onStatus, responses[0])
+	require.Equal(t, int64(0), migrationContext.ThrottleCommandedByHook)
+	require.Equal(t, int64(0), migrationContext.PostponeCutOverCommandedByHook)
+	require.Equal(t, int64(1), migrationContext.UserCommandedUnpostponeFlag)
+	require.Equal(t, int64(2500), migrationContext.ChunkSize)
+	require.Equal(t, "waiting on deploy", migrationContext.GetHooksStatusMessage())
+}
+
+func TestHooksExecutorHookExecutionPolicies(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	hooksExecutor := NewHooksExecutor(migrationContext)
+
+	writeTmpHook := func(hookName, script string) string {
+		path, err := os.MkdirTemp("", t.Name())
+		require.Nil(t, err)
+		require.Nil(t, os.WriteFile(filepath.Join(path, hookName), []byte(script), 0777))
+		return path
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		migrationContext.HooksPath = writeTmpHook(onBeforeCutOver, "#!/bin/sh\nsleep 30 &\nsleep 30")
+		defer os.RemoveAll(migrationContext.HooksPath)
+		migrationContext.HooksTimeouts = onBeforeCutOver + "=1"
+		defer func() { migrationContext.HooksTimeouts = "" }()
+
+		startTime := time.Now()
+		err := hooksExecutor.onBeforeCutOver()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "timed out")
+		require.Less(t, time.Since(startTime), 10*time.Second)
+	})
+
+	t.Run("ignore-exit-code", func(t *testing.T) {
+		migrationContext.HooksPath = writeTmpHook(onRowCopyComplete, "#!/bin/sh\nexit 1")
+		defer os.RemoveAll(migrationContext.HooksPath)
+		require.Error(t, hooksExecutor.onRowCopyComplete())
+
+		migrationContext.HooksIgnoreExitCode = onRowCopyComplete
+		defer func() { migrationContext.HooksIgnoreExitCode = "" }()
+		require.Nil(t, hooksExecutor.onRowCopyComplete())
+	})
+
+	t.Run("async", func(t *testing.T) {
+		migrationContext.HooksPath = writeTmpHook(onStatus, "#!/bin/sh\nsleep 1\necho $GH_OST_STATUS >> \"$(dirname $0)/status\"\nexit 1")
+		defer os.RemoveAll(migrationContext.HooksPath)
+		migrationContext.HooksAsync = onStatus
+		defer func() { migrationContext.HooksAsync = "" }()
+
+		startTime := time.Now()
+		require.Nil(t, hooksExecutor.onStatus("first"))
+		require.Nil(t, hooksExecutor.onStatus("second"))
+		require.Less(t, time.Since(startTime), time.Second)
+
+		statusFile := filepath.Join(migrationContext.HooksPath, "status")
+		require.Eventually(t, func() bool {
+			hooksExecutor.runningAsyncLock.Lock()
+			defer hooksExecutor.runningAsyncLock.Unlock()
+			return !hooksExecutor.runningAsync[onStatus]
+		}, 5*time.Second, 100*time.Millisecond)
+		status, err := os.ReadFile(statusFile)
+		require.Nil(t, err)
+		require.Equal(t, "'first'\n", string(status))
+	})
+
+	t.Run("background", func(t *testing.T) {
+		var executed []int
+		done := make(chan bool)
+		for i := 0; i < 3; i++ {
+			i := i
+			hooksExecutor.executeInBackground(onThrottleChange, func() error {
+				time.Sleep(10 * time.Millisecond)
+				executed = append(executed, i)
+				if i == 2 {
+					done <- true
+				}
+				return fmt.Errorf("failed %d", i)
+			})
+		}
+		<-done
+		require.Equal(t, []int{0, 1, 2}, executed)
+	})
+
+	t.Run("abort wait", func(t *testing.T) {
+		migrationContext.HooksPath = writeTmpHook(onAbort, "#!/bin/sh\nsleep 30")
+		defer os.RemoveAll(migrationContext.HooksPath)
+		migrationContext.HooksTimeouts = onAbort + "=1"
+		defer func() { migrationContext.HooksTimeouts = "" }()
+
+		startTime := time.Now()
+		require.Error(t, hooksExecutor.onAbortWithin(fmt.Errorf("panic")))
+		require.Less(t, time.Since(startTime), 10*time.Second)
+	})
+}
+
+func TestHooksExecutorValidateHooks(t *testing.T) {
+	{
+		hooksExecutor := NewHooksExecutor(base.NewMigrationContext())
+		hooksExecutor.migrationContext.HooksWebhookTimeoutMillis = 1000
+		hooksExecutor.migrationContext.HooksTimeouts = "gh-ost-on-status=5, gh-ost-on-before-cut-over=0"
+		hooksExecutor.migrationContext.HooksAsync = "gh-ost-on-status,gh-ost-on-interactive-command"
+		require.Nil(t, hooksExecutor.validateHooks())
+		require.Equal(t, 5*time.Second, hooksExecutor.hookTimeout(onStatus))
+		require.Equal(t, time.Duration(0), hooksExecutor.hookTimeout(onBeforeCutOver))
+	}
+	{
+		hooksExecutor := NewHooksExecutor(base.NewMigrationContext())
+		hooksExecutor.migrationContext.HooksTimeouts = "gh-ost-on-status"
+		require.Error(t, hooksExecutor.validateHooks())
+	}
+	{
+		hooksExecutor := NewHooksExecutor(base.NewMigrationContext())
+		hooksExecutor.migrationContext.HooksAsync = "gh-ost-on-before-cut-over"
+		require.Error(t, hooksExecutor.validateHooks())
+	}
+}
+
+func TestHooksExecutorHookVariables(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	hooksExecutor := NewHooksExecutor(migrationContext)
+
+	hooksPath, err := os.MkdirTemp("", t.Name())
+	require.Nil(t, err)
+	defer os.RemoveAll(hooksPath)
+	migrationContext.HooksPath = hooksPath
+
+	readHookEnv := func(hookName string, execute func() error) map[string]string {
+		envFile := filepath.Join(hooksPath, hookName+".env")
+		script := fmt.Sprintf("#!/bin/sh\nenv > %s", envFile)
+		require.Nil(t, os.WriteFile(filepath.Join(hooksPath, hookName), []byte(script), 0777))
+		require.Nil(t, execute())
+		content, err := os.ReadFile(envFile)
+		require.Nil(t, err)
+		env := make(map[string]string)
+		for _, line := range strings.Split(string(content), "\n") {
+			if split := strings.SplitN(line, "=", 2); len(split) == 2 {
+				env[split[0]] = split[1]
+			}
+		}
+		return env
+	}
+
+	env := readHookEnv(onThrottleChange, func() error { return hooksExecutor.onThrottleChange(true, "lag=3.5s") })
+	require.Equal(t, "true", env["GH_OST_THROTTLED"])
+	require.Equal(t, "lag=3.5s", env["GH_OST_THROTTLE_REASON"])
+
+	env = readHookEnv(onCutOverAttemptFail, func() error {
+		return hooksExecutor.onCutOverAttemptFailed(2, fmt.Errorf("lock wait timeout exceeded"))
+	})
+	require.Equal(t, "2", env["GH_OST_CUT_OVER_ATTEMPT"])
+	require.Equal(t, "lock wait timeout exceeded", env["GH_OST_CUT_OVER_ERROR"])
+
+	hibernateUntil := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	env = readHookEnv(onHibernationBegin, func() error {
+		return hooksExecutor.onHibernationBegin("Threads_running", 1200, 1000, hibernateUntil)
+	})
+	require.Equal(t, "Threads_running", env["GH_OST_CRITICAL_LOAD_VARIABLE"])
+	require.Equal(t, "1200", env["GH_OST_CRITICAL_LOAD_VALUE"])
+	require.Equal(t, "1000", env["GH_OST_CRITICAL_LOAD_THRESHOLD"])
+	require.Equal(t, "2024-06-01T10:00:00Z", env["GH_OST_HIBERNATE_UNTIL"])
+
+	env = readHookEnv(onAbort, func() error { return hooksExecutor.onAbort(fmt.Errorf("User commanded 'panic'")) })
+	require.Equal(t, "User commanded 'panic'", env["GH_OST_ABORT_REASON"])
+	_, isCrossServer := env["GH_OST_TARGET_HOST"]
+	require.False(t, isCrossServer)
+
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tablename"
+	migrationContext.TargetHost = "target.example.com"
+	migrationContext.TargetDatabaseName = "test_target"
+	migrationContext.TargetConnectionConfig = mysql.NewConnectionConfig()
+	migrationContext.TargetConnectionConfig.ImpliedKey = &mysql.InstanceKey{Hostname: "target-1.example.com", Port: 3306}
+	env = readHookEnv(onHandOff, hooksExecutor.onHandOff)
+	require.Equal(t, "target-1.example.com", env["GH_OST_TARGET_HOST"])
+	require.Equal(t, "test_target", env["GH_OST_TARGET_DATABASE_NAME"])
+	require.Equal(t, "tablename", env["GH_OST_TARGET_TABLE_NAME"])
 }
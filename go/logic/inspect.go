@@ -11,9 +11,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/github/gh-ost/go/base"
 	"github.com/github/gh-ost/go/mysql"
@@ -25,6 +27,10 @@ import (
 const startReplicationPostWait = 250 * time.Millisecond
 const startReplicationMaxWait = 2 * time.Second
 
+// generatedInvisiblePrimaryKeyColumnName is the column MySQL 8.0.30+ adds as primary key to tables created
+// without one, when sql_generate_invisible_primary_key is ON
+const generatedInvisiblePrimaryKeyColumnName = "my_row_id"
+
 // Inspector reads data from the read-MySQL-server (typically a replica, but can be the master)
 // It is used for gaining initial status and structure, and later also follow up on progress and changelog
 type Inspector struct {
@@ -34,6 +40,10 @@ type Inspector struct {
 	informationSchemaDb *gosql.DB
 	migrationContext    *base.MigrationContext
 	name                string
+
+	// ghostDB reads the ghost table: it is db, or a connection to the target server on a
+	// cross-server migration (--target-host), where the ghost table is created
+	ghostDB *gosql.DB
 }
 
 func NewInspector(migrationContext *base.MigrationContext) *Inspector {
@@ -45,15 +55,14 @@ func NewInspector(migrationContext *base.MigrationContext) *Inspector {
 }
 
 func (this *Inspector) InitDBConnections() (err error) {
-	inspectorUri := this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
-	if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, inspectorUri); err != nil {
+	if this.db, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, this.name, this.connectionConfig, this.migrationContext.DatabaseName, ""); err != nil {
 		return err
 	}
 
-	informationSchemaUri := this.connectionConfig.GetDBUri("information_schema")
-	if this.informationSchemaDb, _, err = mysql.GetDB(this.migrationContext.Uuid, informationSchemaUri); err != nil {
+	if this.informationSchemaDb, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, this.name, this.connectionConfig, "information_schema", ""); err != nil {
 		return err
 	}
+	this.ghostDB = this.db
 
 	if err := this.validateConnection(); err != nil {
 		return err
@@ -80,6 +89,15 @@ func (this *Inspector) InitDBConnections() (err error) {
 	return nil
 }
 
+// InitTargetDBConnection connects to the target server of a cross-server migration (--target-host),
+// so as to inspect the ghost table there
+func (this *Inspector) InitTargetDBConnection() (err error) {
+	if this.ghostDB, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, this.name, this.migrationContext.TargetConnectionConfig, this.migrationContext.GetGhostDatabaseName(), ""); err != nil {
+		return err
+	}
+	return this.ghostDB.Ping()
+}
+
 func (this *Inspector) ValidateOriginalTable() (err error) {
 	if err := this.validateTable(); err != nil {
 		return err
@@ -97,21 +115,56 @@ func (this *Inspector) ValidateOriginalTable() (err error) {
 }
 
 func (this *Inspector) InspectTableColumnsAndUniqueKeys(tableName string) (columns *sql.ColumnList, virtualColumns *sql.ColumnList, uniqueKeys [](*sql.UniqueKey), err error) {
-	uniqueKeys, err = this.getCandidateUniqueKeys(tableName)
+	return this.inspectTableColumnsAndUniqueKeys(this.db, this.migrationContext.DatabaseName, tableName)
+}
+
+func (this *Inspector) inspectTableColumnsAndUniqueKeys(db *gosql.DB, databaseName, tableName string) (columns *sql.ColumnList, virtualColumns *sql.ColumnList, uniqueKeys [](*sql.UniqueKey), err error) {
+	uniqueKeys, err = this.getCandidateUniqueKeys(db, databaseName, tableName)
 	if err != nil {
 		return columns, virtualColumns, uniqueKeys, err
 	}
-	if len(uniqueKeys) == 0 {
-		return columns, virtualColumns, uniqueKeys, fmt.Errorf("No PRIMARY nor UNIQUE key found in table! Bailing out")
-	}
-	columns, virtualColumns, err = mysql.GetTableColumns(this.db, this.migrationContext.DatabaseName, tableName)
+	columns, virtualColumns, err = mysql.GetTableColumns(db, databaseName, tableName)
 	if err != nil {
 		return columns, virtualColumns, uniqueKeys, err
 	}
+	if len(uniqueKeys) == 0 {
+		if this.hasHiddenGeneratedInvisiblePrimaryKey(db, databaseName, tableName, columns) {
+			return columns, virtualColumns, uniqueKeys, fmt.Errorf("%s.%s has a generated invisible primary key (%s), hidden by show_gipk_in_create_table_and_information_schema=OFF. Set it to ON for gh-ost to migrate based on this key. Bailing out", sql.EscapeName(databaseName), sql.EscapeName(tableName), generatedInvisiblePrimaryKeyColumnName)
+		}
+		if !this.migrationContext.NoUniqueKeyAllowed {
+			return columns, virtualColumns, uniqueKeys, fmt.Errorf("No PRIMARY nor UNIQUE key found in table! Bailing out")
+		}
+		this.migrationContext.Log.Warningf("No PRIMARY nor UNIQUE key found in %s.%s. You have supplied with --allow-no-unique-key and so this migration proceeds", sql.EscapeName(databaseName), sql.EscapeName(tableName))
+	}
 
 	return columns, virtualColumns, uniqueKeys, nil
 }
 
+// hasHiddenGeneratedInvisiblePrimaryKey checks whether a table which seems to have no unique key actually
+// has a MySQL 8.0.30+ generated invisible primary key, which is hidden from information_schema and from
+// SHOW COLUMNS when show_gipk_in_create_table_and_information_schema is OFF.
+func (this *Inspector) hasHiddenGeneratedInvisiblePrimaryKey(db *gosql.DB, databaseName, tableName string, columns *sql.ColumnList) bool {
+	if mysql.IsMariaDB(this.dbVersion) {
+		// MariaDB does not generate invisible primary keys; a my_row_id column there is the user's
+		return false
+	}
+	if columns.GetColumn(generatedInvisiblePrimaryKeyColumnName) != nil {
+		return false
+	}
+	// An invisible column can still be selected explicitly. On servers or tables without one, this fails
+	query := fmt.Sprintf(`select /* gh-ost */ %s from %s.%s limit 0`,
+		sql.EscapeName(generatedInvisiblePrimaryKeyColumnName),
+		sql.EscapeName(databaseName),
+		sql.EscapeName(tableName),
+	)
+	rows, err := db.Query(query)
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
 func (this *Inspector) InspectOriginalTable() (err error) {
 	this.migrationContext.OriginalTableColumns, this.migrationContext.OriginalTableVirtualColumns, this.migrationContext.OriginalTableUniqueKeys, err = this.InspectTableColumnsAndUniqueKeys(this.migrationContext.OriginalTableName)
 	if err != nil {
@@ -121,6 +174,16 @@ func (this *Inspector) InspectOriginalTable() (err error) {
 	if err != nil {
 		return err
 	}
+	this.migrationContext.OriginalTablePartitions, _, err = this.getTablePartitions(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	if this.migrationContext.CopyPerPartition {
+		if len(this.migrationContext.OriginalTablePartitions) == 0 {
+			return fmt.Errorf("--copy-per-partition requested, but %s.%s is not partitioned", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		this.migrationContext.Log.Infof("Will copy rows per partition: %s", strings.Join(this.migrationContext.OriginalTablePartitions, ", "))
+	}
 	return nil
 }
 
@@ -133,13 +196,13 @@ func (this *Inspector) inspectOriginalAndGhostTables() (err error) {
 		return fmt.Errorf("It seems like table structure is not identical between master and replica. This scenario is not supported.")
 	}
 
-	this.migrationContext.GhostTableColumns, this.migrationContext.GhostTableVirtualColumns, this.migrationContext.GhostTableUniqueKeys, err = this.InspectTableColumnsAndUniqueKeys(this.migrationContext.GetGhostTableName())
+	this.migrationContext.GhostTableColumns, this.migrationContext.GhostTableVirtualColumns, this.migrationContext.GhostTableUniqueKeys, err = this.inspectTableColumnsAndUniqueKeys(this.ghostDB, this.migrationContext.GetGhostDatabaseName(), this.migrationContext.GetGhostTableName())
 	if err != nil {
 		return err
 	}
 	sharedUniqueKeys := this.getSharedUniqueKeys(this.migrationContext.OriginalTableUniqueKeys, this.migrationContext.GhostTableUniqueKeys)
 	for i, sharedUniqueKey := range sharedUniqueKeys {
-		this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &sharedUniqueKey.Columns)
+		this.applyColumnTypes(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &sharedUniqueKey.Columns)
 		uniqueKeyIsValid := true
 		for _, column := range sharedUniqueKey.Columns.Columns() {
 			switch column.Type {
@@ -163,31 +226,102 @@ func (this *Inspector) inspectOriginalAndGhostTables() (err error) {
 		}
 	}
 	if this.migrationContext.UniqueKey == nil {
-		return fmt.Errorf("No shared unique key can be found after ALTER! Bailing out")
-	}
-	this.migrationContext.Log.Infof("Chosen shared unique key is %s", this.migrationContext.UniqueKey.Name)
-	if this.migrationContext.UniqueKey.HasNullable {
-		if this.migrationContext.NullableUniqueKeyAllowed {
-			this.migrationContext.Log.Warningf("Chosen key (%s) has nullable columns. You have supplied with --allow-nullable-unique-key and so this migration proceeds. As long as there aren't NULL values in this key's column, migration should be fine. NULL values will corrupt migration's data", this.migrationContext.UniqueKey)
-		} else {
-			return fmt.Errorf("Chosen key (%s) has nullable columns. Bailing out. To force this operation to continue, supply --allow-nullable-unique-key flag. Only do so if you are certain there are no actual NULL values in this key. As long as there aren't, migration should be fine. NULL values in columns of this key will corrupt migration's data", this.migrationContext.UniqueKey)
+		if !this.migrationContext.NoUniqueKeyAllowed {
+			return fmt.Errorf("No shared unique key can be found after ALTER! Bailing out")
 		}
+		this.migrationContext.UniqueKey = this.getGhostOnlyUniqueKey()
 	}
 
 	this.migrationContext.SharedColumns, this.migrationContext.MappedSharedColumns = this.getSharedColumns(this.migrationContext.OriginalTableColumns, this.migrationContext.GhostTableColumns, this.migrationContext.OriginalTableVirtualColumns, this.migrationContext.GhostTableVirtualColumns, this.migrationContext.ColumnRenameMap)
 	this.migrationContext.Log.Infof("Shared columns are %s", this.migrationContext.SharedColumns)
-	// By fact that a non-empty unique key exists we also know the shared columns are non-empty
+	if this.migrationContext.SharedColumns.Len() == 0 {
+		return fmt.Errorf("No shared columns found between original and ghost tables. Bailing out")
+	}
 
 	// This additional step looks at which columns are unsigned. We could have merged this within
 	// the `getTableColumns()` function, but it's a later patch and introduces some complexity; I feel
 	// comfortable in doing this as a separate step.
-	this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns, &this.migrationContext.UniqueKey.Columns)
-	this.applyColumnTypes(this.migrationContext.DatabaseName, this.migrationContext.GetGhostTableName(), this.migrationContext.GhostTableColumns, this.migrationContext.MappedSharedColumns)
+	this.applyColumnTypes(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, this.migrationContext.OriginalTableColumns, this.migrationContext.SharedColumns)
+	this.applyColumnTypes(this.ghostDB, this.migrationContext.GetGhostDatabaseName(), this.migrationContext.GetGhostTableName(), this.migrationContext.GhostTableColumns, this.migrationContext.MappedSharedColumns)
+
+	if this.migrationContext.UniqueKey == nil {
+		if this.migrationContext.UniqueKey, this.migrationContext.FullRowMatchColumns, err = this.getFullRowUniqueKey(); err != nil {
+			return err
+		}
+	}
+	if this.migrationContext.UniqueKey.IsFullRow && this.migrationContext.IsCrossServerMigration() {
+		return fmt.Errorf("No unique key can be used for this migration, and rows cannot be matched by all of their columns on a cross-server migration (--target-host). Bailing out")
+	}
+	if this.migrationContext.UniqueKey.IsFullRow {
+		if err := this.validateFullRowDuplicates(); err != nil {
+			return err
+		}
+	}
+	this.applyColumnTypes(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &this.migrationContext.UniqueKey.Columns)
+	if err := this.validateBinlogRowImageUniqueKey(); err != nil {
+		return err
+	}
+	if err := this.validateBinlogRowImageDefaults(); err != nil {
+		return err
+	}
+	if err := this.validateFullBinlogRowImage(); err != nil {
+		return err
+	}
+	if err := this.validateColumnTransformations(); err != nil {
+		return err
+	}
+	if err := this.validateRowFilter(); err != nil {
+		return err
+	}
+	if err := this.validateArchive(); err != nil {
+		return err
+	}
+	if err := this.validateSideTable(); err != nil {
+		return err
+	}
+	if err := this.validateShards(); err != nil {
+		return err
+	}
+
+	switch {
+	case this.migrationContext.UniqueKey.IsFullRow:
+		this.migrationContext.Log.Warningf("No unique key can be used for this migration. Rows will be copied in order of their NOT NULL columns %s, and matched by all of columns %s. This is very expensive: each chunk copied and each binlog event applied looks up the ghost table for an identical row, which, lacking a matching index, scans the ghost table. Identical duplicate rows may not be migrated accurately while being written to",
+			this.migrationContext.UniqueKey.Columns.Names(), this.migrationContext.FullRowMatchColumns.Names())
+	case this.migrationContext.UniqueKey.IsGhostOnly:
+		this.migrationContext.Log.Warningf("No shared unique key found. Will use %s, which only exists on the ghost table. Its uniqueness is not enforced on the original table: rows duplicating its values are silently dropped. Lacking an index on the original table, each chunk copied scans the original table", this.migrationContext.UniqueKey)
+	default:
+		this.migrationContext.Log.Infof("Chosen shared unique key is %s", this.migrationContext.UniqueKey.Name)
+		if this.migrationContext.UniqueKey.IsPrimary() && this.migrationContext.UniqueKey.Columns.Len() == 1 && this.migrationContext.UniqueKey.Columns.Names()[0] == generatedInvisiblePrimaryKeyColumnName {
+			this.migrationContext.Log.Infof("Chosen key is a generated invisible primary key")
+		}
+	}
+	if this.migrationContext.UniqueKey.HasNullable {
+		if this.migrationContext.NullableUniqueKeyAllowed {
+			this.migrationContext.Log.Warningf("Chosen key (%s) has nullable columns. You have supplied with --allow-nullable-unique-key and so this migration proceeds. As long as there aren't NULL values in this key's column, migration should be fine. NULL values will corrupt migration's data", this.migrationContext.UniqueKey)
+		} else {
+			return fmt.Errorf("Chosen key (%s) has nullable columns. Bailing out. To force this operation to continue, supply --allow-nullable-unique-key flag. Only do so if you are certain there are no actual NULL values in this key. As long as there aren't, migration should be fine. NULL values in columns of this key will corrupt migration's data", this.migrationContext.UniqueKey)
+		}
+	}
+
+	if !this.migrationContext.UniqueKey.IsFullRow {
+		if err := this.validateUniqueKeyPartitioning(); err != nil {
+			return err
+		}
+	}
+	if err := this.validateParentSideForeignKeysReferencedColumns(); err != nil {
+		return err
+	}
+	if err := this.validateChildSideForeignKeysColumns(); err != nil {
+		return err
+	}
 
 	for i := range this.migrationContext.SharedColumns.Columns() {
 		column := this.migrationContext.SharedColumns.Columns()[i]
 		mappedColumn := this.migrationContext.MappedSharedColumns.Columns()[i]
 		if column.Name == mappedColumn.Name && column.Type == sql.DateTimeColumnType && mappedColumn.Type == sql.TimestampColumnType {
+			if this.migrationContext.IsCrossServerMigration() {
+				return fmt.Errorf("Converting column %s from DATETIME to TIMESTAMP is not supported on a cross-server migration (--target-host). Bailing out", sql.EscapeName(column.Name))
+			}
 			this.migrationContext.MappedSharedColumns.SetConvertDatetimeToTimestamp(column.Name, this.migrationContext.ApplierTimeZone)
 		}
 		if column.Name == mappedColumn.Name && column.Type == sql.EnumColumnType && mappedColumn.Charset != "" {
@@ -212,6 +346,71 @@ func (this *Inspector) inspectOriginalAndGhostTables() (err error) {
 	return nil
 }
 
+// validateUniqueKeyPartitioning validates that the chosen unique key includes all of the ghost table's
+// partitioning columns, as MySQL requires of unique keys on a partitioned table: otherwise rows are not
+// located by the chosen key within a single partition, and the key is not enforced on the ghost table.
+func (this *Inspector) validateUniqueKeyPartitioning() (err error) {
+	var partitionColumns []string
+	this.migrationContext.GhostTablePartitions, partitionColumns, err = this.getTablePartitions(this.ghostDB, this.migrationContext.GetGhostDatabaseName(), this.migrationContext.GetGhostTableName())
+	if err != nil {
+		return err
+	}
+	if len(this.migrationContext.GhostTablePartitions) == 0 {
+		return nil
+	}
+	this.migrationContext.Log.Infof("Ghost table is partitioned on %s; partitions: %s", strings.Join(partitionColumns, ", "), strings.Join(this.migrationContext.GhostTablePartitions, ", "))
+
+	// The chosen key's columns, by their names on the ghost table
+	uniqueKeyColumnNames := []string{}
+	for _, column := range this.migrationContext.UniqueKey.Columns.Names() {
+		if mappedColumn, ok := this.migrationContext.ColumnRenameMap[column]; ok {
+			column = mappedColumn
+		}
+		uniqueKeyColumnNames = append(uniqueKeyColumnNames, column)
+	}
+	if sql.NewColumnList(partitionColumns).IsSubsetOf(sql.NewColumnList(uniqueKeyColumnNames)) {
+		return nil
+	}
+	return fmt.Errorf("Chosen key (%s) does not include all of the ghost table's partitioning columns (%s). Bailing out", this.migrationContext.UniqueKey, strings.Join(partitionColumns, ", "))
+}
+
+// getTablePartitions returns the names of a table's partitions, in ordinal order, and the columns
+// referenced by its partitioning and subpartitioning expressions. A non-partitioned table has no partitions.
+func (this *Inspector) getTablePartitions(db *gosql.DB, databaseName, tableName string) (partitionNames []string, partitionColumns []string, err error) {
+	query := `
+		SELECT /* gh-ost */
+			PARTITION_NAME,
+			MIN(PARTITION_EXPRESSION) AS PARTITION_EXPRESSION,
+			MIN(SUBPARTITION_EXPRESSION) AS SUBPARTITION_EXPRESSION
+		FROM
+			INFORMATION_SCHEMA.PARTITIONS
+		WHERE
+			TABLE_SCHEMA = ?
+			AND TABLE_NAME = ?
+			AND PARTITION_NAME IS NOT NULL
+		GROUP BY
+			PARTITION_NAME
+		ORDER BY
+			MIN(PARTITION_ORDINAL_POSITION)`
+	var partitionExpressions []string
+	err = sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
+		partitionNames = append(partitionNames, m.GetString("PARTITION_NAME"))
+		if len(partitionExpressions) == 0 {
+			partitionExpressions = append(partitionExpressions, m.GetString("PARTITION_EXPRESSION"), m.GetString("SUBPARTITION_EXPRESSION"))
+		}
+		return nil
+	}, databaseName, tableName)
+	if err != nil || len(partitionNames) == 0 {
+		return partitionNames, partitionColumns, err
+	}
+	columns, _, err := mysql.GetTableColumns(db, databaseName, tableName)
+	if err != nil {
+		return partitionNames, partitionColumns, err
+	}
+	partitionColumns = sql.ParsePartitionExpressionColumns(strings.Join(partitionExpressions, ","), columns)
+	return partitionNames, partitionColumns, nil
+}
+
 // validateConnection issues a simple can-connect to MySQL
 func (this *Inspector) validateConnection() error {
 	if len(this.connectionConfig.Password) > mysql.MaxReplicationPasswordLength {
@@ -242,10 +441,11 @@ func (this *Inspector) validateGrants() error {
 			if strings.Contains(grant, `SUPER`) && strings.Contains(grant, ` ON *.*`) {
 				foundSuper = true
 			}
-			if strings.Contains(grant, `REPLICATION CLIENT`) && strings.Contains(grant, ` ON *.*`) {
+			// MariaDB 10.5.2+ has BINLOG MONITOR in place of REPLICATION CLIENT, and REPLICATION REPLICA as an alias of REPLICATION SLAVE
+			if (strings.Contains(grant, `REPLICATION CLIENT`) || strings.Contains(grant, `BINLOG MONITOR`)) && strings.Contains(grant, ` ON *.*`) {
 				foundReplicationClient = true
 			}
-			if strings.Contains(grant, `REPLICATION SLAVE`) && strings.Contains(grant, ` ON *.*`) {
+			if (strings.Contains(grant, `REPLICATION SLAVE`) || strings.Contains(grant, `REPLICATION REPLICA`)) && strings.Contains(grant, ` ON *.*`) {
 				foundReplicationSlave = true
 			}
 			if strings.Contains(grant, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.*", this.migrationContext.DatabaseName)) {
@@ -414,14 +614,80 @@ func (this *Inspector) validateBinlogs() error {
 		return err
 	}
 	this.migrationContext.OriginalBinlogRowImage = strings.ToUpper(this.migrationContext.OriginalBinlogRowImage)
-	if this.migrationContext.OriginalBinlogRowImage != "FULL" {
-		return fmt.Errorf("%s has '%s' binlog_row_image, and only 'FULL' is supported. This operation cannot proceed. You may `set global binlog_row_image='full'` and try again", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	switch this.migrationContext.OriginalBinlogRowImage {
+	case "FULL":
+	case "MINIMAL", "NOBLOB":
+		this.migrationContext.Log.Infof("%s has '%s' binlog_row_image. Partial row images will be applied by the columns they hold", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	default:
+		return fmt.Errorf("%s has '%s' binlog_row_image, and only 'FULL', 'MINIMAL' and 'NOBLOB' are supported. This operation cannot proceed. You may `set global binlog_row_image='full'` and try again", this.connectionConfig.Key.String(), this.migrationContext.OriginalBinlogRowImage)
+	}
+
+	if mysql.IsMariaDB(this.dbVersion) {
+		if err := mysql.ValidateMariaDBBinlogCompression(this.db, this.connectionConfig.Key.String()); err != nil {
+			return err
+		}
+	} else {
+		// binlog_transaction_compression only exists as of MySQL 8.0.20, hence errors are ignored
+		var hasTransactionCompression bool
+		query = `select /* gh-ost */ @@global.binlog_transaction_compression`
+		if err := this.db.QueryRow(query).Scan(&hasTransactionCompression); err == nil && hasTransactionCompression {
+			this.migrationContext.Log.Infof("%s has binlog_transaction_compression enabled. Transaction payload events will be decompressed", this.connectionConfig.Key.String())
+		}
 	}
 
 	this.migrationContext.Log.Infof("binary logs validated on %s", this.connectionConfig.Key.String())
 	return nil
 }
 
+// validateBinlogRowImageUniqueKey checks that, with binlog_row_image=MINIMAL, the binlog events' before
+// images hold the migration key: these only hold the original table's primary key, and rows cannot be
+// matched by all of their columns off a partial row image.
+func (this *Inspector) validateBinlogRowImageUniqueKey() error {
+	if this.migrationContext.OriginalBinlogRowImage != "MINIMAL" {
+		return nil
+	}
+	if !this.migrationContext.UniqueKey.IsPrimary() || !this.migrationContext.UniqueKey.HasOriginalIndex() {
+		return fmt.Errorf("binlog_row_image=MINIMAL requires the migration key to be the PRIMARY KEY, but it is %s. You may `set global binlog_row_image='full'` and try again", this.migrationContext.UniqueKey)
+	}
+	return nil
+}
+
+// validateBinlogRowImageDefaults checks that, with binlog_row_image=MINIMAL, inserted rows can be applied
+// onto the ghost table: their row images lack the columns the INSERT left to their default value, which
+// the ghost table must then reproduce. Any shared column whose default differs between the original and
+// ghost tables, or is not constant (e.g. CURRENT_TIMESTAMP), would silently diverge.
+func (this *Inspector) validateBinlogRowImageDefaults() error {
+	if this.migrationContext.OriginalBinlogRowImage != "MINIMAL" {
+		return nil
+	}
+	mappedColumns := this.migrationContext.MappedSharedColumns.Columns()
+	columnNames := []string{}
+	for i, column := range this.migrationContext.SharedColumns.Columns() {
+		if this.migrationContext.ColumnTransformations.Get(mappedColumns[i].Name) != nil {
+			continue
+		}
+		if !column.HasSameConstantDefault(&mappedColumns[i]) {
+			columnNames = append(columnNames, sql.EscapeName(column.Name))
+		}
+	}
+	if len(columnNames) > 0 {
+		return fmt.Errorf("binlog_row_image=MINIMAL omits defaulted columns of inserted rows, but the default value of %s differs between the original and ghost tables, or is not constant. You may `set global binlog_row_image='full'` and try again", strings.Join(columnNames, ", "))
+	}
+	return nil
+}
+
+// validateFullBinlogRowImage checks that binlog_row_image is FULL when the flags in use rely on complete
+// row images. binlog_row_image may still be set per session, which the applier checks for on each event.
+func (this *Inspector) validateFullBinlogRowImage() error {
+	if this.migrationContext.OriginalBinlogRowImage == "" || this.migrationContext.OriginalBinlogRowImage == "FULL" {
+		return nil
+	}
+	if flags := this.migrationContext.GetFullRowImageFlags(); len(flags) > 0 {
+		return fmt.Errorf("%s require binlog_row_image=FULL, as they rely on complete row images, but it is %s. You may `set global binlog_row_image='full'` and try again", strings.Join(flags, ", "), this.migrationContext.OriginalBinlogRowImage)
+	}
+	return nil
+}
+
 // validateLogSlaveUpdates checks that binary log log_slave_updates is set. This test is not required when migrating on replica or when migrating directly on master
 func (this *Inspector) validateLogSlaveUpdates() error {
 	query := `select /* gh-ost */ @@global.log_slave_updates`
@@ -517,10 +783,21 @@ func (this *Inspector) validateTableForeignKeys(allowChildForeignKeys bool) erro
 	if err != nil {
 		return err
 	}
+	if this.migrationContext.IsCrossServerMigration() && numParentForeignKeys+numChildForeignKeys > 0 {
+		return this.migrationContext.Log.Errorf("Found %d foreign keys on %s.%s. Foreign keys are not supported on a cross-server migration (--target-host). Bailing out", numParentForeignKeys+numChildForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
 	if numParentForeignKeys > 0 {
-		return this.migrationContext.Log.Errorf("Found %d parent-side foreign keys on %s.%s. Parent-side foreign keys are not supported. Bailing out", numParentForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		if !this.migrationContext.RebindParentForeignKeys {
+			return this.migrationContext.Log.Errorf("Found %d parent-side foreign keys on %s.%s. Parent-side foreign keys are not supported. Bailing out", numParentForeignKeys, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		if err := this.readParentSideForeignKeys(); err != nil {
+			return err
+		}
 	}
 	if numChildForeignKeys > 0 {
+		if this.migrationContext.PreserveForeignKeys {
+			return this.readChildSideForeignKeys()
+		}
 		if allowChildForeignKeys {
 			this.migrationContext.Log.Debugf("Foreign keys found and will be dropped, as per given --discard-foreign-keys flag")
 			return nil
@@ -531,6 +808,112 @@ func (this *Inspector) validateTableForeignKeys(allowChildForeignKeys bool) erro
 	return nil
 }
 
+// readParentSideForeignKeys reads the foreign keys referencing the migrated table, to be rebound
+// onto the migrated table at cut-over, as per --rebind-parent-foreign-keys
+func (this *Inspector) readParentSideForeignKeys() (err error) {
+	this.migrationContext.ParentSideForeignKeys, err = mysql.GetParentSideForeignKeys(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	for _, foreignKey := range this.migrationContext.ParentSideForeignKeys {
+		if foreignKey.TableSchema == this.migrationContext.DatabaseName && foreignKey.TableName == this.migrationContext.OriginalTableName {
+			return this.migrationContext.Log.Errorf("Found self-referencing foreign key %s on %s.%s. Self-referencing foreign keys are not supported. Bailing out", sql.EscapeName(foreignKey.Name), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		reboundName := this.migrationContext.GetReboundForeignKeyName(foreignKey.Name)
+		if utf8.RuneCountInString(reboundName) > mysql.MaxTableNameLength {
+			return this.migrationContext.Log.Errorf("Rebound foreign key name %s is longer than %d characters. Bailing out", reboundName, mysql.MaxTableNameLength)
+		}
+		this.migrationContext.Log.Infof("Found parent-side foreign key %s on %s.%s (%s); will rebind at cut-over as %s",
+			sql.EscapeName(foreignKey.Name), sql.EscapeName(foreignKey.TableSchema), sql.EscapeName(foreignKey.TableName), strings.Join(foreignKey.Columns, ", "), sql.EscapeName(reboundName),
+		)
+	}
+	return nil
+}
+
+// readChildSideForeignKeys reads the foreign keys of the migrated table, to be re-created on
+// the ghost table before cut-over, as per --preserve-foreign-keys
+func (this *Inspector) readChildSideForeignKeys() (err error) {
+	this.migrationContext.ChildSideForeignKeys, err = mysql.GetChildSideForeignKeys(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName)
+	if err != nil {
+		return err
+	}
+	var ghostForeignKeyNames []interface{}
+	for _, foreignKey := range this.migrationContext.ChildSideForeignKeys {
+		for _, rule := range []string{foreignKey.DeleteRule, foreignKey.UpdateRule} {
+			switch strings.ToUpper(rule) {
+			case "CASCADE", "SET NULL", "SET DEFAULT":
+				// Changes made by cascading actions are not written as row events to the binary log
+				return this.migrationContext.Log.Errorf("Foreign key %s on %s.%s has a %s referential action. Cascading actions are not visible in the binary log and are not supported. Bailing out", sql.EscapeName(foreignKey.Name), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), rule)
+			}
+		}
+		ghostForeignKeyName := this.migrationContext.GetGhostForeignKeyName(foreignKey.Name)
+		if utf8.RuneCountInString(ghostForeignKeyName) > mysql.MaxTableNameLength {
+			return this.migrationContext.Log.Errorf("Ghost foreign key name %s is longer than %d characters. Bailing out", ghostForeignKeyName, mysql.MaxTableNameLength)
+		}
+		ghostForeignKeyNames = append(ghostForeignKeyNames, ghostForeignKeyName)
+		this.migrationContext.Log.Infof("Found child-side foreign key %s referencing %s.%s; will be created on the ghost table as %s",
+			sql.EscapeName(foreignKey.Name), sql.EscapeName(foreignKey.ReferencedTableSchema), sql.EscapeName(foreignKey.ReferencedTableName), sql.EscapeName(ghostForeignKeyName),
+		)
+	}
+
+	// Foreign key names are unique per schema
+	query := fmt.Sprintf(`
+		SELECT /* gh-ost */
+			CONSTRAINT_NAME
+		FROM
+			INFORMATION_SCHEMA.TABLE_CONSTRAINTS
+		WHERE
+			CONSTRAINT_SCHEMA = ?
+			AND CONSTRAINT_TYPE = 'FOREIGN KEY'
+			AND CONSTRAINT_NAME IN (%s)`,
+		strings.TrimSuffix(strings.Repeat("?,", len(ghostForeignKeyNames)), ","),
+	)
+	var foundForeignKeys []string
+	err = sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
+		foundForeignKeys = append(foundForeignKeys, rowMap.GetString("CONSTRAINT_NAME"))
+		return nil
+	}, append([]interface{}{this.migrationContext.DatabaseName}, ghostForeignKeyNames...)...)
+	if err != nil {
+		return err
+	}
+	if len(foundForeignKeys) > 0 {
+		return this.migrationContext.Log.Errorf("Found gh-ost foreign keys (%s). Please use a different suffix or drop them. Bailing out", strings.Join(foundForeignKeys, ","))
+	}
+	return nil
+}
+
+// validateChildSideForeignKeysColumns makes sure the columns of preserved child-side foreign keys
+// still exist on the ghost table
+func (this *Inspector) validateChildSideForeignKeysColumns() error {
+	for _, foreignKey := range this.migrationContext.ChildSideForeignKeys {
+		for _, column := range foreignKey.Columns {
+			if mappedColumn, ok := this.migrationContext.ColumnRenameMap[column]; ok {
+				column = mappedColumn
+			}
+			if this.migrationContext.GhostTableColumns.GetColumn(column) == nil {
+				return fmt.Errorf("Foreign key %s is on column %s, which does not exist after ALTER. Bailing out", sql.EscapeName(foreignKey.Name), sql.EscapeName(column))
+			}
+		}
+	}
+	return nil
+}
+
+// validateParentSideForeignKeysReferencedColumns makes sure the columns referenced by parent-side
+// foreign keys still exist on the ghost table
+func (this *Inspector) validateParentSideForeignKeysReferencedColumns() error {
+	for _, foreignKey := range this.migrationContext.ParentSideForeignKeys {
+		for _, column := range foreignKey.ReferencedColumns {
+			if mappedColumn, ok := this.migrationContext.ColumnRenameMap[column]; ok {
+				column = mappedColumn
+			}
+			if this.migrationContext.GhostTableColumns.GetColumn(column) == nil {
+				return fmt.Errorf("Foreign key %s on %s.%s references column %s, which does not exist after ALTER. Bailing out", sql.EscapeName(foreignKey.Name), sql.EscapeName(foreignKey.TableSchema), sql.EscapeName(foreignKey.TableName), sql.EscapeName(column))
+			}
+		}
+	}
+	return nil
+}
+
 // validateTableTriggers makes sure no triggers exist on the migrated table. if --include_triggers is used then it fetches the triggers
 func (this *Inspector) validateTableTriggers() error {
 	query := `
@@ -682,7 +1065,7 @@ func (this *Inspector) CountTableRows(ctx context.Context) error {
 }
 
 // applyColumnTypes
-func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsLists ...*sql.ColumnList) error {
+func (this *Inspector) applyColumnTypes(db *gosql.DB, databaseName, tableName string, columnsLists ...*sql.ColumnList) error {
 	query := `
 		select /* gh-ost */ *
 		from
@@ -690,7 +1073,7 @@ func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsL
 		where
 			table_schema=?
 			and table_name=?`
-	err := sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+	err := sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
 		columnName := m.GetString("COLUMN_NAME")
 		columnType := m.GetString("COLUMN_TYPE")
 		columnOctetLength := m.GetUint("CHARACTER_OCTET_LENGTH")
@@ -701,6 +1084,8 @@ func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsL
 				continue
 			}
 
+			column.DataType = strings.ToLower(m.GetString("DATA_TYPE"))
+			column.DeclaredType = strings.ToLower(columnType)
 			if strings.Contains(columnType, "unsigned") {
 				column.IsUnsigned = true
 			}
@@ -730,15 +1115,36 @@ func (this *Inspector) applyColumnTypes(databaseName, tableName string, columnsL
 			if strings.Contains(extra, " GENERATED") {
 				column.IsVirtual = true
 			}
+			if m.GetString("IS_NULLABLE") == "YES" {
+				column.IsNullable = true
+			}
 			if charset := m.GetString("CHARACTER_SET_NAME"); charset != "" {
 				column.Charset = charset
 			}
+			column.HasDefault = m["COLUMN_DEFAULT"].Valid
+			column.Default = m.GetString("COLUMN_DEFAULT")
+			column.IsDefaultGenerated = isGeneratedColumnDefault(column.Default, extra)
 		}
 		return nil
 	}, databaseName, tableName)
 	return err
 }
 
+// isGeneratedColumnDefault tells whether a column default, as listed by information_schema, is an expression
+// evaluated per row. MySQL 8.0 flags these as DEFAULT_GENERATED; earlier versions only allow CURRENT_TIMESTAMP.
+func isGeneratedColumnDefault(columnDefault, extra string) bool {
+	if strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED") {
+		return true
+	}
+	columnDefault = strings.ToUpper(columnDefault)
+	for _, prefix := range []string{"CURRENT_TIMESTAMP", "NOW(", "LOCALTIME"} {
+		if strings.HasPrefix(columnDefault, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // getAutoIncrementValue get's the original table's AUTO_INCREMENT value, if exists (0 value if not exists)
 func (this *Inspector) getAutoIncrementValue(tableName string) (autoIncrement uint64, err error) {
 	query := `
@@ -758,7 +1164,7 @@ func (this *Inspector) getAutoIncrementValue(tableName string) (autoIncrement ui
 
 // getCandidateUniqueKeys investigates a table and returns the list of unique keys
 // candidate for chunking
-func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*sql.UniqueKey), err error) {
+func (this *Inspector) getCandidateUniqueKeys(db *gosql.DB, databaseName, tableName string) (uniqueKeys [](*sql.UniqueKey), err error) {
 	query := `
 		SELECT /* gh-ost */
 			COLUMNS.TABLE_SCHEMA,
@@ -821,7 +1227,7 @@ func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*
 				ELSE 100
 			END,
 			COUNT_COLUMN_IN_INDEX`
-	err = sqlutils.QueryRowsMap(this.db, query, func(m sqlutils.RowMap) error {
+	err = sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
 		uniqueKey := &sql.UniqueKey{
 			Name:            m.GetString("INDEX_NAME"),
 			Columns:         *sql.ParseColumnList(m.GetString("COLUMN_NAMES")),
@@ -830,7 +1236,7 @@ func (this *Inspector) getCandidateUniqueKeys(tableName string) (uniqueKeys [](*
 		}
 		uniqueKeys = append(uniqueKeys, uniqueKey)
 		return nil
-	}, this.migrationContext.DatabaseName, tableName, this.migrationContext.DatabaseName, tableName)
+	}, databaseName, tableName, databaseName, tableName)
 	if err != nil {
 		return uniqueKeys, err
 	}
@@ -854,6 +1260,410 @@ func (this *Inspector) getSharedUniqueKeys(originalUniqueKeys, ghostUniqueKeys [
 	return uniqueKeys
 }
 
+// getGhostOnlyUniqueKey returns the first unique key of the ghost table whose columns all exist,
+// under the same names, on the original table, or nil if there is no such key. The original table
+// has no unique key on these columns, and row copy iterates them without the support of an index.
+func (this *Inspector) getGhostOnlyUniqueKey() *sql.UniqueKey {
+	renamedColumns := map[string]bool{}
+	for _, renamedColumn := range this.migrationContext.ColumnRenameMap {
+		renamedColumns[strings.ToLower(renamedColumn)] = true
+	}
+	isOriginalColumn := func(column string) bool {
+		if this.migrationContext.OriginalTableColumns.GetColumn(column) == nil {
+			return false
+		}
+		if this.migrationContext.OriginalTableVirtualColumns.GetColumn(column) != nil || this.migrationContext.GhostTableVirtualColumns.GetColumn(column) != nil {
+			return false
+		}
+		for droppedColumn := range this.migrationContext.DroppedColumnsMap {
+			if strings.EqualFold(column, droppedColumn) {
+				return false
+			}
+		}
+		return !renamedColumns[strings.ToLower(column)]
+	}
+	for _, ghostUniqueKey := range this.migrationContext.GhostTableUniqueKeys {
+		isCandidate := true
+		for _, column := range ghostUniqueKey.Columns.Names() {
+			isCandidate = isCandidate && isOriginalColumn(column)
+		}
+		if !isCandidate {
+			continue
+		}
+		uniqueKey := &sql.UniqueKey{
+			Name:            ghostUniqueKey.Name,
+			Columns:         *sql.NewColumnList(ghostUniqueKey.Columns.Names()),
+			HasNullable:     ghostUniqueKey.HasNullable,
+			IsAutoIncrement: ghostUniqueKey.IsAutoIncrement,
+			IsGhostOnly:     true,
+		}
+		this.applyColumnTypes(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, &uniqueKey.Columns)
+		for _, column := range uniqueKey.Columns.Columns() {
+			if column.Type == sql.FloatColumnType || column.Type == sql.JSONColumnType {
+				isCandidate = false
+			}
+		}
+		if isCandidate {
+			return uniqueKey
+		}
+	}
+	return nil
+}
+
+// getFullRowUniqueKey sets up a migration of a table which has no usable unique key. Rows are matched
+// by all shared columns which hold the same values, under the same names, on both tables, and compare
+// reliably. Of those, the NOT NULL columns make the (non-unique) key rows are iterated by on row copy.
+func (this *Inspector) getFullRowUniqueKey() (uniqueKey *sql.UniqueKey, matchColumns *sql.ColumnList, err error) {
+	matchColumnNames := []string{}
+	iterationColumnNames := []string{}
+	for i, column := range this.migrationContext.SharedColumns.Columns() {
+		mappedColumn := this.migrationContext.MappedSharedColumns.Columns()[i]
+		if column.Name != mappedColumn.Name || column.Type != mappedColumn.Type || column.Charset != mappedColumn.Charset {
+			continue
+		}
+		if this.migrationContext.ColumnTransformations.Get(mappedColumn.Name) != nil {
+			continue
+		}
+		if column.Type == sql.FloatColumnType || column.Type == sql.JSONColumnType {
+			continue
+		}
+		matchColumnNames = append(matchColumnNames, column.Name)
+		if !column.IsNullable {
+			iterationColumnNames = append(iterationColumnNames, column.Name)
+		}
+	}
+	if len(matchColumnNames) == 0 {
+		return nil, nil, fmt.Errorf("No unique key found, and no shared column can be used to match rows by. Bailing out")
+	}
+	if len(iterationColumnNames) == 0 {
+		return nil, nil, fmt.Errorf("No unique key found, and no NOT NULL shared column can be used to iterate rows by. Bailing out")
+	}
+	if this.migrationContext.CopyPerPartition {
+		return nil, nil, fmt.Errorf("--copy-per-partition is not supported when no unique key can be used. Bailing out")
+	}
+	matchColumns = sql.NewColumnList(matchColumnNames)
+	this.applyColumnTypes(this.db, this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, matchColumns)
+	uniqueKey = &sql.UniqueKey{
+		Columns:   *sql.NewColumnList(iterationColumnNames),
+		IsFullRow: true,
+	}
+	return uniqueKey, matchColumns, nil
+}
+
+// validateFullRowDuplicates checks that, when rows are matched by all of their columns, the original table
+// holds no identical duplicate rows. Row copy copies duplicates as they are, but binlog events inserting a row
+// identical to one the ghost table has are not applied: writes to duplicates may leave fewer of them on the
+// ghost table. --allow-duplicate-rows accepts such loss. Finding duplicates scans the original table.
+func (this *Inspector) validateFullRowDuplicates() error {
+	query, err := sql.BuildDuplicateRowsExistQuery(this.migrationContext.DatabaseName, this.migrationContext.OriginalTableName, this.migrationContext.FullRowMatchColumns.Names())
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Looking for identical duplicate rows on %s.%s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	var hasDuplicates bool
+	if err := this.db.QueryRow(query).Scan(&hasDuplicates); err != nil {
+		return err
+	}
+	if !hasDuplicates {
+		return nil
+	}
+	if this.migrationContext.DuplicateRowsAllowed {
+		this.migrationContext.Log.Warningf("%s.%s holds identical duplicate rows by columns %s. You have supplied --allow-duplicate-rows: row copy copies them as they are, but inserts of rows identical to ones the ghost table has are not applied, so writes to duplicates may leave fewer of them on the migrated table", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), this.migrationContext.FullRowMatchColumns.Names())
+		return nil
+	}
+	return fmt.Errorf("%s.%s holds identical duplicate rows by columns %s, which rows are matched by: inserts of rows identical to ones the ghost table has are not applied, so writes to duplicates may leave fewer of them on the migrated table. Bailing out. To migrate anyway, supply --allow-duplicate-rows", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), this.migrationContext.FullRowMatchColumns.Names())
+}
+
+// validateColumnTransformations validates that transformed columns are non-generated ghost table columns,
+// not part of the chosen unique key, and that their expressions reference nothing but original table columns.
+// The latter is validated by evaluating each expression over a derived table of the referenced columns, as
+// is done when applying binlog events.
+func (this *Inspector) validateColumnTransformations() error {
+	for _, transformation := range this.migrationContext.ColumnTransformations {
+		var ghostColumn *sql.Column
+		for _, columnName := range this.migrationContext.GhostTableColumns.Names() {
+			if strings.EqualFold(columnName, transformation.Column) {
+				ghostColumn = this.migrationContext.GhostTableColumns.GetColumn(columnName)
+			}
+		}
+		if ghostColumn == nil {
+			return fmt.Errorf("Transformed column %s does not exist on the ghost table", sql.EscapeName(transformation.Column))
+		}
+		transformation.Column = ghostColumn.Name
+		if this.migrationContext.GhostTableVirtualColumns.GetColumn(ghostColumn.Name) != nil {
+			return fmt.Errorf("Transformed column %s is a generated column", sql.EscapeName(transformation.Column))
+		}
+		for _, columnName := range this.migrationContext.UniqueKey.Columns.Names() {
+			if strings.EqualFold(columnName, transformation.Column) {
+				return fmt.Errorf("Transformed column %s is part of the chosen unique key %s, which is not supported", sql.EscapeName(transformation.Column), this.migrationContext.UniqueKey.Name)
+			}
+		}
+
+		transformation.SetReferencedColumns(this.migrationContext.OriginalTableColumns)
+		referencedColumns := []string{"1"}
+		if transformation.ReferencedColumns.Len() > 0 {
+			referencedColumns = make([]string, transformation.ReferencedColumns.Len())
+			for i, columnName := range transformation.ReferencedColumns.Names() {
+				referencedColumns[i] = sql.EscapeName(columnName)
+			}
+		}
+		query := fmt.Sprintf(`select /* gh-ost */ %s from (select %s from %s.%s limit 0) as %s`,
+			transformation.Expression,
+			strings.Join(referencedColumns, ", "),
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.OriginalTableName),
+			sql.EscapeName(sql.TransformedRowAlias),
+		)
+		rows, err := this.db.Query(query)
+		if err != nil {
+			return fmt.Errorf("Invalid transformation of column %s: expression must reference original table columns only: %+v", sql.EscapeName(transformation.Column), err)
+		}
+		rows.Close()
+		this.migrationContext.Log.Infof("Column %s is transformed by %s, referencing original columns %s", sql.EscapeName(transformation.Column), transformation.Expression, transformation.ReferencedColumns)
+	}
+	return nil
+}
+
+// validateRowFilter validates that the row filter (--where) references nothing but original table columns,
+// evaluating it over a derived table of the referenced columns as is done when applying binlog events.
+// It then reports how many rows the filter discards.
+func (this *Inspector) validateRowFilter() error {
+	rowFilter := this.migrationContext.RowFilter
+	if rowFilter == nil {
+		return nil
+	}
+	rowFilter.SetReferencedColumns(this.migrationContext.OriginalTableColumns)
+	referencedColumns := []string{"1"}
+	if rowFilter.ReferencedColumns.Len() > 0 {
+		referencedColumns = make([]string, rowFilter.ReferencedColumns.Len())
+		for i, columnName := range rowFilter.ReferencedColumns.Names() {
+			referencedColumns[i] = sql.EscapeName(columnName)
+		}
+	}
+	query := fmt.Sprintf(`select /* gh-ost */ 1 from (select %s from %s.%s limit 0) as %s where %s`,
+		strings.Join(referencedColumns, ", "),
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+		sql.EscapeName(sql.TransformedRowAlias),
+		rowFilter.Expression,
+	)
+	rows, err := this.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("Invalid --where: predicate must reference original table columns only: %+v", err)
+	}
+	rows.Close()
+	this.migrationContext.Log.Infof("Rows are filtered by %s, referencing original columns %s", rowFilter.Expression, rowFilter.ReferencedColumns)
+	return this.estimateRowFilterDiscardedRows()
+}
+
+// estimateRowFilterDiscardedRows reports the number of original table rows the row filter discards: estimated
+// via EXPLAIN, or counted on --noop --exact-rowcount. Row copy progress is based on all of the table's rows.
+func (this *Inspector) estimateRowFilterDiscardedRows() error {
+	discardedCondition := fmt.Sprintf("not ifnull((%s), false)", this.migrationContext.RowFilter.Expression)
+	if this.migrationContext.Noop && this.migrationContext.CountTableRows {
+		query := fmt.Sprintf(`select /* gh-ost */ count(*) from %s.%s where %s`,
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.OriginalTableName),
+			discardedCondition,
+		)
+		var discardedRows int64
+		if err := this.db.QueryRow(query).Scan(&discardedRows); err != nil {
+			return err
+		}
+		this.migrationContext.Log.Infof("Rows discarded by --where, via COUNT: %d", discardedRows)
+		return nil
+	}
+	query := fmt.Sprintf(`explain select /* gh-ost */ * from %s.%s where %s`,
+		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.OriginalTableName),
+		discardedCondition,
+	)
+	var discardedRows int64
+	err := sqlutils.QueryRowsMap(this.db, query, func(rowMap sqlutils.RowMap) error {
+		filtered, err := strconv.ParseFloat(rowMap.GetStringD("filtered", "100"), 64)
+		if err != nil {
+			filtered = 100
+		}
+		discardedRows = int64(float64(rowMap.GetInt64("rows")) * filtered / 100)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	this.migrationContext.Log.Infof("Rows discarded by --where, estimated via EXPLAIN: %d of %d", discardedRows, this.migrationContext.RowsEstimate)
+	return nil
+}
+
+// validateArchive validates the archive table (--archive-table) or file (--archive-file), and determines what
+// is archived. Columns the ALTER drops are archived, along with the unique key columns, for all rows. Rows the
+// row filter (--where) discards are archived in full; so are all rows when the ALTER also drops columns.
+func (this *Inspector) validateArchive() error {
+	if !this.migrationContext.IsArchiving() {
+		return nil
+	}
+	if !this.migrationContext.UniqueKey.HasOriginalIndex() {
+		return fmt.Errorf("--archive-table and --archive-file require a unique key shared by the original and ghost tables, which archived rows are keyed by. Chosen key is %s", this.migrationContext.UniqueKey)
+	}
+	if this.migrationContext.ArchiveFileName != "" {
+		if _, _, err := parseArchiveFileName(this.migrationContext.ArchiveFileName); err != nil {
+			return err
+		}
+	}
+	if archiveTableName := this.migrationContext.ArchiveTableName; archiveTableName != "" {
+		if len(archiveTableName) > mysql.MaxTableNameLength {
+			return fmt.Errorf("--archive-table %s is too long (only %d characters allowed)", archiveTableName, mysql.MaxTableNameLength)
+		}
+		for _, tableName := range []string{
+			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetGhostTableName(),
+			this.migrationContext.GetOldTableName(),
+			this.migrationContext.GetChangelogTableName(),
+		} {
+			if strings.EqualFold(archiveTableName, tableName) {
+				return fmt.Errorf("--archive-table %s collides with table %s, which the migration uses", archiveTableName, tableName)
+			}
+		}
+	}
+
+	isDroppedColumn := func(column string) bool {
+		for droppedColumn := range this.migrationContext.DroppedColumnsMap {
+			if strings.EqualFold(column, droppedColumn) {
+				return true
+			}
+		}
+		return false
+	}
+	hasDroppedColumns := false
+	for _, column := range this.migrationContext.OriginalTableColumns.Names() {
+		if isDroppedColumn(column) && this.migrationContext.OriginalTableVirtualColumns.GetColumn(column) == nil {
+			hasDroppedColumns = true
+		}
+	}
+	if !hasDroppedColumns && this.migrationContext.RowFilter == nil {
+		return fmt.Errorf("Nothing to archive: --archive-table and --archive-file archive the columns the ALTER drops, or the rows --where discards")
+	}
+	this.migrationContext.ArchiveColumns = this.migrationContext.OriginalTableColumns.FilterBy(func(column sql.Column) bool {
+		if this.migrationContext.OriginalTableVirtualColumns.GetColumn(column.Name) != nil {
+			return false
+		}
+		if this.migrationContext.RowFilter != nil {
+			return true
+		}
+		return this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil || isDroppedColumn(column.Name)
+	})
+	this.migrationContext.ArchiveRowFilter = nil
+	if this.migrationContext.RowFilter != nil && !hasDroppedColumns {
+		this.migrationContext.ArchiveRowFilter = this.migrationContext.RowFilter.Negate()
+	}
+	if this.migrationContext.ArchiveRowFilter != nil {
+		this.migrationContext.Log.Infof("Rows discarded by --where will be archived, columns %s", this.migrationContext.ArchiveColumns.Names())
+	} else {
+		this.migrationContext.Log.Infof("All rows will be archived, columns %s", this.migrationContext.ArchiveColumns.Names())
+	}
+	return nil
+}
+
+// validateSideTable validates a vertical split (--side-table, --side-columns), and determines the side table's
+// columns: the unique key columns, by which side rows are keyed, and the side columns, which the ALTER drops.
+func (this *Inspector) validateSideTable() error {
+	if !this.migrationContext.IsVerticalSplit() {
+		return nil
+	}
+	if len(this.migrationContext.SideColumnNames) == 0 {
+		return fmt.Errorf("--side-table requires --side-columns")
+	}
+	if !this.migrationContext.UniqueKey.HasOriginalIndex() || this.migrationContext.UniqueKey.IsFullRow {
+		return fmt.Errorf("--side-table requires a unique key shared by the original and ghost tables, which side rows are keyed by. Chosen key is %s", this.migrationContext.UniqueKey)
+	}
+	sideTableName := this.migrationContext.SideTableName
+	if len(this.migrationContext.GetSideGhostTableName()) > mysql.MaxTableNameLength {
+		return fmt.Errorf("--side-table %s is too long (only %d characters allowed, including the ghost table affixes)", sideTableName, mysql.MaxTableNameLength-len("__gho"))
+	}
+	for _, tableName := range []string{
+		this.migrationContext.OriginalTableName,
+		this.migrationContext.GetMigratedTableName(),
+		this.migrationContext.GetGhostTableName(),
+		this.migrationContext.GetOldTableName(),
+		this.migrationContext.GetChangelogTableName(),
+		this.migrationContext.ArchiveTableName,
+	} {
+		if strings.EqualFold(sideTableName, tableName) {
+			return fmt.Errorf("--side-table %s collides with table %s, which the migration uses", sideTableName, tableName)
+		}
+	}
+
+	sideColumns := make(map[string]bool)
+	for _, columnName := range this.migrationContext.SideColumnNames {
+		column := this.migrationContext.OriginalTableColumns.GetColumn(columnName)
+		if column == nil {
+			return fmt.Errorf("--side-columns column %s not found on %s.%s", sql.EscapeName(columnName), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+		}
+		if this.migrationContext.OriginalTableVirtualColumns.GetColumn(columnName) != nil {
+			return fmt.Errorf("--side-columns column %s is a virtual column, which cannot be moved onto the side table", sql.EscapeName(columnName))
+		}
+		if this.migrationContext.UniqueKey.Columns.GetColumn(columnName) != nil {
+			return fmt.Errorf("--side-columns column %s is part of unique key %s, which both tables are keyed by, and is not to be listed", sql.EscapeName(columnName), this.migrationContext.UniqueKey.Name)
+		}
+		if this.migrationContext.SharedColumns.GetColumn(columnName) != nil {
+			return fmt.Errorf("--side-columns column %s remains on the migrated table. The ALTER must drop the columns moved onto the side table", sql.EscapeName(columnName))
+		}
+		sideColumns[columnName] = true
+	}
+	this.migrationContext.SideTableColumns = this.migrationContext.OriginalTableColumns.FilterBy(func(column sql.Column) bool {
+		return sideColumns[column.Name] || this.migrationContext.UniqueKey.Columns.GetColumn(column.Name) != nil
+	})
+	this.migrationContext.Log.Infof("Columns %s will be moved onto side table %s, keyed by %s", this.migrationContext.SideColumnNames, sql.EscapeName(sideTableName), this.migrationContext.UniqueKey.Name)
+	return nil
+}
+
+// validateShards validates a horizontal split (--shard-tables, --shard-column), and sets the sharding column
+// rows are routed by. Its values must read alike off the original table and off binlog events.
+func (this *Inspector) validateShards() error {
+	if !this.migrationContext.IsHorizontalSplit() {
+		return nil
+	}
+	if !this.migrationContext.UniqueKey.HasOriginalIndex() || this.migrationContext.UniqueKey.IsFullRow {
+		return fmt.Errorf("--shard-tables requires a unique key shared by the original and ghost tables. Chosen key is %s", this.migrationContext.UniqueKey)
+	}
+	if this.migrationContext.RenamedTableName != "" {
+		return fmt.Errorf("--shard-tables is incompatible with an ALTER statement renaming the table")
+	}
+	for i, shardTableName := range this.migrationContext.ShardTableNames {
+		if len(this.migrationContext.GetShardGhostTableName(i)) > mysql.MaxTableNameLength {
+			return fmt.Errorf("--shard-tables table %s is too long (only %d characters allowed, including the ghost table affixes)", shardTableName, mysql.MaxTableNameLength-len("__gho"))
+		}
+		for _, tableName := range []string{
+			this.migrationContext.OriginalTableName,
+			this.migrationContext.GetGhostTableName(),
+			this.migrationContext.GetOldTableName(),
+			this.migrationContext.GetChangelogTableName(),
+			this.migrationContext.ArchiveTableName,
+			this.migrationContext.SideTableName,
+		} {
+			if strings.EqualFold(shardTableName, tableName) {
+				return fmt.Errorf("--shard-tables table %s collides with table %s, which the migration uses", shardTableName, tableName)
+			}
+		}
+	}
+
+	columnName := this.migrationContext.ShardColumnName
+	column := this.migrationContext.OriginalTableColumns.GetColumn(columnName)
+	if column == nil {
+		return fmt.Errorf("--shard-column %s not found on %s.%s", sql.EscapeName(columnName), sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+	// Binlog events and MySQL's CRC32() hold values of these types in different text forms
+	switch column.DataType {
+	case "float", "double", "json", "binary", "timestamp", "datetime", "bit", "set":
+		return fmt.Errorf("--shard-column %s is of type %s, which rows cannot be routed by. Use an integer, string or DATE column", sql.EscapeName(columnName), column.DataType)
+	}
+	if this.migrationContext.ShardRouter.Method == sql.RangeShardMethod && !column.IsInteger() {
+		return fmt.Errorf("--shard-by=range requires an integer --shard-column; %s is of type %s", sql.EscapeName(columnName), column.DataType)
+	}
+	this.migrationContext.ShardRouter.Column = *column
+	this.migrationContext.Log.Infof("Rows will be split onto %d shard tables %s by %s of %s", len(this.migrationContext.ShardTableNames), this.migrationContext.ShardTableNames, this.migrationContext.ShardRouter.Method, sql.EscapeName(columnName))
+	return nil
+}
+
 // getSharedColumns returns the intersection of two lists of columns in same order as the first list
 func (this *Inspector) getSharedColumns(originalColumns, ghostColumns *sql.ColumnList, originalVirtualColumns, ghostVirtualColumns *sql.ColumnList, columnRenameMap map[string]string) (*sql.ColumnList, *sql.ColumnList) {
 	sharedColumnNames := []string{}
@@ -908,6 +1718,14 @@ func (this *Inspector) showCreateTable(tableName string) (createTableStatement s
 	return createTableStatement, err
 }
 
+// showCreateGhostTable returns the `show create table` statement for the ghost table
+func (this *Inspector) showCreateGhostTable() (createTableStatement string, err error) {
+	var dummy string
+	query := fmt.Sprintf(`show /* gh-ost */ create table %s.%s`, sql.EscapeName(this.migrationContext.GetGhostDatabaseName()), sql.EscapeName(this.migrationContext.GetGhostTableName()))
+	err = this.ghostDB.QueryRow(query).Scan(&dummy, &createTableStatement)
+	return createTableStatement, err
+}
+
 // readChangelogState reads changelog hints
 func (this *Inspector) readChangelogState(hint string) (string, error) {
 	query := fmt.Sprintf(`
@@ -942,6 +1760,9 @@ func (this *Inspector) getReplicationLag() (replicationLag time.Duration, err er
 }
 
 func (this *Inspector) Teardown() {
+	if this.ghostDB != nil && this.ghostDB != this.db {
+		this.ghostDB.Close()
+	}
 	this.db.Close()
 	this.informationSchemaDb.Close()
 }
@@ -8,6 +8,7 @@ package logic
 import (
 	"testing"
 
+	"github.com/github/gh-ost/go/base"
 	"github.com/github/gh-ost/go/sql"
 	"github.com/stretchr/testify/require"
 )
@@ -30,3 +31,112 @@ func TestInspectGetSharedUniqueKeys(t *testing.T) {
 	require.Equal(t, "id,org_id", sharedUniqKeys[1].Columns.String())
 	require.Equal(t, "id", sharedUniqKeys[2].Columns.String())
 }
+
+func TestInspectValidateBinlogRowImageUniqueKey(t *testing.T) {
+	primaryKey := &sql.UniqueKey{Name: "PRIMARY", Columns: *sql.NewColumnList([]string{"id"})}
+	uniqueKey := &sql.UniqueKey{Name: "item_uidx", Columns: *sql.NewColumnList([]string{"item_id"})}
+
+	migrationContext := base.NewMigrationContext()
+	inspector := NewInspector(migrationContext)
+	migrationContext.OriginalTableUniqueKeys = []*sql.UniqueKey{primaryKey, uniqueKey}
+	migrationContext.UniqueKey = uniqueKey
+
+	migrationContext.OriginalBinlogRowImage = "FULL"
+	require.NoError(t, inspector.validateBinlogRowImageUniqueKey())
+
+	migrationContext.OriginalBinlogRowImage = "MINIMAL"
+	require.Error(t, inspector.validateBinlogRowImageUniqueKey())
+
+	migrationContext.UniqueKey = primaryKey
+	require.NoError(t, inspector.validateBinlogRowImageUniqueKey())
+
+	migrationContext.OriginalTableUniqueKeys = []*sql.UniqueKey{uniqueKey}
+	migrationContext.UniqueKey = uniqueKey
+	require.Error(t, inspector.validateBinlogRowImageUniqueKey())
+
+	migrationContext.OriginalTableUniqueKeys = []*sql.UniqueKey{}
+	migrationContext.UniqueKey = &sql.UniqueKey{Name: "PRIMARY", Columns: *sql.NewColumnList([]string{"id", "name"}), IsFullRow: true}
+	require.Error(t, inspector.validateBinlogRowImageUniqueKey())
+}
+
+func TestInspectValidateBinlogRowImageDefaults(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	inspector := NewInspector(migrationContext)
+	migrationContext.SharedColumns = sql.NewColumnList([]string{"id", "status", "created_at"})
+	migrationContext.MappedSharedColumns = sql.NewColumnList([]string{"id", "status", "created_at"})
+	setDefault := func(columns *sql.ColumnList, name, value string, isGenerated bool) {
+		column := columns.GetColumn(name)
+		column.HasDefault = true
+		column.Default = value
+		column.IsDefaultGenerated = isGenerated
+	}
+	setDefault(migrationContext.SharedColumns, "status", "new", false)
+	setDefault(migrationContext.MappedSharedColumns, "status", "new", false)
+
+	migrationContext.OriginalBinlogRowImage = "MINIMAL"
+	require.NoError(t, inspector.validateBinlogRowImageDefaults())
+
+	setDefault(migrationContext.MappedSharedColumns, "status", "pending", false)
+	require.Error(t, inspector.validateBinlogRowImageDefaults())
+
+	migrationContext.OriginalBinlogRowImage = "FULL"
+	require.NoError(t, inspector.validateBinlogRowImageDefaults())
+
+	migrationContext.OriginalBinlogRowImage = "MINIMAL"
+	setDefault(migrationContext.MappedSharedColumns, "status", "new", false)
+	setDefault(migrationContext.SharedColumns, "created_at", "CURRENT_TIMESTAMP", true)
+	setDefault(migrationContext.MappedSharedColumns, "created_at", "CURRENT_TIMESTAMP", true)
+	require.Error(t, inspector.validateBinlogRowImageDefaults())
+}
+
+func TestInspectIsGeneratedColumnDefault(t *testing.T) {
+	require.True(t, isGeneratedColumnDefault("CURRENT_TIMESTAMP", ""))
+	require.True(t, isGeneratedColumnDefault("current_timestamp(6)", "on update CURRENT_TIMESTAMP(6)"))
+	require.True(t, isGeneratedColumnDefault("(uuid())", "DEFAULT_GENERATED"))
+	require.False(t, isGeneratedColumnDefault("0", ""))
+	require.False(t, isGeneratedColumnDefault("", ""))
+}
+
+func TestInspectValidateFullBinlogRowImage(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	inspector := NewInspector(migrationContext)
+
+	migrationContext.OriginalBinlogRowImage = "MINIMAL"
+	require.NoError(t, inspector.validateFullBinlogRowImage())
+
+	require.NoError(t, migrationContext.ReadRowFilter("id > 10"))
+	require.Error(t, inspector.validateFullBinlogRowImage())
+
+	migrationContext.RowFilter = nil
+	migrationContext.ArchiveFileName = "archive.csv"
+	require.Error(t, inspector.validateFullBinlogRowImage())
+
+	migrationContext.OriginalBinlogRowImage = "FULL"
+	require.NoError(t, inspector.validateFullBinlogRowImage())
+}
+
+func TestInspectValidateShards(t *testing.T) {
+	newShardContext := func(shardMethod, rangeBounds, dataType string) *base.MigrationContext {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.DatabaseName = "test"
+		migrationContext.OriginalTableName = "test"
+		migrationContext.ShardColumnName = "item_id"
+		require.NoError(t, migrationContext.ReadShardTables("test_0,test_1", shardMethod, rangeBounds))
+		migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "item_id"})
+		migrationContext.OriginalTableColumns.GetColumn("item_id").DataType = dataType
+		migrationContext.UniqueKey = &sql.UniqueKey{Name: "PRIMARY", Columns: *sql.NewColumnList([]string{"id"})}
+		return migrationContext
+	}
+
+	for _, dataType := range []string{"bigint", "varchar", "decimal", "date"} {
+		require.NoError(t, NewInspector(newShardContext("hash", "", dataType)).validateShards(), dataType)
+	}
+	for _, dataType := range []string{"bit", "set", "double", "json", "timestamp"} {
+		require.Error(t, NewInspector(newShardContext("hash", "", dataType)).validateShards(), dataType)
+	}
+
+	require.NoError(t, NewInspector(newShardContext("range", "100", "int")).validateShards())
+	for _, dataType := range []string{"varchar", "decimal", "date"} {
+		require.Error(t, NewInspector(newShardContext("range", "100", dataType)).validateShards(), dataType)
+	}
+}
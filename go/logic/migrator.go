@@ -23,7 +23,7 @@ import (
 )
 
 var (
-	ErrMigratorUnsupportedRenameAlter = errors.New("ALTER statement seems to RENAME the table. This is not supported, and you should run your RENAME outside gh-ost.")
+	ErrMigratorUnsupportedRenameAlter = errors.New("ALTER statement seems to RENAME the table in a way gh-ost cannot follow (e.g. into another schema). This is not supported, and you should run your RENAME outside gh-ost.")
 	ErrMigrationNotAllowedOnMaster    = errors.New("It seems like this migration attempt to run directly on master. Preferably it would be executed on a replica (this reduces load from the master). To proceed please provide --allow-on-master.")
 	RetrySleepFn                      = time.Sleep
 )
@@ -78,6 +78,7 @@ type Migrator struct {
 	server           *Server
 	throttler        *Throttler
 	hooksExecutor    *HooksExecutor
+	cdcTap           *CDCTap
 	migrationContext *base.MigrationContext
 
 	firstThrottlingCollected   chan bool
@@ -257,16 +258,35 @@ func (this *Migrator) onChangelogHeartbeatEvent(dmlEvent *binlog.BinlogDMLEvent)
 // listenOnPanicAbort aborts on abort request
 func (this *Migrator) listenOnPanicAbort() {
 	err := <-this.migrationContext.PanicAbort
+	this.migrationContext.Log.Errorf("Aborting: %+v", err)
+	this.hooksExecutor.onAbortWithin(err)
 	this.migrationContext.Log.Fatale(err)
 }
 
 // validateAlterStatement validates the `alter` statement meets criteria.
 // At this time this means:
 // - column renames are approved
-// - no table rename allowed
+// - a table rename, if any, stays within the migrated schema
 func (this *Migrator) validateAlterStatement() (err error) {
 	if this.parser.IsRenameTable() {
-		return ErrMigratorUnsupportedRenameAlter
+		renameTableSchema := this.parser.GetRenameTableSchema()
+		if this.parser.GetRenameTableName() == "" {
+			return ErrMigratorUnsupportedRenameAlter
+		}
+		if renameTableSchema != "" && renameTableSchema != this.migrationContext.DatabaseName {
+			return ErrMigratorUnsupportedRenameAlter
+		}
+		if len(this.parser.GetRenameTableName()) > mysql.MaxTableNameLength {
+			return fmt.Errorf("ALTER statement renames the table to %s, which exceeds %d characters", sql.EscapeName(this.parser.GetRenameTableName()), mysql.MaxTableNameLength)
+		}
+		this.migrationContext.AlterStatementOptions = this.parser.GetAlterStatementOptionsWithoutRename()
+		if this.parser.GetRenameTableName() != this.migrationContext.OriginalTableName {
+			this.migrationContext.RenamedTableName = this.parser.GetRenameTableName()
+			this.migrationContext.Log.Infof("Alter statement renames the table to %s; the ghost table will assume this name at cut-over", sql.EscapeName(this.migrationContext.RenamedTableName))
+		}
+	}
+	if this.migrationContext.RenameTableCompatView && this.migrationContext.RenamedTableName == "" {
+		return fmt.Errorf("--rename-table-compat-view requires the ALTER statement to rename the table")
 	}
 	if this.parser.HasNonTrivialRenames() && !this.migrationContext.SkipRenamedColumns {
 		this.migrationContext.ColumnRenameMap = this.parser.GetNonTrivialRenames()
@@ -275,6 +295,12 @@ func (this *Migrator) validateAlterStatement() (err error) {
 		}
 		this.migrationContext.Log.Infof("Alter statement has column(s) renamed. gh-ost finds the following renames: %v; --approve-renamed-columns is given and so migration proceeds.", this.parser.GetNonTrivialRenames())
 	}
+	if partitionMaintenance := this.parser.GetPartitionMaintenance(); partitionMaintenance != "" {
+		return fmt.Errorf("ALTER statement has %s, which operates on the table's existing partitions rather than altering the table. This is not supported: run it directly on the table, outside gh-ost", partitionMaintenance)
+	}
+	if this.parser.IsPartitioningChange() {
+		this.migrationContext.Log.Infof("Alter statement changes the table's partitioning. The chosen unique key will be validated against the ghost table's partitioning")
+	}
 	this.migrationContext.DroppedColumnsMap = this.parser.DroppedColumnsMap()
 	return nil
 }
@@ -335,6 +361,9 @@ func (this *Migrator) Migrate() (err error) {
 
 	go this.listenOnPanicAbort()
 
+	if err := this.hooksExecutor.validateHooks(); err != nil {
+		return err
+	}
 	if err := this.hooksExecutor.onStartup(); err != nil {
 		return err
 	}
@@ -349,6 +378,12 @@ func (this *Migrator) Migrate() (err error) {
 	//   so we don't leave things hanging around
 	defer this.teardown()
 
+	if this.migrationContext.CredentialsRefreshIntervalSeconds > 0 {
+		// Set ahead of initiating the inspector, so that connection configs derived from
+		// the inspector's, such as the applier's, refresh credentials as well
+		this.migrationContext.InspectorConnectionConfig.OnAccessDenied = this.migrationContext.RefreshCredentialsOnAccessDenied
+		this.migrationContext.GetBinlogConnectionConfig().OnAccessDenied = this.migrationContext.RefreshCredentialsOnAccessDenied
+	}
 	if err := this.initiateInspector(); err != nil {
 		return err
 	}
@@ -364,7 +399,9 @@ func (this *Migrator) Migrate() (err error) {
 	// In MySQL 8.0 (and possibly earlier) some DDL statements can be applied instantly.
 	// Attempt to do this if AttemptInstantDDL is set.
 	if this.migrationContext.AttemptInstantDDL {
-		if this.migrationContext.Noop {
+		if this.migrationContext.RenamedTableName != "" {
+			this.migrationContext.Log.Infof("ALTER statement renames the table; not attempting instant DDL")
+		} else if this.migrationContext.Noop {
 			this.migrationContext.Log.Debugf("Noop operation; not really attempting instant DDL")
 		} else {
 			this.migrationContext.Log.Infof("Attempting to execute alter with ALGORITHM=INSTANT")
@@ -395,6 +432,16 @@ func (this *Migrator) Migrate() (err error) {
 	if err := this.applier.prepareQueries(); err != nil {
 		return err
 	}
+	if !this.migrationContext.Noop {
+		if err := this.applier.CreateArchive(); err != nil {
+			this.migrationContext.Log.Errorf("Unable to create archive, see further error details. Bailing out")
+			return err
+		}
+		if err := this.applier.CreateSideGhostTable(); err != nil {
+			this.migrationContext.Log.Errorf("Unable to create side ghost table, see further error details. Bailing out")
+			return err
+		}
+	}
 	// Validation complete! We're good to execute this migration
 	if err := this.hooksExecutor.onValidated(); err != nil {
 		return err
@@ -408,6 +455,9 @@ func (this *Migrator) Migrate() (err error) {
 	if err := this.countTableRows(); err != nil {
 		return err
 	}
+	if err := this.initiateCDCTap(); err != nil {
+		return err
+	}
 	if err := this.addDMLEventsListener(); err != nil {
 		return err
 	}
@@ -424,6 +474,7 @@ func (this *Migrator) Migrate() (err error) {
 	go this.iterateChunks()
 	this.migrationContext.MarkRowCopyStartTime()
 	go this.initiateStatus()
+	go this.initiateCredentialsRefresh()
 
 	this.migrationContext.Log.Debugf("Operating until row copy is complete")
 	this.consumeRowCopyComplete()
@@ -451,6 +502,10 @@ func (this *Migrator) Migrate() (err error) {
 	}
 	atomic.StoreInt64(&this.migrationContext.CutOverCompleteFlag, 1)
 
+	if err := this.createRenamedTableCompatView(); err != nil {
+		return err
+	}
+
 	if err := this.finalCleanup(); err != nil {
 		return nil
 	}
@@ -458,6 +513,17 @@ func (this *Migrator) Migrate() (err error) {
 		return err
 	}
 	this.migrationContext.Log.Infof("Done migrating %s.%s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	if this.migrationContext.IsCrossServerMigration() {
+		this.migrationContext.Log.Infof("Table is now %s.%s on %+v", sql.EscapeName(this.migrationContext.GetGhostDatabaseName()), sql.EscapeName(this.migrationContext.GetTargetTableName()), this.migrationContext.TargetConnectionConfig.Key)
+	} else if this.migrationContext.RenamedTableName != "" {
+		this.migrationContext.Log.Infof("Table is now named %s.%s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.RenamedTableName))
+	}
+	if this.migrationContext.IsHorizontalSplit() {
+		this.migrationContext.Log.Infof("Rows are now split onto shard tables %s by %s of %s", this.migrationContext.ShardTableNames, this.migrationContext.ShardRouter.Method, sql.EscapeName(this.migrationContext.ShardColumnName))
+	}
+	if this.migrationContext.IsVerticalSplit() {
+		this.migrationContext.Log.Infof("Columns %s are now on side table %s.%s", this.migrationContext.SideColumnNames, sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.SideTableName))
+	}
 	return nil
 }
 
@@ -476,6 +542,9 @@ func (this *Migrator) handleCutOverResult(cutOverError error) (err error) {
 		return nil
 	}
 	// Only on error:
+	if err := this.hooksExecutor.onCutOverAttemptFailed(atomic.LoadInt64(&this.migrationContext.CutOverAttempts), cutOverError); err != nil {
+		this.migrationContext.Log.Errorf("%s hook failed, ignoring: %+v", onCutOverAttemptFail, err)
+	}
 
 	if this.migrationContext.TestOnReplica {
 		// With `--test-on-replica` we stop replication thread, and then proceed to use
@@ -520,15 +589,17 @@ func (this *Migrator) cutOver() (err error) {
 				this.migrationContext.Log.Debugf("current HeartbeatLag (%.2fs) is too high, it needs to be less than both --max-lag-millis (%.2fs) and --cut-over-lock-timeout-seconds (%.2fs) to continue", heartbeatLag.Seconds(), maxLagMillisecondsThrottle.Seconds(), cutOverLockTimeout.Seconds())
 				return true, nil
 			}
-			if this.migrationContext.PostponeCutOverFlagFile == "" {
-				return false, nil
-			}
 			if atomic.LoadInt64(&this.migrationContext.UserCommandedUnpostponeFlag) > 0 {
 				atomic.StoreInt64(&this.migrationContext.UserCommandedUnpostponeFlag, 0)
+				atomic.StoreInt64(&this.migrationContext.PostponeCutOverCommandedByHook, 0)
 				return false, nil
 			}
-			if base.FileExists(this.migrationContext.PostponeCutOverFlagFile) {
+			postpone := atomic.LoadInt64(&this.migrationContext.PostponeCutOverCommandedByHook) > 0
+			if this.migrationContext.PostponeCutOverFlagFile != "" && base.FileExists(this.migrationContext.PostponeCutOverFlagFile) {
 				// Postpone file defined and exists!
+				postpone = true
+			}
+			if postpone {
 				if atomic.LoadInt64(&this.migrationContext.IsPostponingCutOver) == 0 {
 					if err := this.hooksExecutor.onBeginPostponed(); err != nil {
 						return true, err
@@ -562,6 +633,12 @@ func (this *Migrator) cutOver() (err error) {
 		}
 	}
 
+	atomic.AddInt64(&this.migrationContext.CutOverAttempts, 1)
+	if this.migrationContext.IsCrossServerMigration() {
+		err = this.handOff()
+		this.handleCutOverResult(err)
+		return err
+	}
 	switch this.migrationContext.CutOverType {
 	case base.CutOverAtomic:
 		// Atomic solution: we use low timeout and multiple attempts. But for
@@ -572,6 +649,12 @@ func (this *Migrator) cutOver() (err error) {
 	default:
 		return this.migrationContext.Log.Fatalf("Unknown cut-over type: %d; should never get here!", this.migrationContext.CutOverType)
 	}
+	if err != nil {
+		// The ghost table keeps catching up without foreign keys until the next attempt
+		if dropErr := this.applier.DropChildSideForeignKeysFromGhost(); dropErr != nil {
+			this.migrationContext.Log.Errore(dropErr)
+		}
+	}
 	this.handleCutOverResult(err)
 	return err
 }
@@ -638,7 +721,23 @@ func (this *Migrator) cutOverTwoStep() (err error) {
 			return err
 		}
 	}
+	if err := this.addChildSideForeignKeysToGhost(); err != nil {
+		if unlockErr := this.retryOperation(this.applier.UnlockTables, true); unlockErr != nil {
+			this.migrationContext.Log.Errore(unlockErr)
+		}
+		return err
+	}
+	// Should rebinding fail, child tables are restored, and the cut-over is aborted before the swap
+	if err := this.rebindParentSideForeignKeys(); err != nil {
+		if unlockErr := this.retryOperation(this.applier.UnlockTables, true); unlockErr != nil {
+			this.migrationContext.Log.Errore(unlockErr)
+		}
+		return err
+	}
 	if err := this.retryOperation(this.applier.SwapTablesQuickAndBumpy); err != nil {
+		if restoreErr := this.applier.RestoreParentSideForeignKeys(); restoreErr != nil {
+			this.migrationContext.Log.Errore(restoreErr)
+		}
 		return err
 	}
 	if err := this.retryOperation(this.applier.UnlockTables); err != nil {
@@ -648,6 +747,68 @@ func (this *Migrator) cutOverTwoStep() (err error) {
 	lockAndRenameDuration := this.migrationContext.RenameTablesEndTime.Sub(this.migrationContext.LockTablesStartTime)
 	renameDuration := this.migrationContext.RenameTablesEndTime.Sub(this.migrationContext.RenameTablesStartTime)
 	this.migrationContext.Log.Debugf("Lock & rename duration: %s (rename only: %s). During this time, queries on %s were locked or failing", lockAndRenameDuration, renameDuration, sql.EscapeName(this.migrationContext.OriginalTableName))
+	if len(this.migrationContext.ParentSideForeignKeys) > 0 && !this.migrationContext.TestOnReplica {
+		this.migrationContext.Log.Infof("Rebound %d parent-side foreign keys within the cut-over; they added %s to the %s lock time", len(this.migrationContext.ParentSideForeignKeys), this.migrationContext.ParentSideForeignKeysRebindDuration, lockAndRenameDuration)
+	}
+	return nil
+}
+
+// handOff replaces the cut-over of a cross-server migration. It locks down the original table, executes
+// what's left of last DML entries onto the target server, and renames the ghost table there onto its
+// final name. The gh-ost-on-hand-off hook then runs while the original table is still locked, e.g.
+// to point apps at the target server; should it fail, the hand-off is rolled back. Once the hook
+// succeeds the hand-off is done: the original table is renamed away, without retries, and unlocked.
+// The original table is unlocked on any error.
+func (this *Migrator) handOff() (err error) {
+	atomic.StoreInt64(&this.migrationContext.InCutOverCriticalSectionFlag, 1)
+	defer atomic.StoreInt64(&this.migrationContext.InCutOverCriticalSectionFlag, 0)
+	atomic.StoreInt64(&this.migrationContext.AllEventsUpToLockProcessedInjectedFlag, 0)
+
+	unlockTables := func() error {
+		if unlockErr := this.retryOperation(this.applier.UnlockTables, true); unlockErr != nil {
+			return this.migrationContext.Log.Errore(unlockErr)
+		}
+		return nil
+	}
+	if err := this.hooksExecutor.onBeforeHandOff(); err != nil {
+		return err
+	}
+	if err := this.retryOperation(this.applier.LockOriginalTable, true); err != nil {
+		unlockTables()
+		return err
+	}
+	if err := this.retryOperation(this.waitForEventsUpToLock, true); err != nil {
+		unlockTables()
+		return err
+	}
+	if err := this.retryOperation(this.applier.RenameGhostTableOnTarget, true); err != nil {
+		unlockTables()
+		return err
+	}
+	if err := this.hooksExecutor.onHandOff(); err != nil {
+		this.migrationContext.Log.Errorf("%s hook failed; rolling back hand-off", onHandOff)
+		if rollbackErr := this.retryOperation(this.applier.RenameGhostTableOnTargetRollback, true); rollbackErr != nil {
+			this.migrationContext.Log.Errore(rollbackErr)
+		}
+		unlockTables()
+		return err
+	}
+
+	// The hand-off is done: apps have moved onto the target server, which nothing below may undo.
+	// Failures are reported, but neither retried nor returned, lest the hand-off be attempted again.
+	if err := this.applier.RenameOriginalTableAway(); err != nil {
+		this.migrationContext.Log.Errorf("Hand-off is complete, but renaming %s.%s to %s failed: %+v. Rename or drop the original table once no longer written to",
+			sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName), sql.EscapeName(this.migrationContext.GetOldTableName()), err)
+		unlockTables()
+		return nil
+	}
+	if err := unlockTables(); err != nil {
+		this.migrationContext.Log.Errorf("Hand-off is complete, but unlocking %s.%s failed; it is unlocked once gh-ost disconnects", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.OriginalTableName))
+	}
+
+	lockAndRenameDuration := this.migrationContext.RenameTablesEndTime.Sub(this.migrationContext.LockTablesStartTime)
+	renameDuration := this.migrationContext.RenameTablesEndTime.Sub(this.migrationContext.RenameTablesStartTime)
+	this.migrationContext.Log.Debugf("Lock & hand-off duration: %s (hand-off only: %s). During this time, queries on %s were locked", lockAndRenameDuration, renameDuration, sql.EscapeName(this.migrationContext.OriginalTableName))
 	return nil
 }
 
@@ -688,6 +849,9 @@ func (this *Migrator) atomicCutOver() (err error) {
 			this.migrationContext.Log.Errore(err)
 		}
 	}
+	if err := this.addChildSideForeignKeysToGhost(); err != nil {
+		return this.migrationContext.Log.Errore(err)
+	}
 
 	// Step 2
 	// We now attempt an atomic RENAME on original & ghost tables, and expect it to block.
@@ -749,12 +913,41 @@ func (this *Migrator) atomicCutOver() (err error) {
 	return nil
 }
 
+// ReloadConfig re-reads the config file and applies the settings which may change
+// while the migration runs. Changes are logged and written to the changelog table.
+func (this *Migrator) ReloadConfig() (changes []base.ConfigChange, err error) {
+	changes, err = this.migrationContext.ReloadConfigFile()
+	if err != nil {
+		return changes, this.migrationContext.Log.Errore(err)
+	}
+	this.migrationContext.MarkPointOfInterest()
+	if len(changes) == 0 {
+		this.migrationContext.Log.Infof("Config reloaded: no changes")
+		return changes, nil
+	}
+	diff := make([]string, 0, len(changes))
+	for _, change := range changes {
+		this.migrationContext.Log.Infof("Config reloaded: %s", change)
+		diff = append(diff, change.String())
+	}
+	if this.applier != nil {
+		changelogValue := strings.Join(diff, "; ")
+		if len(changelogValue) > maxChangelogValueLength {
+			changelogValue = changelogValue[:maxChangelogValueLength]
+		}
+		if _, err := this.applier.WriteAndLogChangelog("reload-config", changelogValue); err != nil {
+			this.migrationContext.Log.Errorf("Failed writing config reload to changelog: %+v", err)
+		}
+	}
+	return changes, nil
+}
+
 // initiateServer begins listening on unix socket/tcp for incoming interactive commands
 func (this *Migrator) initiateServer() (err error) {
 	var f printStatusFunc = func(rule PrintStatusRule, writer io.Writer) {
 		this.printStatus(rule, writer)
 	}
-	this.server = NewServer(this.migrationContext, this.hooksExecutor, f)
+	this.server = NewServer(this.migrationContext, this.hooksExecutor, f, this.ReloadConfig)
 	if err := this.server.BindSocketFile(); err != nil {
 		return err
 	}
@@ -799,11 +992,12 @@ func (this *Migrator) initiateInspector() (err error) {
 			return err
 		}
 		this.migrationContext.ApplierConnectionConfig = this.migrationContext.InspectorConnectionConfig.DuplicateCredentials(*key)
-		if this.migrationContext.CliMasterUser != "" {
-			this.migrationContext.ApplierConnectionConfig.User = this.migrationContext.CliMasterUser
+		masterUser, masterPassword := this.migrationContext.GetMasterCredentials()
+		if masterUser != "" {
+			this.migrationContext.ApplierConnectionConfig.User = masterUser
 		}
-		if this.migrationContext.CliMasterPassword != "" {
-			this.migrationContext.ApplierConnectionConfig.Password = this.migrationContext.CliMasterPassword
+		if masterPassword != "" {
+			this.migrationContext.ApplierConnectionConfig.Password = masterPassword
 		}
 		if err := this.migrationContext.ApplierConnectionConfig.RegisterTLSConfig(); err != nil {
 			return err
@@ -828,6 +1022,15 @@ func (this *Migrator) initiateInspector() (err error) {
 	if err := this.inspector.validateLogSlaveUpdates(); err != nil {
 		return err
 	}
+	if this.migrationContext.IsCrossServerMigration() {
+		if err := this.migrationContext.SetupTargetConnectionConfig(); err != nil {
+			return err
+		}
+		if err := this.inspector.InitTargetDBConnection(); err != nil {
+			return err
+		}
+		this.migrationContext.Log.Infof("Target forced to be %+v", this.migrationContext.TargetConnectionConfig.Key)
+	}
 
 	return nil
 }
@@ -843,7 +1046,7 @@ func (this *Migrator) initiateStatus() {
 			return
 		}
 		go this.printStatus(HeuristicPrintStatusRule)
-		totalCopied := atomic.LoadInt64(&this.migrationContext.TotalRowsCopied)
+		totalCopied := this.migrationContext.GetTotalRowsIterated()
 		if previousCount > 0 {
 			copiedThisLoop := totalCopied - previousCount
 			atomic.StoreInt64(&this.migrationContext.EtaRowsPerSecond, copiedThisLoop)
@@ -852,6 +1055,27 @@ func (this *Migrator) initiateStatus() {
 	}
 }
 
+// initiateCredentialsRefresh periodically re-reads credentials and TLS certificates from their
+// sources, as per --credentials-refresh-interval-seconds, such that new connections use them
+func (this *Migrator) initiateCredentialsRefresh() {
+	if this.migrationContext.CredentialsRefreshIntervalSeconds <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(this.migrationContext.CredentialsRefreshIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if atomic.LoadInt64(&this.finishedMigrating) > 0 {
+			return
+		}
+		if err := this.migrationContext.RefreshCredentials(); err != nil {
+			// Existing connections keep working; try again on next interval
+			this.migrationContext.Log.Errorf("Failed refreshing credentials: %+v", err)
+			continue
+		}
+		this.migrationContext.Log.Debugf("Credentials refreshed")
+	}
+}
+
 // printMigrationStatusHint prints a detailed configuration dump, that is useful
 // to keep in mind; such as the name of migrated table, throttle params etc.
 // This gets printed at beginning and end of migration, every 10 minutes throughout
@@ -861,7 +1085,7 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 	fmt.Fprintf(w, "# Migrating %s.%s; Ghost table is %s.%s\n",
 		sql.EscapeName(this.migrationContext.DatabaseName),
 		sql.EscapeName(this.migrationContext.OriginalTableName),
-		sql.EscapeName(this.migrationContext.DatabaseName),
+		sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
 		sql.EscapeName(this.migrationContext.GetGhostTableName()),
 	)
 	fmt.Fprintf(w, "# Migrating %+v; inspecting %+v; executing on %+v\n",
@@ -869,6 +1093,28 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 		*this.inspector.connectionConfig.ImpliedKey,
 		this.migrationContext.Hostname,
 	)
+	if this.migrationContext.IsHorizontalSplit() {
+		fmt.Fprintf(w, "# Splitting rows by %s of %s onto shard tables %s\n",
+			this.migrationContext.ShardRouter.Method,
+			sql.EscapeName(this.migrationContext.ShardColumnName),
+			strings.Join(this.migrationContext.ShardTableNames, ", "),
+		)
+	}
+	if this.migrationContext.IsVerticalSplit() {
+		fmt.Fprintf(w, "# Side table is %s.%s; Side ghost table is %s.%s\n",
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.SideTableName),
+			sql.EscapeName(this.migrationContext.DatabaseName),
+			sql.EscapeName(this.migrationContext.GetSideGhostTableName()),
+		)
+	}
+	if this.migrationContext.IsCrossServerMigration() {
+		fmt.Fprintf(w, "# Handing off to %+v as %s.%s\n",
+			this.migrationContext.TargetConnectionConfig.Key,
+			sql.EscapeName(this.migrationContext.GetGhostDatabaseName()),
+			sql.EscapeName(this.migrationContext.GetTargetTableName()),
+		)
+	}
 	fmt.Fprintf(w, "# Migration started at %+v\n",
 		this.migrationContext.StartTime.Format(time.RubyDate),
 	)
@@ -910,6 +1156,11 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 			throttleControlReplicaKeys.Len(),
 		)
 	}
+	if targetThrottleControlReplicaKeys := this.migrationContext.GetTargetThrottleControlReplicaKeys(); targetThrottleControlReplicaKeys.Len() > 0 {
+		fmt.Fprintf(w, "# target-throttle-control-replicas count: %+v\n",
+			targetThrottleControlReplicaKeys.Len(),
+		)
+	}
 
 	if this.migrationContext.PostponeCutOverFlagFile != "" {
 		setIndicator := ""
@@ -920,6 +1171,9 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 			this.migrationContext.PostponeCutOverFlagFile, setIndicator,
 		)
 	}
+	if atomic.LoadInt64(&this.migrationContext.PostponeCutOverCommandedByHook) > 0 {
+		fmt.Fprintf(w, "# postpone-cut-over: commanded by hook\n")
+	}
 	if this.migrationContext.PanicFlagFile != "" {
 		fmt.Fprintf(w, "# panic-flag-file: %+v\n",
 			this.migrationContext.PanicFlagFile,
@@ -933,11 +1187,12 @@ func (this *Migrator) printMigrationStatusHint(writers ...io.Writer) {
 	}
 }
 
-// getProgressPercent returns an estimate of migration progess as a percent.
+// getProgressPercent returns an estimate of migration progess as a percent. Rows discarded by
+// the row filter (--where) count as progress, as the row estimate is that of the entire table.
 func (this *Migrator) getProgressPercent(rowsEstimate int64) (progressPct float64) {
 	progressPct = 100.0
 	if rowsEstimate > 0 {
-		progressPct *= float64(this.migrationContext.GetTotalRowsCopied()) / float64(rowsEstimate)
+		progressPct *= float64(this.migrationContext.GetTotalRowsIterated()) / float64(rowsEstimate)
 	}
 	return progressPct
 }
@@ -949,7 +1204,7 @@ func (this *Migrator) getMigrationETA(rowsEstimate int64) (eta string, duration
 	if progressPct >= 100.0 {
 		duration = 0
 	} else if progressPct >= 0.1 {
-		totalRowsCopied := this.migrationContext.GetTotalRowsCopied()
+		totalRowsCopied := this.migrationContext.GetTotalRowsIterated()
 		etaRowsPerSecond := atomic.LoadInt64(&this.migrationContext.EtaRowsPerSecond)
 		var etaSeconds float64
 		// If there is data available on our current row-copies-per-second rate, use it.
@@ -1045,11 +1300,11 @@ func (this *Migrator) printStatus(rule PrintStatusRule, writers ...io.Writer) {
 
 	elapsedTime := this.migrationContext.ElapsedTime()
 	elapsedSeconds := int64(elapsedTime.Seconds())
-	totalRowsCopied := this.migrationContext.GetTotalRowsCopied()
+	totalRowsCopied := this.migrationContext.GetTotalRowsIterated()
 	rowsEstimate := atomic.LoadInt64(&this.migrationContext.RowsEstimate) + atomic.LoadInt64(&this.migrationContext.RowsDeltaEstimate)
 	if atomic.LoadInt64(&this.rowCopyCompleteFlag) == 1 {
-		// Done copying rows. The totalRowsCopied value is the de-facto number of rows,
-		// and there is no further need to keep updating the value.
+		// Done copying rows. The totalRowsCopied value (including rows discarded by the row filter)
+		// is the de-facto number of rows, and there is no further need to keep updating the value.
 		rowsEstimate = totalRowsCopied
 	}
 
@@ -1083,6 +1338,22 @@ func (this *Migrator) printStatus(rule PrintStatusRule, writers ...io.Writer) {
 		state,
 		eta,
 	)
+	if partitionName := this.migrationContext.GetCopyPartitionName(); partitionName != "" && atomic.LoadInt64(&this.rowCopyCompleteFlag) == 0 {
+		ordinal, total := this.migrationContext.GetCopyPartitionProgress()
+		status = fmt.Sprintf("%s; Partition: %s (%d/%d)", status, partitionName, ordinal, total)
+	}
+	if this.migrationContext.RowFilter != nil {
+		status = fmt.Sprintf("%s; Discarded: %d", status, this.migrationContext.GetTotalRowsDiscarded())
+	}
+	if this.migrationContext.IsArchiving() {
+		status = fmt.Sprintf("%s; Archived: %d", status, this.migrationContext.GetTotalRowsArchived())
+	}
+	if this.cdcTap != nil {
+		status = fmt.Sprintf("%s; CDC tap: %d emitted, %d dropped", status, this.cdcTap.GetEmittedEvents(), this.cdcTap.GetDroppedEvents())
+	}
+	if hooksStatusMessage := this.migrationContext.GetHooksStatusMessage(); hooksStatusMessage != "" {
+		status = fmt.Sprintf("%s; Hook: %s", status, hooksStatusMessage)
+	}
 	this.applier.WriteChangelog(
 		fmt.Sprintf("copy iteration %d at %d", this.migrationContext.GetIteration(), time.Now().Unix()),
 		state,
@@ -1118,6 +1389,9 @@ func (this *Migrator) initiateStreaming() error {
 			return this.onChangelogEvent(dmlEvent)
 		},
 	)
+	if err := this.addDDLEventsListener(); err != nil {
+		return err
+	}
 
 	go func() {
 		this.migrationContext.Log.Debugf("Beginning streaming")
@@ -1149,6 +1423,10 @@ func (this *Migrator) addDMLEventsListener() error {
 		this.migrationContext.DatabaseName,
 		this.migrationContext.OriginalTableName,
 		func(dmlEvent *binlog.BinlogDMLEvent) error {
+			if this.cdcTap != nil {
+				// Emitted ahead of being applied, which may rewrite the event
+				this.cdcTap.Emit(dmlEvent)
+			}
 			this.applyEventsQueue <- newApplyEventStructByDML(dmlEvent)
 			return nil
 		},
@@ -1156,9 +1434,83 @@ func (this *Migrator) addDMLEventsListener() error {
 	return err
 }
 
+// initiateCDCTap creates the tap emitting the original table's row changes onto --cdc-tap, if given
+func (this *Migrator) initiateCDCTap() (err error) {
+	if this.migrationContext.CDCTapDestination == "" {
+		return nil
+	}
+	if this.cdcTap, err = NewCDCTap(this.migrationContext); err != nil {
+		return err
+	}
+	go this.cdcTap.Run()
+	this.migrationContext.Log.Infof("CDC tap: emitting row changes to %s", this.migrationContext.CDCTapDestination)
+	return nil
+}
+
+// addDDLEventsListener begins listening for structure changes of the original, ghost (and side or shard ghost) &
+// changelog tables, made by anyone other than gh-ost
+func (this *Migrator) addDDLEventsListener() error {
+	tableNames := []string{
+		this.migrationContext.OriginalTableName,
+		this.migrationContext.GetGhostTableName(),
+		this.migrationContext.GetChangelogTableName(),
+	}
+	if this.migrationContext.IsVerticalSplit() {
+		tableNames = append(tableNames, this.migrationContext.GetSideGhostTableName())
+	}
+	for i := range this.migrationContext.ShardTableNames {
+		tableNames = append(tableNames, this.migrationContext.GetShardGhostTableName(i))
+	}
+	for _, tableName := range tableNames {
+		if err := this.eventsStreamer.AddDDLListener(this.migrationContext.DatabaseName, tableName, this.onConcurrentDDL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onConcurrentDDL reacts to a DDL statement on a migrated table by aborting or throttling, as
+// configured by --concurrent-ddl-action. A change in the original table's number of columns
+// always aborts, since its binlog events can no longer be applied onto the ghost table.
+func (this *Migrator) onConcurrentDDL(ddlEvent *binlog.BinlogDDLEvent) error {
+	if ddlEvent.IsColumnCount() {
+		if !strings.EqualFold(ddlEvent.TableName, this.migrationContext.OriginalTableName) {
+			return nil
+		}
+		if ddlEvent.ColumnCount == this.migrationContext.OriginalTableColumns.Len() {
+			return nil
+		}
+		if this.migrationContext.GhostTableColumns != nil && ddlEvent.ColumnCount == this.migrationContext.GhostTableColumns.Len() {
+			// Once cut-over renames the ghost table onto the original table's name, some events may
+			// still be read before streaming stops
+			return nil
+		}
+		this.migrationContext.PanicAbort <- fmt.Errorf("Table %s.%s has %d columns on binlog events, but %d columns were inspected. It has been altered by another party. Aborting without cleanup",
+			sql.EscapeName(ddlEvent.DatabaseName), sql.EscapeName(ddlEvent.TableName), ddlEvent.ColumnCount, this.migrationContext.OriginalTableColumns.Len())
+		return nil
+	}
+
+	if !strings.EqualFold(ddlEvent.TableName, this.migrationContext.OriginalTableName) && strings.Contains(ddlEvent.Statement, "/* generated by server */") {
+		// Drops of gh-ost's own tables may be logged as rewritten by the server, without gh-ost's comment
+		return nil
+	}
+	concurrentDDL := fmt.Sprintf("%s.%s: %s", sql.EscapeName(ddlEvent.DatabaseName), sql.EscapeName(ddlEvent.TableName), ddlEvent.Statement)
+	switch this.migrationContext.ConcurrentDDLAction {
+	case base.ConcurrentDDLThrottle:
+		this.migrationContext.Log.Warningf("Concurrent DDL on %s. Throttling until acknowledged via the 'acknowledge-ddl' interactive command", concurrentDDL)
+		this.migrationContext.SetConcurrentDDL(concurrentDDL)
+		if this.applier != nil {
+			this.applier.WriteAndLogChangelog("concurrent-ddl", concurrentDDL)
+		}
+	default:
+		this.migrationContext.PanicAbort <- fmt.Errorf("Concurrent DDL on %s. Aborting without cleanup", concurrentDDL)
+	}
+	return nil
+}
+
 // initiateThrottler kicks in the throttling collection and the throttling checks.
 func (this *Migrator) initiateThrottler() {
-	this.throttler = NewThrottler(this.migrationContext, this.applier, this.inspector, this.appVersion)
+	this.throttler = NewThrottler(this.migrationContext, this.applier, this.inspector, this.hooksExecutor, this.appVersion)
 
 	go this.throttler.initiateThrottlerCollection(this.firstThrottlingCollected)
 	this.migrationContext.Log.Infof("Waiting for first throttle metrics to be collected")
@@ -1199,6 +1551,15 @@ func (this *Migrator) initiateApplier() error {
 			return err
 		}
 	}
+	if this.migrationContext.IsHorizontalSplit() && !this.migrationContext.Noop {
+		if err := this.applier.CreateShardGhostTables(); err != nil {
+			this.migrationContext.Log.Errorf("Unable to create shard ghost tables, see further error details. Bailing out")
+			return err
+		}
+	}
+	if err := this.hooksExecutor.onGhostTableCreated(); err != nil {
+		return err
+	}
 	this.applier.WriteChangelogState(string(GhostTableMigrated))
 	go this.applier.InitiateHeartbeat()
 	return nil
@@ -1244,6 +1605,18 @@ func (this *Migrator) iterateChunks() error {
 			}); err != nil {
 				return terminateRowIteration(err)
 			}
+			if !hasFurtherRange && this.migrationContext.CopyPerPartition {
+				// Done with this partition; move on to the next one, if any
+				if err := this.retryOperation(func() (e error) {
+					hasFurtherRange, e = this.applier.ReadNextPartitionRangeValues()
+					return e
+				}); err != nil {
+					return terminateRowIteration(err)
+				}
+				if hasFurtherRange {
+					return nil
+				}
+			}
 			if !hasFurtherRange {
 				atomic.StoreInt64(&hasNoFurtherRangeFlag, 1)
 				return terminateRowIteration(nil)
@@ -1261,11 +1634,12 @@ func (this *Migrator) iterateChunks() error {
 					// _ghost_ table, which no longer exists. So, bothering error messages and all, but no damage.
 					return nil
 				}
-				_, rowsAffected, _, err := this.applier.ApplyIterationInsertQuery()
+				_, rowsAffected, rowsDiscarded, _, err := this.applier.ApplyIterationInsertQuery()
 				if err != nil {
 					return err // wrapping call will retry
 				}
 				atomic.AddInt64(&this.migrationContext.TotalRowsCopied, rowsAffected)
+				atomic.AddInt64(&this.migrationContext.TotalRowsDiscarded, rowsDiscarded)
 				atomic.AddInt64(&this.migrationContext.Iteration, 1)
 				return nil
 			}
@@ -1384,6 +1758,46 @@ func (this *Migrator) executeWriteFuncs() error {
 	}
 }
 
+// addChildSideForeignKeysToGhost validates the ghost table rows against the original table's
+// child-side foreign keys, then creates those foreign keys on the ghost table. It runs within the
+// cut-over's locked section, once all binlog events are applied: until then the ghost table lags
+// the original table, and foreign keys on it could fail, or reject writes onto parent tables.
+func (this *Migrator) addChildSideForeignKeysToGhost() error {
+	if len(this.migrationContext.ChildSideForeignKeys) == 0 {
+		return nil
+	}
+	if err := this.applier.ValidateChildSideForeignKeysOnGhost(); err != nil {
+		return err
+	}
+	return this.applier.AddChildSideForeignKeysToGhost()
+}
+
+// rebindParentSideForeignKeys points foreign keys of child tables onto the ghost table, within the
+// cut-over's locked section and right before the swap. It is skipped when tables are swapped back.
+func (this *Migrator) rebindParentSideForeignKeys() error {
+	if len(this.migrationContext.ParentSideForeignKeys) == 0 {
+		return nil
+	}
+	if this.migrationContext.TestOnReplica {
+		this.migrationContext.Log.Infof("Not rebinding parent-side foreign keys: tables are swapped back")
+		return nil
+	}
+	return this.applier.RebindParentSideForeignKeys()
+}
+
+// createRenamedTableCompatView creates a view under the original table name, when the
+// table has been renamed by the migration and such view is requested
+func (this *Migrator) createRenamedTableCompatView() error {
+	if !this.migrationContext.RenameTableCompatView {
+		return nil
+	}
+	if this.migrationContext.Noop || this.migrationContext.TestOnReplica {
+		this.migrationContext.Log.Infof("Not creating compatibility view: tables were not swapped")
+		return nil
+	}
+	return this.retryOperation(this.applier.CreateRenamedTableCompatView)
+}
+
 // finalCleanup takes actions at very end of migration, dropping tables etc.
 func (this *Migrator) finalCleanup() error {
 	atomic.StoreInt64(&this.migrationContext.CleanupImminentFlag, 1)
@@ -1394,7 +1808,7 @@ func (this *Migrator) finalCleanup() error {
 	}
 
 	if this.migrationContext.Noop {
-		if createTableStatement, err := this.inspector.showCreateTable(this.migrationContext.GetGhostTableName()); err == nil {
+		if createTableStatement, err := this.inspector.showCreateGhostTable(); err == nil {
 			this.migrationContext.Log.Infof("New table structure follows")
 			fmt.Println(createTableStatement)
 		} else {
@@ -1404,6 +1818,14 @@ func (this *Migrator) finalCleanup() error {
 	if err := this.eventsStreamer.Close(); err != nil {
 		this.migrationContext.Log.Errore(err)
 	}
+	if this.cdcTap != nil {
+		if err := this.cdcTap.Close(); err != nil {
+			this.migrationContext.Log.Errore(err)
+		}
+	}
+	if err := this.applier.CloseArchive(); err != nil {
+		return err
+	}
 
 	if err := this.retryOperation(this.applier.DropChangelogTable); err != nil {
 		return err
@@ -1418,7 +1840,8 @@ func (this *Migrator) finalCleanup() error {
 			this.migrationContext.Log.Infof("-- drop table %s.%s", sql.EscapeName(this.migrationContext.DatabaseName), sql.EscapeName(this.migrationContext.GetOldTableName()))
 		}
 	}
-	if this.migrationContext.Noop {
+	// On a horizontal split, the ghost table merely served as the shard ghost tables' template
+	if this.migrationContext.Noop || this.migrationContext.IsHorizontalSplit() {
 		if err := this.retryOperation(this.applier.DropGhostTable); err != nil {
 			return err
 		}
@@ -1449,4 +1872,9 @@ func (this *Migrator) teardown() {
 		this.migrationContext.Log.Infof("Tearing down throttler")
 		this.throttler.Teardown()
 	}
+
+	if this.cdcTap != nil {
+		this.migrationContext.Log.Infof("Tearing down CDC tap")
+		this.cdcTap.Close()
+	}
 }
@@ -118,6 +118,56 @@ func TestMigratorOnChangelogEvent(t *testing.T) {
 	})
 }
 
+func TestMigratorOnConcurrentDDL(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tbl"
+	migrationContext.OriginalTableColumns = sql.NewColumnList([]string{"id", "name"})
+	migrationContext.GhostTableColumns = sql.NewColumnList([]string{"id", "name", "email"})
+	migrator := NewMigrator(migrationContext, "1.2.3")
+
+	expectAbort := func(t *testing.T, ddlEvent *binlog.BinlogDDLEvent) error {
+		go migrator.onConcurrentDDL(ddlEvent)
+		select {
+		case err := <-migrationContext.PanicAbort:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("expected abort")
+		}
+		return nil
+	}
+
+	t.Run("expected column counts", func(t *testing.T) {
+		require.NoError(t, migrator.onConcurrentDDL(binlog.NewBinlogColumnCountEvent("test", "tbl", 2)))
+		require.NoError(t, migrator.onConcurrentDDL(binlog.NewBinlogColumnCountEvent("test", "tbl", 3)))
+		require.NoError(t, migrator.onConcurrentDDL(binlog.NewBinlogColumnCountEvent("test", "_tbl_gho", 5)))
+	})
+
+	t.Run("changed column count", func(t *testing.T) {
+		migrationContext.ConcurrentDDLAction = base.ConcurrentDDLThrottle
+		err := expectAbort(t, binlog.NewBinlogColumnCountEvent("test", "tbl", 4))
+		require.ErrorContains(t, err, "has 4 columns on binlog events, but 2 columns were inspected")
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		migrationContext.ConcurrentDDLAction = base.ConcurrentDDLAbort
+		err := expectAbort(t, binlog.NewBinlogDDLEvent("test", "tbl", "alter table tbl add index(name)"))
+		require.ErrorContains(t, err, "Concurrent DDL on `test`.`tbl`: alter table tbl add index(name)")
+	})
+
+	t.Run("throttle", func(t *testing.T) {
+		migrationContext.ConcurrentDDLAction = base.ConcurrentDDLThrottle
+		require.NoError(t, migrator.onConcurrentDDL(binlog.NewBinlogDDLEvent("test", "tbl", "alter table tbl add index(name)")))
+		require.Equal(t, "`test`.`tbl`: alter table tbl add index(name)", migrationContext.AcknowledgeConcurrentDDL())
+	})
+
+	t.Run("server generated drop of gh-ost tables", func(t *testing.T) {
+		migrationContext.ConcurrentDDLAction = base.ConcurrentDDLThrottle
+		require.NoError(t, migrator.onConcurrentDDL(binlog.NewBinlogDDLEvent("test", "_tbl_gho", "DROP TABLE IF EXISTS `_tbl_gho` /* generated by server */")))
+		require.Equal(t, "", migrationContext.GetConcurrentDDL())
+	})
+}
+
 func TestMigratorValidateStatement(t *testing.T) {
 	t.Run("add-column", func(t *testing.T) {
 		migrationContext := base.NewMigrationContext()
@@ -162,13 +212,47 @@ func TestMigratorValidateStatement(t *testing.T) {
 
 	t.Run("rename-table", func(t *testing.T) {
 		migrationContext := base.NewMigrationContext()
+		migrationContext.DatabaseName = "test"
+		migrationContext.OriginalTableName = "test"
 		migrator := NewMigrator(migrationContext, "1.2.3")
-		require.Nil(t, migrator.parser.ParseAlterStatement(`ALTER TABLE test RENAME TO test_new`))
+		require.Nil(t, migrator.parser.ParseAlterStatement(`ALTER TABLE test ADD COLUMN c int, RENAME TO test_new`))
+
+		require.Nil(t, migrator.validateAlterStatement())
+		require.Equal(t, "test_new", migrator.migrationContext.RenamedTableName)
+		require.Equal(t, "test_new", migrator.migrationContext.GetMigratedTableName())
+		require.Equal(t, "ADD COLUMN c int", migrator.migrationContext.AlterStatementOptions)
+		require.Len(t, migrator.migrationContext.DroppedColumnsMap, 0)
+	})
+
+	t.Run("rename-table-other-schema", func(t *testing.T) {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.DatabaseName = "test"
+		migrationContext.OriginalTableName = "test"
+		migrator := NewMigrator(migrationContext, "1.2.3")
+		require.Nil(t, migrator.parser.ParseAlterStatement(`ALTER TABLE test RENAME TO other.test_new`))
 
 		err := migrator.validateAlterStatement()
 		require.Error(t, err)
 		require.True(t, errors.Is(err, ErrMigratorUnsupportedRenameAlter))
-		require.Len(t, migrator.migrationContext.DroppedColumnsMap, 0)
+	})
+
+	t.Run("partition-maintenance", func(t *testing.T) {
+		migrationContext := base.NewMigrationContext()
+		migrator := NewMigrator(migrationContext, "1.2.3")
+		require.Nil(t, migrator.parser.ParseAlterStatement(`ALTER TABLE test TRUNCATE PARTITION p0`))
+
+		err := migrator.validateAlterStatement()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "TRUNCATE PARTITION")
+	})
+
+	t.Run("rename-table-compat-view-without-rename", func(t *testing.T) {
+		migrationContext := base.NewMigrationContext()
+		migrationContext.RenameTableCompatView = true
+		migrator := NewMigrator(migrationContext, "1.2.3")
+		require.Nil(t, migrator.parser.ParseAlterStatement(`ALTER TABLE test ADD COLUMN c int`))
+
+		require.Error(t, migrator.validateAlterStatement())
 	})
 }
 
@@ -200,6 +284,10 @@ func TestMigratorGetProgressPercent(t *testing.T) {
 		migrationContext.TotalRowsCopied = 250
 		require.Equal(t, float64(25.0), migrator.getProgressPercent(1000))
 	}
+	{
+		migrationContext.TotalRowsDiscarded = 500
+		require.Equal(t, float64(75.0), migrator.getProgressPercent(1000))
+	}
 }
 
 func TestMigratorGetMigrationStateAndETA(t *testing.T) {
@@ -32,6 +32,7 @@ var (
 )
 
 type printStatusFunc func(PrintStatusRule, io.Writer)
+type reloadConfigFunc func() ([]base.ConfigChange, error)
 
 // Server listens for requests on a socket file or via TCP
 type Server struct {
@@ -40,14 +41,16 @@ type Server struct {
 	tcpListener      net.Listener
 	hooksExecutor    *HooksExecutor
 	printStatus      printStatusFunc
+	reloadConfig     reloadConfigFunc
 	isCPUProfiling   int64
 }
 
-func NewServer(migrationContext *base.MigrationContext, hooksExecutor *HooksExecutor, printStatus printStatusFunc) *Server {
+func NewServer(migrationContext *base.MigrationContext, hooksExecutor *HooksExecutor, printStatus printStatusFunc, reloadConfig reloadConfigFunc) *Server {
 	return &Server{
 		migrationContext: migrationContext,
 		hooksExecutor:    hooksExecutor,
 		printStatus:      printStatus,
+		reloadConfig:     reloadConfig,
 	}
 }
 
@@ -220,9 +223,11 @@ max-load=<load>                      # Set a new set of max-load thresholds
 throttle-query=<query>               # Set a new throttle-query (no quotes)
 throttle-http=<URL>                  # Set a new throttle URL
 throttle-control-replicas=<replicas> # Set a new comma delimited list of throttle control replicas
+reload-config                        # Re-read the config file and apply chunk-size, dml-batch-size, nice-ratio, max-load, critical-load, max-lag-millis & throttle settings
 throttle                             # Force throttling
 no-throttle                          # End forced throttling (other throttling may still apply)
 unpostpone                           # Bail out a cut-over postpone; proceed to cut-over
+acknowledge-ddl                      # Acknowledge a concurrent DDL on the migrated tables; end the throttling it caused
 panic                                # panic and quit without cleanup
 help                                 # This message
 - use '?' (question mark) as argument to get info rather than set. e.g. "max-load=?" will just print out current max-load.
@@ -374,6 +379,21 @@ help                                 # This message
 			fmt.Fprintf(writer, "%s\n", this.migrationContext.GetThrottleControlReplicaKeys().ToCommaDelimitedList())
 			return ForcePrintStatusAndHintRule, nil
 		}
+	case "reload-config":
+		{
+			changes, err := this.reloadConfig()
+			if err != nil {
+				return NoPrintStatusRule, err
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(writer, "No changes")
+				return NoPrintStatusRule, nil
+			}
+			for _, change := range changes {
+				fmt.Fprintln(writer, change.String())
+			}
+			return ForcePrintStatusAndHintRule, nil
+		}
 	case "throttle", "pause", "suspend":
 		{
 			if arg != "" && arg != this.migrationContext.OriginalTableName {
@@ -414,6 +434,21 @@ help                                 # This message
 			fmt.Fprintf(writer, "You may only invoke this when gh-ost is actively postponing migration. At this time it is not.\n")
 			return NoPrintStatusRule, nil
 		}
+	case "acknowledge-ddl", "ack-ddl":
+		{
+			if arg != "" && arg != this.migrationContext.OriginalTableName {
+				// User explicitly provided table name. This is a courtesy protection mechanism
+				err := fmt.Errorf("User commanded 'acknowledge-ddl' on %s, but migrated table is %s; ignoring request.", arg, this.migrationContext.OriginalTableName)
+				return NoPrintStatusRule, err
+			}
+			if concurrentDDL := this.migrationContext.AcknowledgeConcurrentDDL(); concurrentDDL != "" {
+				this.migrationContext.Log.Infof("User acknowledged concurrent DDL: %s", concurrentDDL)
+				fmt.Fprintf(writer, "Acknowledged: %s\n", concurrentDDL)
+				return ForcePrintStatusAndHintRule, nil
+			}
+			fmt.Fprintf(writer, "No concurrent DDL awaiting acknowledgement.\n")
+			return NoPrintStatusRule, nil
+		}
 	case "panic":
 		{
 			if arg == "" && this.migrationContext.ForceNamedPanicCommand {
@@ -1,6 +1,9 @@
 package logic
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"testing"
 	"time"
 
@@ -66,3 +69,78 @@ func TestServerRunCPUProfile(t *testing.T) {
 		require.Equal(t, int64(0), s.isCPUProfiling)
 	})
 }
+
+func TestServerReloadConfig(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	hooksExecutor := NewHooksExecutor(migrationContext)
+
+	t.Run("changes", func(t *testing.T) {
+		s := NewServer(migrationContext, hooksExecutor, nil, func() ([]base.ConfigChange, error) {
+			return []base.ConfigChange{{Key: "chunk-size", OldValue: "1000", NewValue: "500"}}, nil
+		})
+		var buf bytes.Buffer
+		writer := bufio.NewWriter(&buf)
+		rule, err := s.applyServerCommand("reload-config", writer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush())
+		require.Equal(t, PrintStatusRule(ForcePrintStatusAndHintRule), rule)
+		require.Equal(t, "chunk-size: \"1000\" => \"500\"\n", buf.String())
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		s := NewServer(migrationContext, hooksExecutor, nil, func() ([]base.ConfigChange, error) {
+			return nil, nil
+		})
+		var buf bytes.Buffer
+		writer := bufio.NewWriter(&buf)
+		rule, err := s.applyServerCommand("reload-config", writer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush())
+		require.Equal(t, NoPrintStatusRule, rule)
+		require.Equal(t, "No changes\n", buf.String())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		s := NewServer(migrationContext, hooksExecutor, nil, func() ([]base.ConfigChange, error) {
+			return nil, errors.New("invalid config")
+		})
+		rule, err := s.applyServerCommand("reload-config", bufio.NewWriter(&bytes.Buffer{}))
+		require.Error(t, err)
+		require.Equal(t, NoPrintStatusRule, rule)
+	})
+}
+
+func TestServerAcknowledgeDDL(t *testing.T) {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.OriginalTableName = "tbl"
+	s := NewServer(migrationContext, NewHooksExecutor(migrationContext), nil, nil)
+
+	t.Run("nothing to acknowledge", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := bufio.NewWriter(&buf)
+		rule, err := s.applyServerCommand("acknowledge-ddl", writer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush())
+		require.Equal(t, NoPrintStatusRule, rule)
+		require.Equal(t, "No concurrent DDL awaiting acknowledgement.\n", buf.String())
+	})
+
+	t.Run("other table", func(t *testing.T) {
+		migrationContext.SetConcurrentDDL("alter table tbl add index(name)")
+		_, err := s.applyServerCommand("acknowledge-ddl=other", bufio.NewWriter(&bytes.Buffer{}))
+		require.Error(t, err)
+		require.Equal(t, "alter table tbl add index(name)", migrationContext.GetConcurrentDDL())
+	})
+
+	t.Run("acknowledge", func(t *testing.T) {
+		migrationContext.SetConcurrentDDL("alter table tbl add index(name)")
+		var buf bytes.Buffer
+		writer := bufio.NewWriter(&buf)
+		rule, err := s.applyServerCommand("ack-ddl=tbl", writer)
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush())
+		require.Equal(t, PrintStatusRule(ForcePrintStatusAndHintRule), rule)
+		require.Equal(t, "Acknowledged: alter table tbl add index(name)\n", buf.String())
+		require.Equal(t, "", migrationContext.GetConcurrentDDL())
+	})
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/github/gh-ost/go/binlog"
 	"github.com/github/gh-ost/go/mysql"
 
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/openark/golib/sqlutils"
 )
 
@@ -24,6 +25,7 @@ type BinlogEventListener struct {
 	databaseName string
 	tableName    string
 	onDmlEvent   func(event *binlog.BinlogDMLEvent) error
+	onDdlEvent   func(event *binlog.BinlogDDLEvent) error
 }
 
 const (
@@ -48,7 +50,7 @@ type EventsStreamer struct {
 
 func NewEventsStreamer(migrationContext *base.MigrationContext) *EventsStreamer {
 	return &EventsStreamer{
-		connectionConfig: migrationContext.InspectorConnectionConfig,
+		connectionConfig: migrationContext.GetBinlogConnectionConfig(),
 		migrationContext: migrationContext,
 		listeners:        [](*BinlogEventListener){},
 		listenersMutex:   &sync.Mutex{},
@@ -79,6 +81,27 @@ func (this *EventsStreamer) AddListener(
 	return nil
 }
 
+// AddDDLListener registers a new listener for structure changes on given table: DDL statements
+// and column counts. The listener is notified synchronously.
+func (this *EventsStreamer) AddDDLListener(databaseName string, tableName string, onDdlEvent func(event *binlog.BinlogDDLEvent) error) (err error) {
+	this.listenersMutex.Lock()
+	defer this.listenersMutex.Unlock()
+
+	if databaseName == "" {
+		return fmt.Errorf("Empty database name in AddDDLListener")
+	}
+	if tableName == "" {
+		return fmt.Errorf("Empty table name in AddDDLListener")
+	}
+	listener := &BinlogEventListener{
+		databaseName: databaseName,
+		tableName:    tableName,
+		onDdlEvent:   onDdlEvent,
+	}
+	this.listeners = append(this.listeners, listener)
+	return nil
+}
+
 // notifyListeners will notify relevant listeners with given DML event. Only
 // listeners registered for changes on the table on which the DML operates are notified.
 func (this *EventsStreamer) notifyListeners(binlogEvent *binlog.BinlogDMLEvent) {
@@ -87,6 +110,9 @@ func (this *EventsStreamer) notifyListeners(binlogEvent *binlog.BinlogDMLEvent)
 
 	for _, listener := range this.listeners {
 		listener := listener
+		if listener.onDmlEvent == nil {
+			continue
+		}
 		if !strings.EqualFold(listener.databaseName, binlogEvent.DatabaseName) {
 			continue
 		}
@@ -103,9 +129,34 @@ func (this *EventsStreamer) notifyListeners(binlogEvent *binlog.BinlogDMLEvent)
 	}
 }
 
+// notifyDDLListeners will notify the DDL listeners of the table given DDL event is on
+func (this *EventsStreamer) notifyDDLListeners(binlogEvent *binlog.BinlogDDLEvent) {
+	this.listenersMutex.Lock()
+	defer this.listenersMutex.Unlock()
+
+	for _, listener := range this.listeners {
+		if listener.onDdlEvent == nil {
+			continue
+		}
+		if !strings.EqualFold(listener.databaseName, binlogEvent.DatabaseName) {
+			continue
+		}
+		if !strings.EqualFold(listener.tableName, binlogEvent.TableName) {
+			continue
+		}
+		if err := listener.onDdlEvent(binlogEvent); err != nil {
+			this.migrationContext.Log.Errore(err)
+		}
+	}
+}
+
 func (this *EventsStreamer) InitDBConnections() (err error) {
-	EventsStreamerUri := this.connectionConfig.GetDBUri(this.migrationContext.DatabaseName)
-	if this.db, _, err = mysql.GetDB(this.migrationContext.Uuid, EventsStreamerUri); err != nil {
+	databaseName := this.migrationContext.DatabaseName
+	if this.usesSeparateBinlogConnection() {
+		// a replication user need not have any privileges on the migrated database
+		databaseName = ""
+	}
+	if this.db, _, err = mysql.GetConnectionConfigDB(this.migrationContext.Uuid, this.name, this.connectionConfig, databaseName, ""); err != nil {
 		return err
 	}
 	version, err := base.ValidateConnection(this.db, this.connectionConfig, this.migrationContext, this.name)
@@ -113,6 +164,12 @@ func (this *EventsStreamer) InitDBConnections() (err error) {
 		return err
 	}
 	this.dbVersion = version
+	this.migrationContext.BinlogMySQLVersion = version
+	if this.usesSeparateBinlogConnection() {
+		if err := this.validateBinlogConnection(); err != nil {
+			return err
+		}
+	}
 	if err := this.readCurrentBinlogCoordinates(); err != nil {
 		return err
 	}
@@ -123,10 +180,142 @@ func (this *EventsStreamer) InitDBConnections() (err error) {
 	return nil
 }
 
+// usesSeparateBinlogConnection is true when binlogs are read from a server or as a user
+// other than the inspector's
+func (this *EventsStreamer) usesSeparateBinlogConnection() bool {
+	return this.connectionConfig != this.migrationContext.InspectorConnectionConfig
+}
+
+// validateBinlogConnection validates the binlog server when it is not the inspected server: its
+// binlogs must be ROW based with a supported row image, and must include the changes seen by the
+// inspector, as gh-ost maps its changelog writes onto binlog coordinates of the binlog server.
+func (this *EventsStreamer) validateBinlogConnection() error {
+	key := this.connectionConfig.Key.String()
+
+	var hasBinaryLogs, logReplicaUpdates bool
+	var binlogFormat, binlogRowImage string
+	query := `select /* gh-ost */ @@global.log_bin, @@global.binlog_format, @@global.binlog_row_image`
+	if err := this.db.QueryRow(query).Scan(&hasBinaryLogs, &binlogFormat, &binlogRowImage); err != nil {
+		return err
+	}
+	if !hasBinaryLogs {
+		return fmt.Errorf("Binlog server %s must have binary logs enabled", key)
+	}
+	if strings.ToUpper(binlogFormat) != "ROW" {
+		return fmt.Errorf("Binlog server %s has %s binlog_format; it must be ROW", key, binlogFormat)
+	}
+	switch strings.ToUpper(binlogRowImage) {
+	case "FULL", "MINIMAL", "NOBLOB":
+	default:
+		return fmt.Errorf("Binlog server %s has '%s' binlog_row_image, and only 'FULL', 'MINIMAL' and 'NOBLOB' are supported", key, binlogRowImage)
+	}
+	if mysql.IsMariaDB(this.dbVersion) {
+		if err := mysql.ValidateMariaDBBinlogCompression(this.db, key); err != nil {
+			return err
+		}
+	}
+
+	applierConnectionConfig := this.migrationContext.ApplierConnectionConfig
+	isMaster := applierConnectionConfig != nil && applierConnectionConfig.Key.Equals(&this.connectionConfig.Key)
+	if !isMaster {
+		query = fmt.Sprintf(`select /* gh-ost */ @@global.%s`, mysql.ReplicaTermFor(this.dbVersion, "log_slave_updates"))
+		if err := this.db.QueryRow(query).Scan(&logReplicaUpdates); err != nil {
+			return err
+		}
+		if !logReplicaUpdates {
+			return fmt.Errorf("Binlog server %s is not the master, and has log_slave_updates disabled; its binary logs do not include the migrated changes", key)
+		}
+	}
+
+	inspectorDB, _, err := mysql.GetConnectionConfigDB(this.migrationContext.Uuid, "inspector", this.migrationContext.InspectorConnectionConfig, this.migrationContext.DatabaseName, "")
+	if err != nil {
+		return err
+	}
+	if mysql.IsMariaDB(this.dbVersion) != mysql.IsMariaDB(this.migrationContext.InspectorMySQLVersion) {
+		return fmt.Errorf("Binlog server %s runs %s, but inspected server %s runs %s; they must be both MySQL or both MariaDB", key, this.dbVersion, this.migrationContext.InspectorConnectionConfig.Key.String(), this.migrationContext.InspectorMySQLVersion)
+	}
+	if mysql.IsMariaDB(this.dbVersion) {
+		return this.validateMariaDBBinlogServer(inspectorDB)
+	}
+	var inspectorServerUUID, inspectorGTIDMode, inspectorGTIDExecuted string
+	query = `select /* gh-ost */ @@global.server_uuid, @@global.gtid_mode, @@global.gtid_executed`
+	if err := inspectorDB.QueryRow(query).Scan(&inspectorServerUUID, &inspectorGTIDMode, &inspectorGTIDExecuted); err != nil {
+		return err
+	}
+	var serverUUID, gtidMode, gtidExecuted string
+	if err := this.db.QueryRow(query).Scan(&serverUUID, &gtidMode, &gtidExecuted); err != nil {
+		return err
+	}
+	if serverUUID == inspectorServerUUID {
+		this.migrationContext.Log.Infof("Binlog server %s is the inspected server", key)
+		return nil
+	}
+	if strings.ToUpper(gtidMode) != "ON" || strings.ToUpper(inspectorGTIDMode) != "ON" {
+		this.migrationContext.Log.Warningf("Cannot verify binlog server %s replicates the same changes as inspected server %s without gtid_mode=ON on both; make sure it does", key, this.migrationContext.InspectorConnectionConfig.Key.String())
+		return nil
+	}
+	// Either server may lag behind the other, but one's executed GTIDs must contain the other's
+	var isSubset, isSuperset bool
+	query = `select /* gh-ost */ gtid_subset(?, ?), gtid_subset(?, ?)`
+	if err := this.db.QueryRow(query, inspectorGTIDExecuted, gtidExecuted, gtidExecuted, inspectorGTIDExecuted).Scan(&isSubset, &isSuperset); err != nil {
+		return err
+	}
+	if !isSubset && !isSuperset {
+		return fmt.Errorf("Binlog server %s and inspected server %s have diverging gtid_executed; they do not replicate the same changes", key, this.migrationContext.InspectorConnectionConfig.Key.String())
+	}
+	this.migrationContext.Log.Infof("Binlog server %s validated against inspected server %s", key, this.migrationContext.InspectorConnectionConfig.Key.String())
+	return nil
+}
+
+// validateMariaDBBinlogServer validates the binlog server replicates the same changes as the inspected
+// server, on MariaDB: there is no server_uuid, and GTIDs, which MariaDB always assigns, are compared
+// per replication domain
+func (this *EventsStreamer) validateMariaDBBinlogServer(inspectorDB *gosql.DB) error {
+	key := this.connectionConfig.Key.String()
+
+	var inspectorServerId, serverId uint
+	var inspectorGTIDCurrentPos, gtidCurrentPos string
+	query := `select /* gh-ost */ @@global.server_id, @@global.gtid_current_pos`
+	if err := inspectorDB.QueryRow(query).Scan(&inspectorServerId, &inspectorGTIDCurrentPos); err != nil {
+		return err
+	}
+	if err := this.db.QueryRow(query).Scan(&serverId, &gtidCurrentPos); err != nil {
+		return err
+	}
+	if serverId == inspectorServerId {
+		this.migrationContext.Log.Infof("Binlog server %s is the inspected server", key)
+		return nil
+	}
+	inspectorGTIDSet, err := gomysql.ParseMariadbGTIDSet(inspectorGTIDCurrentPos)
+	if err != nil {
+		return err
+	}
+	gtidSet, err := gomysql.ParseMariadbGTIDSet(gtidCurrentPos)
+	if err != nil {
+		return err
+	}
+	// Either server may lag behind the other, but one's GTID position must contain the other's
+	if !gtidSet.Contain(inspectorGTIDSet) && !inspectorGTIDSet.Contain(gtidSet) {
+		return fmt.Errorf("Binlog server %s and inspected server %s have diverging gtid_current_pos; they do not replicate the same changes", key, this.migrationContext.InspectorConnectionConfig.Key.String())
+	}
+	this.migrationContext.Log.Infof("Binlog server %s validated against inspected server %s", key, this.migrationContext.InspectorConnectionConfig.Key.String())
+	return nil
+}
+
 // initBinlogReader creates and connects the reader: we hook up to a MySQL server as a replica
 func (this *EventsStreamer) initBinlogReader(binlogCoordinates *mysql.BinlogCoordinates) error {
 	goMySQLReader := binlog.NewGoMySQLReader(this.migrationContext)
-	if err := goMySQLReader.ConnectBinlogStreamer(*binlogCoordinates); err != nil {
+	err := goMySQLReader.ConnectBinlogStreamer(*binlogCoordinates)
+	if mysql.IsAccessDeniedError(err) && this.connectionConfig.OnAccessDenied != nil {
+		// Credentials may have been rotated; refresh and try again with the new credentials
+		goMySQLReader.Close()
+		if refreshErr := this.connectionConfig.OnAccessDenied(); refreshErr != nil {
+			return err
+		}
+		goMySQLReader = binlog.NewGoMySQLReader(this.migrationContext)
+		err = goMySQLReader.ConnectBinlogStreamer(*binlogCoordinates)
+	}
+	if err != nil {
 		return err
 	}
 	this.binlogReader = goMySQLReader
@@ -173,6 +362,9 @@ func (this *EventsStreamer) StreamEvents(canStopStreaming func() bool) error {
 			if binlogEntry.DmlEvent != nil {
 				this.notifyListeners(binlogEntry.DmlEvent)
 			}
+			if binlogEntry.DdlEvent != nil {
+				this.notifyDDLListeners(binlogEntry.DdlEvent)
+			}
 		}
 	}()
 	// The next should block and execute forever, unless there's a serious error
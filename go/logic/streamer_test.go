@@ -151,6 +151,80 @@ func (suite *EventsStreamerTestSuite) TestStreamEvents() {
 	suite.Require().Len(dmlEvents, 3)
 }
 
+func (suite *EventsStreamerTestSuite) TestStreamDDLEvents() {
+	ctx := context.Background()
+
+	_, err := suite.db.ExecContext(ctx, "CREATE TABLE test.testing (id INT PRIMARY KEY, name VARCHAR(255))")
+	suite.Require().NoError(err)
+
+	connectionConfig, err := GetConnectionConfig(ctx, suite.mysqlContainer)
+	suite.Require().NoError(err)
+
+	migrationContext := base.NewMigrationContext()
+	migrationContext.ApplierConnectionConfig = connectionConfig
+	migrationContext.InspectorConnectionConfig = connectionConfig
+	migrationContext.DatabaseName = "test"
+	migrationContext.SkipPortValidation = true
+	migrationContext.ReplicaServerId = 99999
+
+	migrationContext.SetConnectionConfig("innodb")
+
+	streamer := NewEventsStreamer(migrationContext)
+
+	err = streamer.InitDBConnections()
+	suite.Require().NoError(err)
+	defer streamer.Close()
+	defer streamer.Teardown()
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+
+	ddlEvents := make([]*binlog.BinlogDDLEvent, 0)
+	err = streamer.AddDDLListener("test", "testing", func(event *binlog.BinlogDDLEvent) error {
+		ddlEvents = append(ddlEvents, event)
+
+		// Stop once we've seen the column count change following the ALTER
+		if len(ddlEvents) == 3 {
+			cancel()
+		}
+
+		return nil
+	})
+	suite.Require().NoError(err)
+
+	group := errgroup.Group{}
+	group.Go(func() error {
+		return streamer.StreamEvents(func() bool {
+			return streamCtx.Err() != nil
+		})
+	})
+
+	group.Go(func() error {
+		for _, query := range []string{
+			"INSERT INTO test.testing (id, name) VALUES (1, 'foo')",
+			"ALTER /* gh-ost */ TABLE test.testing ADD COLUMN ignored INT",
+			"ALTER TABLE test.testing ADD COLUMN email VARCHAR(255)",
+			"INSERT INTO test.testing (id, name) VALUES (2, 'bar')",
+			// Need to write another event to hit the canStopStreaming function again
+			"INSERT INTO test.testing (id, name) VALUES (3, 'baz')",
+		} {
+			if _, err := suite.db.ExecContext(ctx, query); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	err = group.Wait()
+	suite.Require().NoError(err)
+
+	suite.Require().Len(ddlEvents, 3)
+	suite.Require().True(ddlEvents[0].IsColumnCount())
+	suite.Require().Equal(2, ddlEvents[0].ColumnCount)
+	suite.Require().Equal("ALTER TABLE test.testing ADD COLUMN email VARCHAR(255)", ddlEvents[1].Statement)
+	suite.Require().True(ddlEvents[2].IsColumnCount())
+	suite.Require().Equal(4, ddlEvents[2].ColumnCount)
+}
+
 func (suite *EventsStreamerTestSuite) TestStreamEventsAutomaticallyReconnects() {
 	ctx := context.Background()
 
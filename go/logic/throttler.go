@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -36,6 +37,8 @@ var (
 		500: "freno: internal error",
 		-1:  "freno: connection error",
 	}
+	// Numeric values within a throttle reason, e.g. the 1.5 of lag=1.5s
+	throttleReasonValueRegexp = regexp.MustCompile(`[0-9]+([.][0-9]+)?`)
 )
 
 const frenoMagicHint = "freno"
@@ -49,10 +52,11 @@ type Throttler struct {
 	httpClient        *http.Client
 	httpClientTimeout time.Duration
 	inspector         *Inspector
+	hooksExecutor     *HooksExecutor
 	finishedMigrating int64
 }
 
-func NewThrottler(migrationContext *base.MigrationContext, applier *Applier, inspector *Inspector, appVersion string) *Throttler {
+func NewThrottler(migrationContext *base.MigrationContext, applier *Applier, inspector *Inspector, hooksExecutor *HooksExecutor, appVersion string) *Throttler {
 	return &Throttler{
 		appVersion:        appVersion,
 		migrationContext:  migrationContext,
@@ -60,6 +64,7 @@ func NewThrottler(migrationContext *base.MigrationContext, applier *Applier, ins
 		httpClient:        &http.Client{},
 		httpClientTimeout: time.Duration(migrationContext.ThrottleHTTPTimeoutMillis) * time.Millisecond,
 		inspector:         inspector,
+		hooksExecutor:     hooksExecutor,
 		finishedMigrating: 0,
 	}
 }
@@ -112,6 +117,15 @@ func (this *Throttler) shouldThrottle() (result bool, reason string, reasonHint
 			return true, fmt.Sprintf("%+v replica-lag=%fs", lagResult.Key, lagResult.Lag.Seconds()), base.NoThrottleReasonHint
 		}
 	}
+	if this.migrationContext.IsCrossServerMigration() {
+		lagResult := this.migrationContext.GetTargetReplicasLagResult()
+		if lagResult.Err != nil {
+			return true, fmt.Sprintf("target replica %+v %+v", lagResult.Key, lagResult.Err), base.NoThrottleReasonHint
+		}
+		if lagResult.Lag > time.Duration(maxLagMillisecondsThrottleThreshold)*time.Millisecond {
+			return true, fmt.Sprintf("%+v target-replica-lag=%fs", lagResult.Key, lagResult.Lag.Seconds()), base.NoThrottleReasonHint
+		}
+	}
 	// Got here? No metrics indicates we need throttling.
 	return false, "", base.NoThrottleReasonHint
 }
@@ -192,10 +206,8 @@ func (this *Throttler) collectControlReplicasLag() {
 	)
 
 	readReplicaLag := func(connectionConfig *mysql.ConnectionConfig) (lag time.Duration, err error) {
-		dbUri := connectionConfig.GetDBUri("information_schema")
-
 		var heartbeatValue string
-		db, _, err := mysql.GetDB(this.migrationContext.Uuid, dbUri)
+		db, _, err := mysql.GetConnectionConfigDB(this.migrationContext.Uuid, "throttler", connectionConfig, "information_schema", "")
 		if err != nil {
 			return lag, err
 		}
@@ -208,14 +220,27 @@ func (this *Throttler) collectControlReplicasLag() {
 		return lag, err
 	}
 
-	readControlReplicasLag := func() (result *mysql.ReplicationLagResult) {
-		instanceKeyMap := this.migrationContext.GetThrottleControlReplicaKeys()
+	// Replicas of the target server (--target-throttle-control-replicas) do not hold the changelog
+	// table, hence their lag is read off their replication status
+	readTargetReplicaLag := func(connectionConfig *mysql.ConnectionConfig) (lag time.Duration, err error) {
+		var version string
+		db, _, err := mysql.GetConnectionConfigDB(this.migrationContext.Uuid, "throttler", connectionConfig, "information_schema", "")
+		if err != nil {
+			return lag, err
+		}
+		if err := db.QueryRow(`select @@global.version`).Scan(&version); err != nil {
+			return lag, err
+		}
+		return mysql.GetReplicationLagFromSlaveStatus(version, db)
+	}
+
+	readReplicasLag := func(instanceKeyMap *mysql.InstanceKeyMap, credentialsConfig *mysql.ConnectionConfig, readReplicaLag func(*mysql.ConnectionConfig) (time.Duration, error)) (result *mysql.ReplicationLagResult) {
 		if instanceKeyMap.Len() == 0 {
 			return result
 		}
 		lagResults := make(chan *mysql.ReplicationLagResult, instanceKeyMap.Len())
 		for replicaKey := range *instanceKeyMap {
-			connectionConfig := this.migrationContext.InspectorConnectionConfig.DuplicateCredentials(replicaKey)
+			connectionConfig := credentialsConfig.DuplicateCredentials(replicaKey)
 			if err := connectionConfig.RegisterTLSConfig(); err != nil {
 				return &mysql.ReplicationLagResult{Err: err}
 			}
@@ -244,7 +269,10 @@ func (this *Throttler) collectControlReplicasLag() {
 			// No need to read lag
 			return
 		}
-		this.migrationContext.SetControlReplicasLagResult(readControlReplicasLag())
+		this.migrationContext.SetControlReplicasLagResult(readReplicasLag(this.migrationContext.GetThrottleControlReplicaKeys(), this.migrationContext.InspectorConnectionConfig, readReplicaLag))
+		if this.migrationContext.IsCrossServerMigration() {
+			this.migrationContext.SetTargetReplicasLagResult(readReplicasLag(this.migrationContext.GetTargetThrottleControlReplicaKeys(), this.migrationContext.TargetConnectionConfig, readTargetReplicaLag))
+		}
 	}
 
 	relaxedFactor := 10
@@ -376,10 +404,14 @@ func (this *Throttler) collectGeneralThrottleMetrics() error {
 		hibernateUntilTime := time.Now().Add(hibernateDuration)
 		atomic.StoreInt64(&this.migrationContext.HibernateUntil, hibernateUntilTime.UnixNano())
 		this.migrationContext.Log.Errorf("critical-load met: %s=%d, >=%d. Will hibernate for the duration of %+v, until %+v", variableName, value, threshold, hibernateDuration, hibernateUntilTime)
+		this.hooksExecutor.executeInBackground(onHibernationBegin, func() error {
+			return this.hooksExecutor.onHibernationBegin(variableName, value, threshold, hibernateUntilTime)
+		})
 		go func() {
 			time.Sleep(hibernateDuration)
 			this.migrationContext.SetThrottleGeneralCheckResult(base.NewThrottleCheckResult(true, "leaving hibernation", base.LeavingHibernationThrottleReasonHint))
 			atomic.StoreInt64(&this.migrationContext.HibernateUntil, 0)
+			this.hooksExecutor.executeInBackground(onHibernationEnd, this.hooksExecutor.onHibernationEnd)
 		}()
 		return nil
 	}
@@ -404,6 +436,12 @@ func (this *Throttler) collectGeneralThrottleMetrics() error {
 	if atomic.LoadInt64(&this.migrationContext.ThrottleCommandedByUser) > 0 {
 		return setThrottle(true, "commanded by user", base.UserCommandThrottleReasonHint)
 	}
+	if atomic.LoadInt64(&this.migrationContext.ThrottleCommandedByHook) > 0 {
+		return setThrottle(true, "commanded by hook", base.NoThrottleReasonHint)
+	}
+	if concurrentDDL := this.migrationContext.GetConcurrentDDL(); concurrentDDL != "" {
+		return setThrottle(true, fmt.Sprintf("concurrent DDL awaiting acknowledgement: %s", concurrentDDL), base.NoThrottleReasonHint)
+	}
 	if this.migrationContext.ThrottleFlagFile != "" {
 		if base.FileExists(this.migrationContext.ThrottleFlagFile) {
 			// Throttle file defined and exists!
@@ -460,20 +498,37 @@ func (this *Throttler) initiateThrottlerCollection(firstThrottlingCollected chan
 	}()
 }
 
+// throttleReasonKind returns a throttle reason with its numeric values removed, e.g. lag=s for lag=1.5s,
+// so that reasons of the same kind compare equal as their values change
+func throttleReasonKind(reason string) string {
+	return throttleReasonValueRegexp.ReplaceAllString(reason, "")
+}
+
 // initiateThrottlerChecks initiates the throttle ticker and sets the basic behavior of throttling.
 func (this *Throttler) initiateThrottlerChecks() {
+	throttleChanged := func(throttled bool, reason string) {
+		this.hooksExecutor.executeInBackground(onThrottleChange, func() error {
+			return this.hooksExecutor.onThrottleChange(throttled, reason)
+		})
+	}
 	throttlerFunction := func() {
-		alreadyThrottling, currentReason, _ := this.migrationContext.IsThrottled()
+		alreadyThrottling, currentReason, currentReasonHint := this.migrationContext.IsThrottled()
 		shouldThrottle, throttleReason, throttleReasonHint := this.shouldThrottle()
 		if shouldThrottle && !alreadyThrottling {
 			// New throttling
 			this.applier.WriteAndLogChangelog("throttle", throttleReason)
+			throttleChanged(true, throttleReason)
 		} else if shouldThrottle && alreadyThrottling && (currentReason != throttleReason) {
-			// Change of reason
+			// Change of reason. The hook only runs on a change of the reason's kind or hint, as
+			// the values of a reason such as lag=1.5s change on most every check
 			this.applier.WriteAndLogChangelog("throttle", throttleReason)
+			if currentReasonHint != throttleReasonHint || throttleReasonKind(currentReason) != throttleReasonKind(throttleReason) {
+				throttleChanged(true, throttleReason)
+			}
 		} else if alreadyThrottling && !shouldThrottle {
 			// End of throttling
 			this.applier.WriteAndLogChangelog("throttle", "done throttling")
+			throttleChanged(false, "done throttling")
 		}
 		this.migrationContext.SetThrottled(shouldThrottle, throttleReason, throttleReasonHint)
 	}
@@ -0,0 +1,21 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleReasonKind(t *testing.T) {
+	require.Equal(t, throttleReasonKind("lag=1.500000s"), throttleReasonKind("lag=12.000000s"))
+	require.Equal(t, throttleReasonKind("max-load Threads_running=51 >= 50"), throttleReasonKind("max-load Threads_running=80 >= 50"))
+	require.NotEqual(t, throttleReasonKind("lag=1.500000s"), throttleReasonKind("max-load Threads_running=51 >= 50"))
+	require.NotEqual(t, throttleReasonKind("max-load Threads_running=51 >= 50"), throttleReasonKind("max-load Threads_connected=51 >= 50"))
+	require.NotEqual(t, throttleReasonKind("lag=1.500000s"), throttleReasonKind("commanded by user"))
+	require.Equal(t, "commanded by user", throttleReasonKind("commanded by user"))
+}
@@ -0,0 +1,167 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openark/golib/log"
+)
+
+const (
+	webhookEventHeader     = "X-Gh-Ost-Event"
+	webhookSignatureHeader = "X-Gh-Ost-Signature"
+)
+
+// webhookPayload is the JSON body POSTed to webhooks. Variables are the same
+// GH_OST_* variables that hook executables get in their environment.
+type webhookPayload struct {
+	Event     string            `json:"event"`
+	Timestamp time.Time         `json:"timestamp"`
+	Variables map[string]string `json:"variables"`
+}
+
+// validateWebhooks validates the webhook configuration, ahead of any hook being called
+func (this *HooksExecutor) validateWebhooks() error {
+	for _, webhookURL := range splitHooksList(this.migrationContext.HooksWebhookURLs) {
+		u, err := url.ParseRequestURI(webhookURL)
+		if err != nil {
+			return fmt.Errorf("--hooks-webhook-url: invalid URL %q: %+v", webhookURL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("--hooks-webhook-url: unsupported scheme in %q; expecting http or https", webhookURL)
+		}
+	}
+	if err := validateHooksList("hooks-webhook-events", this.migrationContext.HooksWebhookEvents, knownHooks); err != nil {
+		return err
+	}
+	if err := validateHooksList("hooks-webhook-fail-on-error", this.migrationContext.HooksWebhookFailOnError, knownHooks); err != nil {
+		return err
+	}
+	if this.migrationContext.HooksWebhookTimeoutMillis <= 0 {
+		return fmt.Errorf("--hooks-webhook-timeout-millis must be greater than 0")
+	}
+	if this.migrationContext.HooksWebhookRetries < 0 {
+		return fmt.Errorf("--hooks-webhook-retries must not be negative")
+	}
+	return nil
+}
+
+// buildWebhookPayload builds the JSON payload for given hook
+func (this *HooksExecutor) buildWebhookPayload(baseName string, extraVariables ...string) ([]byte, error) {
+	payload := webhookPayload{
+		Event:     baseName,
+		Timestamp: time.Now(),
+		Variables: make(map[string]string),
+	}
+	for _, variable := range this.hookVariables(extraVariables...) {
+		tokens := strings.SplitN(variable, "=", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		value := tokens[1]
+		// Some variables are single-quoted for the sake of shell scripts
+		if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+			value = value[1 : len(value)-1]
+		}
+		payload.Variables[tokens[0]] = value
+	}
+	return json.Marshal(payload)
+}
+
+// postWebhook POSTs a payload to a webhook, once
+func (this *HooksExecutor) postWebhook(webhookURL string, baseName string, body []byte) error {
+	timeout := time.Duration(this.migrationContext.HooksWebhookTimeoutMillis) * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, baseName)
+	if secret := this.migrationContext.HooksWebhookSecret; secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned http=%d", webhookURL, resp.StatusCode)
+	}
+	if responseBody = bytes.TrimSpace(responseBody); bytes.HasPrefix(responseBody, []byte("{")) {
+		response, err := parseHookResponse(responseBody)
+		if err != nil {
+			log.Warningf("%+v webhook %s: ignoring invalid response: %+v", baseName, webhookURL, err)
+			return nil
+		}
+		this.applyHookResponse(baseName, response)
+	}
+	return nil
+}
+
+// executeWebhook POSTs a payload to a webhook, retrying on failure
+func (this *HooksExecutor) executeWebhook(webhookURL string, baseName string, body []byte) (err error) {
+	for i := 0; i <= int(this.migrationContext.HooksWebhookRetries); i++ {
+		if i != 0 {
+			RetrySleepFn(1 * time.Second)
+		}
+		if err = this.postWebhook(webhookURL, baseName, body); err == nil {
+			return nil
+		}
+		log.Warningf("%+v webhook %s failed: %+v", baseName, webhookURL, err)
+	}
+	return err
+}
+
+// executeWebhooks POSTs the hook's payload to all configured webhooks, in order.
+// A webhook failing after all retries fails the hook only when the hook is listed in
+// --hooks-webhook-fail-on-error; otherwise the failure is logged and ignored.
+func (this *HooksExecutor) executeWebhooks(baseName string, extraVariables ...string) error {
+	webhookURLs := splitHooksList(this.migrationContext.HooksWebhookURLs)
+	if len(webhookURLs) == 0 {
+		return nil
+	}
+	if this.migrationContext.HooksWebhookEvents != "" && !hooksListContains(this.migrationContext.HooksWebhookEvents, baseName) {
+		return nil
+	}
+	body, err := this.buildWebhookPayload(baseName, extraVariables...)
+	if err != nil {
+		return log.Errore(err)
+	}
+	for _, webhookURL := range webhookURLs {
+		log.Infof("executing %+v webhook: %+v", baseName, webhookURL)
+		if err := this.executeWebhook(webhookURL, baseName, body); err != nil {
+			if hooksListContains(this.migrationContext.HooksWebhookFailOnError, baseName) {
+				return log.Errore(err)
+			}
+			log.Errorf("%+v webhook %s failed; ignoring as per --hooks-webhook-fail-on-error: %+v", baseName, webhookURL, err)
+		}
+	}
+	return nil
+}
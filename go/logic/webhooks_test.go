@@ -0,0 +1,153 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package logic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/gh-ost/go/base"
+)
+
+func newWebhooksTestContext(serverURL string) *base.MigrationContext {
+	migrationContext := base.NewMigrationContext()
+	migrationContext.AlterStatement = "ENGINE=InnoDB"
+	migrationContext.DatabaseName = "test"
+	migrationContext.OriginalTableName = "tablename"
+	migrationContext.HooksWebhookURLs = serverURL
+	migrationContext.HooksWebhookTimeoutMillis = 1000
+	migrationContext.HooksWebhookRetries = 2
+	return migrationContext
+}
+
+func TestHooksExecutorExecuteWebhooks(t *testing.T) {
+	origRetrySleepFn := RetrySleepFn
+	defer func() { RetrySleepFn = origRetrySleepFn }()
+	RetrySleepFn = func(time.Duration) {}
+
+	t.Run("payload", func(t *testing.T) {
+		var event, signature string
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			event = r.Header.Get(webhookEventHeader)
+			signature = r.Header.Get(webhookSignatureHeader)
+			body, _ = io.ReadAll(r.Body)
+		}))
+		defer server.Close()
+
+		migrationContext := newWebhooksTestContext(server.URL)
+		migrationContext.HooksWebhookSecret = "s3cr3t"
+		hooksExecutor := NewHooksExecutor(migrationContext)
+		require.Nil(t, hooksExecutor.validateWebhooks())
+		require.Nil(t, hooksExecutor.onStatus("all is well"))
+
+		require.Equal(t, onStatus, event)
+		mac := hmac.New(sha256.New, []byte("s3cr3t"))
+		mac.Write(body)
+		require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+
+		var payload webhookPayload
+		require.Nil(t, json.Unmarshal(body, &payload))
+		require.Equal(t, onStatus, payload.Event)
+		require.Equal(t, "test", payload.Variables["GH_OST_DATABASE_NAME"])
+		require.Equal(t, "tablename", payload.Variables["GH_OST_TABLE_NAME"])
+		require.Equal(t, "_tablename_gho", payload.Variables["GH_OST_GHOST_TABLE_NAME"])
+		require.Equal(t, "ENGINE=InnoDB", payload.Variables["GH_OST_DDL"])
+		require.Equal(t, "all is well", payload.Variables["GH_OST_STATUS"])
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		var requests int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt64(&requests, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext(server.URL))
+		hooksExecutor.migrationContext.HooksWebhookFailOnError = "all"
+		require.Nil(t, hooksExecutor.onStartup())
+		require.Equal(t, int64(3), atomic.LoadInt64(&requests))
+	})
+
+	t.Run("fail-on-error", func(t *testing.T) {
+		var requests int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&requests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext(server.URL))
+		hooksExecutor.migrationContext.HooksWebhookFailOnError = onBeforeCutOver
+		require.Nil(t, hooksExecutor.onStartup())
+		require.Equal(t, int64(3), atomic.LoadInt64(&requests))
+		require.NotNil(t, hooksExecutor.onBeforeCutOver())
+		require.Equal(t, int64(6), atomic.LoadInt64(&requests))
+	})
+
+	t.Run("response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"postpone-cut-over": true, "max-lag-millis": 2000}`))
+		}))
+		defer server.Close()
+
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext(server.URL))
+		require.Nil(t, hooksExecutor.onBeforeCutOver())
+		require.Equal(t, int64(1), atomic.LoadInt64(&hooksExecutor.migrationContext.PostponeCutOverCommandedByHook))
+		require.Equal(t, int64(2000), atomic.LoadInt64(&hooksExecutor.migrationContext.MaxLagMillisecondsThrottleThreshold))
+	})
+
+	t.Run("events", func(t *testing.T) {
+		var requests int64
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&requests, 1)
+			require.Equal(t, onSuccess, r.Header.Get(webhookEventHeader))
+		}))
+		defer server.Close()
+
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext(server.URL + ", " + server.URL))
+		hooksExecutor.migrationContext.HooksWebhookEvents = onSuccess
+		require.Nil(t, hooksExecutor.onStartup())
+		require.Nil(t, hooksExecutor.onSuccess())
+		require.Equal(t, int64(2), atomic.LoadInt64(&requests))
+	})
+}
+
+func TestHooksExecutorValidateWebhooks(t *testing.T) {
+	{
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext(""))
+		require.Nil(t, hooksExecutor.validateWebhooks())
+	}
+	{
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext("ftp://example.com/hook"))
+		require.Error(t, hooksExecutor.validateWebhooks())
+	}
+	{
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext("https://example.com/hook"))
+		hooksExecutor.migrationContext.HooksWebhookEvents = "gh-ost-on-success,gh-ost-on-no-such-hook"
+		require.Error(t, hooksExecutor.validateWebhooks())
+	}
+	{
+		hooksExecutor := NewHooksExecutor(newWebhooksTestContext("https://example.com/hook"))
+		hooksExecutor.migrationContext.HooksWebhookFailOnError = "all"
+		hooksExecutor.migrationContext.HooksWebhookTimeoutMillis = 0
+		require.Error(t, hooksExecutor.validateWebhooks())
+	}
+}
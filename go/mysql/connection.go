@@ -13,6 +13,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/go-sql-driver/mysql"
 )
@@ -31,6 +32,11 @@ type ConnectionConfig struct {
 	Timeout              float64
 	TransactionIsolation string
 	Charset              string
+	// OnAccessDenied, when set, is called when a new connection is denied access, after which
+	// the connection is attempted once more. It is expected to refresh the credentials.
+	OnAccessDenied func() error
+	// credentialsMutex guards credentials & TLS config, which may be rotated while connections are in use
+	credentialsMutex sync.Mutex
 }
 
 func NewConnectionConfig() *ConnectionConfig {
@@ -43,6 +49,9 @@ func NewConnectionConfig() *ConnectionConfig {
 
 // DuplicateCredentials creates a new connection config with given key and with same credentials as this config
 func (this *ConnectionConfig) DuplicateCredentials(key InstanceKey) *ConnectionConfig {
+	this.credentialsMutex.Lock()
+	defer this.credentialsMutex.Unlock()
+
 	config := &ConnectionConfig{
 		Key:                  key,
 		User:                 this.User,
@@ -51,6 +60,7 @@ func (this *ConnectionConfig) DuplicateCredentials(key InstanceKey) *ConnectionC
 		Timeout:              this.Timeout,
 		TransactionIsolation: this.TransactionIsolation,
 		Charset:              this.Charset,
+		OnAccessDenied:       this.OnAccessDenied,
 	}
 
 	if this.tlsConfig != nil {
@@ -71,7 +81,25 @@ func (this *ConnectionConfig) Duplicate() *ConnectionConfig {
 }
 
 func (this *ConnectionConfig) String() string {
-	return fmt.Sprintf("%s, user=%s, usingTLS=%t", this.Key.DisplayString(), this.User, this.tlsConfig != nil)
+	user, _ := this.GetCredentials()
+	return fmt.Sprintf("%s, user=%s, usingTLS=%t", this.Key.DisplayString(), user, this.TLSConfig() != nil)
+}
+
+// GetCredentials returns the user & password, safe to call while credentials are being rotated
+func (this *ConnectionConfig) GetCredentials() (user string, password string) {
+	this.credentialsMutex.Lock()
+	defer this.credentialsMutex.Unlock()
+
+	return this.User, this.Password
+}
+
+// SetCredentials sets the user & password. Connections opened from now on use them.
+func (this *ConnectionConfig) SetCredentials(user string, password string) {
+	this.credentialsMutex.Lock()
+	defer this.credentialsMutex.Unlock()
+
+	this.User = user
+	this.Password = password
 }
 
 func (this *ConnectionConfig) Equals(other *ConnectionConfig) bool {
@@ -106,34 +134,63 @@ func (this *ConnectionConfig) UseTLS(caCertificatePath, clientCertificate, clien
 		certs = []tls.Certificate{cert}
 	}
 
+	this.credentialsMutex.Lock()
 	this.tlsConfig = &tls.Config{
 		ServerName:         this.Key.Hostname,
 		Certificates:       certs,
 		RootCAs:            rootCertPool,
 		InsecureSkipVerify: allowInsecure,
 	}
+	this.credentialsMutex.Unlock()
+
+	return this.RegisterTLSConfig()
+}
+
+// CopyTLSConfig sets this config's TLS config to a copy of given config's, for this config's
+// server, and registers it. A nil TLS config on given config disables TLS on this config.
+func (this *ConnectionConfig) CopyTLSConfig(other *ConnectionConfig) error {
+	otherTLSConfig := other.TLSConfig()
+
+	this.credentialsMutex.Lock()
+	this.tlsConfig = nil
+	if otherTLSConfig != nil {
+		this.tlsConfig = &tls.Config{
+			ServerName:         this.Key.Hostname,
+			Certificates:       otherTLSConfig.Certificates,
+			RootCAs:            otherTLSConfig.RootCAs,
+			InsecureSkipVerify: otherTLSConfig.InsecureSkipVerify,
+		}
+	}
+	this.credentialsMutex.Unlock()
 
 	return this.RegisterTLSConfig()
 }
 
 func (this *ConnectionConfig) RegisterTLSConfig() error {
-	if this.tlsConfig == nil {
+	tlsConfig := this.TLSConfig()
+	if tlsConfig == nil {
 		return nil
 	}
-	if this.tlsConfig.ServerName == "" {
+	if tlsConfig.ServerName == "" {
 		return errors.New("tlsConfig.ServerName cannot be empty")
 	}
 
-	var tlsOption = GetDBTLSConfigKey(this.tlsConfig.ServerName)
+	var tlsOption = GetDBTLSConfigKey(tlsConfig.ServerName)
 
-	return mysql.RegisterTLSConfig(tlsOption, this.tlsConfig)
+	return mysql.RegisterTLSConfig(tlsOption, tlsConfig)
 }
 
 func (this *ConnectionConfig) TLSConfig() *tls.Config {
+	this.credentialsMutex.Lock()
+	defer this.credentialsMutex.Unlock()
+
 	return this.tlsConfig
 }
 
 func (this *ConnectionConfig) GetDBUri(databaseName string) string {
+	this.credentialsMutex.Lock()
+	defer this.credentialsMutex.Unlock()
+
 	hostname := this.Key.Hostname
 	var ip = net.ParseIP(hostname)
 	if (ip != nil) && (ip.To4() == nil) {
@@ -0,0 +1,105 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	"context"
+	gosql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-sql-driver/mysql"
+)
+
+// ER_ACCESS_DENIED_ERROR
+const accessDeniedErrorCode = 1045
+
+// connectionConfigConnector is a driver.Connector which opens each connection using the
+// credentials & TLS config of its connection config as of connection time
+type connectionConfigConnector struct {
+	connectionConfig atomic.Pointer[ConnectionConfig]
+	databaseName     string
+	extraParams      string
+}
+
+// knownConnectors are the connectors of DBs in the knownDBs cache, by the same cache key
+var knownConnectors = make(map[string]*connectionConfigConnector)
+
+func (this *connectionConfigConnector) connect(ctx context.Context, connectionConfig *ConnectionConfig) (driver.Conn, error) {
+	dsnConfig, err := mysql.ParseDSN(connectionConfig.GetDBUri(this.databaseName) + this.extraParams)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(dsnConfig)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (this *connectionConfigConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connectionConfig := this.connectionConfig.Load()
+	conn, err := this.connect(ctx, connectionConfig)
+	if IsAccessDeniedError(err) && connectionConfig.OnAccessDenied != nil {
+		if refreshErr := connectionConfig.OnAccessDenied(); refreshErr != nil {
+			return nil, err
+		}
+		return this.connect(ctx, this.connectionConfig.Load())
+	}
+	return conn, err
+}
+
+func (this *connectionConfigConnector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
+
+// GetConnectionConfigDB returns a DB pool to given connection config's server & database, cached by
+// name, server, database and extra URI params. Connections are opened with the connection config's
+// credentials and TLS config as of connection time, such that rotated credentials and certificates
+// apply to new connections without rebuilding the pool. Given connection config replaces that of an
+// already cached pool.
+func GetConnectionConfigDB(migrationUuid string, name string, connectionConfig *ConnectionConfig, databaseName string, extraParams string) (db *gosql.DB, exists bool, err error) {
+	cacheKey := fmt.Sprintf("%s:%s:%s/%s?%s", migrationUuid, name, connectionConfig.Key.StringCode(), databaseName, extraParams)
+
+	knownDBsMutex.Lock()
+	defer knownDBsMutex.Unlock()
+
+	if db, exists = knownDBs[cacheKey]; exists {
+		knownConnectors[cacheKey].connectionConfig.Store(connectionConfig)
+		return db, exists, nil
+	}
+	connector := &connectionConfigConnector{
+		databaseName: databaseName,
+		extraParams:  extraParams,
+	}
+	connector.connectionConfig.Store(connectionConfig)
+	db = gosql.OpenDB(connector)
+	db.SetMaxOpenConns(MaxDBPoolConnections)
+	db.SetMaxIdleConns(MaxDBPoolConnections)
+	knownDBs[cacheKey] = db
+	knownConnectors[cacheKey] = connector
+	return db, exists, nil
+}
+
+// IsAccessDeniedError returns true when given error is a MySQL access denied error, as
+// returned by either the SQL driver or the binlog reader
+func IsAccessDeniedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var driverError *mysql.MySQLError
+	if errors.As(err, &driverError) {
+		return driverError.Number == accessDeniedErrorCode
+	}
+	var binlogReaderError *gomysql.MyError
+	if errors.As(err, &binlogReaderError) {
+		return binlogReaderError.Code == accessDeniedErrorCode
+	}
+	return false
+}
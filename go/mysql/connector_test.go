@@ -0,0 +1,135 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAccessDeniedError(t *testing.T) {
+	require.False(t, IsAccessDeniedError(nil))
+	require.False(t, IsAccessDeniedError(errors.New("access denied")))
+	require.True(t, IsAccessDeniedError(&mysql.MySQLError{Number: 1045, Message: "Access denied"}))
+	require.True(t, IsAccessDeniedError(fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1045})))
+	require.False(t, IsAccessDeniedError(&mysql.MySQLError{Number: 1064}))
+	require.True(t, IsAccessDeniedError(&gomysql.MyError{Code: 1045}))
+}
+
+func TestConnectionConfigCredentials(t *testing.T) {
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
+	c.SetCredentials("gromit", "penguin")
+	user, password := c.GetCredentials()
+	require.Equal(t, "gromit", user)
+	require.Equal(t, "penguin", password)
+	require.Contains(t, c.GetDBUri("test"), "gromit:penguin@tcp(myhost:3306)/test")
+
+	c.SetCredentials("wallace", "cheese")
+	require.Contains(t, c.GetDBUri("test"), "wallace:cheese@tcp(myhost:3306)/test")
+	require.Equal(t, "myhost:3306, user=wallace, usingTLS=false", c.String())
+}
+
+func TestGetConnectionConfigDB(t *testing.T) {
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "myhost", Port: 3306}
+	c.SetCredentials("gromit", "penguin")
+
+	db, exists, err := GetConnectionConfigDB(t.Name(), "inspector", c, "test", "")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// credentials are not part of the cache key
+	c.SetCredentials("wallace", "cheese")
+	cachedDB, exists, err := GetConnectionConfigDB(t.Name(), "inspector", c, "test", "")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, db, cachedDB)
+
+	otherDB, exists, err := GetConnectionConfigDB(t.Name(), "applier", c, "test", "")
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.NotEqual(t, db, otherDB)
+
+	// a cached pool takes the given connection config
+	duplicate := c.DuplicateCredentials(c.Key)
+	_, exists, err = GetConnectionConfigDB(t.Name(), "inspector", duplicate, "test", "")
+	require.NoError(t, err)
+	require.True(t, exists)
+	knownDBsMutex.Lock()
+	connector := knownConnectors[fmt.Sprintf("%s:inspector:myhost:3306/test?", t.Name())]
+	knownDBsMutex.Unlock()
+	require.Equal(t, duplicate, connector.connectionConfig.Load())
+}
+
+func TestConnectionConfigConnectorAccessDenied(t *testing.T) {
+	// A fake server which denies access to anyone: it sends a minimal handshake, and
+	// responds to the handshake response with an ER_ACCESS_DENIED_ERROR packet
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go denyAccess(conn)
+		}
+	}()
+
+	var refreshes int
+	c := NewConnectionConfig()
+	c.Key = InstanceKey{Hostname: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+	c.SetCredentials("gromit", "penguin")
+	c.Timeout = 1
+	c.OnAccessDenied = func() error {
+		refreshes++
+		c.SetCredentials("wallace", "cheese")
+		return nil
+	}
+	connector := &connectionConfigConnector{databaseName: "test"}
+	connector.connectionConfig.Store(c)
+	_, err = connector.Connect(context.Background())
+	require.True(t, IsAccessDeniedError(err))
+	require.Equal(t, 1, refreshes)
+}
+
+func denyAccess(conn net.Conn) {
+	defer conn.Close()
+	writePacket := func(sequence byte, payload []byte) {
+		header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), sequence}
+		conn.Write(append(header, payload...))
+	}
+	// protocol 10 handshake: version, connection id, auth data, capabilities
+	handshake := []byte{10}
+	handshake = append(handshake, []byte("8.0.0-fake\x00")...)
+	handshake = append(handshake, 1, 0, 0, 0)
+	handshake = append(handshake, []byte("abcdefgh\x00")...)
+	handshake = append(handshake, 0xff, 0xf7)          // capability flags, lower
+	handshake = append(handshake, 0xff, 0x02, 0x00)    // charset, status
+	handshake = append(handshake, 0xff, 0x81)          // capability flags, upper (incl. CLIENT_PLUGIN_AUTH)
+	handshake = append(handshake, 21)                  // auth data length
+	handshake = append(handshake, make([]byte, 10)...) // reserved
+	handshake = append(handshake, []byte("ijklmnopqrst\x00")...)
+	handshake = append(handshake, []byte("mysql_native_password\x00")...)
+	writePacket(0, handshake)
+
+	buf := make([]byte, 4096)
+	if _, err := conn.Read(buf); err != nil {
+		return
+	}
+	errPacket := []byte{0xff, 0x15, 0x04} // 1045
+	errPacket = append(errPacket, []byte("#28000Access denied")...)
+	writePacket(2, errPacket)
+}
@@ -0,0 +1,40 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// IsMariaDB is true when given @@version is that of a MariaDB server, e.g. "10.6.16-MariaDB-log"
+func IsMariaDB(mysqlVersion string) bool {
+	return strings.Contains(strings.ToLower(mysqlVersion), "mariadb")
+}
+
+// BinlogFlavorFor returns the binlog flavor of a server of given @@version
+func BinlogFlavorFor(mysqlVersion string) string {
+	if IsMariaDB(mysqlVersion) {
+		return gomysql.MariaDBFlavor
+	}
+	return gomysql.MySQLFlavor
+}
+
+// ValidateMariaDBBinlogCompression checks a MariaDB server does not compress its binlog events
+// (log_bin_compress), which the binlog reader cannot parse
+func ValidateMariaDBBinlogCompression(db *gosql.DB, key string) error {
+	var logBinCompress bool
+	if err := db.QueryRow(`select /* gh-ost */ @@global.log_bin_compress`).Scan(&logBinCompress); err != nil {
+		return err
+	}
+	if logBinCompress {
+		return fmt.Errorf("%s has log_bin_compress enabled, and compressed binlog events are not supported. You may `set global log_bin_compress=OFF` and try again", key)
+	}
+	return nil
+}
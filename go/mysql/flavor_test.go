@@ -0,0 +1,36 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package mysql
+
+import (
+	"testing"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMariaDB(t *testing.T) {
+	require.True(t, IsMariaDB("10.6.16-MariaDB-1:10.6.16+maria~ubu2004-log"))
+	require.True(t, IsMariaDB("11.4.2-MariaDB"))
+	require.False(t, IsMariaDB("8.0.40"))
+	require.False(t, IsMariaDB("8.4.3-percona"))
+	require.False(t, IsMariaDB(""))
+}
+
+func TestBinlogFlavorFor(t *testing.T) {
+	require.Equal(t, gomysql.MariaDBFlavor, BinlogFlavorFor("10.11.6-MariaDB-log"))
+	require.Equal(t, gomysql.MySQLFlavor, BinlogFlavorFor("8.0.40"))
+	require.Equal(t, gomysql.MySQLFlavor, BinlogFlavorFor(""))
+}
+
+func TestReplicaTermFor(t *testing.T) {
+	require.Equal(t, "slave status", ReplicaTermFor("8.0.40", "slave status"))
+	require.Equal(t, "replica status", ReplicaTermFor("8.4.3", "slave status"))
+	require.Equal(t, "Seconds_Behind_Source", ReplicaTermFor("9.1.0", "Seconds_Behind_Master"))
+	require.Equal(t, "slave status", ReplicaTermFor("10.6.16-MariaDB-log", "slave status"))
+	require.Equal(t, "Seconds_Behind_Master", ReplicaTermFor("11.4.2-MariaDB", "Seconds_Behind_Master"))
+	require.Equal(t, "log_slave_updates", ReplicaTermFor("11.4.2-MariaDB", "log_slave_updates"))
+}
@@ -18,6 +18,7 @@ var MysqlReplicaTermMap = map[string]string{
 	"Relay_Master_Log_File": "Relay_Source_Log_File",
 	"Slave_IO_Running":      "Replica_IO_Running",
 	"Slave_SQL_Running":     "Replica_SQL_Running",
+	"log_slave_updates":     "log_replica_updates",
 	"master status":         "binary log status",
 	"slave hosts":           "replicas",
 	"slave status":          "replica status",
@@ -25,6 +26,11 @@ var MysqlReplicaTermMap = map[string]string{
 }
 
 func ReplicaTermFor(mysqlVersion string, term string) string {
+	if IsMariaDB(mysqlVersion) {
+		// MariaDB versions are not comparable with MySQL's, and MariaDB keeps supporting the
+		// original terms, as statements, variables and SHOW SLAVE STATUS columns
+		return term
+	}
 	vs, err := version.NewVersion(mysqlVersion)
 	if err != nil {
 		// default to returning the same term if we cannot determine the version
@@ -37,6 +37,20 @@ type Trigger struct {
 	Timing    string
 }
 
+// ForeignKey describes a foreign key constraint: the child table holding the constraint,
+// and the parent table it references
+type ForeignKey struct {
+	Name                  string
+	TableSchema           string
+	TableName             string
+	Columns               []string
+	ReferencedTableSchema string
+	ReferencedTableName   string
+	ReferencedColumns     []string
+	UpdateRule            string
+	DeleteRule            string
+}
+
 func NewNoReplicationLagResult() *ReplicationLagResult {
 	return &ReplicationLagResult{Lag: 0, Err: nil}
 }
@@ -252,3 +266,65 @@ func GetTriggers(db *gosql.DB, databaseName, tableName string) (triggers []Trigg
 	}
 	return triggers, nil
 }
+
+// getForeignKeys reads foreign keys matching the given KEY_COLUMN_USAGE condition
+func getForeignKeys(db *gosql.DB, condition string, args ...interface{}) (foreignKeys []ForeignKey, err error) {
+	query := fmt.Sprintf(`
+		SELECT /* gh-ost */
+			KEY_COLUMN_USAGE.CONSTRAINT_NAME,
+			KEY_COLUMN_USAGE.TABLE_SCHEMA,
+			KEY_COLUMN_USAGE.TABLE_NAME,
+			KEY_COLUMN_USAGE.COLUMN_NAME,
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_SCHEMA,
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_NAME,
+			KEY_COLUMN_USAGE.REFERENCED_COLUMN_NAME,
+			REFERENTIAL_CONSTRAINTS.UPDATE_RULE,
+			REFERENTIAL_CONSTRAINTS.DELETE_RULE
+		FROM
+			INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+			JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS ON (
+				REFERENTIAL_CONSTRAINTS.CONSTRAINT_SCHEMA = KEY_COLUMN_USAGE.CONSTRAINT_SCHEMA
+				AND REFERENTIAL_CONSTRAINTS.CONSTRAINT_NAME = KEY_COLUMN_USAGE.CONSTRAINT_NAME
+				AND REFERENTIAL_CONSTRAINTS.TABLE_NAME = KEY_COLUMN_USAGE.TABLE_NAME
+			)
+		WHERE
+			KEY_COLUMN_USAGE.REFERENCED_TABLE_NAME IS NOT NULL
+			AND %s
+		ORDER BY
+			KEY_COLUMN_USAGE.TABLE_SCHEMA,
+			KEY_COLUMN_USAGE.TABLE_NAME,
+			KEY_COLUMN_USAGE.CONSTRAINT_NAME,
+			KEY_COLUMN_USAGE.ORDINAL_POSITION`, condition)
+
+	err = sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
+		name := m.GetString("CONSTRAINT_NAME")
+		tableSchema := m.GetString("TABLE_SCHEMA")
+		tableName := m.GetString("TABLE_NAME")
+		if len(foreignKeys) == 0 || foreignKeys[len(foreignKeys)-1].Name != name || foreignKeys[len(foreignKeys)-1].TableSchema != tableSchema || foreignKeys[len(foreignKeys)-1].TableName != tableName {
+			foreignKeys = append(foreignKeys, ForeignKey{
+				Name:                  name,
+				TableSchema:           tableSchema,
+				TableName:             tableName,
+				ReferencedTableSchema: m.GetString("REFERENCED_TABLE_SCHEMA"),
+				ReferencedTableName:   m.GetString("REFERENCED_TABLE_NAME"),
+				UpdateRule:            m.GetString("UPDATE_RULE"),
+				DeleteRule:            m.GetString("DELETE_RULE"),
+			})
+		}
+		foreignKey := &foreignKeys[len(foreignKeys)-1]
+		foreignKey.Columns = append(foreignKey.Columns, m.GetString("COLUMN_NAME"))
+		foreignKey.ReferencedColumns = append(foreignKey.ReferencedColumns, m.GetString("REFERENCED_COLUMN_NAME"))
+		return nil
+	}, args...)
+	return foreignKeys, err
+}
+
+// GetParentSideForeignKeys reads the foreign keys of other tables which reference given table
+func GetParentSideForeignKeys(db *gosql.DB, databaseName, tableName string) (foreignKeys []ForeignKey, err error) {
+	return getForeignKeys(db, `KEY_COLUMN_USAGE.REFERENCED_TABLE_SCHEMA = ? AND KEY_COLUMN_USAGE.REFERENCED_TABLE_NAME = ?`, databaseName, tableName)
+}
+
+// GetChildSideForeignKeys reads the foreign keys of given table, which reference other tables
+func GetChildSideForeignKeys(db *gosql.DB, databaseName, tableName string) (foreignKeys []ForeignKey, err error) {
+	return getForeignKeys(db, `KEY_COLUMN_USAGE.TABLE_SCHEMA = ? AND KEY_COLUMN_USAGE.TABLE_NAME = ?`, databaseName, tableName)
+}
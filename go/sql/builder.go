@@ -20,6 +20,7 @@ const (
 	GreaterThanOrEqualsComparisonSign ValueComparisonSign = ">="
 	GreaterThanComparisonSign         ValueComparisonSign = ">"
 	NotEqualsComparisonSign           ValueComparisonSign = "!="
+	NullSafeEqualsComparisonSign      ValueComparisonSign = "<=>"
 )
 
 // EscapeName will escape a db/table/column/... name by wrapping with backticks.
@@ -32,20 +33,33 @@ func EscapeName(name string) string {
 	return fmt.Sprintf("`%s`", name)
 }
 
+// buildTableReference returns the escaped `schema`.`table` reference, followed by a
+// PARTITION selection clause when partitionName is non-empty.
+func buildTableReference(databaseName, tableName, partitionName string) string {
+	tableReference := fmt.Sprintf("%s.%s", databaseName, tableName)
+	if partitionName != "" {
+		tableReference = fmt.Sprintf("%s partition (%s)", tableReference, EscapeName(partitionName))
+	}
+	return tableReference
+}
+
+// buildColumnPreparedValue returns the prepared value token of given column, which converts
+// the value as the column requires
+func buildColumnPreparedValue(column *Column) string {
+	if column.timezoneConversion != nil {
+		return fmt.Sprintf("convert_tz(?, '%s', '%s')", column.timezoneConversion.ToTimezone, "+00:00")
+	} else if column.enumToTextConversion {
+		return fmt.Sprintf("ELT(?, %s)", column.EnumValues)
+	} else if column.Type == JSONColumnType {
+		return "convert(? using utf8mb4)"
+	}
+	return "?"
+}
+
 func buildColumnsPreparedValues(columns *ColumnList) []string {
 	values := make([]string, columns.Len())
 	for i, column := range columns.Columns() {
-		var token string
-		if column.timezoneConversion != nil {
-			token = fmt.Sprintf("convert_tz(?, '%s', '%s')", column.timezoneConversion.ToTimezone, "+00:00")
-		} else if column.enumToTextConversion {
-			token = fmt.Sprintf("ELT(?, %s)", column.EnumValues)
-		} else if column.Type == JSONColumnType {
-			token = "convert(? using utf8mb4)"
-		} else {
-			token = "?"
-		}
-		values[i] = token
+		values[i] = buildColumnPreparedValue(&column)
 	}
 	return values
 }
@@ -101,6 +115,36 @@ func BuildEqualsPreparedComparison(columns []string) (result string, err error)
 	return BuildEqualsComparison(columns, values)
 }
 
+// BuildNullSafeEqualsPreparedComparison builds a comparison of given columns to prepared values,
+// where NULL values compare as equal to each other
+func BuildNullSafeEqualsPreparedComparison(columns []string) (result string, err error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("Got 0 columns in BuildNullSafeEqualsPreparedComparison")
+	}
+	comparisons := []string{}
+	for _, column := range columns {
+		comparison, err := BuildValueComparison(column, "?", NullSafeEqualsComparisonSign)
+		if err != nil {
+			return "", err
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	result = strings.Join(comparisons, " and ")
+	result = fmt.Sprintf("(%s)", result)
+	return result, nil
+}
+
+// buildFullRowNotExistsCondition builds a NOT EXISTS condition, which holds when the ghost table
+// has no row whose given columns all equal (null-safe) those of the original table's current row
+func buildFullRowNotExistsCondition(databaseName, originalTableName, ghostTableName string, columns []string) string {
+	comparisons := make([]string, len(columns))
+	for i, column := range columns {
+		column = EscapeName(column)
+		comparisons[i] = fmt.Sprintf("(%s.%s.%s <=> %s.%s.%s)", databaseName, ghostTableName, column, databaseName, originalTableName, column)
+	}
+	return fmt.Sprintf("and not exists (select 1 from %s.%s where %s)", databaseName, ghostTableName, strings.Join(comparisons, " and "))
+}
+
 func BuildSetPreparedClause(columns *ColumnList) (result string, err error) {
 	if columns.Len() == 0 {
 		return "", fmt.Errorf("Got 0 columns in BuildSetPreparedClause")
@@ -182,7 +226,12 @@ func BuildRangePreparedComparison(columns *ColumnList, args []interface{}, compa
 	return BuildRangeComparison(columns.Names(), values, args, comparisonSign)
 }
 
-func BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName string, sharedColumns []string, mappedSharedColumns []string, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool, noWait bool) (result string, explodedArgs []interface{}, err error) {
+// BuildRangeInsertQuery builds the INSERT...SELECT query which copies a chunk of rows onto the ghost table.
+// The unique key's index is forced onto the original table, unless uniqueKey is empty. When fullRowMatchColumns
+// are given, rows which the ghost table already has, matched by these columns, are not copied. Transformed
+// ghost table columns are populated by their transformation's expression over the original table's columns.
+// When a row filter is given, only rows matching it are copied.
+func BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, partitionName string, sharedColumns []string, mappedSharedColumns []string, columnTransformations ColumnTransformations, rowFilter *RowFilter, uniqueKey string, uniqueKeyColumns *ColumnList, fullRowMatchColumns []string, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool, noWait bool) (result string, explodedArgs []interface{}, err error) {
 	if len(sharedColumns) == 0 {
 		return "", explodedArgs, fmt.Errorf("Got 0 shared columns in BuildRangeInsertQuery")
 	}
@@ -190,19 +239,17 @@ func BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName strin
 	originalTableName = EscapeName(originalTableName)
 	ghostTableName = EscapeName(ghostTableName)
 
-	mappedSharedColumns = duplicateNames(mappedSharedColumns)
-	for i := range mappedSharedColumns {
-		mappedSharedColumns[i] = EscapeName(mappedSharedColumns[i])
+	selectExpressions, ghostColumns := buildRangeCopyColumns(sharedColumns, mappedSharedColumns, columnTransformations)
+	for i := range ghostColumns {
+		ghostColumns[i] = EscapeName(ghostColumns[i])
 	}
-	mappedSharedColumnsListing := strings.Join(mappedSharedColumns, ", ")
+	mappedSharedColumnsListing := strings.Join(ghostColumns, ", ")
+	sharedColumnsListing := strings.Join(selectExpressions, ", ")
 
-	sharedColumns = duplicateNames(sharedColumns)
-	for i := range sharedColumns {
-		sharedColumns[i] = EscapeName(sharedColumns[i])
+	forceIndexClause := ""
+	if uniqueKey != "" {
+		forceIndexClause = fmt.Sprintf("force index (%s)", EscapeName(uniqueKey))
 	}
-	sharedColumnsListing := strings.Join(sharedColumns, ", ")
-
-	uniqueKey = EscapeName(uniqueKey)
 	var minRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
 	if includeRangeStartValues {
 		minRangeComparisonSign = GreaterThanOrEqualsComparisonSign
@@ -225,6 +272,14 @@ func BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName strin
 		return "", explodedArgs, err
 	}
 	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	fullRowCondition := ""
+	if len(fullRowMatchColumns) > 0 {
+		fullRowCondition = buildFullRowNotExistsCondition(databaseName, originalTableName, ghostTableName, fullRowMatchColumns)
+	}
+	rowFilterCondition := ""
+	if rowFilter != nil {
+		rowFilterCondition = fmt.Sprintf("and %s", rowFilter.buildRangeCondition())
+	}
 	result = fmt.Sprintf(`
 		insert /* gh-ost %s.%s */ ignore
 		into
@@ -233,25 +288,219 @@ func BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName strin
 		(
 			select %s
 			from
-				%s.%s
-			force index (%s)
+				%s
+			%s
 			where
 				(%s and %s)
 				%s
+				%s
+				%s
 		)`,
 		databaseName, originalTableName, databaseName, ghostTableName, mappedSharedColumnsListing,
-		sharedColumnsListing, databaseName, originalTableName, uniqueKey,
-		rangeStartComparison, rangeEndComparison, transactionalClause)
+		sharedColumnsListing, buildTableReference(databaseName, originalTableName, partitionName), forceIndexClause,
+		rangeStartComparison, rangeEndComparison, rowFilterCondition, fullRowCondition, transactionalClause)
 	return result, explodedArgs, nil
 }
 
-func BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName string, sharedColumns []string, mappedSharedColumns []string, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool, noWait bool) (result string, explodedArgs []interface{}, err error) {
+// buildRangeCopyColumns returns the expressions which a chunk-copy selects off the original table, and
+// the ghost table columns these populate: the shared columns, or their transformations, followed by the
+// columns which only a transformation populates
+func buildRangeCopyColumns(sharedColumns []string, mappedSharedColumns []string, columnTransformations ColumnTransformations) (selectExpressions []string, ghostColumns []string) {
+	for i, sharedColumn := range sharedColumns {
+		if transformation := columnTransformations.Get(mappedSharedColumns[i]); transformation != nil {
+			selectExpressions = append(selectExpressions, fmt.Sprintf("(%s)", transformation.Expression))
+		} else {
+			selectExpressions = append(selectExpressions, EscapeName(sharedColumn))
+		}
+	}
+	ghostColumns = duplicateNames(mappedSharedColumns)
+	for _, transformation := range columnTransformations.ExtraColumns(NewColumnList(mappedSharedColumns)) {
+		selectExpressions = append(selectExpressions, fmt.Sprintf("(%s)", transformation.Expression))
+		ghostColumns = append(ghostColumns, transformation.Column)
+	}
+	return selectExpressions, ghostColumns
+}
+
+func BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, partitionName string, sharedColumns []string, mappedSharedColumns []string, columnTransformations ColumnTransformations, rowFilter *RowFilter, uniqueKey string, uniqueKeyColumns *ColumnList, fullRowMatchColumns []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool, noWait bool) (result string, explodedArgs []interface{}, err error) {
 	rangeStartValues := buildColumnsPreparedValues(uniqueKeyColumns)
 	rangeEndValues := buildColumnsPreparedValues(uniqueKeyColumns)
-	return BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, transactionalTable, noWait)
+	return BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, partitionName, sharedColumns, mappedSharedColumns, columnTransformations, rowFilter, uniqueKey, uniqueKeyColumns, fullRowMatchColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, transactionalTable, noWait)
+}
+
+// buildRangePreparedCondition builds the condition restricting a query to a chunk's range of the unique key
+func buildRangePreparedCondition(uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool) (result string, explodedArgs []interface{}, err error) {
+	var minRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
+	if includeRangeStartValues {
+		minRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	}
+	rangeStartComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeStartArgs, minRangeComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	rangeEndComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	return fmt.Sprintf("(%s and %s)", rangeStartComparison, rangeEndComparison), explodedArgs, nil
 }
 
-func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string) (result string, explodedArgs []interface{}, err error) {
+// BuildRangeDiscardedCountPreparedQuery builds the query counting the rows of a chunk which the row filter
+// discards, i.e. which the chunk-INSERT query does not copy. A NULL predicate discards the row.
+func BuildRangeDiscardedCountPreparedQuery(databaseName, tableName, partitionName string, rowFilter *RowFilter, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool) (result string, explodedArgs []interface{}, err error) {
+	if rowFilter == nil {
+		return "", explodedArgs, fmt.Errorf("Got no row filter in BuildRangeDiscardedCountPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	forceIndexClause := ""
+	if uniqueKey != "" {
+		forceIndexClause = fmt.Sprintf("force index (%s)", EscapeName(uniqueKey))
+	}
+	rangeCondition, explodedArgs, err := buildRangePreparedCondition(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	result = fmt.Sprintf(`
+		select /* gh-ost %s.%s */
+			count(*)
+		from
+			%s
+		%s
+		where
+			%s
+			and not ifnull(%s, false)`,
+		databaseName, tableName,
+		buildTableReference(databaseName, tableName, partitionName), forceIndexClause,
+		rangeCondition, rowFilter.buildRangeCondition())
+	return result, explodedArgs, nil
+}
+
+// BuildRangeSelectPreparedQuery builds the query reading given columns of a chunk's rows, which match
+// the row filter, if given.
+func BuildRangeSelectPreparedQuery(databaseName, tableName, partitionName string, columns []string, rowFilter *RowFilter, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool) (result string, explodedArgs []interface{}, err error) {
+	if len(columns) == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildRangeSelectPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+	columns = duplicateNames(columns)
+	for i := range columns {
+		columns[i] = EscapeName(columns[i])
+	}
+
+	forceIndexClause := ""
+	if uniqueKey != "" {
+		forceIndexClause = fmt.Sprintf("force index (%s)", EscapeName(uniqueKey))
+	}
+	rangeCondition, explodedArgs, err := buildRangePreparedCondition(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	rowFilterCondition := ""
+	if rowFilter != nil {
+		rowFilterCondition = fmt.Sprintf("and %s", rowFilter.buildRangeCondition())
+	}
+	result = fmt.Sprintf(`
+		select /* gh-ost %s.%s */
+			%s
+		from
+			%s
+		%s
+		where
+			%s
+			%s`,
+		databaseName, tableName,
+		strings.Join(columns, ", "),
+		buildTableReference(databaseName, tableName, partitionName), forceIndexClause,
+		rangeCondition, rowFilterCondition)
+	return result, explodedArgs, nil
+}
+
+// BuildRangeCopySelectPreparedQuery builds the query reading a chunk's rows off the original table, as the
+// chunk-INSERT query would copy them onto the ghost table, for a ghost table on another server. It returns
+// the ghost table columns which the selected values populate, in order.
+func BuildRangeCopySelectPreparedQuery(databaseName, originalTableName, partitionName string, sharedColumns []string, mappedSharedColumns []string, columnTransformations ColumnTransformations, rowFilter *RowFilter, uniqueKey string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, transactionalTable bool, noWait bool) (result string, explodedArgs []interface{}, ghostColumns []string, err error) {
+	if len(sharedColumns) == 0 {
+		return "", explodedArgs, ghostColumns, fmt.Errorf("Got 0 shared columns in BuildRangeCopySelectPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	originalTableName = EscapeName(originalTableName)
+
+	selectExpressions, ghostColumns := buildRangeCopyColumns(sharedColumns, mappedSharedColumns, columnTransformations)
+	forceIndexClause := ""
+	if uniqueKey != "" {
+		forceIndexClause = fmt.Sprintf("force index (%s)", EscapeName(uniqueKey))
+	}
+	rangeCondition, explodedArgs, err := buildRangePreparedCondition(uniqueKeyColumns, rangeStartArgs, rangeEndArgs, includeRangeStartValues)
+	if err != nil {
+		return "", explodedArgs, ghostColumns, err
+	}
+	rowFilterCondition := ""
+	if rowFilter != nil {
+		rowFilterCondition = fmt.Sprintf("and %s", rowFilter.buildRangeCondition())
+	}
+	transactionalClause := ""
+	if transactionalTable {
+		if noWait {
+			transactionalClause = "for share nowait"
+		} else {
+			transactionalClause = "lock in share mode"
+		}
+	}
+	result = fmt.Sprintf(`
+		select /* gh-ost %s.%s */
+			%s
+		from
+			%s
+		%s
+		where
+			%s
+			%s
+		%s`,
+		databaseName, originalTableName,
+		strings.Join(selectExpressions, ", "),
+		buildTableReference(databaseName, originalTableName, partitionName), forceIndexClause,
+		rangeCondition, rowFilterCondition, transactionalClause)
+	return result, explodedArgs, ghostColumns, nil
+}
+
+// BuildMultiRowInsertIgnorePreparedQuery builds an `insert ignore` of given number of rows onto given columns
+func BuildMultiRowInsertIgnorePreparedQuery(databaseName, tableName string, columns []string, rowCount int) (result string, err error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("Got 0 columns in BuildMultiRowInsertIgnorePreparedQuery")
+	}
+	if rowCount < 1 {
+		return "", fmt.Errorf("Got 0 rows in BuildMultiRowInsertIgnorePreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+	columns = duplicateNames(columns)
+	for i := range columns {
+		columns[i] = EscapeName(columns[i])
+	}
+	rowValues := fmt.Sprintf("(%s)", strings.Join(buildPreparedValues(len(columns)), ", "))
+	values := make([]string, rowCount)
+	for i := range values {
+		values[i] = rowValues
+	}
+	result = fmt.Sprintf(`
+		insert /* gh-ost %s.%s */ ignore
+		into
+			%s.%s
+			(%s)
+		values
+			%s`,
+		databaseName, tableName,
+		databaseName, tableName,
+		strings.Join(columns, ", "),
+		strings.Join(values, ",\n\t\t\t"))
+	return result, nil
+}
+
+func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName, partitionName string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string) (result string, explodedArgs []interface{}, err error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
 	}
@@ -290,7 +539,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 		select /* gh-ost %s.%s %s */
 			%s
 		from
-			%s.%s
+			%s
 		where
 			%s and %s
 		order by
@@ -299,7 +548,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 		offset %d`,
 		databaseName, tableName, hint,
 		strings.Join(uniqueKeyColumnNames, ", "),
-		databaseName, tableName,
+		buildTableReference(databaseName, tableName, partitionName),
 		rangeStartComparison, rangeEndComparison,
 		strings.Join(uniqueKeyColumnAscending, ", "),
 		(chunkSize - 1),
@@ -307,7 +556,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 	return result, explodedArgs, nil
 }
 
-func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string) (result string, explodedArgs []interface{}, err error) {
+func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName, partitionName string, uniqueKeyColumns *ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string) (result string, explodedArgs []interface{}, err error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", explodedArgs, fmt.Errorf("Got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
 	}
@@ -348,7 +597,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 			select
 				%s
 			from
-				%s.%s
+				%s
 			where
 				%s and %s
 			order by
@@ -358,7 +607,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 			%s
 		limit 1`,
 		databaseName, tableName, hint, strings.Join(uniqueKeyColumnNames, ", "),
-		strings.Join(uniqueKeyColumnNames, ", "), databaseName, tableName,
+		strings.Join(uniqueKeyColumnNames, ", "), buildTableReference(databaseName, tableName, partitionName),
 		rangeStartComparison, rangeEndComparison,
 		strings.Join(uniqueKeyColumnAscending, ", "), chunkSize,
 		strings.Join(uniqueKeyColumnDescending, ", "),
@@ -366,15 +615,15 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 	return result, explodedArgs, nil
 }
 
-func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName string, uniqueKey *UniqueKey) (string, error) {
-	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, uniqueKey, "asc")
+func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName, partitionName string, uniqueKey *UniqueKey) (string, error) {
+	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey, "asc")
 }
 
-func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName string, uniqueKey *UniqueKey) (string, error) {
-	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, uniqueKey, "desc")
+func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName, partitionName string, uniqueKey *UniqueKey) (string, error) {
+	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKey, "desc")
 }
 
-func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName string, uniqueKey *UniqueKey, order string) (string, error) {
+func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName string, uniqueKey *UniqueKey, order string) (string, error) {
 	if uniqueKey.Columns.Len() == 0 {
 		return "", fmt.Errorf("Got 0 columns in BuildUniqueKeyMinMaxValuesPreparedQuery")
 	}
@@ -391,21 +640,114 @@ func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName string, uni
 			uniqueKeyColumnOrder[i] = fmt.Sprintf("%s %s", uniqueKeyColumnNames[i], order)
 		}
 	}
+	forceIndexClause := ""
+	if uniqueKey.HasOriginalIndex() {
+		forceIndexClause = fmt.Sprintf("force index (%s)", uniqueKey.Name)
+	}
 	query := fmt.Sprintf(`
 		select /* gh-ost %s.%s */ %s
 		from
-			%s.%s
-		force index (%s)
+			%s
+		%s
 		order by
 			%s
 		limit 1`,
 		databaseName, tableName, strings.Join(uniqueKeyColumnNames, ", "),
-		databaseName, tableName, uniqueKey.Name,
+		buildTableReference(databaseName, tableName, partitionName), forceIndexClause,
 		strings.Join(uniqueKeyColumnOrder, ", "),
 	)
 	return query, nil
 }
 
+// BuildForeignKeyDefinition builds a foreign key constraint definition, as used in an
+// ALTER TABLE ... ADD clause
+func BuildForeignKeyDefinition(constraintName string, columns []string, referencedDatabaseName, referencedTableName string, referencedColumns []string, updateRule, deleteRule string) (string, error) {
+	if len(columns) == 0 || len(columns) != len(referencedColumns) {
+		return "", fmt.Errorf("Got %d columns and %d referenced columns in BuildForeignKeyDefinition", len(columns), len(referencedColumns))
+	}
+	columns = duplicateNames(columns)
+	for i := range columns {
+		columns[i] = EscapeName(columns[i])
+	}
+	referencedColumns = duplicateNames(referencedColumns)
+	for i := range referencedColumns {
+		referencedColumns[i] = EscapeName(referencedColumns[i])
+	}
+	definition := fmt.Sprintf("constraint %s foreign key (%s) references %s.%s (%s)",
+		EscapeName(constraintName),
+		strings.Join(columns, ", "),
+		EscapeName(referencedDatabaseName),
+		EscapeName(referencedTableName),
+		strings.Join(referencedColumns, ", "),
+	)
+	if deleteRule != "" {
+		definition = fmt.Sprintf("%s on delete %s", definition, strings.ToLower(deleteRule))
+	}
+	if updateRule != "" {
+		definition = fmt.Sprintf("%s on update %s", definition, strings.ToLower(updateRule))
+	}
+	return definition, nil
+}
+
+// BuildForeignKeyViolationsQuery builds a query counting the rows of a table which do not satisfy
+// a foreign key: rows with non-NULL key columns for which there is no matching referenced row.
+func BuildForeignKeyViolationsQuery(databaseName, tableName string, columns []string, referencedDatabaseName, referencedTableName string, referencedColumns []string) (string, error) {
+	if len(columns) == 0 || len(columns) != len(referencedColumns) {
+		return "", fmt.Errorf("Got %d columns and %d referenced columns in BuildForeignKeyViolationsQuery", len(columns), len(referencedColumns))
+	}
+	notNullComparisons := make([]string, len(columns))
+	equalsComparisons := make([]string, len(columns))
+	for i := range columns {
+		notNullComparisons[i] = fmt.Sprintf("child.%s is not null", EscapeName(columns[i]))
+		equalsComparisons[i] = fmt.Sprintf("parent.%s = child.%s", EscapeName(referencedColumns[i]), EscapeName(columns[i]))
+	}
+	query := fmt.Sprintf(`
+		select /* gh-ost */ count(*)
+		from
+			%s.%s as child
+		where
+			%s
+			and not exists (
+				select 1
+				from
+					%s.%s as parent
+				where
+					%s
+			)`,
+		EscapeName(databaseName), EscapeName(tableName),
+		strings.Join(notNullComparisons, " and "),
+		EscapeName(referencedDatabaseName), EscapeName(referencedTableName),
+		strings.Join(equalsComparisons, " and "),
+	)
+	return query, nil
+}
+
+// BuildDuplicateRowsExistQuery builds a query telling whether a table has rows whose given columns
+// all hold the same values, NULLs included
+func BuildDuplicateRowsExistQuery(databaseName, tableName string, columns []string) (string, error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("Got 0 columns in BuildDuplicateRowsExistQuery")
+	}
+	escapedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		escapedColumns[i] = EscapeName(column)
+	}
+	query := fmt.Sprintf(`
+		select /* gh-ost */ exists (
+			select 1
+			from
+				%s.%s
+			group by
+				%s
+			having
+				count(*) > 1
+		)`,
+		EscapeName(databaseName), EscapeName(tableName),
+		strings.Join(escapedColumns, ", "),
+	)
+	return query, nil
+}
+
 // DMLDeleteQueryBuilder can build DELETE queries for DML events.
 // It holds the prepared query statement so it doesn't need to be recreated every time.
 type DMLDeleteQueryBuilder struct {
@@ -447,6 +789,40 @@ func NewDMLDeleteQueryBuilder(databaseName, tableName string, tableColumns, uniq
 	return b, nil
 }
 
+// NewDMLFullRowDeleteQueryBuilder creates a new DMLDeleteQueryBuilder for tables which have no usable unique key.
+// It prepares a DELETE query statement which deletes a single row, matched null-safe by all given columns.
+// Returns an error if no match columns are given or the prepared statement cannot be built.
+func NewDMLFullRowDeleteQueryBuilder(databaseName, tableName string, tableColumns, matchColumns *ColumnList) (*DMLDeleteQueryBuilder, error) {
+	if matchColumns.Len() == 0 {
+		return nil, fmt.Errorf("no match columns found in NewDMLFullRowDeleteQueryBuilder")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+	equalsComparison, err := BuildNullSafeEqualsPreparedComparison(matchColumns.Names())
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := fmt.Sprintf(`
+		delete /* gh-ost %s.%s */
+		from
+			%s.%s
+		where
+			%s
+		limit 1`,
+		databaseName, tableName,
+		databaseName, tableName,
+		equalsComparison,
+	)
+
+	b := &DMLDeleteQueryBuilder{
+		tableColumns:      tableColumns,
+		uniqueKeyColumns:  matchColumns,
+		preparedStatement: stmt,
+	}
+	return b, nil
+}
+
 // BuildQuery builds the arguments array for a DML event DELETE query.
 // It returns the query string and the unique key arguments array.
 // Returns an error if the number of arguments is not equal to the number of table columns.
@@ -466,15 +842,19 @@ func (b *DMLDeleteQueryBuilder) BuildQuery(args []interface{}) (string, []interf
 // DMLInsertQueryBuilder can build INSERT queries for DML events.
 // It holds the prepared query statement so it doesn't need to be recreated every time.
 type DMLInsertQueryBuilder struct {
-	tableColumns, sharedColumns *ColumnList
-	preparedStatement           string
+	databaseName, tableName                                        string
+	tableColumns, sharedColumns, mappedSharedColumns, matchColumns *ColumnList
+	columnTransformations                                          ColumnTransformations
+	rowFilter                                                      *RowFilter
+	preparedStatement                                              string
 }
 
 // NewDMLInsertQueryBuilder creates a new DMLInsertQueryBuilder.
-// It prepares the INSERT query statement.
+// It prepares the INSERT query statement. Transformed columns are populated by their transformation.
+// When a row filter is given, the row is only inserted if it matches the filter.
 // Returns an error if no shared columns are given, the shared columns are not a subset of the table columns,
 // or the prepared statement cannot be built.
-func NewDMLInsertQueryBuilder(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *ColumnList) (*DMLInsertQueryBuilder, error) {
+func NewDMLInsertQueryBuilder(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, columnTransformations ColumnTransformations, rowFilter *RowFilter) (*DMLInsertQueryBuilder, error) {
 	if !sharedColumns.IsSubsetOf(tableColumns) {
 		return nil, fmt.Errorf("shared columns is not a subset of table columns in NewDMLInsertQueryBuilder")
 	}
@@ -483,11 +863,7 @@ func NewDMLInsertQueryBuilder(databaseName, tableName string, tableColumns, shar
 	}
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
-	mappedSharedColumnNames := duplicateNames(mappedSharedColumns.Names())
-	for i := range mappedSharedColumnNames {
-		mappedSharedColumnNames[i] = EscapeName(mappedSharedColumnNames[i])
-	}
-	preparedValues := buildColumnsPreparedValues(mappedSharedColumns)
+	mappedSharedColumnNames, preparedValues := buildTransformedPreparedValues(mappedSharedColumns, columnTransformations)
 
 	stmt := fmt.Sprintf(`
 		replace /* gh-ost %s.%s */
@@ -501,42 +877,177 @@ func NewDMLInsertQueryBuilder(databaseName, tableName string, tableColumns, shar
 		strings.Join(mappedSharedColumnNames, ", "),
 		strings.Join(preparedValues, ", "),
 	)
+	if rowFilter != nil {
+		stmt = fmt.Sprintf(`
+		replace /* gh-ost %s.%s */
+		into
+			%s.%s
+			(%s)
+		select
+			%s
+		from
+			dual
+		where
+			%s`,
+			databaseName, tableName,
+			databaseName, tableName,
+			strings.Join(mappedSharedColumnNames, ", "),
+			strings.Join(preparedValues, ", "),
+			rowFilter.buildPreparedCondition(),
+		)
+	}
 
 	return &DMLInsertQueryBuilder{
-		tableColumns:      tableColumns,
-		sharedColumns:     sharedColumns,
-		preparedStatement: stmt,
+		databaseName:          databaseName,
+		tableName:             tableName,
+		tableColumns:          tableColumns,
+		sharedColumns:         sharedColumns,
+		mappedSharedColumns:   mappedSharedColumns,
+		columnTransformations: columnTransformations,
+		rowFilter:             rowFilter,
+		preparedStatement:     stmt,
+	}, nil
+}
+
+// NewDMLFullRowInsertQueryBuilder creates a new DMLInsertQueryBuilder for tables which have no usable unique key.
+// It prepares an INSERT query statement which only inserts the row if the table has no row matching it
+// null-safe by all given match columns, which must be named the same on both tables, and not be transformed,
+// and, when a row filter is given, the row matches the filter.
+// Returns an error if no shared or match columns are given, the shared columns are not a subset of the
+// table columns, the match columns are not a subset of the shared columns, or the prepared statement cannot be built.
+func NewDMLFullRowInsertQueryBuilder(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, matchColumns *ColumnList, columnTransformations ColumnTransformations, rowFilter *RowFilter) (*DMLInsertQueryBuilder, error) {
+	if !sharedColumns.IsSubsetOf(tableColumns) {
+		return nil, fmt.Errorf("shared columns is not a subset of table columns in NewDMLFullRowInsertQueryBuilder")
+	}
+	if sharedColumns.Len() == 0 {
+		return nil, fmt.Errorf("no shared columns found in NewDMLFullRowInsertQueryBuilder")
+	}
+	if matchColumns.Len() == 0 {
+		return nil, fmt.Errorf("no match columns found in NewDMLFullRowInsertQueryBuilder")
+	}
+	if !matchColumns.IsSubsetOf(sharedColumns) {
+		return nil, fmt.Errorf("match columns is not a subset of shared columns in NewDMLFullRowInsertQueryBuilder")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+	mappedSharedColumnNames, preparedValues := buildTransformedPreparedValues(mappedSharedColumns, columnTransformations)
+	equalsComparison, err := BuildNullSafeEqualsPreparedComparison(matchColumns.Names())
+	if err != nil {
+		return nil, err
+	}
+	rowFilterCondition := ""
+	if rowFilter != nil {
+		rowFilterCondition = fmt.Sprintf("and %s", rowFilter.buildPreparedCondition())
+	}
+
+	stmt := fmt.Sprintf(`
+		insert /* gh-ost %s.%s */
+		into
+			%s.%s
+			(%s)
+		select
+			%s
+		from
+			dual
+		where
+			not exists (
+				select 1 from %s.%s where %s
+			)
+			%s`,
+		databaseName, tableName,
+		databaseName, tableName,
+		strings.Join(mappedSharedColumnNames, ", "),
+		strings.Join(preparedValues, ", "),
+		databaseName, tableName, equalsComparison,
+		rowFilterCondition,
+	)
+
+	return &DMLInsertQueryBuilder{
+		databaseName:          databaseName,
+		tableName:             tableName,
+		tableColumns:          tableColumns,
+		sharedColumns:         sharedColumns,
+		mappedSharedColumns:   mappedSharedColumns,
+		matchColumns:          matchColumns,
+		columnTransformations: columnTransformations,
+		rowFilter:             rowFilter,
+		preparedStatement:     stmt,
 	}, nil
 }
 
 // BuildQuery builds the arguments array for a DML event INSERT query.
-// It returns the query string and the shared arguments array.
+// It returns the query string and the shared arguments array, followed by the match arguments and the
+// row filter arguments, if any.
 // Returns an error if the number of arguments differs from the number of table columns.
 func (b *DMLInsertQueryBuilder) BuildQuery(args []interface{}) (string, []interface{}, error) {
 	if len(args) != b.tableColumns.Len() {
 		return "", nil, fmt.Errorf("args count differs from table column count in BuildDMLInsertQuery")
 	}
-	sharedArgs := make([]interface{}, 0, b.sharedColumns.Len())
-	for _, column := range b.sharedColumns.Columns() {
-		tableOrdinal := b.tableColumns.Ordinals[column.Name]
-		arg := column.convertArg(args[tableOrdinal], false)
-		sharedArgs = append(sharedArgs, arg)
+	sharedArgs := buildTransformedArgs(args, b.tableColumns, b.sharedColumns, b.mappedSharedColumns, b.columnTransformations)
+	if b.matchColumns != nil {
+		for _, column := range b.matchColumns.Columns() {
+			tableOrdinal := b.tableColumns.Ordinals[column.Name]
+			arg := column.convertArg(args[tableOrdinal], true)
+			sharedArgs = append(sharedArgs, arg)
+		}
+	}
+	if b.rowFilter != nil {
+		sharedArgs = append(sharedArgs, b.rowFilter.buildArgs(args, b.tableColumns)...)
 	}
 	return b.preparedStatement, sharedArgs, nil
 }
 
+// BuildPartialQuery builds an INSERT query and its arguments for a DML event whose row image only
+// holds the columns isPresent marks (binlog_row_image=MINIMAL): missing columns take their default value.
+// Returns an error if the number of arguments differs from the number of table columns, the builder
+// matches rows by all columns or filters rows (which a partial row image cannot do), or a transformed
+// column cannot be computed.
+func (b *DMLInsertQueryBuilder) BuildPartialQuery(args []interface{}, isPresent []bool) (string, []interface{}, error) {
+	if len(args) != b.tableColumns.Len() {
+		return "", nil, fmt.Errorf("args count differs from table column count in BuildPartialQuery")
+	}
+	if b.matchColumns != nil {
+		return "", nil, fmt.Errorf("cannot match a partial row image by all columns in BuildPartialQuery")
+	}
+	if b.rowFilter != nil {
+		return "", nil, fmt.Errorf("cannot filter a partial row image in BuildPartialQuery")
+	}
+	names, values, sharedArgs, err := buildPartialTransformedValues(args, isPresent, b.tableColumns, b.sharedColumns, b.mappedSharedColumns, b.columnTransformations, false)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(names) == 0 {
+		return "", nil, fmt.Errorf("no shared columns found in partial row image in BuildPartialQuery")
+	}
+	query := fmt.Sprintf(`
+		replace /* gh-ost %s.%s */
+		into
+			%s.%s
+			(%s)
+		values
+			(%s)`,
+		b.databaseName, b.tableName,
+		b.databaseName, b.tableName,
+		strings.Join(names, ", "),
+		strings.Join(values, ", "),
+	)
+	return query, sharedArgs, nil
+}
+
 // DMLUpdateQueryBuilder can build UPDATE queries for DML events.
 // It holds the prepared query statement so it doesn't need to be recreated every time.
 type DMLUpdateQueryBuilder struct {
-	tableColumns, sharedColumns, uniqueKeyColumns *ColumnList
-	preparedStatement                             string
+	databaseName, tableName                                            string
+	tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *ColumnList
+	columnTransformations                                              ColumnTransformations
+	preparedStatement                                                  string
 }
 
 // NewDMLUpdateQueryBuilder creates a new DMLUpdateQueryBuilder.
-// It prepares the UPDATE query statement.
+// It prepares the UPDATE query statement. Transformed columns are set by their transformation.
 // Returns an error if no shared columns are given, the shared columns are not a subset of the table columns,
 // no unique key columns are given or the prepared statement cannot be built.
-func NewDMLUpdateQueryBuilder(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *ColumnList) (*DMLUpdateQueryBuilder, error) {
+func NewDMLUpdateQueryBuilder(databaseName, tableName string, tableColumns, sharedColumns, mappedSharedColumns, uniqueKeyColumns *ColumnList, columnTransformations ColumnTransformations) (*DMLUpdateQueryBuilder, error) {
 	if !sharedColumns.IsSubsetOf(tableColumns) {
 		return nil, fmt.Errorf("shared columns is not a subset of table columns in NewDMLUpdateQueryBuilder")
 	}
@@ -554,10 +1065,12 @@ func NewDMLUpdateQueryBuilder(databaseName, tableName string, tableColumns, shar
 	}
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
-	setClause, err := BuildSetPreparedClause(mappedSharedColumns)
-	if err != nil {
-		return nil, err
+	setColumnNames, setValues := buildTransformedPreparedValues(mappedSharedColumns, columnTransformations)
+	setTokens := make([]string, len(setColumnNames))
+	for i := range setColumnNames {
+		setTokens[i] = fmt.Sprintf("%s=%s", setColumnNames[i], setValues[i])
 	}
+	setClause := strings.Join(setTokens, ", ")
 
 	equalsComparison, err := BuildEqualsPreparedComparison(uniqueKeyColumns.Names())
 	if err != nil {
@@ -576,22 +1089,21 @@ func NewDMLUpdateQueryBuilder(databaseName, tableName string, tableColumns, shar
 		equalsComparison,
 	)
 	return &DMLUpdateQueryBuilder{
-		tableColumns:      tableColumns,
-		sharedColumns:     sharedColumns,
-		uniqueKeyColumns:  uniqueKeyColumns,
-		preparedStatement: stmt,
+		databaseName:          databaseName,
+		tableName:             tableName,
+		tableColumns:          tableColumns,
+		sharedColumns:         sharedColumns,
+		mappedSharedColumns:   mappedSharedColumns,
+		uniqueKeyColumns:      uniqueKeyColumns,
+		columnTransformations: columnTransformations,
+		preparedStatement:     stmt,
 	}, nil
 }
 
 // BuildQuery builds the arguments array for a DML event UPDATE query.
 // It returns the query string, the shared arguments array, and the unique key arguments array.
 func (b *DMLUpdateQueryBuilder) BuildQuery(valueArgs, whereArgs []interface{}) (string, []interface{}, []interface{}, error) {
-	sharedArgs := make([]interface{}, 0, b.sharedColumns.Len())
-	for _, column := range b.sharedColumns.Columns() {
-		tableOrdinal := b.tableColumns.Ordinals[column.Name]
-		arg := column.convertArg(valueArgs[tableOrdinal], false)
-		sharedArgs = append(sharedArgs, arg)
-	}
+	sharedArgs := buildTransformedArgs(valueArgs, b.tableColumns, b.sharedColumns, b.mappedSharedColumns, b.columnTransformations)
 
 	uniqueKeyArgs := make([]interface{}, 0, b.uniqueKeyColumns.Len())
 	for _, column := range b.uniqueKeyColumns.Columns() {
@@ -602,3 +1114,42 @@ func (b *DMLUpdateQueryBuilder) BuildQuery(valueArgs, whereArgs []interface{}) (
 
 	return b.preparedStatement, sharedArgs, uniqueKeyArgs, nil
 }
+
+// BuildPartialQuery builds an UPDATE query for a DML event whose after image only holds the columns
+// isPresent marks (binlog_row_image=MINIMAL or NOBLOB): only those columns are set. It returns the query
+// string, the shared arguments array, and the unique key arguments array. The query is empty when none of
+// the present columns are written onto the table, in which case there is nothing to update.
+func (b *DMLUpdateQueryBuilder) BuildPartialQuery(valueArgs, whereArgs []interface{}, isPresent []bool) (string, []interface{}, []interface{}, error) {
+	setColumnNames, setValues, sharedArgs, err := buildPartialTransformedValues(valueArgs, isPresent, b.tableColumns, b.sharedColumns, b.mappedSharedColumns, b.columnTransformations, true)
+	if err != nil || len(setColumnNames) == 0 {
+		return "", nil, nil, err
+	}
+	setTokens := make([]string, len(setColumnNames))
+	for i := range setColumnNames {
+		setTokens[i] = fmt.Sprintf("%s=%s", setColumnNames[i], setValues[i])
+	}
+	equalsComparison, err := BuildEqualsPreparedComparison(b.uniqueKeyColumns.Names())
+	if err != nil {
+		return "", nil, nil, err
+	}
+	query := fmt.Sprintf(`
+		update /* gh-ost %s.%s */
+			%s.%s
+		set
+			%s
+		where
+			%s`,
+		b.databaseName, b.tableName,
+		b.databaseName, b.tableName,
+		strings.Join(setTokens, ", "),
+		equalsComparison,
+	)
+
+	uniqueKeyArgs := make([]interface{}, 0, b.uniqueKeyColumns.Len())
+	for _, column := range b.uniqueKeyColumns.Columns() {
+		tableOrdinal := b.tableColumns.Ordinals[column.Name]
+		arg := column.convertArg(whereArgs[tableOrdinal], true)
+		uniqueKeyArgs = append(uniqueKeyArgs, arg)
+	}
+	return query, sharedArgs, uniqueKeyArgs, nil
+}
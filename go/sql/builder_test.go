@@ -171,7 +171,7 @@ func TestBuildRangeInsertQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3}
 		rangeEndArgs := []interface{}{103}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, "", sharedColumns, sharedColumns, nil, nil, uniqueKey, uniqueKeyColumns, nil, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
 		require.NoError(t, err)
 		expected := `
 			insert /* gh-ost mydb.tbl */ ignore
@@ -199,7 +199,7 @@ func TestBuildRangeInsertQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, "", sharedColumns, sharedColumns, nil, nil, uniqueKey, uniqueKeyColumns, nil, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
 		require.NoError(t, err)
 		expected := `
 			insert /* gh-ost mydb.tbl */ ignore
@@ -241,7 +241,7 @@ func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
 		rangeStartArgs := []interface{}{3}
 		rangeEndArgs := []interface{}{103}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, "", sharedColumns, mappedSharedColumns, nil, nil, uniqueKey, uniqueKeyColumns, nil, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
 		require.NoError(t, err)
 		expected := `
 			insert /* gh-ost mydb.tbl */ ignore
@@ -270,7 +270,7 @@ func TestBuildRangeInsertQueryRenameMap(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, sharedColumns, mappedSharedColumns, uniqueKey, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
+		query, explodedArgs, err := BuildRangeInsertQuery(databaseName, originalTableName, ghostTableName, "", sharedColumns, mappedSharedColumns, nil, nil, uniqueKey, uniqueKeyColumns, nil, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, true, true, true)
 		require.NoError(t, err)
 		expected := `
 			insert /* gh-ost mydb.tbl */ ignore
@@ -305,7 +305,7 @@ func TestBuildRangeInsertPreparedQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, sharedColumns, sharedColumns, uniqueKey, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, true, true, true)
+		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, "", sharedColumns, sharedColumns, nil, nil, uniqueKey, uniqueKeyColumns, nil, rangeStartArgs, rangeEndArgs, true, true, true)
 		require.NoError(t, err)
 		expected := `
 			insert /* gh-ost mydb.tbl */ ignore
@@ -323,6 +323,53 @@ func TestBuildRangeInsertPreparedQuery(t *testing.T) {
 		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
 		require.Equal(t, []interface{}{3, 3, 17, 3, 17, 103, 103, 117, 103, 117}, explodedArgs)
 	}
+	{
+		uniqueKey := "PRIMARY"
+		uniqueKeyColumns := NewColumnList([]string{"id"})
+		rangeStartArgs := []interface{}{3}
+		rangeEndArgs := []interface{}{103}
+
+		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, "p2024", sharedColumns, sharedColumns, nil, nil, uniqueKey, uniqueKeyColumns, nil, rangeStartArgs, rangeEndArgs, true, true, false)
+		require.NoError(t, err)
+		expected := `
+			insert /* gh-ost mydb.tbl */ ignore
+			into
+				mydb.ghost
+				(id, name, position)
+			(
+				select id, name, position
+				from
+					mydb.tbl partition (p2024)
+				force index (PRIMARY)
+				where (((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+				lock in share mode
+			)`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 3, 103, 103}, explodedArgs)
+	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"position"})
+		rangeStartArgs := []interface{}{3}
+		rangeEndArgs := []interface{}{103}
+
+		query, explodedArgs, err := BuildRangeInsertPreparedQuery(databaseName, originalTableName, ghostTableName, "", sharedColumns, sharedColumns, nil, nil, "", uniqueKeyColumns, []string{"id", "name", "position"}, rangeStartArgs, rangeEndArgs, false, true, false)
+		require.NoError(t, err)
+		expected := `
+			insert /* gh-ost mydb.tbl */ ignore
+			into
+				mydb.ghost
+				(id, name, position)
+			(
+				select id, name, position
+				from
+					mydb.tbl
+				where (((position > ?)) and ((position < ?) or ((position = ?))))
+				and not exists (select 1 from mydb.ghost where (mydb.ghost.id <=> mydb.tbl.id) and (mydb.ghost.name <=> mydb.tbl.name) and (mydb.ghost.position <=> mydb.tbl.position))
+				lock in share mode
+			)`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 103, 103}, explodedArgs)
+	}
 }
 
 func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
@@ -334,7 +381,7 @@ func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
 		rangeStartArgs := []interface{}{3, 17}
 		rangeEndArgs := []interface{}{103, 117}
 
-		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, originalTableName, uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, false, "test")
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, originalTableName, "", uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, false, "test")
 		require.NoError(t, err)
 		expected := `
 			select /* gh-ost mydb.tbl test */ name, position
@@ -353,6 +400,26 @@ func TestBuildUniqueKeyRangeEndPreparedQuery(t *testing.T) {
 		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
 		require.Equal(t, []interface{}{3, 3, 17, 103, 103, 117, 103, 117}, explodedArgs)
 	}
+	{
+		uniqueKeyColumns := NewColumnList([]string{"id"})
+		rangeStartArgs := []interface{}{3}
+		rangeEndArgs := []interface{}{103}
+
+		query, explodedArgs, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, originalTableName, "p0", uniqueKeyColumns, rangeStartArgs, rangeEndArgs, chunkSize, true, "test")
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost mydb.tbl test */
+				id
+			from
+				mydb.tbl partition (p0)
+			where ((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?)))
+			order by
+				id asc
+			limit 1
+			offset 499`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 3, 103, 103}, explodedArgs)
+	}
 }
 
 func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
@@ -361,7 +428,7 @@ func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
 	uniqueKeyColumns := NewColumnList([]string{"name", "position"})
 	uniqueKey := &UniqueKey{Name: "PRIMARY", Columns: *uniqueKeyColumns}
 	{
-		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, uniqueKey)
+		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, "", uniqueKey)
 		require.NoError(t, err)
 		expected := `
 			select /* gh-ost mydb.tbl */ name, position
@@ -375,7 +442,7 @@ func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
 		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
 	}
 	{
-		query, err := BuildUniqueKeyMaxValuesPreparedQuery(databaseName, originalTableName, uniqueKey)
+		query, err := BuildUniqueKeyMaxValuesPreparedQuery(databaseName, originalTableName, "", uniqueKey)
 		require.NoError(t, err)
 		expected := `
 			select /* gh-ost mydb.tbl */ name, position
@@ -388,6 +455,98 @@ func TestBuildUniqueKeyMinValuesPreparedQuery(t *testing.T) {
 		`
 		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
 	}
+	{
+		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, "p1", uniqueKey)
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost mydb.tbl */ name, position
+			  from
+			    mydb.tbl partition (p1)
+			  force index (PRIMARY)
+			  order by
+			    name asc, position asc
+			  limit 1
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	}
+	{
+		fullRowKey := &UniqueKey{Columns: *NewColumnList([]string{"position"}), IsFullRow: true}
+		query, err := BuildUniqueKeyMinValuesPreparedQuery(databaseName, originalTableName, "", fullRowKey)
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost mydb.tbl */ position
+			  from
+			    mydb.tbl
+			  order by
+			    position asc
+			  limit 1
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	}
+}
+
+func TestBuildForeignKeyDefinition(t *testing.T) {
+	{
+		definition, err := BuildForeignKeyDefinition("child_fk", []string{"parent_id"}, "mydb", "parent", []string{"id"}, "RESTRICT", "CASCADE")
+		require.NoError(t, err)
+		require.Equal(t, "constraint `child_fk` foreign key (`parent_id`) references `mydb`.`parent` (`id`) on delete cascade on update restrict", definition)
+	}
+	{
+		definition, err := BuildForeignKeyDefinition("_child_fk", []string{"a", "b"}, "mydb", "parent", []string{"x", "y"}, "", "")
+		require.NoError(t, err)
+		require.Equal(t, "constraint `_child_fk` foreign key (`a`, `b`) references `mydb`.`parent` (`x`, `y`)", definition)
+	}
+	{
+		_, err := BuildForeignKeyDefinition("child_fk", []string{"a", "b"}, "mydb", "parent", []string{"x"}, "", "")
+		require.Error(t, err)
+	}
+}
+
+func TestBuildForeignKeyViolationsQuery(t *testing.T) {
+	{
+		query, err := BuildForeignKeyViolationsQuery("mydb", "ghost", []string{"a", "b"}, "otherdb", "parent", []string{"x", "y"})
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost */ count(*)
+			from
+				mydb.ghost as child
+			where
+				child.a is not null and child.b is not null
+				and not exists (
+					select 1
+					from
+						otherdb.parent as parent
+					where
+						parent.x = child.a and parent.y = child.b
+				)`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	}
+	{
+		_, err := BuildForeignKeyViolationsQuery("mydb", "ghost", []string{}, "otherdb", "parent", []string{})
+		require.Error(t, err)
+	}
+}
+
+func TestBuildDuplicateRowsExistQuery(t *testing.T) {
+	{
+		query, err := BuildDuplicateRowsExistQuery("mydb", "tbl", []string{"name", "position"})
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost */ exists (
+				select 1
+				from
+					mydb.tbl
+				group by
+					name, position
+				having
+					count(*) > 1
+			)`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	}
+	{
+		_, err := BuildDuplicateRowsExistQuery("mydb", "tbl", []string{})
+		require.Error(t, err)
+	}
 }
 
 func TestBuildDMLDeleteQuery(t *testing.T) {
@@ -457,6 +616,35 @@ func TestBuildDMLDeleteQuery(t *testing.T) {
 	}
 }
 
+func TestBuildDMLFullRowDeleteQuery(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	args := []interface{}{3, "testname", nil, 17, 23}
+	{
+		matchColumns := NewColumnList([]string{"name", "rank", "position"})
+		builder, err := NewDMLFullRowDeleteQueryBuilder(databaseName, tableName, tableColumns, matchColumns)
+		require.NoError(t, err)
+
+		query, matchArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		expected := `
+			delete /* gh-ost mydb.tbl */
+				from
+					mydb.tbl
+				where
+					((name <=> ?) and (rank <=> ?) and (position <=> ?))
+				limit 1
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{"testname", nil, 17}, matchArgs)
+	}
+	{
+		_, err := NewDMLFullRowDeleteQueryBuilder(databaseName, tableName, tableColumns, NewColumnList([]string{}))
+		require.Error(t, err)
+	}
+}
+
 func TestBuildDMLDeleteQuerySignedUnsigned(t *testing.T) {
 	databaseName := "mydb"
 	tableName := "tbl"
@@ -504,7 +692,7 @@ func TestBuildDMLInsertQuery(t *testing.T) {
 	args := []interface{}{3, "testname", "first", 17, 23}
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
 		require.NoError(t, err)
 		query, sharedArgs, err := builder.BuildQuery(args)
 		require.NoError(t, err)
@@ -520,7 +708,7 @@ func TestBuildDMLInsertQuery(t *testing.T) {
 	}
 	{
 		sharedColumns := NewColumnList([]string{"position", "name", "age", "id"})
-		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
 		require.NoError(t, err)
 		query, sharedArgs, err := builder.BuildQuery(args)
 		require.NoError(t, err)
@@ -536,12 +724,89 @@ func TestBuildDMLInsertQuery(t *testing.T) {
 	}
 	{
 		sharedColumns := NewColumnList([]string{"position", "name", "surprise", "id"})
-		_, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		_, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
 		require.Error(t, err)
 	}
 	{
 		sharedColumns := NewColumnList([]string{})
-		_, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		_, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
+		require.Error(t, err)
+	}
+}
+
+func TestBuildDMLInsertPartialQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
+	args := []interface{}{3, nil, "first", 17, nil}
+	isPresent := []bool{true, false, true, true, false}
+	{
+		builder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, nil, nil)
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildPartialQuery(args, isPresent)
+		require.NoError(t, err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, position)
+				values
+					(?, ?)
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 17}, sharedArgs)
+	}
+	{
+		// The ghost table's default for the missing name column differs from the original table's
+		mappedSharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
+		mappedSharedColumns.GetColumn("name").HasDefault = true
+		builder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, mappedSharedColumns, nil, nil)
+		require.NoError(t, err)
+		_, _, err = builder.BuildPartialQuery(args, isPresent)
+		require.Error(t, err)
+	}
+	{
+		matchColumns := NewColumnList([]string{"id", "name"})
+		builder, err := NewDMLFullRowInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, matchColumns, nil, nil)
+		require.NoError(t, err)
+		_, _, err = builder.BuildPartialQuery(args, isPresent)
+		require.Error(t, err)
+	}
+}
+
+func TestBuildDMLFullRowInsertQuery(t *testing.T) {
+	databaseName := "mydb"
+	tableName := "tbl"
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	args := []interface{}{3, "testname", nil, 17, 23}
+	sharedColumns := NewColumnList([]string{"id", "name", "rank", "position"})
+	{
+		matchColumns := NewColumnList([]string{"name", "rank"})
+		builder, err := NewDMLFullRowInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, matchColumns, nil, nil)
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		expected := `
+			insert /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, name, rank, position)
+				select
+					?, ?, ?, ?
+				from
+					dual
+				where
+					not exists (
+						select 1 from mydb.tbl where ((name <=> ?) and (rank <=> ?))
+					)
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, "testname", nil, 17, "testname", nil}, sharedArgs)
+	}
+	{
+		matchColumns := NewColumnList([]string{"name", "age"})
+		_, err := NewDMLFullRowInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, matchColumns, nil, nil)
+		require.Error(t, err)
+	}
+	{
+		_, err := NewDMLFullRowInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, NewColumnList([]string{}), nil, nil)
 		require.Error(t, err)
 	}
 }
@@ -555,7 +820,7 @@ func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 		// testing signed
 		args := []interface{}{3, "testname", "first", int8(-1), 23}
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
-		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
 		require.NoError(t, err)
 		query, sharedArgs, err := builder.BuildQuery(args)
 		require.NoError(t, err)
@@ -573,7 +838,7 @@ func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 		// testing unsigned
 		args := []interface{}{3, "testname", "first", int8(-1), 23}
 		sharedColumns.SetUnsigned("position")
-		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
 		require.NoError(t, err)
 		query, sharedArgs, err := builder.BuildQuery(args)
 		require.NoError(t, err)
@@ -591,7 +856,7 @@ func TestBuildDMLInsertQuerySignedUnsigned(t *testing.T) {
 		// testing unsigned
 		args := []interface{}{3, "testname", "first", int32(-1), 23}
 		sharedColumns.SetUnsigned("position")
-		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns)
+		builder, err := NewDMLInsertQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, nil, nil)
 		require.NoError(t, err)
 		query, sharedArgs, err := builder.BuildQuery(args)
 		require.NoError(t, err)
@@ -616,7 +881,7 @@ func TestBuildDMLUpdateQuery(t *testing.T) {
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		uniqueKeyColumns := NewColumnList([]string{"position"})
-		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 		require.NoError(t, err)
 		query, sharedArgs, uniqueKeyArgs, err := builder.BuildQuery(valueArgs, whereArgs)
 		require.NoError(t, err)
@@ -634,7 +899,7 @@ func TestBuildDMLUpdateQuery(t *testing.T) {
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		uniqueKeyColumns := NewColumnList([]string{"position", "name"})
-		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 		require.NoError(t, err)
 		query, sharedArgs, uniqueKeyArgs, err := builder.BuildQuery(valueArgs, whereArgs)
 		require.NoError(t, err)
@@ -652,7 +917,7 @@ func TestBuildDMLUpdateQuery(t *testing.T) {
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		uniqueKeyColumns := NewColumnList([]string{"age"})
-		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 		require.NoError(t, err)
 		query, sharedArgs, uniqueKeyArgs, err := builder.BuildQuery(valueArgs, whereArgs)
 		require.NoError(t, err)
@@ -670,7 +935,7 @@ func TestBuildDMLUpdateQuery(t *testing.T) {
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		uniqueKeyColumns := NewColumnList([]string{"age", "position", "id", "name"})
-		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 		require.NoError(t, err)
 		query, sharedArgs, uniqueKeyArgs, err := builder.BuildQuery(valueArgs, whereArgs)
 		require.NoError(t, err)
@@ -688,20 +953,20 @@ func TestBuildDMLUpdateQuery(t *testing.T) {
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		uniqueKeyColumns := NewColumnList([]string{"age", "surprise"})
-		_, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+		_, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 		require.Error(t, err)
 	}
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		uniqueKeyColumns := NewColumnList([]string{})
-		_, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+		_, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 		require.Error(t, err)
 	}
 	{
 		sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 		mappedColumns := NewColumnList([]string{"id", "name", "role", "age"})
 		uniqueKeyColumns := NewColumnList([]string{"id"})
-		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, mappedColumns, uniqueKeyColumns)
+		builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, mappedColumns, uniqueKeyColumns, nil)
 		require.NoError(t, err)
 		query, sharedArgs, uniqueKeyArgs, err := builder.BuildQuery(valueArgs, whereArgs)
 		require.NoError(t, err)
@@ -726,7 +991,7 @@ func TestBuildDMLUpdateQuerySignedUnsigned(t *testing.T) {
 	whereArgs := []interface{}{3, "testname", "findme", int8(-3), 56}
 	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
 	uniqueKeyColumns := NewColumnList([]string{"position"})
-	builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns)
+	builder, err := NewDMLUpdateQueryBuilder(databaseName, tableName, tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, nil)
 	require.NoError(t, err)
 	{
 		// test signed
@@ -761,3 +1026,35 @@ func TestBuildDMLUpdateQuerySignedUnsigned(t *testing.T) {
 		require.Equal(t, []interface{}{uint8(253)}, uniqueKeyArgs)
 	}
 }
+
+func TestBuildDMLUpdatePartialQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "rank", "position", "age"})
+	sharedColumns := NewColumnList([]string{"id", "name", "position", "age"})
+	mappedColumns := NewColumnList([]string{"id", "name", "role", "age"})
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	builder, err := NewDMLUpdateQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, mappedColumns, uniqueKeyColumns, nil)
+	require.NoError(t, err)
+	whereArgs := []interface{}{3, nil, nil, nil, nil}
+	{
+		valueArgs := []interface{}{3, nil, nil, 17, 23}
+		query, sharedArgs, uniqueKeyArgs, err := builder.BuildPartialQuery(valueArgs, whereArgs, []bool{true, false, false, true, true})
+		require.NoError(t, err)
+		expected := `
+			update /* gh-ost mydb.tbl */
+			  mydb.tbl
+					set id=?, role=?, age=?
+				where
+					((id = ?))
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 17, 23}, sharedArgs)
+		require.Equal(t, []interface{}{3}, uniqueKeyArgs)
+	}
+	{
+		// only a column the ghost table does not have is present: nothing to update
+		valueArgs := []interface{}{nil, nil, "newval", nil, nil}
+		query, _, _, err := builder.BuildPartialQuery(valueArgs, whereArgs, []bool{false, false, true, false, false})
+		require.NoError(t, err)
+		require.Equal(t, "", query)
+	}
+}
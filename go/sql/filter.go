@@ -0,0 +1,84 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RowFilter restricts a migration to the original table's rows matching an SQL predicate over
+// original table columns (--where). Other rows are discarded: not copied, and removed from the ghost
+// table when a binlog event updates them into not matching. On row copy the predicate is evaluated on
+// the original table's rows; on applying binlog events, on the binlog event's row values of the
+// referenced columns.
+type RowFilter struct {
+	Expression        string
+	ReferencedColumns *ColumnList
+}
+
+func NewRowFilter(expression string) (*RowFilter, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, fmt.Errorf("Empty row filter")
+	}
+	return &RowFilter{
+		Expression:        expression,
+		ReferencedColumns: NewColumnList([]string{}),
+	}, nil
+}
+
+func (this *RowFilter) String() string {
+	return this.Expression
+}
+
+// SetReferencedColumns finds the columns of given list referenced by the predicate, the same way
+// ColumnTransformation.SetReferencedColumns does
+func (this *RowFilter) SetReferencedColumns(columns *ColumnList) {
+	this.ReferencedColumns = expressionReferencedColumns(this.Expression, columns)
+}
+
+// buildRangeCondition returns the condition restricting a row copy query to matching rows
+func (this *RowFilter) buildRangeCondition() string {
+	return fmt.Sprintf("(%s)", this.Expression)
+}
+
+// buildPreparedCondition returns the condition evaluating the predicate over a binlog event's row
+func (this *RowFilter) buildPreparedCondition() string {
+	if this.ReferencedColumns.Len() == 0 {
+		return fmt.Sprintf("(%s)", this.Expression)
+	}
+	return fmt.Sprintf("exists (select 1 from %s where %s)", buildReferencedColumnsPreparedRow(this.ReferencedColumns), this.Expression)
+}
+
+// buildArgs returns the arguments of the prepared condition, off given binlog event row
+func (this *RowFilter) buildArgs(row []interface{}, tableColumns *ColumnList) []interface{} {
+	return buildReferencedColumnsArgs(this.ReferencedColumns, row, tableColumns)
+}
+
+// Negate returns a filter matching the rows this filter discards, including rows for which it is NULL
+func (this *RowFilter) Negate() *RowFilter {
+	return &RowFilter{
+		Expression:        fmt.Sprintf("not ifnull((%s), false)", this.Expression),
+		ReferencedColumns: this.ReferencedColumns,
+	}
+}
+
+// BuildMatchQuery builds a query evaluating the filter over each of given binlog event rows. The query
+// returns a single row, holding a column per given row, which is true when that row matches the filter.
+func (this *RowFilter) BuildMatchQuery(rows [][]interface{}, tableColumns *ColumnList) (result string, explodedArgs []interface{}, err error) {
+	if len(rows) == 0 {
+		return "", explodedArgs, fmt.Errorf("Got 0 rows in BuildMatchQuery")
+	}
+	condition := this.buildPreparedCondition()
+	conditions := make([]string, len(rows))
+	for i, row := range rows {
+		conditions[i] = fmt.Sprintf("ifnull(%s, false)", condition)
+		explodedArgs = append(explodedArgs, this.buildArgs(row, tableColumns)...)
+	}
+	result = fmt.Sprintf(`select /* gh-ost */ %s`, strings.Join(conditions, ", "))
+	return result, explodedArgs, nil
+}
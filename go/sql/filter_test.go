@@ -0,0 +1,229 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRowFilter(t *testing.T, tableColumns *ColumnList, expression string) *RowFilter {
+	rowFilter, err := NewRowFilter(expression)
+	require.NoError(t, err)
+	rowFilter.SetReferencedColumns(tableColumns)
+	return rowFilter
+}
+
+func TestNewRowFilter(t *testing.T) {
+	{
+		rowFilter, err := NewRowFilter(" created_at >= '2024-01-01' ")
+		require.NoError(t, err)
+		require.Equal(t, "created_at >= '2024-01-01'", rowFilter.String())
+	}
+	{
+		_, err := NewRowFilter("  ")
+		require.Error(t, err)
+	}
+	{
+		tableColumns := NewColumnList([]string{"id", "Status", "created_at"})
+		rowFilter := newTestRowFilter(t, tableColumns, "status = 'active' and id % 2 = 0")
+		require.Equal(t, []string{"id", "Status"}, rowFilter.ReferencedColumns.Names())
+	}
+}
+
+func TestBuildRangeInsertQueryRowFilter(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "status"})
+	rowFilter := newTestRowFilter(t, tableColumns, "status = 'active'")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	query, explodedArgs, err := BuildRangeInsertPreparedQuery("mydb", "tbl", "ghost", "", tableColumns.Names(), tableColumns.Names(), nil, rowFilter, "PRIMARY", uniqueKeyColumns, nil, []interface{}{3}, []interface{}{103}, true, true, false)
+	require.NoError(t, err)
+	expected := `
+		insert /* gh-ost mydb.tbl */ ignore
+		into
+			mydb.ghost
+			(id, status)
+		(
+			select id, status
+			from
+				mydb.tbl
+			force index (PRIMARY)
+			where
+				(((id > ?) or ((id = ?)))
+				and
+				((id < ?) or ((id = ?))))
+				and (status = 'active')
+			lock in share mode
+		)`
+	require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	require.Equal(t, []interface{}{3, 3, 103, 103}, explodedArgs)
+}
+
+func TestBuildRangeDiscardedCountPreparedQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "status"})
+	rowFilter := newTestRowFilter(t, tableColumns, "status = 'active'")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	{
+		query, explodedArgs, err := BuildRangeDiscardedCountPreparedQuery("mydb", "tbl", "", rowFilter, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, false)
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost mydb.tbl */
+				count(*)
+			from
+				mydb.tbl
+			force index (PRIMARY)
+			where
+				(((id > ?)) and ((id < ?) or ((id = ?))))
+				and not ifnull((status = 'active'), false)`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 103, 103}, explodedArgs)
+	}
+	{
+		_, _, err := BuildRangeDiscardedCountPreparedQuery("mydb", "tbl", "", nil, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, false)
+		require.Error(t, err)
+	}
+}
+
+func TestBuildDMLQueriesRowFilter(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "email", "status"})
+	tableColumns.SetCharset("email", "utf8mb4")
+	sharedColumns := NewColumnList([]string{"id", "email", "status"})
+	rowFilter := newTestRowFilter(t, tableColumns, "status = 'active' and email like '%@example.com'")
+	args := []interface{}{3, "gromit@example.com", "active"}
+
+	t.Run("insert", func(t *testing.T) {
+		builder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, nil, rowFilter)
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, email, status)
+				select
+					?, ?, ?
+				from
+					dual
+				where
+					exists (select 1 from (select convert(? using utf8mb4) as email, ? as status) as _gh_ost_row where status = 'active' and email like '%@example.com')
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, "gromit@example.com", "active", []byte("gromit@example.com"), "active"}, sharedArgs)
+
+		_, _, err = builder.BuildPartialQuery(args, []bool{true, true, true})
+		require.Error(t, err)
+	})
+
+	t.Run("full row insert", func(t *testing.T) {
+		matchColumns := NewColumnList([]string{"id", "status"})
+		builder, err := NewDMLFullRowInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, matchColumns, nil, rowFilter)
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		expected := `
+			insert /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, email, status)
+				select
+					?, ?, ?
+				from
+					dual
+				where
+					not exists (
+						select 1 from mydb.tbl where ((id <=> ?) and (status <=> ?))
+					)
+					and exists (select 1 from (select convert(? using utf8mb4) as email, ? as status) as _gh_ost_row where status = 'active' and email like '%@example.com')
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, "gromit@example.com", "active", 3, "active", []byte("gromit@example.com"), "active"}, sharedArgs)
+	})
+
+	t.Run("constant filter", func(t *testing.T) {
+		builder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, nil, newTestRowFilter(t, tableColumns, "@@global.read_only = 0"))
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		require.Contains(t, normalizeQuery(query), "where (@@global.read_only = 0)")
+		require.Equal(t, []interface{}{3, "gromit@example.com", "active"}, sharedArgs)
+	})
+}
+
+func TestRowFilterNegate(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "status"})
+	rowFilter := newTestRowFilter(t, tableColumns, "status = 'active'")
+
+	negated := rowFilter.Negate()
+	require.Equal(t, "not ifnull((status = 'active'), false)", negated.String())
+	require.Equal(t, []string{"status"}, negated.ReferencedColumns.Names())
+	require.Equal(t, "status = 'active'", rowFilter.String())
+}
+
+func TestRowFilterBuildMatchQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "status"})
+	rowFilter := newTestRowFilter(t, tableColumns, "status = 'active'")
+	{
+		query, explodedArgs, err := rowFilter.BuildMatchQuery([][]interface{}{{3, "active"}, {4, "deleted"}}, tableColumns)
+		require.NoError(t, err)
+		expected := "select /* gh-ost */ ifnull(exists (select 1 from (select ? as `status`) as `_gh_ost_row` where status = 'active'), false), ifnull(exists (select 1 from (select ? as `status`) as `_gh_ost_row` where status = 'active'), false)"
+		require.Equal(t, expected, query)
+		require.Equal(t, []interface{}{"active", "deleted"}, explodedArgs)
+	}
+	{
+		_, _, err := rowFilter.BuildMatchQuery([][]interface{}{}, tableColumns)
+		require.Error(t, err)
+	}
+}
+
+func TestRowFilterBuildMatchQueryTypedColumns(t *testing.T) {
+	tableColumns := newTestTypedColumns()
+	tableColumns.SetUnsigned("id")
+	rowFilter := newTestRowFilter(t, tableColumns, "amount > 10.5 and id > 9223372036854775807 and created_at >= '2024-03-01 23:59:59.5'")
+	query, explodedArgs, err := rowFilter.BuildMatchQuery([][]interface{}{{int64(-1), "10.50", "2024-03-01 23:59:59.500"}}, tableColumns)
+	require.NoError(t, err)
+	expected := "select /* gh-ost */ ifnull(exists (select 1 from (select cast(? as unsigned) as `id`, cast(? as decimal(10,2)) as `amount`, cast(? as datetime(3)) as `created_at`) as `_gh_ost_row` where amount > 10.5 and id > 9223372036854775807 and created_at >= '2024-03-01 23:59:59.5'), false)"
+	require.Equal(t, expected, query)
+	require.Equal(t, []interface{}{"18446744073709551615", "10.50", "2024-03-01 23:59:59.500"}, explodedArgs)
+}
+
+func TestBuildRangeSelectPreparedQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "status"})
+	rowFilter := newTestRowFilter(t, tableColumns, "status = 'active'")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	{
+		query, explodedArgs, err := BuildRangeSelectPreparedQuery("mydb", "tbl", "", tableColumns.Names(), rowFilter.Negate(), "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true)
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost mydb.tbl */
+				id, status
+			from
+				mydb.tbl
+			force index (PRIMARY)
+			where
+				(((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+				and (not ifnull((status = 'active'), false))`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 3, 103, 103}, explodedArgs)
+	}
+	{
+		query, explodedArgs, err := BuildRangeSelectPreparedQuery("mydb", "tbl", "p0", []string{"id"}, nil, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, false)
+		require.NoError(t, err)
+		expected := `
+			select /* gh-ost mydb.tbl */
+				id
+			from
+				mydb.tbl partition (p0)
+			force index (PRIMARY)
+			where
+				(((id > ?)) and ((id < ?) or ((id = ?))))`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, 103, 103}, explodedArgs)
+	}
+	{
+		_, _, err := BuildRangeSelectPreparedQuery("mydb", "tbl", "", []string{}, nil, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, false)
+		require.Error(t, err)
+	}
+}
@@ -12,11 +12,24 @@ import (
 )
 
 var (
-	sanitizeQuotesRegexp                 = regexp.MustCompile("('[^']*')")
-	renameColumnRegexp                   = regexp.MustCompile(`(?i)\bchange\s+(column\s+|)([\S]+)\s+([\S]+)\s+`)
-	dropColumnRegexp                     = regexp.MustCompile(`(?i)\bdrop\s+(column\s+|)([\S]+)$`)
-	renameTableRegexp                    = regexp.MustCompile(`(?i)\brename\s+(to|as)\s+`)
-	autoIncrementRegexp                  = regexp.MustCompile(`(?i)\bauto_increment[\s]*=[\s]*([0-9]+)`)
+	sanitizeQuotesRegexp = regexp.MustCompile("('[^']*')")
+	renameColumnRegexp   = regexp.MustCompile(`(?i)\bchange\s+(column\s+|)([\S]+)\s+([\S]+)\s+`)
+	dropColumnRegexp     = regexp.MustCompile(`(?i)\bdrop\s+(column\s+|)([\S]+)$`)
+	renameTableRegexp    = regexp.MustCompile(`(?i)\brename\s+(to|as)\s+`)
+	// RENAME TO|AS [scm.]tbl, with either part optionally quoted
+	renameTableNameRegexp = regexp.MustCompile("(?i)\\brename\\s+(?:to|as)\\s+(?:(`[^`]+`|[^\\s.`]+)[.])?(`[^`]+`|[^\\s.`]+)")
+	autoIncrementRegexp   = regexp.MustCompile(`(?i)\bauto_increment[\s]*=[\s]*([0-9]+)`)
+	partitioningRegexps   = []*regexp.Regexp{
+		// PARTITION BY RANGE (...) ..., possibly following table options, e.g. ENGINE=InnoDB PARTITION BY ...
+		regexp.MustCompile(`(?i)\bpartition\s+by\s+`),
+		// ADD/DROP/REORGANIZE/COALESCE PARTITION
+		regexp.MustCompile(`(?i)\b(add|drop|reorganize|coalesce)\s+partition\b`),
+		// REMOVE PARTITIONING
+		regexp.MustCompile(`(?i)\bremove\s+partitioning\b`),
+	}
+	// TRUNCATE/EXCHANGE/OPTIMIZE/... PARTITION operate on the data or tablespaces of the table's existing
+	// partitions, rather than defining its partitioning: they have no meaning on the ghost table
+	partitionMaintenanceRegexp           = regexp.MustCompile(`(?i)\b(truncate|discard|import|exchange|analyze|check|optimize|rebuild|repair)\s+partition\b`)
 	alterTableExplicitSchemaTableRegexps = []*regexp.Regexp{
 		// ALTER TABLE `scm`.`tbl` something
 		regexp.MustCompile(`(?i)\balter\s+table\s+` + "`" + `([^` + "`" + `]+)` + "`" + `[.]` + "`" + `([^` + "`" + `]+)` + "`" + `\s+(.*$)`),
@@ -33,7 +46,8 @@ var (
 		// ALTER TABLE tbl something
 		regexp.MustCompile(`(?i)\balter\s+table\s+([\S]+)\s+(.*$)`),
 	}
-	enumValuesRegexp = regexp.MustCompile("^enum[(](.*)[)]$")
+	enumValuesRegexp            = regexp.MustCompile("^enum[(](.*)[)]$")
+	partitionExpressionIdRegexp = regexp.MustCompile("`([^`]+)`|([a-zA-Z0-9_$]+)")
 )
 
 type AlterTableParser struct {
@@ -41,10 +55,17 @@ type AlterTableParser struct {
 	droppedColumns         map[string]bool
 	isRenameTable          bool
 	isAutoIncrementDefined bool
+	isPartitioningChange   bool
+
+	partitionMaintenance string
 
 	alterStatementOptions string
 	alterTokens           []string
 
+	renameTableSchema                  string
+	renameTableName                    string
+	alterStatementOptionsWithoutRename string
+
 	explicitSchema string
 	explicitTable  string
 }
@@ -131,6 +152,17 @@ func (this *AlterTableParser) parseAlterToken(alterToken string) {
 			this.isAutoIncrementDefined = true
 		}
 	}
+	{
+		// partitioning
+		for _, partitioningRegexp := range partitioningRegexps {
+			if partitioningRegexp.MatchString(alterToken) {
+				this.isPartitioningChange = true
+			}
+		}
+		if submatch := partitionMaintenanceRegexp.FindStringSubmatch(alterToken); len(submatch) > 0 && this.partitionMaintenance == "" {
+			this.partitionMaintenance = strings.ToUpper(submatch[1]) + " PARTITION"
+		}
+	}
 }
 
 func (this *AlterTableParser) ParseAlterStatement(alterStatement string) (err error) {
@@ -150,11 +182,31 @@ func (this *AlterTableParser) ParseAlterStatement(alterStatement string) (err er
 			break
 		}
 	}
+	var tokensWithoutRename []string
 	for _, alterToken := range this.tokenizeAlterStatement(this.alterStatementOptions) {
+		tokensWithoutRename = append(tokensWithoutRename, this.parseRenameTableToken(alterToken)...)
 		alterToken = this.sanitizeQuotesFromAlterStatement(alterToken)
 		this.parseAlterToken(alterToken)
 		this.alterTokens = append(this.alterTokens, alterToken)
 	}
+	this.alterStatementOptionsWithoutRename = strings.Join(tokensWithoutRename, ", ")
+	return nil
+}
+
+// parseRenameTableToken extracts the new table name out of a RENAME TO|AS clause, and
+// returns the token with the clause removed (or nothing, if nothing else remains).
+// The clause is looked up in the sanitized token so that quoted text is never mistaken
+// for it, but is removed from the original token so as to keep quoted values intact.
+func (this *AlterTableParser) parseRenameTableToken(alterToken string) (remainingTokens []string) {
+	submatch := renameTableNameRegexp.FindStringSubmatch(this.sanitizeQuotesFromAlterStatement(alterToken))
+	if len(submatch) == 0 {
+		return []string{alterToken}
+	}
+	this.renameTableSchema = strings.Trim(submatch[1], "`")
+	this.renameTableName = strings.Trim(submatch[2], "`")
+	if remaining := strings.TrimSpace(strings.Replace(alterToken, submatch[0], "", 1)); remaining != "" {
+		return []string{remaining}
+	}
 	return nil
 }
 
@@ -180,10 +232,38 @@ func (this *AlterTableParser) IsRenameTable() bool {
 	return this.isRenameTable
 }
 
+// GetRenameTableSchema returns the schema given in a RENAME TO|AS clause, if any
+func (this *AlterTableParser) GetRenameTableSchema() string {
+	return this.renameTableSchema
+}
+
+// GetRenameTableName returns the new table name given in a RENAME TO|AS clause, if any
+func (this *AlterTableParser) GetRenameTableName() string {
+	return this.renameTableName
+}
+
+// GetAlterStatementOptionsWithoutRename returns the alter statement options, with the
+// RENAME TO|AS clause removed. This is what is applied onto the ghost table.
+func (this *AlterTableParser) GetAlterStatementOptionsWithoutRename() string {
+	return this.alterStatementOptionsWithoutRename
+}
+
 func (this *AlterTableParser) IsAutoIncrementDefined() bool {
 	return this.isAutoIncrementDefined
 }
 
+// IsPartitioningChange returns true when the ALTER adds, removes or modifies the
+// table's partitioning, e.g. PARTITION BY, ADD PARTITION or REMOVE PARTITIONING.
+func (this *AlterTableParser) IsPartitioningChange() bool {
+	return this.isPartitioningChange
+}
+
+// GetPartitionMaintenance returns the partition maintenance operation of the ALTER, if any,
+// e.g. TRUNCATE PARTITION or EXCHANGE PARTITION, which gh-ost cannot migrate.
+func (this *AlterTableParser) GetPartitionMaintenance() string {
+	return this.partitionMaintenance
+}
+
 func (this *AlterTableParser) GetExplicitSchema() string {
 	return this.explicitSchema
 }
@@ -210,3 +290,23 @@ func ParseEnumValues(enumColumnType string) string {
 	}
 	return enumColumnType
 }
+
+// ParsePartitionExpressionColumns returns the columns referenced by a partitioning expression,
+// as found in INFORMATION_SCHEMA.PARTITIONS, e.g. "to_days(`created_at`)" or "`a`,`b`".
+// Only identifiers which are columns of the given list are returned.
+func ParsePartitionExpressionColumns(partitionExpression string, columns *ColumnList) (partitionColumns []string) {
+	found := make(map[string]bool)
+	for _, submatch := range partitionExpressionIdRegexp.FindAllStringSubmatch(partitionExpression, -1) {
+		identifier := submatch[1]
+		if identifier == "" {
+			identifier = submatch[2]
+		}
+		column := columns.GetColumn(identifier)
+		if column == nil || found[column.Name] {
+			continue
+		}
+		found[column.Name] = true
+		partitionColumns = append(partitionColumns, column.Name)
+	}
+	return partitionColumns
+}
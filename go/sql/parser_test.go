@@ -225,6 +225,121 @@ func TestParseAlterStatementRenameTable(t *testing.T) {
 	}
 }
 
+func TestParseAlterStatementRenameTableName(t *testing.T) {
+	{
+		parser := NewAlterTableParser()
+		statement := "add column c int, drop column b"
+		err := parser.ParseAlterStatement(statement)
+		require.NoError(t, err)
+		require.Equal(t, "", parser.GetRenameTableSchema())
+		require.Equal(t, "", parser.GetRenameTableName())
+		require.Equal(t, "add column c int, drop column b", parser.GetAlterStatementOptionsWithoutRename())
+	}
+	{
+		parser := NewAlterTableParser()
+		statement := "rename as something_else"
+		err := parser.ParseAlterStatement(statement)
+		require.NoError(t, err)
+		require.Equal(t, "", parser.GetRenameTableSchema())
+		require.Equal(t, "something_else", parser.GetRenameTableName())
+		require.Equal(t, "", parser.GetAlterStatementOptionsWithoutRename())
+	}
+	{
+		parser := NewAlterTableParser()
+		statement := "alter table tbl drop column b, RENAME TO `something else`, add key (i)"
+		err := parser.ParseAlterStatement(statement)
+		require.NoError(t, err)
+		require.Equal(t, "something else", parser.GetRenameTableName())
+		require.Equal(t, "drop column b, add key (i)", parser.GetAlterStatementOptionsWithoutRename())
+	}
+	{
+		parser := NewAlterTableParser()
+		statement := "engine=innodb rename to scm.something_else"
+		err := parser.ParseAlterStatement(statement)
+		require.NoError(t, err)
+		require.Equal(t, "scm", parser.GetRenameTableSchema())
+		require.Equal(t, "something_else", parser.GetRenameTableName())
+		require.Equal(t, "engine=innodb", parser.GetAlterStatementOptionsWithoutRename())
+	}
+	{
+		parser := NewAlterTableParser()
+		statement := "rename as `scm`.`something_else`, add column c varchar(32) default 'rename to x'"
+		err := parser.ParseAlterStatement(statement)
+		require.NoError(t, err)
+		require.Equal(t, "scm", parser.GetRenameTableSchema())
+		require.Equal(t, "something_else", parser.GetRenameTableName())
+		require.Equal(t, "add column c varchar(32) default 'rename to x'", parser.GetAlterStatementOptionsWithoutRename())
+	}
+}
+
+func TestParseAlterStatementPartitioningChange(t *testing.T) {
+	{
+		statements := []string{
+			"partition by hash(id) partitions 4",
+			"engine=innodb partition by range (to_days(created_at)) (partition p0 values less than (738000), partition p1 values less than maxvalue)",
+			"add partition (partition p3 values less than (2030))",
+			"DROP PARTITION p0",
+			"reorganize partition p1 into (partition p1a values less than (100), partition p1b values less than maxvalue)",
+			"coalesce partition 2",
+			"add column c int, remove partitioning",
+		}
+		for _, statement := range statements {
+			parser := NewAlterTableParser()
+			err := parser.ParseAlterStatement(statement)
+			require.NoError(t, err)
+			require.True(t, parser.IsPartitioningChange(), statement)
+			require.Empty(t, parser.GetPartitionMaintenance(), statement)
+		}
+	}
+	{
+		statements := []string{
+			"add column c int",
+			"drop column b",
+			"add column i int comment 'partition by hash(id)'",
+			"add index `partition_idx` (i)",
+		}
+		for _, statement := range statements {
+			parser := NewAlterTableParser()
+			err := parser.ParseAlterStatement(statement)
+			require.NoError(t, err)
+			require.False(t, parser.IsPartitioningChange(), statement)
+			require.Empty(t, parser.GetPartitionMaintenance(), statement)
+		}
+	}
+}
+
+func TestParseAlterStatementPartitionMaintenance(t *testing.T) {
+	statements := map[string]string{
+		"truncate partition p0, p1":                            "TRUNCATE PARTITION",
+		"discard partition p0 tablespace":                      "DISCARD PARTITION",
+		"IMPORT PARTITION p0 TABLESPACE":                       "IMPORT PARTITION",
+		"exchange partition p0 with table t2":                  "EXCHANGE PARTITION",
+		"analyze partition all":                                "ANALYZE PARTITION",
+		"check partition p1":                                   "CHECK PARTITION",
+		"optimize partition p0":                                "OPTIMIZE PARTITION",
+		"rebuild partition p0, p1":                             "REBUILD PARTITION",
+		"repair partition p1":                                  "REPAIR PARTITION",
+		"add column c int, truncate partition p0":              "TRUNCATE PARTITION",
+		"add column c int comment 'truncate partition p0'":     "",
+		"add partition (partition p3 values less than (2030))": "",
+	}
+	for statement, partitionMaintenance := range statements {
+		parser := NewAlterTableParser()
+		err := parser.ParseAlterStatement(statement)
+		require.NoError(t, err)
+		require.Equal(t, partitionMaintenance, parser.GetPartitionMaintenance(), statement)
+		require.False(t, parser.IsPartitioningChange() && partitionMaintenance != "", statement)
+	}
+}
+
+func TestParsePartitionExpressionColumns(t *testing.T) {
+	columns := NewColumnList([]string{"id", "created_at", "region", "days"})
+	require.Equal(t, []string{"created_at"}, ParsePartitionExpressionColumns("to_days(`created_at`)", columns))
+	require.Equal(t, []string{"region", "id"}, ParsePartitionExpressionColumns("`region`,`id`", columns))
+	require.Equal(t, []string{"id"}, ParsePartitionExpressionColumns("id % 4 + id", columns))
+	require.Empty(t, ParsePartitionExpressionColumns("to_days(`other`)", columns))
+}
+
 func TestParseAlterStatementExplicitTable(t *testing.T) {
 	{
 		parser := NewAlterTableParser()
@@ -0,0 +1,125 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package sql
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+type ShardMethod string
+
+const (
+	HashShardMethod  ShardMethod = "hash"
+	RangeShardMethod ShardMethod = "range"
+)
+
+// ShardRouter routes the original table's rows onto shards, by the value of a sharding column, on a
+// horizontal split (--shard-tables). The hash method routes a row onto shard CRC32(value) % count; the
+// range method onto the first shard whose (exclusive) upper bound exceeds the value, or else onto the
+// last shard. Rows with a NULL value go onto the first shard.
+// A row is routed alike on row copy, where BuildShardCondition evaluates on the original table's rows,
+// and on applying binlog events, where Route evaluates on the binlog event's row values.
+type ShardRouter struct {
+	Method      ShardMethod
+	ShardCount  int
+	RangeBounds []int64
+	Column      Column
+}
+
+func NewShardRouter(method ShardMethod, shardCount int, rangeBounds []int64) (*ShardRouter, error) {
+	if shardCount < 2 {
+		return nil, fmt.Errorf("Got %d shards; a horizontal split requires at least 2", shardCount)
+	}
+	switch method {
+	case HashShardMethod:
+		if len(rangeBounds) > 0 {
+			return nil, fmt.Errorf("Range bounds given to %s sharding", method)
+		}
+	case RangeShardMethod:
+		if len(rangeBounds) != shardCount-1 {
+			return nil, fmt.Errorf("Range sharding onto %d shards requires %d range bounds; got %d", shardCount, shardCount-1, len(rangeBounds))
+		}
+		for i := 1; i < len(rangeBounds); i++ {
+			if rangeBounds[i] <= rangeBounds[i-1] {
+				return nil, fmt.Errorf("Range bounds must be ascending; got %d after %d", rangeBounds[i], rangeBounds[i-1])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Unknown shard method: %s", method)
+	}
+	return &ShardRouter{
+		Method:      method,
+		ShardCount:  shardCount,
+		RangeBounds: rangeBounds,
+	}, nil
+}
+
+// ParseShardRangeBounds parses a comma delimited list of integer range bounds
+func ParseShardRangeBounds(rangeBounds string) (bounds []int64, err error) {
+	if strings.TrimSpace(rangeBounds) == "" {
+		return bounds, nil
+	}
+	for _, token := range strings.Split(rangeBounds, ",") {
+		bound, err := strconv.ParseInt(strings.TrimSpace(token), 10, 64)
+		if err != nil {
+			return bounds, fmt.Errorf("Invalid range bound %q: %+v", token, err)
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// Route returns the shard onto which given binlog event row goes
+func (this *ShardRouter) Route(row []interface{}, tableColumns *ColumnList) (shard int, err error) {
+	ordinal, ok := tableColumns.Ordinals[this.Column.Name]
+	if !ok || ordinal >= len(row) {
+		return shard, fmt.Errorf("Sharding column %s not found in row", EscapeName(this.Column.Name))
+	}
+	value := this.Column.FormatValue(row[ordinal])
+	if value == nil {
+		return 0, nil
+	}
+	text := value.(string)
+	switch this.Method {
+	case HashShardMethod:
+		return int(crc32.ChecksumIEEE([]byte(text)) % uint32(this.ShardCount)), nil
+	case RangeShardMethod:
+		number, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			if _, uerr := strconv.ParseUint(text, 10, 64); uerr == nil {
+				// Beyond any bound
+				return this.ShardCount - 1, nil
+			}
+			return shard, fmt.Errorf("Range sharding requires integer values of %s; got %q", EscapeName(this.Column.Name), text)
+		}
+		for i, bound := range this.RangeBounds {
+			if number < bound {
+				return i, nil
+			}
+		}
+		return this.ShardCount - 1, nil
+	}
+	return shard, fmt.Errorf("Unknown shard method: %s", this.Method)
+}
+
+// BuildShardCondition returns the SQL predicate over the original table's columns, matching the rows of given shard
+func (this *ShardRouter) BuildShardCondition(shard int) string {
+	column := EscapeName(this.Column.Name)
+	if this.Method == HashShardMethod {
+		return fmt.Sprintf("ifnull(crc32(%s) %% %d, 0) = %d", column, this.ShardCount, shard)
+	}
+	switch {
+	case shard == 0:
+		return fmt.Sprintf("(%s is null or %s < %d)", column, column, this.RangeBounds[0])
+	case shard == this.ShardCount-1:
+		return fmt.Sprintf("%s >= %d", column, this.RangeBounds[shard-1])
+	default:
+		return fmt.Sprintf("(%s >= %d and %s < %d)", column, this.RangeBounds[shard-1], column, this.RangeBounds[shard])
+	}
+}
@@ -0,0 +1,127 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package sql
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShardRouter(t *testing.T) {
+	_, err := NewShardRouter(HashShardMethod, 4, nil)
+	require.NoError(t, err)
+	_, err = NewShardRouter(RangeShardMethod, 3, []int64{100, 200})
+	require.NoError(t, err)
+
+	_, err = NewShardRouter(HashShardMethod, 1, nil)
+	require.Error(t, err)
+	_, err = NewShardRouter(HashShardMethod, 2, []int64{100})
+	require.Error(t, err)
+	_, err = NewShardRouter(RangeShardMethod, 3, []int64{100})
+	require.Error(t, err)
+	_, err = NewShardRouter(RangeShardMethod, 3, []int64{200, 100})
+	require.Error(t, err)
+	_, err = NewShardRouter("modulo", 3, nil)
+	require.Error(t, err)
+}
+
+func TestParseShardRangeBounds(t *testing.T) {
+	bounds, err := ParseShardRangeBounds("")
+	require.NoError(t, err)
+	require.Empty(t, bounds)
+
+	bounds, err = ParseShardRangeBounds("-5, 100,2000")
+	require.NoError(t, err)
+	require.Equal(t, []int64{-5, 100, 2000}, bounds)
+
+	_, err = ParseShardRangeBounds("100,abc")
+	require.Error(t, err)
+}
+
+func TestShardRouterRoute(t *testing.T) {
+	columns := NewColumnList([]string{"id", "name", "account_id"})
+
+	t.Run("hash", func(t *testing.T) {
+		router, err := NewShardRouter(HashShardMethod, 4, nil)
+		require.NoError(t, err)
+		router.Column = *columns.GetColumn("account_id")
+
+		shard, err := router.Route([]interface{}{1, "gromit", int64(42)}, columns)
+		require.NoError(t, err)
+		require.Equal(t, 0, shard)
+		shard, err = router.Route([]interface{}{1, "gromit", nil}, columns)
+		require.NoError(t, err)
+		require.Equal(t, 0, shard)
+
+		router.Column = *columns.GetColumn("name")
+		shard, err = router.Route([]interface{}{1, "gromit", int64(42)}, columns)
+		require.NoError(t, err)
+		require.Equal(t, 3, shard)
+		require.Equal(t, "ifnull(crc32(`name`) % 4, 0) = 3", router.BuildShardCondition(3))
+	})
+
+	t.Run("hash unsigned", func(t *testing.T) {
+		columns := NewColumnList([]string{"id", "name", "account_id"})
+		columns.SetUnsigned("account_id")
+		router, err := NewShardRouter(HashShardMethod, 4, nil)
+		require.NoError(t, err)
+		router.Column = *columns.GetColumn("account_id")
+
+		// binlog events hold unsigned values as signed ones
+		shard, err := router.Route([]interface{}{1, "gromit", int32(-1)}, columns)
+		require.NoError(t, err)
+		require.Equal(t, 3, shard)
+	})
+
+	t.Run("hash decimal", func(t *testing.T) {
+		columns := NewColumnList([]string{"id", "amount"})
+		amount := columns.GetColumn("amount")
+		amount.DataType, amount.DeclaredType = "decimal", "decimal(10,2)"
+		router, err := NewShardRouter(HashShardMethod, 4, nil)
+		require.NoError(t, err)
+		router.Column = *columns.GetColumn("amount")
+
+		// binlog events hold 1.50 without its trailing zero, whereas CRC32() hashes "1.50"
+		for _, value := range []string{"1.5", "-0.5", "0", "12345678.9"} {
+			shard, err := router.Route([]interface{}{1, decimal.RequireFromString(value)}, columns)
+			require.NoError(t, err)
+			expected := int(crc32.ChecksumIEEE([]byte(decimal.RequireFromString(value).StringFixed(2))) % 4)
+			require.Equal(t, expected, shard, value)
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		router, err := NewShardRouter(RangeShardMethod, 3, []int64{100, 200})
+		require.NoError(t, err)
+		router.Column = *columns.GetColumn("account_id")
+
+		for value, expected := range map[interface{}]int{
+			int64(-7):  0,
+			int64(99):  0,
+			int64(100): 1,
+			int32(199): 1,
+			int64(200): 2,
+			int64(1e9): 2,
+		} {
+			shard, err := router.Route([]interface{}{1, "gromit", value}, columns)
+			require.NoError(t, err)
+			require.Equal(t, expected, shard, value)
+		}
+		shard, err := router.Route([]interface{}{1, "gromit", nil}, columns)
+		require.NoError(t, err)
+		require.Equal(t, 0, shard)
+
+		_, err = router.Route([]interface{}{1, "gromit", "abc"}, columns)
+		require.Error(t, err)
+
+		require.Equal(t, "(`account_id` is null or `account_id` < 100)", router.BuildShardCondition(0))
+		require.Equal(t, "(`account_id` >= 100 and `account_id` < 200)", router.BuildShardCondition(1))
+		require.Equal(t, "`account_id` >= 200", router.BuildShardCondition(2))
+	})
+}
@@ -0,0 +1,304 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TransformedRowAlias is the alias of the derived table holding the original row's values
+// referenced by a column transformation, when applying binlog events
+const TransformedRowAlias = "_gh_ost_row"
+
+// ColumnTransformation computes the value of a ghost table column with an SQL expression over
+// original table columns. On row copy the expression is evaluated on the original table's rows;
+// on applying binlog events, on the binlog event's row values of the referenced columns.
+type ColumnTransformation struct {
+	Column            string
+	Expression        string
+	ReferencedColumns *ColumnList
+}
+
+// ParseColumnTransformation parses a "column=expression" column transformation definition
+func ParseColumnTransformation(definition string) (*ColumnTransformation, error) {
+	tokens := strings.SplitN(definition, "=", 2)
+	if len(tokens) != 2 {
+		return nil, fmt.Errorf("Invalid column transformation %q: expecting column=expression", definition)
+	}
+	column := strings.Trim(strings.TrimSpace(tokens[0]), "`")
+	expression := strings.TrimSpace(tokens[1])
+	if column == "" || expression == "" {
+		return nil, fmt.Errorf("Invalid column transformation %q: expecting column=expression", definition)
+	}
+	return &ColumnTransformation{
+		Column:            column,
+		Expression:        expression,
+		ReferencedColumns: NewColumnList([]string{}),
+	}, nil
+}
+
+func (this *ColumnTransformation) String() string {
+	return fmt.Sprintf("%s=%s", EscapeName(this.Column), this.Expression)
+}
+
+// SetReferencedColumns finds the columns of given list referenced by the expression. Columns are
+// recognized by name, outside string literals and comments, and not when followed by an opening
+// parenthesis, which makes for a function call. Anything else that is named like a column is taken
+// to be that column, which at worst references more columns than needed.
+func (this *ColumnTransformation) SetReferencedColumns(columns *ColumnList) {
+	this.ReferencedColumns = expressionReferencedColumns(this.Expression, columns)
+}
+
+// expressionReferencedColumns returns the columns of given list referenced by given SQL expression
+func expressionReferencedColumns(expression string, columns *ColumnList) *ColumnList {
+	referenced := make(map[string]bool)
+	for _, identifier := range expressionIdentifiers(expression) {
+		referenced[strings.ToLower(identifier)] = true
+	}
+	return columns.FilterBy(func(column Column) bool {
+		return referenced[strings.ToLower(column.Name)]
+	})
+}
+
+// expressionIdentifiers returns the possibly column referencing identifiers of given SQL expression
+func expressionIdentifiers(expression string) (identifiers []string) {
+	runes := []rune(expression)
+	isIdentifierRune := func(r rune) bool {
+		return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	followedByParenthesis := func(i int) bool {
+		for ; i < len(runes) && unicode.IsSpace(runes[i]); i++ {
+		}
+		return i < len(runes) && runes[i] == '('
+	}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"':
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' {
+					i++
+				} else if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+					} else {
+						break
+					}
+				}
+			}
+			i++
+		case r == '`':
+			var identifier strings.Builder
+			for i++; i < len(runes); i++ {
+				if runes[i] == '`' {
+					if i+1 < len(runes) && runes[i+1] == '`' {
+						i++
+					} else {
+						break
+					}
+				}
+				identifier.WriteRune(runes[i])
+			}
+			i++
+			identifiers = append(identifiers, identifier.String())
+		case r == '#' || (r == '-' && i+2 < len(runes) && runes[i+1] == '-' && unicode.IsSpace(runes[i+2])):
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/'); i++ {
+			}
+			i++
+		case r == '@':
+			// user or system variable
+			for i++; i < len(runes) && (runes[i] == '@' || runes[i] == '.' || isIdentifierRune(runes[i])); i++ {
+			}
+		case isIdentifierRune(r):
+			start := i
+			for ; i < len(runes) && isIdentifierRune(runes[i]); i++ {
+			}
+			if unicode.IsDigit(runes[start]) || followedByParenthesis(i) {
+				continue
+			}
+			identifiers = append(identifiers, string(runes[start:i]))
+		default:
+			i++
+		}
+	}
+	return identifiers
+}
+
+// buildPreparedValue returns the prepared value token computing the transformed column's value off
+// the referenced columns' values: a scalar subquery over a single row derived table
+func (this *ColumnTransformation) buildPreparedValue() string {
+	if this.ReferencedColumns.Len() == 0 {
+		return fmt.Sprintf("(%s)", this.Expression)
+	}
+	return fmt.Sprintf("(select %s from %s)", this.Expression, buildReferencedColumnsPreparedRow(this.ReferencedColumns))
+}
+
+// buildArgs returns the arguments of the prepared value token, off given binlog event row
+func (this *ColumnTransformation) buildArgs(row []interface{}, tableColumns *ColumnList) []interface{} {
+	return buildReferencedColumnsArgs(this.ReferencedColumns, row, tableColumns)
+}
+
+// buildReferencedColumnsPreparedRow returns a single row derived table of given columns' prepared values,
+// over which an expression referencing these columns evaluates as it would over the original table: values
+// are typed as the columns are, lest expressions evaluate differently on binlog events than on row copy.
+func buildReferencedColumnsPreparedRow(referencedColumns *ColumnList) string {
+	values := make([]string, referencedColumns.Len())
+	for i, column := range referencedColumns.Columns() {
+		var token string
+		if column.Type == EnumColumnType {
+			// binlog events hold the ordinal of ENUM values
+			token = fmt.Sprintf("ELT(?, %s)", column.EnumValues)
+		} else if column.Charset != "" {
+			token = fmt.Sprintf("convert(? using %s)", column.Charset)
+		} else if castType := column.castType(); castType != "" {
+			token = fmt.Sprintf("cast(? as %s)", castType)
+		} else {
+			token = "?"
+		}
+		values[i] = fmt.Sprintf("%s as %s", token, EscapeName(column.Name))
+	}
+	return fmt.Sprintf("(select %s) as %s", strings.Join(values, ", "), EscapeName(TransformedRowAlias))
+}
+
+// buildReferencedColumnsArgs returns the arguments of buildReferencedColumnsPreparedRow, off given binlog event row
+func buildReferencedColumnsArgs(referencedColumns *ColumnList, row []interface{}, tableColumns *ColumnList) []interface{} {
+	args := make([]interface{}, 0, referencedColumns.Len())
+	for _, column := range referencedColumns.Columns() {
+		tableOrdinal := tableColumns.Ordinals[column.Name]
+		args = append(args, column.convertArg(row[tableOrdinal], false))
+	}
+	return args
+}
+
+// ColumnTransformations is a list of column transformations, of distinct ghost table columns
+type ColumnTransformations []*ColumnTransformation
+
+// Get returns the transformation of given ghost table column, or nil if it is not transformed
+func (this ColumnTransformations) Get(columnName string) *ColumnTransformation {
+	for _, transformation := range this {
+		if strings.EqualFold(transformation.Column, columnName) {
+			return transformation
+		}
+	}
+	return nil
+}
+
+// ExtraColumns returns the transformations of ghost table columns which are not among given
+// mapped shared columns, and are therefore only populated by their transformation
+func (this ColumnTransformations) ExtraColumns(mappedSharedColumns *ColumnList) (extra ColumnTransformations) {
+	for _, transformation := range this {
+		isShared := false
+		for _, columnName := range mappedSharedColumns.Names() {
+			if strings.EqualFold(transformation.Column, columnName) {
+				isShared = true
+				break
+			}
+		}
+		if !isShared {
+			extra = append(extra, transformation)
+		}
+	}
+	return extra
+}
+
+// buildTransformedPreparedValues returns the names and prepared value tokens of the ghost table columns
+// written by DML events: the mapped shared columns, followed by the extra transformed columns
+func buildTransformedPreparedValues(mappedSharedColumns *ColumnList, columnTransformations ColumnTransformations) (names []string, values []string) {
+	for _, column := range mappedSharedColumns.Columns() {
+		names = append(names, EscapeName(column.Name))
+		if transformation := columnTransformations.Get(column.Name); transformation != nil {
+			values = append(values, transformation.buildPreparedValue())
+		} else {
+			values = append(values, buildColumnPreparedValue(&column))
+		}
+	}
+	for _, transformation := range columnTransformations.ExtraColumns(mappedSharedColumns) {
+		names = append(names, EscapeName(transformation.Column))
+		values = append(values, transformation.buildPreparedValue())
+	}
+	return names, values
+}
+
+// buildTransformedArgs returns the arguments of the values prepared by buildTransformedPreparedValues,
+// off given binlog event row
+func buildTransformedArgs(row []interface{}, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, columnTransformations ColumnTransformations) []interface{} {
+	args := make([]interface{}, 0, sharedColumns.Len())
+	mappedColumns := mappedSharedColumns.Columns()
+	for i, column := range sharedColumns.Columns() {
+		if transformation := columnTransformations.Get(mappedColumns[i].Name); transformation != nil {
+			args = append(args, transformation.buildArgs(row, tableColumns)...)
+			continue
+		}
+		tableOrdinal := tableColumns.Ordinals[column.Name]
+		args = append(args, column.convertArg(row[tableOrdinal], false))
+	}
+	for _, transformation := range columnTransformations.ExtraColumns(mappedSharedColumns) {
+		args = append(args, transformation.buildArgs(row, tableColumns)...)
+	}
+	return args
+}
+
+// buildPartialTransformedValues returns the names, prepared value tokens and arguments of the ghost table
+// columns written by a DML event whose row image only holds some columns (binlog_row_image=MINIMAL or
+// NOBLOB), as given by isPresent: columns missing from the row image are not written. A transformed
+// column is written when all of its referenced columns are present. It is skipped when none are
+// present and skipUnchanged is set, as for an UPDATE's after image, where missing columns are unchanged.
+// Any other transformed column cannot be computed, which is an error. Without skipUnchanged, as for an
+// INSERT, missing columns take the ghost table's default value, which must then be the original table's.
+func buildPartialTransformedValues(row []interface{}, isPresent []bool, tableColumns, sharedColumns, mappedSharedColumns *ColumnList, columnTransformations ColumnTransformations, skipUnchanged bool) (names []string, values []string, args []interface{}, err error) {
+	isColumnPresent := func(column Column) bool {
+		tableOrdinal := tableColumns.Ordinals[column.Name]
+		return isPresent == nil || (tableOrdinal < len(isPresent) && isPresent[tableOrdinal])
+	}
+	addTransformation := func(transformation *ColumnTransformation) error {
+		presentCount := 0
+		for _, column := range transformation.ReferencedColumns.Columns() {
+			if isColumnPresent(column) {
+				presentCount++
+			}
+		}
+		switch {
+		case presentCount == transformation.ReferencedColumns.Len() && (presentCount > 0 || !skipUnchanged):
+			names = append(names, EscapeName(transformation.Column))
+			values = append(values, transformation.buildPreparedValue())
+			args = append(args, transformation.buildArgs(row, tableColumns)...)
+		case presentCount == 0 && skipUnchanged:
+		default:
+			return fmt.Errorf("Cannot compute transformed column %s: the binlog row image lacks some of its referenced columns %s", EscapeName(transformation.Column), transformation.ReferencedColumns)
+		}
+		return nil
+	}
+	mappedColumns := mappedSharedColumns.Columns()
+	for i, column := range sharedColumns.Columns() {
+		if transformation := columnTransformations.Get(mappedColumns[i].Name); transformation != nil {
+			if err := addTransformation(transformation); err != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+		if !isColumnPresent(column) {
+			if !skipUnchanged && !column.HasSameConstantDefault(&mappedColumns[i]) {
+				// The ghost table's default would not reproduce the value the original row got
+				return nil, nil, nil, fmt.Errorf("Cannot insert a row whose binlog row image lacks column %s: its default value differs between the original and ghost tables, or is not constant", EscapeName(column.Name))
+			}
+			continue
+		}
+		names = append(names, EscapeName(mappedColumns[i].Name))
+		values = append(values, buildColumnPreparedValue(&mappedColumns[i]))
+		args = append(args, column.convertArg(row[tableColumns.Ordinals[column.Name]], false))
+	}
+	for _, transformation := range columnTransformations.ExtraColumns(mappedSharedColumns) {
+		if err := addTransformation(transformation); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return names, values, args, nil
+}
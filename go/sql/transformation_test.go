@@ -0,0 +1,278 @@
+/*
+   Copyright 2022 GitHub Inc.
+	 See https://github.com/github/gh-ost/blob/master/LICENSE
+*/
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColumnTransformation(t *testing.T) {
+	{
+		transformation, err := ParseColumnTransformation("amount = amount_cents / 100")
+		require.NoError(t, err)
+		require.Equal(t, "amount", transformation.Column)
+		require.Equal(t, "amount_cents / 100", transformation.Expression)
+	}
+	{
+		transformation, err := ParseColumnTransformation("`is_active`=if(status = 'active', 1, 0)")
+		require.NoError(t, err)
+		require.Equal(t, "is_active", transformation.Column)
+		require.Equal(t, "if(status = 'active', 1, 0)", transformation.Expression)
+	}
+	{
+		_, err := ParseColumnTransformation("lower(email)")
+		require.Error(t, err)
+	}
+	{
+		_, err := ParseColumnTransformation("email=")
+		require.Error(t, err)
+	}
+	{
+		_, err := ParseColumnTransformation(" = lower(email)")
+		require.Error(t, err)
+	}
+}
+
+func TestExpressionIdentifiers(t *testing.T) {
+	require.Equal(t, []string{"email"}, expressionIdentifiers("lower(email)"))
+	require.Equal(t, []string{"first_name", "last_name"}, expressionIdentifiers("concat(first_name, ' ', `last_name`)"))
+	require.Equal(t, []string{"amount", "AS"}, expressionIdentifiers("cast(amount / 100 AS decimal(10, 2))"))
+	require.Equal(t, []string{"status"}, expressionIdentifiers(`if(status = 'it''s "name"', "x\"y", @@session.time_zone) /* name */ # name`))
+	require.Equal(t, []string{"weird`name"}, expressionIdentifiers("`weird``name` + 1e3"))
+}
+
+func TestSetReferencedColumns(t *testing.T) {
+	columns := NewColumnList([]string{"id", "first_name", "last_name", "Email"})
+	transformation, err := ParseColumnTransformation("display_name=concat(LAST_NAME, ', ', first_name, lower(email), 'id')")
+	require.NoError(t, err)
+	transformation.SetReferencedColumns(columns)
+	require.Equal(t, []string{"first_name", "last_name", "Email"}, transformation.ReferencedColumns.Names())
+}
+
+func newTestColumnTransformations(t *testing.T, tableColumns *ColumnList, definitions ...string) ColumnTransformations {
+	transformations := ColumnTransformations{}
+	for _, definition := range definitions {
+		transformation, err := ParseColumnTransformation(definition)
+		require.NoError(t, err)
+		transformation.SetReferencedColumns(tableColumns)
+		transformations = append(transformations, transformation)
+	}
+	return transformations
+}
+
+func TestColumnTransformationsExtraColumns(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "name", "cents"})
+	transformations := newTestColumnTransformations(t, tableColumns, "NAME=upper(name)", "dollars=cents / 100")
+	require.NotNil(t, transformations.Get("name"))
+	require.Nil(t, transformations.Get("cents"))
+	extra := transformations.ExtraColumns(NewColumnList([]string{"id", "name"}))
+	require.Len(t, extra, 1)
+	require.Equal(t, "dollars", extra[0].Column)
+}
+
+func TestBuildRangeInsertQueryColumnTransformations(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "email", "cents"})
+	transformations := newTestColumnTransformations(t, tableColumns, "email=lower(email)", "dollars=cents / 100")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	query, explodedArgs, err := BuildRangeInsertQuery("mydb", "tbl", "ghost", "", []string{"id", "email"}, []string{"id", "email"}, transformations, nil, "PRIMARY", uniqueKeyColumns, nil, []string{"@v1s"}, []string{"@v1e"}, []interface{}{3}, []interface{}{103}, true, true, false)
+	require.NoError(t, err)
+	expected := `
+		insert /* gh-ost mydb.tbl */ ignore
+		into
+			mydb.ghost
+			(id, email, dollars)
+		(
+			select id, (lower(email)), (cents / 100)
+			from
+				mydb.tbl
+			force index (PRIMARY)
+			where
+				(((id > @v1s) or ((id = @v1s)))
+				and
+				((id < @v1e) or ((id = @v1e))))
+			lock in share mode
+		)`
+	require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	require.Equal(t, []interface{}{3, 3, 103, 103}, explodedArgs)
+}
+
+func TestBuildRangeCopySelectPreparedQuery(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "email", "cents"})
+	transformations := newTestColumnTransformations(t, tableColumns, "email=lower(email)", "dollars=cents / 100")
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+
+	query, explodedArgs, ghostColumns, err := BuildRangeCopySelectPreparedQuery("mydb", "tbl", "", []string{"id", "email"}, []string{"id", "email"}, transformations, nil, "PRIMARY", uniqueKeyColumns, []interface{}{3}, []interface{}{103}, true, true, false)
+	require.NoError(t, err)
+	expected := `
+		select /* gh-ost mydb.tbl */
+			id, (lower(email)), (cents / 100)
+		from
+			mydb.tbl
+		force index (PRIMARY)
+		where
+			(((id > ?) or ((id = ?))) and ((id < ?) or ((id = ?))))
+		lock in share mode`
+	require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	require.Equal(t, []interface{}{3, 3, 103, 103}, explodedArgs)
+	require.Equal(t, []string{"id", "email", "dollars"}, ghostColumns)
+
+	query, err = BuildMultiRowInsertIgnorePreparedQuery("targetdb", "ghost", ghostColumns, 2)
+	require.NoError(t, err)
+	expected = `
+		insert /* gh-ost targetdb.ghost */ ignore
+		into
+			targetdb.ghost
+			(id, email, dollars)
+		values
+			(?, ?, ?),
+			(?, ?, ?)`
+	require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	_, err = BuildMultiRowInsertIgnorePreparedQuery("targetdb", "ghost", ghostColumns, 0)
+	require.Error(t, err)
+}
+
+func TestBuildDMLQueriesColumnTransformations(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "email", "status", "cents"})
+	tableColumns.SetCharset("email", "utf8mb4")
+	tableColumns.SetColumnType("status", EnumColumnType)
+	tableColumns.SetEnumValues("status", "'active','inactive'")
+	sharedColumns := NewColumnList([]string{"id", "email", "status"})
+	transformations := newTestColumnTransformations(t, tableColumns, "email=lower(email)", "dollars=cents / 100", "migrated_at=now()")
+	args := []interface{}{3, "Gromit@Example.com", 1, 1250}
+
+	t.Run("insert", func(t *testing.T) {
+		builder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, transformations, nil)
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		expected := `
+			replace /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, email, status, dollars, migrated_at)
+				values
+					(?, (select lower(email) from (select convert(? using utf8mb4) as email) as _gh_ost_row), ?, (select cents / 100 from (select ? as cents) as _gh_ost_row), (now()))
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, []byte("Gromit@Example.com"), 1, 1250}, sharedArgs)
+	})
+
+	t.Run("full row insert", func(t *testing.T) {
+		matchColumns := NewColumnList([]string{"id", "status"})
+		builder, err := NewDMLFullRowInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, matchColumns, transformations, nil)
+		require.NoError(t, err)
+		query, sharedArgs, err := builder.BuildQuery(args)
+		require.NoError(t, err)
+		expected := `
+			insert /* gh-ost mydb.tbl */
+				into mydb.tbl
+					(id, email, status, dollars, migrated_at)
+				select
+					?, (select lower(email) from (select convert(? using utf8mb4) as email) as _gh_ost_row), ?, (select cents / 100 from (select ? as cents) as _gh_ost_row), (now())
+				from
+					dual
+				where
+					not exists (
+						select 1 from mydb.tbl where ((id <=> ?) and (status <=> ?))
+					)
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, []byte("Gromit@Example.com"), 1, 1250, 3, 1}, sharedArgs)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		uniqueKeyColumns := NewColumnList([]string{"id"})
+		statusTransformations := newTestColumnTransformations(t, tableColumns, "status=upper(status)")
+		builder, err := NewDMLUpdateQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, append(statusTransformations, transformations[1]))
+		require.NoError(t, err)
+		query, sharedArgs, uniqueKeyArgs, err := builder.BuildQuery(args, args)
+		require.NoError(t, err)
+		expected := `
+			update /* gh-ost mydb.tbl */
+				mydb.tbl
+			set
+				id=?, email=?, status=(select upper(status) from (select ELT(?, 'active','inactive') as status) as _gh_ost_row), dollars=(select cents / 100 from (select ? as cents) as _gh_ost_row)
+			where
+				((id = ?))
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, "Gromit@Example.com", 1, 1250}, sharedArgs)
+		require.Equal(t, []interface{}{3}, uniqueKeyArgs)
+	})
+}
+
+// newTestTypedColumns returns columns whose binlog event values are not of their native type
+func newTestTypedColumns() *ColumnList {
+	columns := NewColumnList([]string{"id", "amount", "created_at"})
+	id := columns.GetColumn("id")
+	id.DataType, id.DeclaredType, id.IsUnsigned = "bigint", "bigint unsigned", true
+	amount := columns.GetColumn("amount")
+	amount.DataType, amount.DeclaredType = "decimal", "decimal(10,2)"
+	createdAt := columns.GetColumn("created_at")
+	createdAt.DataType, createdAt.DeclaredType = "datetime", "datetime(3)"
+	return columns
+}
+
+func TestBuildDMLQueriesTypedColumnTransformations(t *testing.T) {
+	tableColumns := newTestTypedColumns()
+	sharedColumns := NewColumnList([]string{"id"})
+	sharedColumns.SetUnsigned("id")
+	transformations := newTestColumnTransformations(t, tableColumns, "half_id=id div 2", "doubled=amount * 2", "created_day=date(created_at + interval 1 second)")
+	builder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, transformations, nil)
+	require.NoError(t, err)
+	query, sharedArgs, err := builder.BuildQuery([]interface{}{int64(-1), "10.50", "2024-03-01 23:59:59.500"})
+	require.NoError(t, err)
+	expected := `
+		replace /* gh-ost mydb.tbl */
+			into mydb.tbl
+				(id, half_id, doubled, created_day)
+			values
+				(?, (select id div 2 from (select cast(? as unsigned) as id) as _gh_ost_row), (select amount * 2 from (select cast(? as decimal(10,2)) as amount) as _gh_ost_row), (select date(created_at + interval 1 second) from (select cast(? as datetime(3)) as created_at) as _gh_ost_row))
+	`
+	require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+	require.Equal(t, []interface{}{"18446744073709551615", "18446744073709551615", "10.50", "2024-03-01 23:59:59.500"}, sharedArgs)
+}
+
+func TestBuildPartialDMLQueriesColumnTransformations(t *testing.T) {
+	tableColumns := NewColumnList([]string{"id", "first_name", "last_name", "cents"})
+	sharedColumns := NewColumnList([]string{"id", "first_name", "last_name"})
+	uniqueKeyColumns := NewColumnList([]string{"id"})
+	transformations := newTestColumnTransformations(t, tableColumns, "full_name=concat(first_name, ' ', last_name)", "dollars=cents / 100")
+	builder, err := NewDMLUpdateQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, uniqueKeyColumns, transformations)
+	require.NoError(t, err)
+	whereArgs := []interface{}{3, nil, nil, nil}
+
+	t.Run("all referenced columns present", func(t *testing.T) {
+		valueArgs := []interface{}{3, "Wallace", "Gromit", nil}
+		query, sharedArgs, _, err := builder.BuildPartialQuery(valueArgs, whereArgs, []bool{true, true, true, false})
+		require.NoError(t, err)
+		expected := `
+			update /* gh-ost mydb.tbl */
+				mydb.tbl
+			set
+				id=?, first_name=?, last_name=?, full_name=(select concat(first_name, ' ', last_name) from (select ? as first_name, ? as last_name) as _gh_ost_row)
+			where
+				((id = ?))
+		`
+		require.Equal(t, normalizeQuery(expected), normalizeQuery(query))
+		require.Equal(t, []interface{}{3, "Wallace", "Gromit", "Wallace", "Gromit"}, sharedArgs)
+	})
+
+	t.Run("some referenced columns missing", func(t *testing.T) {
+		valueArgs := []interface{}{3, "Wallace", nil, 1250}
+		_, _, _, err := builder.BuildPartialQuery(valueArgs, whereArgs, []bool{true, true, false, true})
+		require.Error(t, err)
+	})
+
+	t.Run("insert with no referenced columns present", func(t *testing.T) {
+		insertBuilder, err := NewDMLInsertQueryBuilder("mydb", "tbl", tableColumns, sharedColumns, sharedColumns, transformations, nil)
+		require.NoError(t, err)
+		_, _, err = insertBuilder.BuildPartialQuery([]interface{}{3, "Wallace", "Gromit", nil}, []bool{true, true, true, false})
+		require.Error(t, err)
+	})
+}
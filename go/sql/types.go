@@ -11,6 +11,8 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/shopspring/decimal"
 )
 
 type ColumnType int
@@ -38,11 +40,15 @@ type CharacterSetConversion struct {
 }
 
 type Column struct {
-	Name                 string
-	IsUnsigned           bool
-	IsVirtual            bool
-	Charset              string
-	Type                 ColumnType
+	Name       string
+	IsUnsigned bool
+	IsVirtual  bool
+	IsNullable bool
+	Charset    string
+	Type       ColumnType
+	DataType   string
+	// The column's type as declared, e.g. decimal(10,2) unsigned, as listed by information_schema
+	DeclaredType         string
 	EnumValues           string
 	timezoneConversion   *TimezoneConversion
 	enumToTextConversion bool
@@ -50,6 +56,70 @@ type Column struct {
 	// https://github.com/github/gh-ost/issues/909
 	BinaryOctetLength uint
 	charsetConversion *CharacterSetConversion
+	// The column's default value, as listed by information_schema. A generated default is an
+	// expression evaluated per row, e.g. CURRENT_TIMESTAMP
+	Default            string
+	HasDefault         bool
+	IsDefaultGenerated bool
+}
+
+// HasSameConstantDefault returns true when a row inserted without this column, and a row inserted
+// without given column, get the same value: both have the same, constant default value, or none.
+func (this *Column) HasSameConstantDefault(other *Column) bool {
+	if this.IsDefaultGenerated || other.IsDefaultGenerated {
+		return false
+	}
+	return this.HasDefault == other.HasDefault && this.Default == other.Default
+}
+
+// IsInteger returns true for columns of an integer data type
+func (this *Column) IsInteger() bool {
+	switch this.DataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		return true
+	}
+	return false
+}
+
+// IsBinary returns true for columns of a binary string data type, whose values are bytes rather than text
+func (this *Column) IsBinary() bool {
+	switch this.DataType {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return true
+	}
+	return false
+}
+
+// castType returns the type to CAST a binlog event value of this column to, so that an expression evaluates
+// on it as it would on the column itself. Binlog values of unsigned BIGINT, DECIMAL and temporal columns are
+// passed as strings, and JSON values as text. Other values are passed as their native type, or are text
+// converted to the column's charset, and need no CAST: it then returns an empty string.
+func (this *Column) castType() string {
+	// precision and scale, e.g. "(10,2)", if any
+	length := ""
+	if start := strings.Index(this.DeclaredType, "("); start >= 0 {
+		if end := strings.Index(this.DeclaredType[start:], ")"); end >= 0 {
+			length = this.DeclaredType[start : start+end+1]
+		}
+	}
+	switch this.DataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		if this.IsUnsigned {
+			return "unsigned"
+		}
+		return "signed"
+	case "decimal":
+		return "decimal" + length
+	case "date":
+		return "date"
+	case "datetime", "timestamp":
+		return "datetime" + length
+	case "time":
+		return "time" + length
+	case "json":
+		return "json"
+	}
+	return ""
 }
 
 func (this *Column) convertArg(arg interface{}, isUniqueKeyColumn bool) interface{} {
@@ -106,6 +176,118 @@ func (this *Column) convertArg(arg interface{}, isUniqueKeyColumn bool) interfac
 	return arg
 }
 
+// enumValue returns the value of given 1-based ENUM index, as listed in EnumValues: quoted, with quotes doubled
+func (this *Column) enumValue(index int64) (value string, ok bool) {
+	var values []string
+	var current strings.Builder
+	inQuote := false
+	for i := 0; i < len(this.EnumValues); i++ {
+		c := this.EnumValues[i]
+		switch {
+		case c == '\'' && inQuote && i+1 < len(this.EnumValues) && this.EnumValues[i+1] == '\'':
+			current.WriteByte(c)
+			i++
+		case c == '\'':
+			if inQuote {
+				values = append(values, current.String())
+				current.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			current.WriteByte(c)
+		}
+	}
+	if index < 1 || index > int64(len(values)) {
+		return "", false
+	}
+	return values[index-1], true
+}
+
+// decimalScale returns the number of decimal places of a DECIMAL column, as declared
+func (this *Column) decimalScale() (scale int32, ok bool) {
+	if this.DataType != "decimal" {
+		return 0, false
+	}
+	start := strings.Index(this.DeclaredType, ",")
+	end := strings.Index(this.DeclaredType, ")")
+	if start < 0 || end < start {
+		// decimal(M) has no decimal places
+		return 0, strings.Contains(this.DeclaredType, "(")
+	}
+	places, err := strconv.ParseInt(this.DeclaredType[start+1:end], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(places), true
+}
+
+// FormatValue returns the text of a binlog event's value of this column, the way a query returns it,
+// or nil for NULL. ENUM values, which binlog events hold as indexes, are returned by name. DECIMAL
+// values are returned with the column's decimal places, trailing zeros included.
+func (this *Column) FormatValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if this.Type == EnumColumnType {
+		if index, ok := value.(int64); ok {
+			if enumValue, ok := this.enumValue(index); ok {
+				return enumValue
+			}
+		}
+	}
+	switch value := this.convertArg(value, true).(type) {
+	case []byte:
+		return string(value)
+	case string:
+		return value
+	case decimal.Decimal:
+		if scale, ok := this.decimalScale(); ok {
+			return value.StringFixed(scale)
+		}
+		return value.String()
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// JSONValue returns a binlog event's value of this column as a JSON encodable value: numbers remain
+// numbers, unsigned ones included, ENUM values are returned by name, text is decoded from the column's
+// character set, and binary values are returned as bytes, which encode as base64.
+func (this *Column) JSONValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if this.Type == EnumColumnType {
+		if index, ok := value.(int64); ok {
+			if enumValue, ok := this.enumValue(index); ok {
+				return enumValue
+			}
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		if this.Type == BinaryColumnType {
+			return []byte(value)
+		}
+		if encoding, ok := charsetEncodingMap[this.Charset]; ok {
+			if decoded, err := encoding.NewDecoder().String(value); err == nil {
+				return decoded
+			}
+		}
+		return value
+	case []byte:
+		if this.Type == BinaryColumnType {
+			return value
+		}
+		return this.JSONValue(string(value))
+	case int64:
+		if this.IsUnsigned {
+			return uint64(value)
+		}
+	}
+	return this.convertArg(value, false)
+}
+
 func NewColumns(names []string) []Column {
 	result := make([]Column, len(names))
 	for i := range names {
@@ -269,6 +451,12 @@ type UniqueKey struct {
 	Columns         ColumnList
 	HasNullable     bool
 	IsAutoIncrement bool
+	// IsGhostOnly is set when the key only exists on the ghost table. Its columns exist on the
+	// original table, but are not indexed there, and their uniqueness is assumed, not enforced.
+	IsGhostOnly bool
+	// IsFullRow is set when there is no key at all. Columns are then the non-nullable columns
+	// rows are iterated by, and rows are matched by the values of all of their columns.
+	IsFullRow bool
 }
 
 // IsPrimary checks if this unique key is primary
@@ -276,6 +464,11 @@ func (this *UniqueKey) IsPrimary() bool {
 	return this.Name == "PRIMARY"
 }
 
+// HasOriginalIndex checks if the original table has an index on this key, which row copy may force
+func (this *UniqueKey) HasOriginalIndex() bool {
+	return !this.IsGhostOnly && !this.IsFullRow
+}
+
 func (this *UniqueKey) Len() int {
 	return this.Columns.Len()
 }
@@ -285,6 +478,12 @@ func (this *UniqueKey) String() string {
 	if this.IsAutoIncrement {
 		description = fmt.Sprintf("%s (auto_increment)", description)
 	}
+	if this.IsGhostOnly {
+		description = fmt.Sprintf("%s (ghost table only)", description)
+	}
+	if this.IsFullRow {
+		description = "full row"
+	}
 	return fmt.Sprintf("%s: %s; has nullable: %+v", description, this.Columns.Names(), this.HasNullable)
 }
 
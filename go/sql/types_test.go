@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/openark/golib/log"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,3 +41,81 @@ func TestGetColumn(t *testing.T) {
 		require.Nil(t, column)
 	}
 }
+
+func TestColumnFormatValue(t *testing.T) {
+	columnList := NewColumnList([]string{"id", "name", "status", "amount"})
+	columnList.SetCharset("name", "utf8mb4")
+	columnList.SetColumnType("status", EnumColumnType)
+	columnList.SetEnumValues("status", `'active','it''s, done'`)
+	columnList.SetUnsigned("amount")
+
+	require.Nil(t, columnList.GetColumn("name").FormatValue(nil))
+	require.Equal(t, "17", columnList.GetColumn("id").FormatValue(int32(17)))
+	require.Equal(t, "gromit", columnList.GetColumn("name").FormatValue("gromit"))
+	require.Equal(t, "active", columnList.GetColumn("status").FormatValue(int64(1)))
+	require.Equal(t, "it's, done", columnList.GetColumn("status").FormatValue(int64(2)))
+	require.Equal(t, "3", columnList.GetColumn("status").FormatValue(int64(3)))
+	require.Equal(t, "4294967295", columnList.GetColumn("amount").FormatValue(int32(-1)))
+}
+
+func TestColumnFormatValueDecimal(t *testing.T) {
+	column := Column{Name: "amount", DataType: "decimal", DeclaredType: "decimal(10,2)"}
+	require.Equal(t, "1.50", column.FormatValue(decimal.RequireFromString("1.5")))
+	require.Equal(t, "-0.50", column.FormatValue(decimal.RequireFromString("-0.5")))
+	require.Equal(t, "0.00", column.FormatValue(decimal.RequireFromString("0")))
+
+	column.DeclaredType = "decimal(10,0) unsigned"
+	require.Equal(t, "15", column.FormatValue(decimal.RequireFromString("15")))
+}
+
+func TestColumnJSONValue(t *testing.T) {
+	columnList := NewColumnList([]string{"id", "name", "status", "amount", "legacy", "hash"})
+	columnList.SetCharset("name", "utf8mb4")
+	columnList.SetColumnType("status", EnumColumnType)
+	columnList.SetEnumValues("status", `'active','archived'`)
+	columnList.SetUnsigned("amount")
+	columnList.SetCharset("legacy", "latin1")
+	columnList.SetColumnType("hash", BinaryColumnType)
+
+	require.Nil(t, columnList.GetColumn("name").JSONValue(nil))
+	require.Equal(t, int32(17), columnList.GetColumn("id").JSONValue(int32(17)))
+	require.Equal(t, "gromit", columnList.GetColumn("name").JSONValue("gromit"))
+	require.Equal(t, "gromit", columnList.GetColumn("name").JSONValue([]byte("gromit")))
+	require.Equal(t, "archived", columnList.GetColumn("status").JSONValue(int64(2)))
+	require.Equal(t, uint32(4294967295), columnList.GetColumn("amount").JSONValue(int32(-1)))
+	require.Equal(t, uint64(18446744073709551615), columnList.GetColumn("amount").JSONValue(int64(-1)))
+	require.Equal(t, "café", columnList.GetColumn("legacy").JSONValue("caf\xe9"))
+	require.Equal(t, []byte{0, 1, 2}, columnList.GetColumn("hash").JSONValue("\x00\x01\x02"))
+}
+
+func TestColumnIsBinary(t *testing.T) {
+	for dataType, isBinary := range map[string]bool{"binary": true, "varbinary": true, "blob": true, "longblob": true, "varchar": false, "text": false, "json": false, "int": false} {
+		column := Column{Name: "c", DataType: dataType}
+		require.Equal(t, isBinary, column.IsBinary(), dataType)
+	}
+}
+
+func TestColumnCastType(t *testing.T) {
+	testCases := []struct {
+		dataType     string
+		declaredType string
+		isUnsigned   bool
+		expected     string
+	}{
+		{"bigint", "bigint unsigned", true, "unsigned"},
+		{"int", "int", false, "signed"},
+		{"decimal", "decimal(10,2) unsigned", true, "decimal(10,2)"},
+		{"datetime", "datetime(6)", false, "datetime(6)"},
+		{"timestamp", "timestamp", false, "datetime"},
+		{"date", "date", false, "date"},
+		{"time", "time(3)", false, "time(3)"},
+		{"json", "json", false, "json"},
+		{"double", "double", false, ""},
+		{"varbinary", "varbinary(16)", false, ""},
+		{"", "", false, ""},
+	}
+	for _, tc := range testCases {
+		column := Column{Name: "c", DataType: tc.dataType, DeclaredType: tc.declaredType, IsUnsigned: tc.isUnsigned}
+		require.Equal(t, tc.expected, column.castType(), tc.declaredType)
+	}
+}
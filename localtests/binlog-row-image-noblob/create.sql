@@ -0,0 +1,31 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  color varchar(32) not null default 'red',
+  t text,
+  updated tinyint not null default 0,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 1, 'blue', repeat('a', 1000), 0);
+insert into gh_ost_test values (null, 2, 'green', repeat('b', 1000), 0);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  set session binlog_row_image = 'NOBLOB';
+  insert into gh_ost_test (i, t) values (11, repeat('c', 1000));
+  insert into gh_ost_test (i, color, t) values (13, 'yellow', repeat('d', 1000));
+  insert into gh_ost_test (i) values (17);
+  update gh_ost_test set updated = 1 where i = 11 and updated = 0;
+  update gh_ost_test set t = concat(t, 'x'), updated = 2 where i = 13 and updated = 0;
+  delete from gh_ost_test where i = 17;
+end ;;
@@ -0,0 +1,27 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  t varchar(128) charset utf8mb4,
+  primary key(id)
+) auto_increment=1;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  set session binlog_transaction_compression = ON;
+  start transaction;
+  insert into gh_ost_test values (null, 11, 'compressed');
+  insert into gh_ost_test values (null, 13, 'compressed');
+  insert into gh_ost_test values (null, 17, 'compressed');
+  update gh_ost_test set t = 'updated' where i = 13;
+  delete from gh_ost_test where i = 17;
+  commit;
+end ;;
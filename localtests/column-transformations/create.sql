@@ -0,0 +1,27 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  email varchar(128) charset utf8mb4 not null,
+  status enum('active', 'inactive') not null default 'active',
+  cents int not null,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 'Gromit@Example.com', 'active', 1250);
+insert into gh_ost_test values (null, 'wallace@example.com', 'inactive', 7);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 'Shaun@Example.COM', 'active', 99);
+  insert into gh_ost_test values (null, 'preston@example.com', 'inactive', 123456);
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set email='Preston@Example.com', cents=cents+1, status='active' where id = @last_insert_id;
+end ;;
@@ -0,0 +1,36 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  ts timestamp,
+  primary key(id)
+) auto_increment=1
+partition by range (id) (
+  partition p0 values less than (10),
+  partition p1 values less than (20),
+  partition p2 values less than (1000),
+  partition p3 values less than maxvalue
+);
+
+insert into gh_ost_test values (null, 3, now());
+insert into gh_ost_test values (null, 5, now());
+insert into gh_ost_test select null, i, now() from gh_ost_test;
+insert into gh_ost_test select null, i, now() from gh_ost_test;
+insert into gh_ost_test select null, i, now() from gh_ost_test;
+insert into gh_ost_test select null, i, now() from gh_ost_test;
+insert into gh_ost_test select null, i, now() from gh_ost_test;
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 11, now());
+  update gh_ost_test set i=i+1 where id < 25;
+  delete from gh_ost_test where id = 40;
+end ;;
@@ -0,0 +1,27 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  a int not null,
+  b int not null,
+  sum_ab int as (a + b) persistent,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test (id, a, b) values (null, 2, 3);
+insert into gh_ost_test (id, a, b) values (null, 7, 11);
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test (id, a, b) values (null, 13, 17);
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set b=b+1 where id = @last_insert_id;
+  delete from gh_ost_test where id = @last_insert_id - 1;
+end ;;
@@ -0,0 +1,26 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  i int not null,
+  color varchar(32),
+  ts timestamp default current_timestamp,
+  key i_idx(i)
+) auto_increment=1;
+
+insert into gh_ost_test (i, color) values (1, 'red'), (2, 'green'), (3, null), (4, 'blue');
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test (i, color) select max(i) + 1, 'orange' from gh_ost_test;
+  insert into gh_ost_test (i, color) select max(i) + 1, null from gh_ost_test;
+  update gh_ost_test set color = concat(ifnull(color, ''), '-') where i = 2;
+  update gh_ost_test set color = 'yellow' where color is null order by i limit 1;
+  delete from gh_ost_test where i = (select min(i) from (select i from gh_ost_test where color = 'orange') as t);
+end ;;
@@ -84,7 +84,7 @@ echo_dot() {
 
 start_replication() {
   mysql_version="$(gh-ost-test-mysql-replica -e  "select @@version")"
-  if [[ $mysql_version =~ "8.4" ]]; then
+  if [[ ! $mysql_version =~ "MariaDB" && $mysql_version =~ "8.4" ]]; then
     seconds_behind_source="Seconds_Behind_Source"
     replica_terminology="replica"
   else
@@ -130,6 +130,15 @@ test_single() {
     fi
   fi
 
+  if [ -f $tests_path/$test_name/only_versions ] ; then
+    only_versions=$(cat $tests_path/$test_name/only_versions)
+    mysql_version=$(gh-ost-test-mysql-master -s -s -e "select @@version")
+    if ! echo "$mysql_version" | egrep -i -q "${only_versions}" ; then
+      echo -n "Skipping: $test_name"
+      return 0
+    fi
+  fi
+
   echo -n "Testing: $test_name"
 
   echo_dot
@@ -158,6 +167,7 @@ test_single() {
   orig_columns="*"
   ghost_columns="*"
   order_by=""
+  orig_where=""
   if [ -f $tests_path/$test_name/orig_columns ] ; then
     orig_columns=$(cat $tests_path/$test_name/orig_columns)
   fi
@@ -167,6 +177,9 @@ test_single() {
   if [ -f $tests_path/$test_name/order_by ] ; then
     order_by="order by $(cat $tests_path/$test_name/order_by)"
   fi
+  if [ -f $tests_path/$test_name/orig_where ] ; then
+    orig_where="where $(cat $tests_path/$test_name/orig_where)"
+  fi
   # graceful sleep for replica to catch up
   echo_dot
   sleep 1
@@ -252,13 +265,13 @@ test_single() {
   fi
 
   echo_dot
-  gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${orig_columns} from gh_ost_test ${order_by}" -ss > $orig_content_output_file
+  gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${orig_columns} from gh_ost_test ${orig_where} ${order_by}" -ss > $orig_content_output_file
   gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${ghost_columns} from _gh_ost_test_gho ${order_by}" -ss > $ghost_content_output_file
   orig_checksum=$(cat $orig_content_output_file | md5sum)
   ghost_checksum=$(cat $ghost_content_output_file | md5sum)
 
   if [ "$orig_checksum" != "$ghost_checksum" ] ; then
-    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${orig_columns} from gh_ost_test" -ss > $orig_content_output_file
+    gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${orig_columns} from gh_ost_test ${orig_where}" -ss > $orig_content_output_file
     gh-ost-test-mysql-replica --default-character-set=utf8mb4 test -e "select ${ghost_columns} from _gh_ost_test_gho" -ss > $ghost_content_output_file
     echo "ERROR $test_name: checksum mismatch"
     echo "---"
@@ -0,0 +1,30 @@
+drop table if exists gh_ost_test;
+drop table if exists gh_ost_test_notes;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  notes varchar(128) charset utf8mb4 null,
+  ts timestamp,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 11, 'fragile', now());
+insert into gh_ost_test values (null, 13, null, now());
+insert into gh_ost_test values (null, 17, 'sturdy', now());
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 23, 'fragile', now());
+  set @last_insert_id := last_insert_id();
+  update gh_ost_test set notes='handle with care' where id = @last_insert_id;
+  update gh_ost_test set i=i+1 where id = @last_insert_id - 1;
+  delete from gh_ost_test where id = @last_insert_id - 2;
+end ;;
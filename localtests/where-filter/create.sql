@@ -0,0 +1,33 @@
+drop table if exists gh_ost_test;
+create table gh_ost_test (
+  id int auto_increment,
+  i int not null,
+  status varchar(16) not null default 'active',
+  ts timestamp,
+  primary key(id)
+) auto_increment=1;
+
+insert into gh_ost_test values (null, 11, 'active', now());
+insert into gh_ost_test values (null, 13, 'archived', now());
+insert into gh_ost_test values (null, 17, 'active', now());
+insert into gh_ost_test values (null, 19, 'archived', now());
+
+drop event if exists gh_ost_test;
+delimiter ;;
+create event gh_ost_test
+  on schedule every 1 second
+  starts current_timestamp
+  ends current_timestamp + interval 60 second
+  on completion not preserve
+  enable
+  do
+begin
+  insert into gh_ost_test values (null, 23, 'active', now());
+  set @active_id := last_insert_id();
+  insert into gh_ost_test values (null, 29, 'archived', now());
+  set @archived_id := last_insert_id();
+  update gh_ost_test set i=i+1 where id = @active_id;
+  update gh_ost_test set status='active' where id = @archived_id;
+  update gh_ost_test set status='archived' where id = @active_id - 2;
+  delete from gh_ost_test where id = @active_id - 4;
+end ;;